@@ -0,0 +1,114 @@
+package main
+
+import (
+    "encoding/json"
+    "flag"
+    "fmt"
+    "io"
+    "log"
+    "math"
+    "os"
+    "sort"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/steamdt"
+)
+
+// diffReport lists the differences between two dumps. Symbols are compared by
+// market hash name; prices per quote source (platform and side).
+type diffReport struct {
+    ThresholdPct float64       `json:"threshold_pct"`
+    Added        []string      `json:"added"`
+    Removed      []string      `json:"removed"`
+    Changed      []priceChange `json:"changed"`
+}
+
+type priceChange struct {
+    Symbol    string  `json:"symbol"`
+    Source    string  `json:"source"`
+    Old       string  `json:"old"`
+    New       string  `json:"new"`
+    ChangePct float64 `json:"change_pct"`
+}
+
+func main() {
+    var threshold float64
+    var asJSON bool
+    flag.Float64Var(&threshold, "threshold", 5, "report price changes of at least this many percent")
+    flag.BoolVar(&asJSON, "json", false, "print the full diff as JSON instead of a summary")
+    flag.Usage = func() {
+        fmt.Fprintf(flag.CommandLine.Output(), "usage: dumpdiff [flags] old.json new.json\n")
+        flag.PrintDefaults()
+    }
+    flag.Parse()
+    if flag.NArg() != 2 {
+        flag.Usage()
+        os.Exit(2)
+    }
+    if threshold < 0 { log.Fatal("threshold must be >= 0") }
+
+    oldDump, err := loadDump(flag.Arg(0))
+    if err != nil { log.Fatalf("%s: %v", flag.Arg(0), err) }
+    newDump, err := loadDump(flag.Arg(1))
+    if err != nil { log.Fatalf("%s: %v", flag.Arg(1), err) }
+
+    rep := diffDumps(oldDump, newDump, threshold)
+    if asJSON {
+        enc := json.NewEncoder(os.Stdout)
+        enc.SetEscapeHTML(false)
+        enc.SetIndent("", "  ")
+        if err := enc.Encode(rep); err != nil { log.Fatalf("encode: %v", err) }
+        return
+    }
+    writeSummary(os.Stdout, rep)
+}
+
+// loadDump parses a steamdt_dump output file with the SteamDT adapter, so the
+// prices compared are the ones the service would serve.
+func loadDump(path string) (map[string][]provider.Quote, error) {
+    f, err := os.Open(path)
+    if err != nil { return nil, err }
+    defer f.Close()
+    return steamdt.New(steamdt.Config{IncludeBids: true}, nil).Decode(f)
+}
+
+// diffDumps compares two decoded dumps. A price change is reported when a
+// source quoted in both moved by at least thresholdPct percent of the old price.
+func diffDumps(oldDump, newDump map[string][]provider.Quote, thresholdPct float64) diffReport {
+    rep := diffReport{ThresholdPct: thresholdPct, Added: []string{}, Removed: []string{}, Changed: []priceChange{}}
+    for sym := range newDump {
+        if _, ok := oldDump[sym]; !ok { rep.Added = append(rep.Added, sym) }
+    }
+    for sym, oldQs := range oldDump {
+        newQs, ok := newDump[sym]
+        if !ok {
+            rep.Removed = append(rep.Removed, sym)
+            continue
+        }
+        newBySource := make(map[string]provider.Quote, len(newQs))
+        for _, q := range newQs { newBySource[q.Source] = q }
+        for _, q := range oldQs {
+            nq, ok := newBySource[q.Source]
+            if !ok { continue }
+            o, n := q.PriceAmount(), nq.PriceAmount()
+            if !o.Valid() || !n.Valid() || o.Sign() <= 0 || n.Cmp(o) == 0 { continue }
+            pct, _ := n.Sub(o).PercentOf(o).Rat().Float64()
+            if math.Abs(pct) < thresholdPct { continue }
+            rep.Changed = append(rep.Changed, priceChange{Symbol: sym, Source: q.Source, Old: q.Price, New: nq.Price, ChangePct: math.Round(pct*100) / 100})
+        }
+    }
+    sort.Strings(rep.Added)
+    sort.Strings(rep.Removed)
+    sort.Slice(rep.Changed, func(i, j int) bool {
+        if rep.Changed[i].Symbol != rep.Changed[j].Symbol { return rep.Changed[i].Symbol < rep.Changed[j].Symbol }
+        return rep.Changed[i].Source < rep.Changed[j].Source
+    })
+    return rep
+}
+
+func writeSummary(w io.Writer, rep diffReport) {
+    fmt.Fprintf(w, "added: %d, removed: %d, changed >= %g%%: %d\n", len(rep.Added), len(rep.Removed), rep.ThresholdPct, len(rep.Changed))
+    for _, s := range rep.Added { fmt.Fprintf(w, "+ %s\n", s) }
+    for _, s := range rep.Removed { fmt.Fprintf(w, "- %s\n", s) }
+    for _, c := range rep.Changed { fmt.Fprintf(w, "~ %s [%s] %s -> %s (%+.2f%%)\n", c.Symbol, c.Source, c.Old, c.New, c.ChangePct) }
+}
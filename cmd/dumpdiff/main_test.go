@@ -0,0 +1,49 @@
+package main
+
+import (
+    "bytes"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+)
+
+func writeDump(t *testing.T, name, data string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), name)
+    doc := `{"success":true,"data":[` + data + `],"errorCode":0,"errorMsg":null,"errorData":null,"errorCodeStr":null}`
+    if err := os.WriteFile(path, []byte(doc), 0o644); err != nil { t.Fatalf("write: %v", err) }
+    return path
+}
+
+func TestDiffDumps_Categories(t *testing.T) {
+    oldPath := writeDump(t, "old.json", `
+        {"marketHashName":"Kept","dataList":[{"platform":"BUFF","sellPrice":10,"updateTime":1735787045},{"platform":"YOUPIN","sellPrice":"20","updateTime":1735787045}]},
+        {"marketHashName":"Small Move","dataList":[{"platform":"BUFF","sellPrice":100,"updateTime":1735787045}]},
+        {"marketHashName":"Gone","dataList":[{"platform":"BUFF","sellPrice":1,"updateTime":1735787045}]}`)
+    newPath := writeDump(t, "new.json", `
+        {"marketHashName":"Kept","dataList":[{"platform":"BUFF","sellPrice":"11,5","updateTime":1735787045},{"platform":"YOUPIN","sellPrice":20,"updateTime":1735787045}]},
+        {"marketHashName":"Small Move","dataList":[{"platform":"BUFF","sellPrice":101,"updateTime":1735787045}]},
+        {"marketHashName":"New Item","dataList":[]}`)
+
+    oldDump, err := loadDump(oldPath)
+    if err != nil { t.Fatalf("load old: %v", err) }
+    newDump, err := loadDump(newPath)
+    if err != nil { t.Fatalf("load new: %v", err) }
+
+    rep := diffDumps(oldDump, newDump, 5)
+    if len(rep.Added) != 1 || rep.Added[0] != "New Item" { t.Fatalf("added: %v", rep.Added) }
+    if len(rep.Removed) != 1 || rep.Removed[0] != "Gone" { t.Fatalf("removed: %v", rep.Removed) }
+    if len(rep.Changed) != 1 { t.Fatalf("want 1 change above 5%%, got %+v", rep.Changed) }
+    c := rep.Changed[0]
+    if c.Symbol != "Kept" || c.Source != "SteamDT:BUFF:sell" || c.Old != "10" || c.New != "11.5" || c.ChangePct != 15 { t.Fatalf("unexpected change: %+v", c) }
+
+    var buf bytes.Buffer
+    writeSummary(&buf, rep)
+    out := buf.String()
+    for _, want := range []string{"added: 1, removed: 1, changed >= 5%: 1", "+ New Item", "- Gone", "~ Kept [SteamDT:BUFF:sell] 10 -> 11.5 (+15.00%)"} {
+        if !strings.Contains(out, want) { t.Fatalf("summary missing %q:\n%s", want, out) }
+    }
+
+    if rep := diffDumps(oldDump, newDump, 0.5); len(rep.Changed) != 2 { t.Fatalf("want small move at 0.5%%, got %+v", rep.Changed) }
+}
@@ -5,17 +5,21 @@ import (
     "encoding/json"
     "flag"
     "fmt"
+    "io"
     "log"
     "net/http"
     "os"
     "strings"
+    "text/tabwriter"
     "time"
 
+    "priceprovider/internal/aggregate"
     "priceprovider/internal/config"
     "priceprovider/internal/httpx"
     "priceprovider/internal/provider"
     "priceprovider/internal/provider/ratelimit"
     "priceprovider/internal/provider/cache"
+    "priceprovider/internal/provider/multi"
     pricempirepkg "priceprovider/internal/provider/pricempire"
     "priceprovider/internal/provider/pricempireadapter"
     "priceprovider/internal/provider/steamdt"
@@ -30,7 +34,11 @@ func main() {
     var peSourcesCSV string
     var peAppID int
     var timeout int
+    var providerTimeout int
     var configPath string
+    var aggregateOut bool
+    var side string
+    var includeSides bool
 
     flag.StringVar(&symbolsCSV, "symbols", getenv("SYMBOLS", "AK-47 | Redline (Field-Tested)"), "comma-separated marketHashNames")
     flag.BoolVar(&includeBids, "include-bids", getenvBool("INCLUDE_BIDS", true), "include Pricempire bids where available (N/A) and SteamDT bids")
@@ -39,8 +47,18 @@ func main() {
     flag.StringVar(&peSourcesCSV, "pe-sources", getenv("PRICEMPIRE_SOURCES", "buff"), "Pricempire sources CSV (e.g., buff,steam,skinport)")
     flag.IntVar(&peAppID, "pe-appid", getenvInt("PRICEMPIRE_APP_ID", 730), "Pricempire app id")
     flag.IntVar(&timeout, "timeout", getenvInt("REQUEST_TIMEOUT_SEC", 15), "request timeout seconds")
+    flag.IntVar(&providerTimeout, "provider-timeout", 0, "per-provider timeout seconds within -timeout (0 = none)")
     flag.StringVar(&configPath, "config", getenv("CONFIG_FILE", ""), "path to config.json (optional)")
+    flag.BoolVar(&aggregateOut, "aggregate", false, "print aggregate.LatestByMarket rows instead of raw quotes")
+    flag.StringVar(&side, "side", "", "with -aggregate: keep only sell|bid rows (all collapses sides)")
+    flag.BoolVar(&includeSides, "include-sides", true, "with -aggregate: keep sides separate when grouping")
     flag.Parse()
+    side = strings.ToLower(strings.TrimSpace(side))
+    switch side {
+    case "", "all", "sell", "bid":
+    default:
+        log.Fatalf("invalid -side %q (all|sell|bid)", side)
+    }
 
     // Load config (optional) and merge with flags/env
     cfg, err := config.Load(configPath)
@@ -53,60 +71,89 @@ func main() {
     if peAppID != 0 { cfg.Pricempire.AppID = peAppID }
     if timeout != 0 { cfg.Server.RequestTimeoutSec = timeout }
 
-    httpClient := httpx.New(time.Duration(cfg.Server.RequestTimeoutSec) * time.Second)
+    // clientFor builds an upstream client, optionally behind the provider's proxy.
+    clientFor := func(name, proxy string) *httpx.Client {
+        c := httpx.New(time.Duration(cfg.Server.RequestTimeoutSec) * time.Second)
+        if strings.TrimSpace(proxy) != "" {
+            u, err := httpx.ParseProxyURL(proxy)
+            if err != nil { log.Fatalf("config: %s: %v", name, err) }
+            if err := c.SetProxy(u); err != nil { log.Fatalf("%s: %v", name, err) }
+        }
+        if dir := strings.TrimSpace(cfg.Debug.FixturesDir); dir != "" {
+            if err := c.UseFixtures(dir); err != nil { log.Fatalf("debug: %v", err) }
+        }
+        if cfg.Debug.DumpHTTP { c.EnableDump(cfg.Debug.DumpBodyBytes) }
+        return c
+    }
 
     providers := make([]provider.Provider, 0, 2)
     if cfg.SteamDT.Enabled && cfg.SteamDT.APIKey != "" {
         st := steamdt.New(steamdt.Config{
             Name:        "SteamDT",
             URL:         cfg.SteamDT.Endpoint,
+            URLs:        cfg.SteamDT.Endpoints,
             Method:      http.MethodPost,
             Headers:     map[string]string{"Authorization": "Bearer " + cfg.SteamDT.APIKey},
             Currency:    cfg.SteamDT.Currency,
             IncludeBids: cfg.SteamDT.IncludeBids,
-        }, httpClient)
+            StrictErrors: cfg.SteamDT.StrictErrors,
+            IncludePlatformItemID: cfg.SteamDT.IncludePlatformItemID,
+        }, clientFor("steamdt", cfg.SteamDT.ProxyURL))
         var p provider.Provider = st
         if cfg.SteamDT.MaxRequestsPerMinute > 0 {
             rate := float64(cfg.SteamDT.MaxRequestsPerMinute) / 60.0
             burst := cfg.SteamDT.Burst
             if burst <= 0 { burst = 1 }
-            p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
+            tb := ratelimit.NewTokenBucket(rate, burst)
+            if cfg.SteamDT.StartEmpty { tb.WithTokens(0) }
+            p = &ratelimit.TokenBucketProvider{P: p, TB: tb}
         } else if cfg.SteamDT.MinRequestIntervalSec > 0 {
             interval := time.Duration(cfg.SteamDT.MinRequestIntervalSec) * time.Second
             p = &ratelimit.MinInterval{P: p, Interval: interval}
         }
         if cfg.SteamDT.CacheTTLSeconds > 0 {
-            p = &cache.Provider{P: p, TTL: time.Duration(cfg.SteamDT.CacheTTLSeconds) * time.Second, MaxItems: cfg.SteamDT.CacheMaxItems}
+            p = &cache.Provider{P: p, TTL: time.Duration(cfg.SteamDT.CacheTTLSeconds) * time.Second, MaxItems: cfg.SteamDT.CacheMaxItems, MaxTTL: time.Duration(cfg.SteamDT.CacheMaxTTLSeconds) * time.Second}
         }
         providers = append(providers, p)
     }
     if cfg.Pricempire.Enabled && cfg.Pricempire.APIKey != "" {
-        peClient, err := pricempirepkg.NewPricempireAPIClient(
-            cfg.Pricempire.APIKey,
-            pricempirepkg.WithHTTPClient(httpClient.HTTP),
+        peOpts := []pricempirepkg.PricempireAPIClientOption{
+            pricempirepkg.WithHTTPClient(clientFor("pricempire", cfg.Pricempire.ProxyURL).HTTP),
             pricempirepkg.WithHeader(http.Header{
                 "User-Agent": []string{"price-provider/1.0"},
             }),
-        )
+            pricempirepkg.WithSkipMalformed(cfg.Pricempire.SkipMalformed),
+        }
+        if u := strings.TrimRight(strings.TrimSpace(cfg.Pricempire.BaseURL), "/"); u != "" {
+            peOpts = append(peOpts, pricempirepkg.WithBaseURL(u))
+        }
+        peClient, err := pricempirepkg.NewPricempireAPIClient(cfg.Pricempire.APIKey, peOpts...)
         if err != nil { log.Fatalf("pricempire client: %v", err) }
         pe := pricempireadapter.New(pricempireadapter.Config{
             Name:     "Pricempire",
             AppID:    cfg.Pricempire.AppID,
             Currency: cfg.Pricempire.Currency,
             Sources:  cfg.Pricempire.Sources,
+            EmitAvg30: cfg.Pricempire.EmitAvg30,
+            CaseInsensitive: cfg.Pricempire.CaseInsensitive,
+            SourceCurrency: cfg.Pricempire.SourceCurrency,
+            APIVersion: strings.ToLower(strings.TrimSpace(cfg.Pricempire.APIVersion)),
+            PerSourceRequests: cfg.Pricempire.PerSourceRequests,
         }, peClient)
         var p provider.Provider = pe
         if cfg.Pricempire.MaxRequestsPerMinute > 0 {
             rate := float64(cfg.Pricempire.MaxRequestsPerMinute) / 60.0
             burst := cfg.Pricempire.Burst
             if burst <= 0 { burst = 1 }
-            p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
+            tb := ratelimit.NewTokenBucket(rate, burst)
+            if cfg.Pricempire.StartEmpty { tb.WithTokens(0) }
+            p = &ratelimit.TokenBucketProvider{P: p, TB: tb}
         } else if cfg.Pricempire.MinRequestIntervalSec > 0 {
             interval := time.Duration(cfg.Pricempire.MinRequestIntervalSec) * time.Second
             p = &ratelimit.MinInterval{P: p, Interval: interval}
         }
         if cfg.Pricempire.CacheTTLSeconds > 0 {
-            p = &cache.Provider{P: p, TTL: time.Duration(cfg.Pricempire.CacheTTLSeconds) * time.Second, MaxItems: cfg.Pricempire.CacheMaxItems}
+            p = &cache.Provider{P: p, TTL: time.Duration(cfg.Pricempire.CacheTTLSeconds) * time.Second, MaxItems: cfg.Pricempire.CacheMaxItems, MaxTTL: time.Duration(cfg.Pricempire.CacheMaxTTLSeconds) * time.Second}
         }
         providers = append(providers, p)
     }
@@ -119,19 +166,28 @@ func main() {
             AppID:               cfg.Skinstable.AppID,
             Sites:               cfg.Skinstable.Sites,
             ItemsCacheTTLSeconds: cfg.Skinstable.ItemsCacheTTLSeconds,
-        }, httpClient)
+            PageSize:            cfg.Skinstable.PageSize,
+            IncludeBids:         cfg.Skinstable.IncludeBids,
+            SiteTimeoutSeconds:  cfg.Skinstable.SiteTimeoutSeconds,
+            SiteRetries:         cfg.Skinstable.SiteRetries,
+            StaleGraceSeconds:   cfg.Skinstable.StaleGraceSeconds,
+            Method:              cfg.Skinstable.Method,
+            ParamNames:          cfg.Skinstable.ParamNames,
+        }, clientFor("skinstable", cfg.Skinstable.ProxyURL))
         var p provider.Provider = stx
         if cfg.Skinstable.MaxRequestsPerMinute > 0 {
             rate := float64(cfg.Skinstable.MaxRequestsPerMinute) / 60.0
             burst := cfg.Skinstable.Burst
             if burst <= 0 { burst = 1 }
-            p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
+            tb := ratelimit.NewTokenBucket(rate, burst)
+            if cfg.Skinstable.StartEmpty { tb.WithTokens(0) }
+            p = &ratelimit.TokenBucketProvider{P: p, TB: tb}
         } else if cfg.Skinstable.MinRequestIntervalSec > 0 {
             interval := time.Duration(cfg.Skinstable.MinRequestIntervalSec) * time.Second
             p = &ratelimit.MinInterval{P: p, Interval: interval}
         }
         if cfg.Skinstable.CacheTTLSeconds > 0 {
-            p = &cache.Provider{P: p, TTL: time.Duration(cfg.Skinstable.CacheTTLSeconds) * time.Second, MaxItems: cfg.Skinstable.CacheMaxItems}
+            p = &cache.Provider{P: p, TTL: time.Duration(cfg.Skinstable.CacheTTLSeconds) * time.Second, MaxItems: cfg.Skinstable.CacheMaxItems, MaxTTL: time.Duration(cfg.Skinstable.CacheMaxTTLSeconds) * time.Second}
         }
         providers = append(providers, p)
     }
@@ -145,41 +201,76 @@ func main() {
     ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
     defer cancel()
 
-    type result struct {
-        name   string
-        quotes []provider.Quote
-        err    error
+    all, results := fetchAll(ctx, providers, symbols, time.Duration(providerTimeout)*time.Second)
+    // stderr, so stdout stays valid JSON
+    if err := printSummary(os.Stderr, results); err != nil { log.Printf("summary: %v", err) }
+    if len(all) == 0 {
+        log.Fatal("no quotes received")
     }
-    ch := make(chan result, len(providers))
-    for _, p := range providers {
-        p := p
-        go func() {
-            qs, err := p.Fetch(ctx, symbols)
-            ch <- result{name: p.Name(), quotes: qs, err: err}
-        }()
+
+    if aggregateOut {
+        err = printLatest(os.Stdout, all, side, includeSides)
+    } else {
+        err = printQuotes(os.Stdout, all)
     }
+    if err != nil { log.Fatalf("output: %v", err) }
+}
 
-    var all []provider.Quote
-    for i := 0; i < len(providers); i++ {
-        r := <-ch
-        if r.err != nil {
-            log.Printf("%s error: %v", r.name, r.err)
+// fetchAll queries every provider concurrently and logs per-provider results.
+// perProvider > 0 bounds each provider separately.
+func fetchAll(ctx context.Context, providers []provider.Provider, symbols []string, perProvider time.Duration) ([]provider.Quote, []multi.Result) {
+    results := (&multi.Provider{Providers: providers, Timeout: perProvider}).FetchEach(ctx, symbols)
+    for _, r := range results {
+        if r.Err != nil {
+            log.Printf("%s error: %v", r.Name, r.Err)
             continue
         }
-        log.Printf("%s: %d quotes", r.name, len(r.quotes))
-        all = append(all, r.quotes...)
+        log.Printf("%s: %d quotes", r.Name, len(r.Quotes))
     }
+    all, _ := multi.Merge(results)
+    return all, results
+}
 
-    if len(all) == 0 {
-        log.Fatal("no quotes received")
+// printSummary writes one row per provider: quotes, elapsed time and the
+// error, prefixed with its kind (timeout, rate limited, ...), or "-".
+func printSummary(w io.Writer, results []multi.Result) error {
+    tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+    fmt.Fprintln(tw, "PROVIDER\tQUOTES\tELAPSED\tERROR")
+    for _, r := range results {
+        msg := "-"
+        if r.Err != nil { msg = fmt.Sprintf("%v: %v", provider.Kind(r.Err), r.Err) }
+        fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", r.Name, len(r.Quotes), r.Elapsed.Round(time.Millisecond), msg)
     }
+    return tw.Flush()
+}
 
-    // Print up to 10 quotes as JSON for inspection
+// printQuotes prints up to 10 quotes as JSON for inspection.
+func printQuotes(w io.Writer, all []provider.Quote) error {
     n := len(all)
     if n > 10 { n = 10 }
     sample := struct{ Quotes []provider.Quote `json:"quotes"` }{Quotes: all[:n]}
-    b, _ := json.MarshalIndent(sample, "", "  ")
-    fmt.Println(string(b))
+    b, err := json.MarshalIndent(sample, "", "  ")
+    if err != nil { return err }
+    _, err = fmt.Fprintln(w, string(b))
+    return err
+}
+
+// printLatest collapses quotes like /api/latest and prints every Latest row.
+// side "sell" or "bid" keeps only that side; "all" collapses sides entirely.
+func printLatest(w io.Writer, all []provider.Quote, side string, includeSides bool) error {
+    if side == "all" { includeSides = false }
+    if side == "sell" || side == "bid" { includeSides = true }
+    agg := aggregate.LatestByMarket(all, includeSides)
+    if side == "sell" || side == "bid" {
+        f := agg[:0]
+        for _, a := range agg { if a.Side == side { f = append(f, a) } }
+        agg = f
+    }
+    out := struct{ Latest []aggregate.Latest `json:"latest"` }{Latest: agg}
+    b, err := json.MarshalIndent(out, "", "  ")
+    if err != nil { return err }
+    _, err = fmt.Fprintln(w, string(b))
+    return err
 }
 
 func splitCSV(s string) []string {
@@ -0,0 +1,81 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+)
+
+type fakeProvider struct {
+    name   string
+    quotes []provider.Quote
+}
+
+func (f fakeProvider) Name() string { return f.name }
+func (f fakeProvider) Fetch(context.Context, []string) ([]provider.Quote, error) { return f.quotes, nil }
+
+// failingProvider always fails with err.
+type failingProvider struct {
+    name string
+    err  error
+}
+
+func (f failingProvider) Name() string { return f.name }
+func (f failingProvider) Fetch(context.Context, []string) ([]provider.Quote, error) { return nil, f.err }
+
+func TestPrintSummary_OneRowPerProvider(t *testing.T) {
+    providers := []provider.Provider{
+        fakeProvider{"SteamDT", []provider.Quote{{Symbol: "A", Price: "1"}, {Symbol: "B", Price: "2"}}},
+        failingProvider{"Pricempire", &provider.Error{Kind: provider.ErrRateLimited, Status: 429}},
+    }
+    _, results := fetchAll(t.Context(), providers, []string{"A", "B"}, 0)
+
+    var buf bytes.Buffer
+    if err := printSummary(&buf, results); err != nil { t.Fatalf("summary: %v", err) }
+    lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+    if len(lines) != 3 { t.Fatalf("want header and two rows, got:\n%s", buf.String()) }
+    if f := strings.Fields(lines[0]); strings.Join(f, " ") != "PROVIDER QUOTES ELAPSED ERROR" { t.Fatalf("header: %q", lines[0]) }
+    if f := strings.Fields(lines[1]); f[0] != "SteamDT" || f[1] != "2" || f[3] != "-" { t.Fatalf("SteamDT row: %q", lines[1]) }
+    if f := strings.Fields(lines[2]); f[0] != "Pricempire" || f[1] != "0" || !strings.HasSuffix(lines[2], "rate limited: rate limited (status 429)") { t.Fatalf("Pricempire row: %q", lines[2]) }
+}
+
+func TestPrintLatest_AggregatesProviderOutput(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    providers := []provider.Provider{
+        fakeProvider{"steamdt", []provider.Quote{
+            {Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t0},
+            {Symbol: sym, Price: "9", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: t0},
+        }},
+        fakeProvider{"pricempire", []provider.Quote{
+            {Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff163", ReceivedAt: t0.Add(time.Second)},
+        }},
+    }
+    all, _ := fetchAll(t.Context(), providers, []string{sym}, 0)
+    if len(all) != 3 { t.Fatalf("want 3 quotes, got %d", len(all)) }
+
+    decode := func(side string, includeSides bool) []aggregate.Latest {
+        var buf bytes.Buffer
+        if err := printLatest(&buf, all, side, includeSides); err != nil { t.Fatalf("print: %v", err) }
+        var out struct{ Latest []aggregate.Latest `json:"latest"` }
+        if err := json.Unmarshal(buf.Bytes(), &out); err != nil { t.Fatalf("decode: %v\n%s", err, buf.String()) }
+        return out.Latest
+    }
+
+    // sides kept apart: bid, sell and the side-less Pricempire row
+    if rows := decode("", true); len(rows) != 3 { t.Fatalf("include-sides: want 3 rows, got %+v", rows) }
+
+    // collapsed: one BUFF row, newest wins
+    rows := decode("", false)
+    if len(rows) != 1 || rows[0].Price != "11" || rows[0].Provider != "Pricempire" { t.Fatalf("collapsed: unexpected %+v", rows) }
+    if rows2 := decode("all", true); len(rows2) != 1 { t.Fatalf("side=all should collapse, got %+v", rows2) }
+
+    rows = decode("bid", false)
+    if len(rows) != 1 || rows[0].Side != "bid" || rows[0].Price != "9" { t.Fatalf("side=bid: unexpected %+v", rows) }
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+    "crypto/subtle"
+    "encoding/json"
+    "net/http"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/pricempireadapter"
+)
+
+// providerToggles tracks which providers take part in the fan-out.
+// State lives in memory only and resets on restart.
+type providerToggles struct {
+    mu       sync.RWMutex
+    disabled map[string]*atomic.Bool // key: lower-cased provider name
+}
+
+// toggles is the process-wide kill switch registry consulted by collectQuotes.
+var toggles = &providerToggles{}
+
+func toggleKey(name string) string { return strings.ToLower(strings.TrimSpace(name)) }
+
+// Register makes a provider known to the registry (enabled by default).
+func (t *providerToggles) Register(name string) {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    if t.disabled == nil { t.disabled = make(map[string]*atomic.Bool) }
+    if _, ok := t.disabled[toggleKey(name)]; !ok {
+        t.disabled[toggleKey(name)] = &atomic.Bool{}
+    }
+}
+
+// Set enables or disables a registered provider. It reports false for unknown names.
+func (t *providerToggles) Set(name string, enabled bool) bool {
+    t.mu.RLock()
+    flag, ok := t.disabled[toggleKey(name)]
+    t.mu.RUnlock()
+    if !ok { return false }
+    flag.Store(!enabled)
+    return true
+}
+
+// Disabled reports whether the named provider is switched off.
+func (t *providerToggles) Disabled(name string) bool {
+    t.mu.RLock()
+    flag, ok := t.disabled[toggleKey(name)]
+    t.mu.RUnlock()
+    return ok && flag.Load()
+}
+
+// Snapshot returns the enabled state per registered provider.
+func (t *providerToggles) Snapshot() map[string]bool {
+    t.mu.RLock()
+    defer t.mu.RUnlock()
+    out := make(map[string]bool, len(t.disabled))
+    for k, v := range t.disabled { out[k] = !v.Load() }
+    return out
+}
+
+// registerAdmin wires the admin routes onto mux behind requireAdmin.
+func registerAdmin(mux *http.ServeMux, token string, providers []provider.Provider) {
+    for _, p := range providers { toggles.Register(p.Name()) }
+    mux.Handle("GET /admin/providers", requireAdmin(token, handleListProviders(providers)))
+    mux.Handle("POST /admin/providers/{name}/disable", requireAdmin(token, handleToggleProvider(false)))
+    mux.Handle("POST /admin/providers/{name}/enable", requireAdmin(token, handleToggleProvider(true)))
+    mux.Handle("POST /admin/upstreams/disable", requireAdmin(token, handleToggleUpstreams(false)))
+    mux.Handle("POST /admin/upstreams/enable", requireAdmin(token, handleToggleUpstreams(true)))
+}
+
+// requireAdmin checks the Bearer token. With no token configured the admin
+// surface is closed entirely.
+func requireAdmin(token string, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if token == "" {
+            writeError(w, "admin disabled", http.StatusForbidden)
+            return
+        }
+        // constant-time, so response timing does not leak the token
+        if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+            writeError(w, "unauthorized", http.StatusUnauthorized)
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+type providerState struct {
+    Name    string `json:"name"`
+    Enabled bool   `json:"enabled"`
+    // ItemCache reports the size of an adapter's full-dataset cache.
+    ItemCache *pricempireadapter.CacheStats `json:"item_cache,omitempty"`
+}
+
+// itemCacheReporter is implemented by adapters with a full-dataset cache
+// (currently the Pricempire adapter).
+type itemCacheReporter interface {
+    CacheStats() pricempireadapter.CacheStats
+}
+
+func handleListProviders(providers []provider.Provider) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        reporters := make(map[string]itemCacheReporter)
+        for _, p := range providers {
+            for _, layer := range provider.Chain(p) {
+                if c, ok := layer.(itemCacheReporter); ok { reporters[toggleKey(p.Name())] = c }
+            }
+        }
+        snap := toggles.Snapshot()
+        out := make([]providerState, 0, len(snap))
+        for name, enabled := range snap {
+            s := providerState{Name: name, Enabled: enabled}
+            if c, ok := reporters[name]; ok {
+                stats := c.CacheStats()
+                s.ItemCache = &stats
+            }
+            out = append(out, s)
+        }
+        sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+        w.Header().Set("Content-Type", "application/json; charset=utf-8")
+        _ = json.NewEncoder(w).Encode(struct { Providers []providerState `json:"providers"` }{Providers: out})
+    })
+}
+
+func handleToggleProvider(enabled bool) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        name := r.PathValue("name")
+        if !toggles.Set(name, enabled) {
+            writeError(w, "unknown provider", http.StatusNotFound)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json; charset=utf-8")
+        _ = json.NewEncoder(w).Encode(providerState{Name: toggleKey(name), Enabled: enabled})
+    })
+}
+
+// handleToggleUpstreams flips the global degraded mode (see upstreamsDisabled).
+func handleToggleUpstreams(enabled bool) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        upstreamsDisabled.Store(!enabled)
+        w.Header().Set("Content-Type", "application/json; charset=utf-8")
+        _ = json.NewEncoder(w).Encode(struct { Enabled bool `json:"enabled"` }{Enabled: enabled})
+    })
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/balancer"
+    "priceprovider/internal/provider/pricempire"
+    "priceprovider/internal/provider/pricempireadapter"
+    "priceprovider/internal/provider/ratelimit"
+)
+
+func TestAdmin_DisableExcludesProviderFromFanOut(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p1 := fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}
+    p2 := fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: ts}}}
+    providers := []provider.Provider{p1, p2}
+
+    mux := http.NewServeMux()
+    registerAdmin(mux, "secret", providers)
+    t.Cleanup(func() { toggles.Set("pricempire", true) })
+
+    toggle := func(action string) int {
+        req := httptest.NewRequest(http.MethodPost, "/admin/providers/Pricempire/"+action, nil)
+        req.Header.Set("Authorization", "Bearer secret")
+        rr := httptest.NewRecorder()
+        mux.ServeHTTP(rr, req)
+        return rr.Code
+    }
+    count := func() int {
+        rr := httptest.NewRecorder()
+        writeQuotes(rr, t.Context(), providers, []string{sym}, quotesOptions{})
+        var resp quotesResponse
+        if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+        return len(resp.Quotes)
+    }
+
+    if code := toggle("disable"); code != http.StatusOK { t.Fatalf("disable status=%d", code) }
+    if n := count(); n != 1 { t.Fatalf("want 1 quote with pricempire disabled, got %d", n) }
+
+    if code := toggle("enable"); code != http.StatusOK { t.Fatalf("enable status=%d", code) }
+    if n := count(); n != 2 { t.Fatalf("want 2 quotes after re-enable, got %d", n) }
+}
+
+func TestAdmin_RequiresToken(t *testing.T) {
+    mux := http.NewServeMux()
+    registerAdmin(mux, "secret", []provider.Provider{fakeProvider{name: "steamdt"}})
+
+    req := httptest.NewRequest(http.MethodPost, "/admin/providers/steamdt/disable", nil)
+    rr := httptest.NewRecorder()
+    mux.ServeHTTP(rr, req)
+    if rr.Code != http.StatusUnauthorized { t.Fatalf("want 401, got %d", rr.Code) }
+    if toggles.Disabled("steamdt") { t.Fatalf("provider disabled without auth") }
+
+    req = httptest.NewRequest(http.MethodPost, "/admin/providers/unknown/disable", nil)
+    req.Header.Set("Authorization", "Bearer secret")
+    rr = httptest.NewRecorder()
+    mux.ServeHTTP(rr, req)
+    if rr.Code != http.StatusNotFound { t.Fatalf("want 404 for unknown provider, got %d", rr.Code) }
+}
+
+func TestDebugRateLimit_DiscoversTokenBucketInChain(t *testing.T) {
+    tb := ratelimit.NewTokenBucket(1, 2)
+    p := wrapProvider(&ratelimit.TokenBucketProvider{P: fakeProvider{name: "steamdt"}, TB: tb}, wrapOptions{CacheTTLSec: 5})
+    if _, err := p.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch: %v", err) }
+
+    rr := httptest.NewRecorder()
+    handleDebugRateLimit([]provider.Provider{p, fakeProvider{name: "plain"}})(rr, httptest.NewRequest(http.MethodGet, "/debug/ratelimit", nil))
+    var resp struct{ Providers []map[string]any `json:"providers"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Providers) != 2 { t.Fatalf("want 2 providers, got %s", rr.Body.String()) }
+    got := resp.Providers[0]
+    if got["limiter"] != "token_bucket" || got["capacity"] != 2.0 { t.Fatalf("unexpected status: %v", got) }
+    if tokens, _ := got["tokens"].(float64); tokens >= 2 { t.Fatalf("want a consumed token, got %v", got) }
+    if resp.Providers[1]["limiter"] != "none" { t.Fatalf("unexpected plain status: %v", resp.Providers[1]) }
+}
+
+func TestDebugRateLimit_ReportsEachKeyPoolMember(t *testing.T) {
+    // wired like main does with key_pool: limiters on the members, none above the balancer
+    wo := wrapOptions{RPM: 60, Burst: 3}
+    members := []balancer.Member{
+        {P: limitProvider(fakeProvider{name: "SteamDT"}, wo)},
+        {P: limitProvider(fakeProvider{name: "SteamDT"}, wo)},
+    }
+    wo.RPM = 0
+    p := wrapProvider(balancer.New("SteamDT", members...), wo)
+    if _, err := p.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch: %v", err) }
+
+    rr := httptest.NewRecorder()
+    handleDebugRateLimit([]provider.Provider{p})(rr, httptest.NewRequest(http.MethodGet, "/debug/ratelimit", nil))
+    var resp struct{ Providers []map[string]any `json:"providers"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Providers) != 2 { t.Fatalf("want one entry per member, got %s", rr.Body.String()) }
+    for i, got := range resp.Providers {
+        if got["provider"] != "SteamDT" || got["member"] != float64(i) || got["limiter"] != "token_bucket" || got["capacity"] != 3.0 { t.Fatalf("unexpected member status: %v", got) }
+    }
+    if t0, _ := resp.Providers[0]["tokens"].(float64); t0 >= 3 { t.Fatalf("want the first member to have paid for the fetch, got %v", resp.Providers[0]) }
+}
+
+func TestAdmin_ListReportsItemCache(t *testing.T) {
+    client, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL("http://127.0.0.1:0"))
+    if err != nil { t.Fatalf("client: %v", err) }
+    pe := pricempireadapter.New(pricempireadapter.Config{ItemsCacheTTLSeconds: 60, MaxCachedItems: 1000}, client)
+    mux := http.NewServeMux()
+    registerAdmin(mux, "secret", []provider.Provider{wrapProvider(pe, wrapOptions{}), fakeProvider{name: "steamdt"}})
+
+    req := httptest.NewRequest(http.MethodGet, "/admin/providers", nil)
+    req.Header.Set("Authorization", "Bearer secret")
+    rr := httptest.NewRecorder()
+    mux.ServeHTTP(rr, req)
+    var resp struct{ Providers []providerState `json:"providers"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    for _, p := range resp.Providers {
+        switch p.Name {
+        case "pricempire":
+            if p.ItemCache == nil || p.ItemCache.MaxItems != 1000 { t.Fatalf("want pricempire item cache stats, got %+v", p) }
+        case "steamdt":
+            if p.ItemCache != nil { t.Fatalf("steamdt has no item cache: %+v", p) }
+        }
+    }
+}
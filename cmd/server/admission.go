@@ -0,0 +1,47 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "net/http"
+    "time"
+)
+
+// admission bounds how many requests run the provider fan-out at once.
+// Requests over the limit wait up to wait for a slot and are then turned
+// away with 503 and a Retry-After hint.
+type admission struct {
+    slots chan struct{}
+    wait  time.Duration
+}
+
+func newAdmission(limit int, wait time.Duration) *admission {
+    if limit <= 0 { return nil }
+    return &admission{slots: make(chan struct{}, limit), wait: wait}
+}
+
+// withAdmission runs next once a slot is free. A nil admission admits all.
+func withAdmission(a *admission, next http.HandlerFunc) http.HandlerFunc {
+    if a == nil { return next }
+    retryAfter := fmt.Sprintf("%d", int(math.Max(1, math.Ceil(a.wait.Seconds()))))
+    return func(w http.ResponseWriter, r *http.Request) {
+        select {
+        case a.slots <- struct{}{}:
+        default:
+            t := time.NewTimer(a.wait)
+            select {
+            case a.slots <- struct{}{}:
+                t.Stop()
+            case <-t.C:
+                w.Header().Set("Retry-After", retryAfter)
+                writeError(w, "server busy, retry later", http.StatusServiceUnavailable)
+                return
+            case <-r.Context().Done():
+                t.Stop()
+                return
+            }
+        }
+        defer func() { <-a.slots }()
+        next(w, r)
+    }
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+)
+
+func TestAdmission_OverLimitGets503WithRetryAfter(t *testing.T) {
+    release := make(chan struct{})
+    started := make(chan struct{}, 2)
+    h := withAdmission(newAdmission(2, 20*time.Millisecond), func(w http.ResponseWriter, r *http.Request) {
+        started <- struct{}{}
+        <-release
+        w.WriteHeader(http.StatusOK)
+    })
+
+    var wg sync.WaitGroup
+    codes := make([]int, 2)
+    for i := range codes {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            rr := httptest.NewRecorder()
+            h(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil))
+            codes[i] = rr.Code
+        }()
+    }
+    <-started
+    <-started
+
+    for i := 0; i < 3; i++ {
+        rr := httptest.NewRecorder()
+        h(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil))
+        if rr.Code != http.StatusServiceUnavailable { t.Fatalf("want 503 over the limit, got %d", rr.Code) }
+        if rr.Header().Get("Retry-After") != "1" { t.Fatalf("want Retry-After 1, got %q", rr.Header().Get("Retry-After")) }
+    }
+
+    close(release)
+    wg.Wait()
+    for _, c := range codes {
+        if c != http.StatusOK { t.Fatalf("admitted request: want 200, got %d", c) }
+    }
+    rr := httptest.NewRecorder()
+    h(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("slots not released: got %d", rr.Code) }
+}
+
+func TestAdmission_WaiterGetsFreedSlot(t *testing.T) {
+    release := make(chan struct{})
+    h := withAdmission(newAdmission(1, time.Second), func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Query().Get("block") != "" { <-release }
+        w.WriteHeader(http.StatusOK)
+    })
+    done := make(chan struct{})
+    go func() {
+        h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/quotes?block=1", nil))
+        close(done)
+    }()
+    time.Sleep(10 * time.Millisecond)
+    go func() { time.Sleep(20 * time.Millisecond); close(release) }()
+
+    rr := httptest.NewRecorder()
+    h(rr, httptest.NewRequest(http.MethodGet, "/api/quotes", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("queued request: want 200, got %d", rr.Code) }
+    <-done
+}
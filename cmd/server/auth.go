@@ -0,0 +1,77 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "priceprovider/internal/config"
+)
+
+// defaultMaxSymbols caps requests when no tier applies.
+const defaultMaxSymbols = 1000
+
+// clientTier is the limit set an API key (or the anonymous caller) gets.
+type clientTier struct {
+    Name       string
+    MaxSymbols int
+}
+
+// apiKeys resolves the X-API-Key header to a tier.
+type apiKeys struct {
+    anonymous clientTier
+    keys      map[string]clientTier
+}
+
+// newAPIKeys validates the auth config. Every key must name a defined tier.
+func newAPIKeys(c config.Auth) (*apiKeys, error) {
+    a := &apiKeys{anonymous: clientTier{Name: "anonymous", MaxSymbols: defaultMaxSymbols}, keys: make(map[string]clientTier, len(c.APIKeys))}
+    if c.AnonymousMaxSymbols > 0 { a.anonymous.MaxSymbols = c.AnonymousMaxSymbols }
+    for key, name := range c.APIKeys {
+        t, ok := c.Tiers[name]
+        if !ok { return nil, fmt.Errorf("auth: api key mapped to unknown tier %q", name) }
+        max := t.MaxSymbols
+        if max <= 0 { max = defaultMaxSymbols }
+        a.keys[key] = clientTier{Name: name, MaxSymbols: max}
+    }
+    return a, nil
+}
+
+type tierKey struct{}
+
+// withAPIKeys attaches the caller's tier to /api/ requests. A request without
+// X-API-Key is anonymous; an unknown key gets 401.
+func withAPIKeys(a *apiKeys, next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if a == nil || !strings.HasPrefix(r.URL.Path, "/api/") {
+            next.ServeHTTP(w, r)
+            return
+        }
+        tier := a.anonymous
+        if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+            t, ok := a.keys[key]
+            if !ok {
+                writeError(w, "invalid API key", http.StatusUnauthorized)
+                return
+            }
+            tier = t
+        }
+        next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tierKey{}, tier)))
+    })
+}
+
+// maxSymbols returns the symbols-per-request cap for the caller of r.
+func maxSymbols(r *http.Request) int {
+    if t, ok := r.Context().Value(tierKey{}).(clientTier); ok { return t.MaxSymbols }
+    return defaultMaxSymbols
+}
+
+// checkSymbolCap writes a 400 naming the caller's limit when n exceeds it.
+func checkSymbolCap(w http.ResponseWriter, r *http.Request, n int) bool {
+    if max := maxSymbols(r); n > max {
+        writeError(w, fmt.Sprintf("too many symbols (max %d)", max), http.StatusBadRequest)
+        return false
+    }
+    return true
+}
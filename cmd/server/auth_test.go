@@ -0,0 +1,77 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "slices"
+    "strings"
+    "testing"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/provider"
+)
+
+func TestAPIKeys_SymbolCapFollowsTier(t *testing.T) {
+    keys, err := newAPIKeys(config.Auth{
+        AnonymousMaxSymbols: 2,
+        Tiers:               map[string]config.Tier{"premium": {MaxSymbols: 5}},
+        APIKeys:             map[string]string{"k-premium": "premium"},
+    })
+    if err != nil { t.Fatalf("auth: %v", err) }
+    h := withAPIKeys(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        handleGetQuotes(w, r, []provider.Provider{fakeProvider{name: "steamdt"}})
+    }))
+    get := func(n int, key string) *httptest.ResponseRecorder {
+        syms := make([]string, n)
+        for i := range syms { syms[i] = string(rune('A' + i)) }
+        req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols="+strings.Join(syms, ","), nil)
+        if key != "" { req.Header.Set("X-API-Key", key) }
+        rr := httptest.NewRecorder()
+        h.ServeHTTP(rr, req)
+        return rr
+    }
+
+    if rr := get(2, ""); rr.Code != http.StatusOK { t.Fatalf("anonymous within cap: got %d", rr.Code) }
+    rr := get(3, "")
+    if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "max 2") { t.Fatalf("anonymous over cap: got %d %q", rr.Code, rr.Body.String()) }
+
+    if rr := get(5, "k-premium"); rr.Code != http.StatusOK { t.Fatalf("premium within cap: got %d", rr.Code) }
+    rr = get(6, "k-premium")
+    if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "max 5") { t.Fatalf("premium over cap: got %d %q", rr.Code, rr.Body.String()) }
+
+    if rr := get(1, "nope"); rr.Code != http.StatusUnauthorized { t.Fatalf("unknown key: want 401, got %d", rr.Code) }
+}
+
+func TestAPIKeys_PostUsesTierCap(t *testing.T) {
+    keys, err := newAPIKeys(config.Auth{Tiers: map[string]config.Tier{"premium": {MaxSymbols: 1500}}, APIKeys: map[string]string{"k": "premium"}})
+    if err != nil { t.Fatalf("auth: %v", err) }
+    h := withAPIKeys(keys, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        handlePostQuotes(w, r, []provider.Provider{fakeProvider{name: "steamdt"}})
+    }))
+    syms := make([]string, 1200)
+    for i := range syms { syms[i] = `"s` + strings.Repeat("x", i%7) + `"` }
+    body := `{"symbols":[` + strings.Join(syms, ",") + `]}`
+    post := func(key string) int {
+        req := httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(body))
+        if key != "" { req.Header.Set("X-API-Key", key) }
+        rr := httptest.NewRecorder()
+        h.ServeHTTP(rr, req)
+        return rr.Code
+    }
+    if code := post(""); code != http.StatusBadRequest { t.Fatalf("anonymous default cap 1000: want 400, got %d", code) }
+    if code := post("k"); code != http.StatusOK { t.Fatalf("premium cap 1500: want 200, got %d", code) }
+}
+
+func TestNewAPIKeys_UnknownTier(t *testing.T) {
+    if _, err := newAPIKeys(config.Auth{APIKeys: map[string]string{"k": "gold"}}); err == nil { t.Fatalf("want error for unknown tier") }
+}
+
+func TestCORS_PreflightAllowsAPIKeyAndIdempotencyKey(t *testing.T) {
+    h := withJSONHeaders(http.NotFoundHandler())
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, httptest.NewRequest(http.MethodOptions, "/api/quotes", nil))
+    allowed := strings.Split(rr.Header().Get("Access-Control-Allow-Headers"), ",")
+    for _, want := range []string{"X-API-Key", "Idempotency-Key"} {
+        if !slices.Contains(allowed, want) { t.Fatalf("preflight does not allow %s: %v", want, allowed) }
+    }
+}
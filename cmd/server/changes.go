@@ -0,0 +1,41 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+)
+
+// maxTrackedChanges bounds the last-seen prices kept for /api/changes.
+const maxTrackedChanges = 100000
+
+// handleChanges serves GET /api/changes?symbols=A,B: it fetches the latest
+// quote per market and side and returns only rows whose price moved since the
+// previous observation (by any caller), with old/new price and delta.
+func handleChanges(tracker *aggregate.ChangeTracker, providers []provider.Provider) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query().Get("symbols")
+        if strings.TrimSpace(q) == "" {
+            writeError(w, "missing symbols query param", http.StatusBadRequest)
+            return
+        }
+        symbols := splitCSV(q)
+        if !checkSymbolCap(w, r, len(symbols)) { return }
+        ctx, cancel := context.WithTimeout(r.Context(), requestDeadline)
+        defer cancel()
+        qs, errs := collectQuotes(ctx, providers, symbols)
+        if len(qs) == 0 && len(errs) > 0 {
+            writeUpstreamError(w, ctx, errs)
+            return
+        }
+        changes := tracker.Observe(aggregate.LatestByMarket(qs, true))
+        w.WriteHeader(http.StatusOK)
+        enc := json.NewEncoder(w)
+        enc.SetEscapeHTML(false)
+        _ = enc.Encode(struct { Changes []aggregate.Change `json:"changes"` }{Changes: changes})
+    }
+}
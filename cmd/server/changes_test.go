@@ -0,0 +1,36 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+)
+
+func TestChanges_ReportsOnlyMovedSymbols(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    tracker := aggregate.NewChangeTracker(maxTrackedChanges)
+    round := func(a, b string) []aggregate.Change {
+        p := fakeProvider{"steamdt", []provider.Quote{
+            {Symbol: "A", Price: a, Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+            {Symbol: "B", Price: b, Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+        }}
+        rr := httptest.NewRecorder()
+        handleChanges(tracker, []provider.Provider{p})(rr, httptest.NewRequest(http.MethodGet, "/api/changes?symbols=A,B", nil))
+        if rr.Code != http.StatusOK { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+        var resp struct{ Changes []aggregate.Change `json:"changes"` }
+        if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+        if resp.Changes == nil { t.Fatalf("want changes array, got %s", rr.Body.String()) }
+        return resp.Changes
+    }
+
+    if got := round("10", "20"); len(got) != 0 { t.Fatalf("first round has no baseline, got %+v", got) }
+    got := round("10", "18")
+    if len(got) != 1 || got[0].Symbol != "B" || got[0].OldPrice != "20" || got[0].NewPrice != "18" || got[0].DeltaPct != "-10.00" {
+        t.Fatalf("want only B changed, got %+v", got)
+    }
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/balancer"
+    "priceprovider/internal/provider/ratelimit"
+)
+
+// rateLimitStatus describes the limiter found in one provider's wrapper chain.
+type rateLimitStatus struct {
+    Provider string `json:"provider"`
+    // Member is the index of the balanced member (key_pool) the limiter belongs to.
+    Member   *int   `json:"member,omitempty"`
+    // Limiter is token_bucket, min_interval or none.
+    Limiter  string `json:"limiter"`
+    *ratelimit.Snapshot
+    IntervalSec float64 `json:"interval_sec,omitempty"`
+}
+
+// rateLimitStatuses walks each provider chain and reports the first limiter found.
+func rateLimitStatuses(providers []provider.Provider) []rateLimitStatus {
+    out := make([]rateLimitStatus, 0, len(providers))
+    for _, p := range providers { out = append(out, chainStatuses(p.Name(), p)...) }
+    return out
+}
+
+// chainStatuses reports the first limiter in p's chain. A balancer has no
+// limiter of its own, its members do, so it yields one entry per member.
+func chainStatuses(name string, p provider.Provider) []rateLimitStatus {
+    st := rateLimitStatus{Provider: name, Limiter: "none"}
+    for _, layer := range provider.Chain(p) {
+        if tb, ok := layer.(*ratelimit.TokenBucketProvider); ok && tb.TB != nil {
+            snap := tb.TB.Snapshot()
+            st.Limiter, st.Snapshot = "token_bucket", &snap
+            break
+        }
+        if mi, ok := layer.(*ratelimit.MinInterval); ok {
+            st.Limiter, st.IntervalSec = "min_interval", mi.Interval.Seconds()
+            break
+        }
+        if b, ok := layer.(*balancer.Provider); ok {
+            var out []rateLimitStatus
+            for i, m := range b.Members() {
+                for _, ms := range chainStatuses(name, m.P) {
+                    ms.Member = &i
+                    out = append(out, ms)
+                }
+            }
+            return out
+        }
+    }
+    return []rateLimitStatus{st}
+}
+
+func handleDebugRateLimit(providers []provider.Provider) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "application/json; charset=utf-8")
+        _ = json.NewEncoder(w).Encode(struct {
+            Providers []rateLimitStatus `json:"providers"`
+        }{Providers: rateLimitStatuses(providers)})
+    }
+}
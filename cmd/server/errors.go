@@ -0,0 +1,59 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "log"
+    "net/http"
+    "strings"
+)
+
+// errorFormat selects how writeError renders errors: "text" (default, like
+// http.Error) or "problem" (RFC 7807 application/problem+json). It is
+// initialized from config on startup.
+var errorFormat = "text"
+
+// problem is an RFC 7807 problem details body.
+type problem struct {
+    Type   string `json:"type"`
+    Title  string `json:"title"`
+    Status int    `json:"status"`
+    Detail string `json:"detail,omitempty"`
+}
+
+// writeError replies with an error message and status code in the configured
+// errorFormat. It takes the same arguments as http.Error.
+func writeError(w http.ResponseWriter, msg string, code int) {
+    if errorFormat != "problem" {
+        http.Error(w, msg, code)
+        return
+    }
+    h := w.Header()
+    h.Del("Content-Length")
+    h.Set("Content-Type", "application/problem+json")
+    h.Set("X-Content-Type-Options", "nosniff")
+    w.WriteHeader(code)
+    enc := json.NewEncoder(w)
+    enc.SetEscapeHTML(false)
+    _ = enc.Encode(problem{Type: "about:blank", Title: http.StatusText(code), Status: code, Detail: msg})
+}
+
+// writeUpstreamError answers a request whose every provider failed. ctx is
+// the request's fan-out context: when the client went away (context.Canceled)
+// nothing is written and the failure is only logged; when the request
+// deadline fired the status is 504, whatever the providers made of it;
+// otherwise upstreamStatus picks the status from the error kinds.
+func writeUpstreamError(w http.ResponseWriter, ctx context.Context, errs []error) {
+    msgs := make([]string, 0, len(errs))
+    for _, e := range errs { msgs = append(msgs, e.Error()) }
+    msg := strings.Join(msgs, "; ")
+    switch err := ctx.Err(); {
+    case errors.Is(err, context.Canceled):
+        log.Printf("client canceled request; not responding: %s", msg)
+    case errors.Is(err, context.DeadlineExceeded):
+        writeError(w, msg, http.StatusGatewayTimeout)
+    default:
+        writeError(w, msg, upstreamStatus(errs))
+    }
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "priceprovider/internal/provider"
+)
+
+func TestErrors_ProblemJSON(t *testing.T) {
+    old := errorFormat
+    errorFormat = "problem"
+    t.Cleanup(func() { errorFormat = old })
+
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes", nil), nil)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400, got %d", rr.Code) }
+    if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" { t.Fatalf("content-type=%q", ct) }
+    var p problem
+    if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil { t.Fatalf("decode: %v (%s)", err, rr.Body.String()) }
+    if p.Type != "about:blank" || p.Title != "Bad Request" || p.Status != 400 || p.Detail == "" { t.Fatalf("unexpected problem: %+v", p) }
+
+    // upstream failures keep their mapped status
+    rr = httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), []provider.Provider{failingProvider{"p", provider.StatusError(http.StatusTooManyRequests, nil)}}, []string{"A"}, quotesOptions{})
+    if err := json.Unmarshal(rr.Body.Bytes(), &p); err != nil { t.Fatalf("decode: %v", err) }
+    if rr.Code != http.StatusTooManyRequests || p.Status != 429 || p.Title != "Too Many Requests" { t.Fatalf("unexpected upstream problem: %d %+v", rr.Code, p) }
+}
+
+func TestErrors_TextByDefault(t *testing.T) {
+    rr := httptest.NewRecorder()
+    handlePostQuotes(rr, httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(`{"symbols":[]}`)), nil)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400, got %d", rr.Code) }
+    if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") { t.Fatalf("content-type=%q", ct) }
+    if strings.TrimSpace(rr.Body.String()) != "symbols cannot be empty" { t.Fatalf("body=%q", rr.Body.String()) }
+}
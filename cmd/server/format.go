@@ -0,0 +1,214 @@
+package main
+
+import (
+    "bytes"
+    "encoding/json"
+    "encoding/xml"
+    "fmt"
+    "net/http"
+    "strings"
+    "time"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/quotepb"
+)
+
+// formatOptions are the presentation settings shared by the JSON endpoints.
+type formatOptions struct {
+    // Case selects key naming: "snake" (default) or "camel".
+    Case string
+    // XML is set when the client negotiated XML via the Accept header.
+    XML bool
+    // Protobuf is set when the client negotiated application/x-protobuf.
+    Protobuf bool
+    // Fields, when non-nil, limits each row to these keys (snake_case names).
+    Fields map[string]bool
+    // EpochMs renders timestamps as Unix epoch milliseconds instead of RFC 3339.
+    EpochMs bool
+}
+
+// defaultCase is the key naming used when a request does not pass ?case=.
+// It is initialized from config on startup.
+var defaultCase = "snake"
+
+// defaultTimestamp is the timestamp format used when a request does not pass
+// ?ts=: "rfc3339" or "epoch_ms". It is initialized from config on startup.
+var defaultTimestamp = "rfc3339"
+
+func parseFormatOptions(r *http.Request) (formatOptions, error) {
+    accept := preferredFormat(r.Header.Get("Accept"))
+    f := formatOptions{Case: defaultCase, XML: accept == "xml", Protobuf: accept == "protobuf"}
+    if v := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("case"))); v != "" { f.Case = v }
+    switch f.Case {
+    case "", "snake", "camel":
+    default:
+        return f, fmt.Errorf("invalid case (snake|camel)")
+    }
+    ts := defaultTimestamp
+    if v := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("ts"))); v != "" { ts = v }
+    switch ts {
+    case "", "rfc3339":
+    case "epoch_ms":
+        f.EpochMs = true
+    default:
+        return f, fmt.Errorf("invalid ts (rfc3339|epoch_ms)")
+    }
+    return f, nil
+}
+
+// preferredFormat returns "json", "xml" or "protobuf" for an Accept header.
+// Media types are taken in listed order; JSON stays the default.
+func preferredFormat(accept string) string {
+    for _, part := range strings.Split(accept, ",") {
+        mt := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+        switch mt {
+        case "application/xml", "text/xml":
+            return "xml"
+        case quotepb.ContentType, "application/protobuf":
+            return "protobuf"
+        case "application/json":
+            return "json"
+        }
+    }
+    return "json"
+}
+
+// field describes one JSON key of a response row. get returns false to omit the key.
+type field[T any] struct {
+    snake string
+    camel string
+    get   func(T) (any, bool)
+}
+
+// parseFieldList validates a ?fields= CSV against a field table. Names may be
+// given in either key style. An empty value returns nil (all fields).
+func parseFieldList[T any](v string, fields []field[T]) (map[string]bool, error) {
+    if strings.TrimSpace(v) == "" { return nil, nil }
+    out := make(map[string]bool)
+    for _, name := range strings.Split(v, ",") {
+        name = strings.TrimSpace(name)
+        if name == "" { continue }
+        found := false
+        for _, fl := range fields {
+            if name == fl.snake || name == fl.camel {
+                out[fl.snake] = true
+                found = true
+                break
+            }
+        }
+        if !found { return nil, fmt.Errorf("invalid field %q", name) }
+    }
+    if len(out) == 0 { return nil, nil }
+    return out, nil
+}
+
+func (f formatOptions) includes(snake string) bool { return f.Fields == nil || f.Fields[snake] }
+
+// present applies the timestamp format to a row value.
+func (f formatOptions) present(v any) any {
+    if t, ok := v.(time.Time); ok && f.EpochMs { return t.UnixMilli() }
+    return v
+}
+
+func (f formatOptions) keyOf(snake, camel string) string {
+    if f.Case == "camel" { return camel }
+    return snake
+}
+
+// marshalRow writes v as a JSON object with keys in table order.
+func marshalRow[T any](v T, fields []field[T], f formatOptions) ([]byte, error) {
+    var buf bytes.Buffer
+    buf.WriteByte('{')
+    first := true
+    for _, fl := range fields {
+        if !f.includes(fl.snake) { continue }
+        val, ok := fl.get(v)
+        if !ok { continue }
+        b, err := marshalNoEscape(f.present(val))
+        if err != nil { return nil, err }
+        if !first { buf.WriteByte(',') }
+        first = false
+        k, _ := marshalNoEscape(f.keyOf(fl.snake, fl.camel))
+        buf.Write(k)
+        buf.WriteByte(':')
+        buf.Write(b)
+    }
+    buf.WriteByte('}')
+    return buf.Bytes(), nil
+}
+
+// encodeXMLRow writes v as <name> with one child element per field, using the
+// same keys and omission rules as marshalRow.
+func encodeXMLRow[T any](enc *xml.Encoder, name string, v T, fields []field[T], f formatOptions) error {
+    start := xml.StartElement{Name: xml.Name{Local: name}}
+    if err := enc.EncodeToken(start); err != nil { return err }
+    for _, fl := range fields {
+        if !f.includes(fl.snake) { continue }
+        val, ok := fl.get(v)
+        if !ok { continue }
+        if err := enc.EncodeElement(f.present(val), xml.StartElement{Name: xml.Name{Local: f.keyOf(fl.snake, fl.camel)}}); err != nil { return err }
+    }
+    return enc.EncodeToken(start.End())
+}
+
+// marshalNoEscape matches the handlers' SetEscapeHTML(false) for nested values.
+func marshalNoEscape(v any) ([]byte, error) {
+    var buf bytes.Buffer
+    enc := json.NewEncoder(&buf)
+    enc.SetEscapeHTML(false)
+    if err := enc.Encode(v); err != nil { return nil, err }
+    return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+var quoteFields = []field[provider.Quote]{
+    {"symbol", "symbol", func(q provider.Quote) (any, bool) { return q.Symbol, true }},
+    {"price", "price", func(q provider.Quote) (any, bool) { return q.Price, true }},
+    {"currency", "currency", func(q provider.Quote) (any, bool) { return q.Currency, true }},
+    {"source", "source", func(q provider.Quote) (any, bool) { return q.Source, true }},
+    {"provider", "provider", func(q provider.Quote) (any, bool) { return q.Provider, q.Provider != "" }},
+    {"received_at", "receivedAt", func(q provider.Quote) (any, bool) { return q.ReceivedAt, true }},
+    {"app_id", "appId", func(q provider.Quote) (any, bool) { return q.AppID, q.AppID != 0 }},
+    {"volume", "volume", func(q provider.Quote) (any, bool) { return q.Volume, q.Volume != 0 }},
+    {"external_id", "externalId", func(q provider.Quote) (any, bool) { return q.ExternalID, q.ExternalID != "" }},
+    {"inflated", "inflated", func(q provider.Quote) (any, bool) { return q.Inflated, q.Inflated != nil }},
+}
+
+var latestFields = []field[aggregate.Latest]{
+    {"symbol", "symbol", func(l aggregate.Latest) (any, bool) { return l.Symbol, true }},
+    {"market", "market", func(l aggregate.Latest) (any, bool) { return l.Market, true }},
+    {"side", "side", func(l aggregate.Latest) (any, bool) { return l.Side, true }},
+    {"currency", "currency", func(l aggregate.Latest) (any, bool) { return l.Currency, true }},
+    {"price", "price", func(l aggregate.Latest) (any, bool) { return l.Price, true }},
+    {"provider", "provider", func(l aggregate.Latest) (any, bool) { return l.Provider, true }},
+    {"received_at", "receivedAt", func(l aggregate.Latest) (any, bool) { return l.ReceivedAt, true }},
+    {"app_id", "appId", func(l aggregate.Latest) (any, bool) { return l.AppID, l.AppID != 0 }},
+}
+
+// quoteJSON renders a quote with the request's formatOptions.
+type quoteJSON struct {
+    q provider.Quote
+    f formatOptions
+}
+
+func (v quoteJSON) MarshalJSON() ([]byte, error) { return marshalRow(v.q, quoteFields, v.f) }
+
+func viewQuotes(qs []provider.Quote, f formatOptions) []quoteJSON {
+    out := make([]quoteJSON, len(qs))
+    for i, q := range qs { out[i] = quoteJSON{q: q, f: f} }
+    return out
+}
+
+// latestJSON renders a Latest row with the request's formatOptions.
+type latestJSON struct {
+    l aggregate.Latest
+    f formatOptions
+}
+
+func (v latestJSON) MarshalJSON() ([]byte, error) { return marshalRow(v.l, latestFields, v.f) }
+
+func viewLatest(ls []aggregate.Latest, f formatOptions) []latestJSON {
+    out := make([]latestJSON, len(ls))
+    for i, l := range ls { out[i] = latestJSON{l: l, f: f} }
+    return out
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "encoding/xml"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "google.golang.org/protobuf/proto"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/timing"
+    "priceprovider/internal/quotepb"
+)
+
+func TestFormat_CaseSnakeAndCamel(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: ts, AppID: 730}}}
+
+    cases := []struct {
+        path     string
+        want     []string
+        unwanted []string
+    }{
+        {"/api/quotes?symbols=" + sym, []string{"received_at", "app_id"}, []string{"receivedAt", "appId"}},
+        {"/api/quotes?case=camel&symbols=" + sym, []string{"receivedAt", "appId"}, []string{"received_at", "app_id"}},
+        {"/api/latest?symbols=" + sym, []string{"received_at", "app_id", "provider"}, []string{"receivedAt"}},
+        {"/api/latest?case=camel&symbols=" + sym, []string{"receivedAt", "appId", "provider"}, []string{"received_at"}},
+    }
+    for _, tc := range cases {
+        req := httptest.NewRequest(http.MethodGet, strings.ReplaceAll(tc.path, " ", "%20"), nil)
+        rr := httptest.NewRecorder()
+        if strings.HasPrefix(tc.path, "/api/quotes") {
+            handleGetQuotes(rr, req, []provider.Provider{p})
+        } else {
+            handleGetLatest(rr, req, []provider.Provider{p})
+        }
+        if rr.Code != 200 { t.Fatalf("%s: status=%d body=%s", tc.path, rr.Code, rr.Body.String()) }
+
+        var env struct {
+            Quotes []map[string]any `json:"quotes"`
+            Latest []map[string]any `json:"latest"`
+        }
+        if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil { t.Fatalf("%s: invalid JSON: %v", tc.path, err) }
+        rows := env.Quotes
+        if rows == nil { rows = env.Latest }
+        if len(rows) != 1 { t.Fatalf("%s: want 1 row, got %s", tc.path, rr.Body.String()) }
+        for _, k := range tc.want {
+            if _, ok := rows[0][k]; !ok { t.Fatalf("%s: missing key %q in %v", tc.path, k, rows[0]) }
+        }
+        for _, k := range tc.unwanted {
+            if _, ok := rows[0][k]; ok { t.Fatalf("%s: unexpected key %q in %v", tc.path, k, rows[0]) }
+        }
+        if rows[0]["symbol"] != sym || rows[0]["price"] != "11" { t.Fatalf("%s: unexpected values %v", tc.path, rows[0]) }
+    }
+}
+
+func TestFormat_TimestampRFC3339AndEpochMs(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 678_000_000, time.UTC)
+    p := fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: ts}}}
+    get := func(path string, h func(http.ResponseWriter, *http.Request, []provider.Provider)) map[string]any {
+        rr := httptest.NewRecorder()
+        h(rr, httptest.NewRequest(http.MethodGet, strings.ReplaceAll(path, " ", "%20"), nil), []provider.Provider{p})
+        if rr.Code != 200 { t.Fatalf("%s: status=%d body=%s", path, rr.Code, rr.Body.String()) }
+        var env map[string]any
+        if err := json.Unmarshal(rr.Body.Bytes(), &env); err != nil { t.Fatalf("%s: invalid JSON: %v", path, err) }
+        return env
+    }
+    row := func(env map[string]any, key string) map[string]any { return env[key].([]any)[0].(map[string]any) }
+
+    env := get("/api/quotes?symbols="+sym, handleGetQuotes)
+    if got := row(env, "quotes")["received_at"]; got != "2025-01-02T03:04:05.678Z" { t.Fatalf("want RFC 3339 by default, got %v", got) }
+    if got := env["meta"].(map[string]any)["oldest_received_at"]; got != "2025-01-02T03:04:05.678Z" { t.Fatalf("meta: got %v", got) }
+
+    env = get("/api/quotes?ts=epoch_ms&symbols="+sym, handleGetQuotes)
+    if got := row(env, "quotes")["received_at"]; got != float64(ts.UnixMilli()) { t.Fatalf("want epoch ms, got %v (%T)", got, got) }
+    meta := env["meta"].(map[string]any)
+    if meta["oldest_received_at"] != float64(ts.UnixMilli()) || meta["newest_received_at"] != float64(ts.UnixMilli()) { t.Fatalf("meta: got %v", meta) }
+
+    env = get("/api/latest?ts=epoch_ms&case=camel&symbols="+sym, handleGetLatest)
+    if got := row(env, "latest")["receivedAt"]; got != float64(ts.UnixMilli()) { t.Fatalf("latest: want epoch ms, got %v", got) }
+
+    defaultTimestamp = "epoch_ms"
+    t.Cleanup(func() { defaultTimestamp = "rfc3339" })
+    if got := row(get("/api/quotes?symbols="+sym, handleGetQuotes), "quotes")["received_at"]; got != float64(ts.UnixMilli()) { t.Fatalf("config default: got %v", got) }
+    if got := row(get("/api/quotes?ts=rfc3339&symbols="+sym, handleGetQuotes), "quotes")["received_at"]; got != "2025-01-02T03:04:05.678Z" { t.Fatalf("override: got %v", got) }
+
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&ts=unix", nil), nil)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for invalid ts, got %d", rr.Code) }
+}
+
+func TestFormat_InvalidCase(t *testing.T) {
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&case=kebab", nil)
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, req, nil)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400, got %d", rr.Code) }
+}
+
+func TestFormat_XMLNegotiationThroughGzip(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"steamdt", []provider.Quote{
+        {Symbol: "A & B", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+        {Symbol: "C", Price: "2.5", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: ts, AppID: 730},
+    }}
+    h := withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        handleGetQuotes(w, r, []provider.Provider{p})
+    }), gzipOptions{})
+
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A%20%26%20B,C", nil)
+    req.Header.Set("Accept", "application/xml")
+    req.Header.Set("Accept-Encoding", "gzip")
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+    if rr.Code != 200 { t.Fatalf("status=%d", rr.Code) }
+    if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") { t.Fatalf("content-type=%q", ct) }
+    if rr.Header().Get("Content-Encoding") != "gzip" { t.Fatalf("want gzip encoding") }
+
+    zr, err := gzip.NewReader(rr.Body)
+    if err != nil { t.Fatalf("gzip: %v", err) }
+    body, err := io.ReadAll(zr)
+    if err != nil { t.Fatalf("read: %v", err) }
+
+    var doc struct {
+        XMLName xml.Name `xml:"quotes"`
+        Quotes  []struct {
+            Symbol     string    `xml:"symbol"`
+            Price      string    `xml:"price"`
+            ReceivedAt time.Time `xml:"received_at"`
+            AppID      int       `xml:"app_id"`
+        } `xml:"quote"`
+    }
+    if err := xml.Unmarshal(body, &doc); err != nil { t.Fatalf("invalid XML: %v\n%s", err, body) }
+    if len(doc.Quotes) != 2 { t.Fatalf("want 2 quotes, got %s", body) }
+    if doc.Quotes[0].Symbol != "A & B" || doc.Quotes[0].Price != "10" || !doc.Quotes[0].ReceivedAt.Equal(ts) { t.Fatalf("unexpected first quote: %+v", doc.Quotes[0]) }
+    if doc.Quotes[1].AppID != 730 { t.Fatalf("want app_id 730, got %+v", doc.Quotes[1]) }
+}
+
+func TestFormat_FieldsProjection(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"steamdt", []provider.Quote{{Symbol: "A", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT", ReceivedAt: ts}}}
+
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&fields=symbol,price,currency", nil), []provider.Provider{p})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    var resp struct{ Quotes []map[string]any `json:"quotes"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 { t.Fatalf("want 1 quote, got %s", rr.Body.String()) }
+    if len(resp.Quotes[0]) != 3 || resp.Quotes[0]["symbol"] != "A" || resp.Quotes[0]["price"] != "10" || resp.Quotes[0]["currency"] != "USD" {
+        t.Fatalf("want only symbol,price,currency, got %v", resp.Quotes[0])
+    }
+
+    rr = httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&fields=symbol,bogus", nil), []provider.Provider{p})
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for unknown field, got %d", rr.Code) }
+}
+
+func TestFormat_ProtobufNegotiation(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    want := []provider.Quote{{Symbol: "A", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT", ReceivedAt: ts, AppID: 730}}
+    p := fakeProvider{"steamdt", want}
+
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil)
+    req.Header.Set("Accept", "application/x-protobuf, application/json;q=0.5")
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, req, []provider.Provider{p})
+    if rr.Code != http.StatusOK { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    if ct := rr.Header().Get("Content-Type"); ct != quotepb.ContentType { t.Fatalf("content-type=%q", ct) }
+    var resp quotepb.QuotesResponse
+    if err := proto.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("unmarshal: %v", err) }
+    if got := quotepb.ToQuotes(resp.GetQuotes()); len(got) != 1 || got[0] != want[0] { t.Fatalf("unexpected quotes: %+v", got) }
+
+    req = httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&group=symbol", nil)
+    req.Header.Set("Accept", "application/x-protobuf")
+    rr = httptest.NewRecorder()
+    handleGetQuotes(rr, req, []provider.Provider{p})
+    if rr.Code != http.StatusBadRequest { t.Fatalf("group with protobuf: want 400, got %d", rr.Code) }
+}
+
+// partialProvider returns its quotes together with err, e.g. a
+// provider.PartialError.
+type partialProvider struct {
+    fakeProvider
+    err error
+}
+
+func (p partialProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    qs, _ := p.fakeProvider.Fetch(ctx, symbols)
+    return qs, p.err
+}
+
+func TestFormat_ProtobufCarriesPartialErrors(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    partial := &provider.PartialError{Symbols: []string{"B"}, Err: context.DeadlineExceeded}
+    p := &timing.Provider{P: partialProvider{fakeProvider{"SteamDT", []provider.Quote{{Symbol: "A", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}, partial}}
+
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A,B", nil)
+    req.Header.Set("Accept", "application/x-protobuf")
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, req, []provider.Provider{p})
+    var resp quotepb.QuotesResponse
+    if err := proto.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil { t.Fatalf("status=%d: %v", rr.Code, err) }
+    if len(resp.GetQuotes()) != 1 { t.Fatalf("want the partial result's quote, got %v", resp.GetQuotes()) }
+    if got := resp.GetPartialErrors()["SteamDT"]; got != partial.Error() { t.Fatalf("want the partial error, got %v", resp.GetPartialErrors()) }
+}
+
+// staleProvider marks itself stale on the request's recorder, as a cache
+// serving expired entries does.
+type staleProvider struct{ fakeProvider }
+
+func (p staleProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if r := timing.FromContext(ctx); r != nil { r.MarkStale(p.name) }
+    return p.fakeProvider.Fetch(ctx, symbols)
+}
+
+func TestFormat_ProtobufCarriesStale(t *testing.T) {
+    p := staleProvider{fakeProvider{"Pricempire", []provider.Quote{{Symbol: "A", Price: "10", Currency: "USD", Source: "Pricempire:buff"}}}}
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil)
+    req.Header.Set("Accept", "application/x-protobuf")
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, req, []provider.Provider{p})
+    var resp quotepb.QuotesResponse
+    if err := proto.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil { t.Fatalf("status=%d: %v", rr.Code, err) }
+    if got := resp.GetStale(); len(got) != 1 || got[0] != "Pricempire" { t.Fatalf("want Pricempire tagged stale, got %v", got) }
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "encoding/json"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "priceprovider/internal/provider"
+)
+
+func serveGzip(t *testing.T, body string, o gzipOptions) *httptest.ResponseRecorder {
+    t.Helper()
+    h := withGzip(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.WriteHeader(http.StatusCreated)
+        // Write in small chunks to exercise the buffering threshold.
+        for i := 0; i < len(body); i += 64 { _, _ = io.WriteString(w, body[i:min(i+64, len(body))]) }
+    }), o)
+    req := httptest.NewRequest(http.MethodGet, "/", nil)
+    req.Header.Set("Accept-Encoding", "gzip")
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+    return rr
+}
+
+func TestWithGzip_SmallBodyUncompressed(t *testing.T) {
+    rr := serveGzip(t, `{"ok":true}`, gzipOptions{MinSize: 1024})
+    if rr.Code != http.StatusCreated { t.Fatalf("status=%d", rr.Code) }
+    if rr.Header().Get("Content-Encoding") != "" { t.Fatalf("small body should not be gzipped") }
+    if rr.Body.String() != `{"ok":true}` { t.Fatalf("body=%q", rr.Body.String()) }
+    if rr.Header().Get("Vary") != "Accept-Encoding" { t.Fatalf("want Vary header") }
+}
+
+func TestWithGzip_LargeBodyCompressed(t *testing.T) {
+    body := strings.Repeat(`{"symbol":"AK-47 | Redline"},`, 200)
+    rr := serveGzip(t, body, gzipOptions{Level: gzip.BestCompression, MinSize: 1024})
+    if rr.Code != http.StatusCreated { t.Fatalf("status=%d", rr.Code) }
+    if rr.Header().Get("Content-Encoding") != "gzip" { t.Fatalf("want gzip encoding") }
+    if rr.Body.Len() >= len(body) { t.Fatalf("body not compressed: %d >= %d", rr.Body.Len(), len(body)) }
+    zr, err := gzip.NewReader(rr.Body)
+    if err != nil { t.Fatalf("gzip: %v", err) }
+    got, _ := io.ReadAll(zr)
+    if string(got) != body { t.Fatalf("round trip mismatch: %d bytes", len(got)) }
+}
+
+func TestLimitBody_DecompressesGzipRequest(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    p := fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell"}}}
+    h := limitBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handlePostQuotes(w, r, []provider.Provider{p}) }))
+
+    var buf bytes.Buffer
+    zw := gzip.NewWriter(&buf)
+    _, _ = io.WriteString(zw, `{"symbols":["`+sym+`"]}`)
+    _ = zw.Close()
+    req := httptest.NewRequest(http.MethodPost, "/api/quotes", &buf)
+    req.Header.Set("Content-Encoding", "gzip")
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+    if rr.Code != http.StatusOK { t.Fatalf("want 200, got %d: %s", rr.Code, rr.Body.String()) }
+    var resp quotesResponse
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 || resp.Quotes[0].Symbol != sym { t.Fatalf("unexpected quotes: %+v", resp.Quotes) }
+
+    req = httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(`{"symbols":["A"]}`))
+    req.Header.Set("Content-Encoding", "gzip")
+    rr = httptest.NewRecorder()
+    h.ServeHTTP(rr, req)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("malformed gzip: want 400, got %d", rr.Code) }
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+    "bytes"
+    "crypto/sha256"
+    "encoding/hex"
+    "io"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+)
+
+// idempotencyStore remembers POST responses by caller and Idempotency-Key so
+// that client retries replay the first response instead of repeating the
+// upstream fan-out. It holds at most max keys; the oldest keys are evicted
+// first.
+type idempotencyStore struct {
+    ttl   time.Duration
+    max   int
+    mu    sync.Mutex
+    m     map[string]*idemEntry
+    order []string // insertion order for eviction
+}
+
+// idemEntry is one remembered response. done is closed once the first
+// request has finished; until then concurrent retries wait on it.
+type idemEntry struct {
+    sum     [sha256.Size]byte
+    done    chan struct{}
+    expires time.Time
+    status  int
+    header  http.Header
+    body    []byte
+    // stored is false when the response was not kept for replay.
+    stored bool
+}
+
+func newIdempotencyStore(ttl time.Duration, max int) *idempotencyStore {
+    if max <= 0 { max = 1000 }
+    return &idempotencyStore{ttl: ttl, max: max, m: make(map[string]*idemEntry)}
+}
+
+// begin returns the entry for key. leader is true when the caller created it
+// and must run the request and call finish.
+func (s *idempotencyStore) begin(key string, sum [sha256.Size]byte) (e *idemEntry, leader bool) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := time.Now()
+    if e, ok := s.m[key]; ok {
+        if e.expires.IsZero() || now.Before(e.expires) { return e, false }
+        delete(s.m, key)
+    }
+    // Drop expired keys from the front and evict the oldest over capacity.
+    for len(s.order) > 0 {
+        k := s.order[0]
+        old, ok := s.m[k]
+        if ok && len(s.m) < s.max && (old.expires.IsZero() || now.Before(old.expires)) { break }
+        if ok { delete(s.m, k) }
+        s.order = s.order[1:]
+    }
+    e = &idemEntry{sum: sum, done: make(chan struct{})}
+    s.m[key] = e
+    s.order = append(s.order, key)
+    return e, true
+}
+
+// finish stores the response for replay. Only statuses 200-499 are
+// remembered: server errors, and requests that panicked or were canceled
+// before writing anything, drop the key so a later retry runs again.
+func (s *idempotencyStore) finish(key string, e *idemEntry, rec *idemRecorder) {
+    s.mu.Lock()
+    e.status, e.header, e.body = rec.status, rec.header, rec.body.Bytes()
+    e.expires = time.Now().Add(s.ttl)
+    e.stored = rec.status >= http.StatusOK && rec.status < http.StatusInternalServerError
+    if !e.stored && s.m[key] == e { delete(s.m, key) }
+    s.mu.Unlock()
+    close(e.done)
+}
+
+// withIdempotency wraps a POST handler. Requests without an Idempotency-Key
+// header, or with a nil store, pass through unchanged.
+func withIdempotency(s *idempotencyStore, next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+        if s == nil || key == "" || r.Method != http.MethodPost {
+            next(w, r)
+            return
+        }
+        body, err := io.ReadAll(r.Body)
+        if err != nil {
+            writeError(w, "invalid body", http.StatusBadRequest)
+            return
+        }
+        r.Body = io.NopCloser(bytes.NewReader(body))
+        // keys are scoped to the caller's credentials, so callers never see
+        // or block each other's keys
+        key = callerScope(r) + ":" + key
+        h := sha256.New()
+        io.WriteString(h, r.URL.RequestURI())
+        h.Write([]byte{0})
+        h.Write(body)
+        var sum [sha256.Size]byte
+        copy(sum[:], h.Sum(nil))
+
+        e, leader := s.begin(key, sum)
+        if leader {
+            rec := &idemRecorder{ResponseWriter: w}
+            defer s.finish(key, e, rec)
+            next(rec, r)
+            return
+        }
+        if e.sum != sum {
+            writeError(w, "Idempotency-Key reused with a different request", http.StatusUnprocessableEntity)
+            return
+        }
+        select {
+        case <-e.done:
+        case <-r.Context().Done():
+            writeError(w, "request canceled", http.StatusServiceUnavailable)
+            return
+        }
+        // nothing to replay: run the request as the retry it is
+        if !e.stored {
+            next(w, r)
+            return
+        }
+        for k, v := range e.header { w.Header()[k] = v }
+        w.Header().Set("Idempotent-Replayed", "true")
+        w.WriteHeader(e.status)
+        _, _ = w.Write(e.body)
+    }
+}
+
+// callerScope identifies the caller by a hash of its credentials (X-API-Key
+// and Authorization), so they are not kept in the store in clear.
+func callerScope(r *http.Request) string {
+    h := sha256.New()
+    io.WriteString(h, r.Header.Get("X-API-Key"))
+    h.Write([]byte{0})
+    io.WriteString(h, r.Header.Get("Authorization"))
+    return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// idemRecorder passes the response through while keeping a copy of it.
+type idemRecorder struct {
+    http.ResponseWriter
+    status int
+    header http.Header
+    body   bytes.Buffer
+}
+
+func (r *idemRecorder) WriteHeader(code int) {
+    if r.status != 0 { return }
+    r.status = code
+    r.header = r.Header().Clone()
+    // Transport encodings are decided per response by the outer middleware.
+    r.header.Del("Content-Encoding")
+    r.header.Del("Content-Length")
+    r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *idemRecorder) Write(b []byte) (int, error) {
+    if r.status == 0 { r.WriteHeader(http.StatusOK) }
+    r.body.Write(b)
+    return r.ResponseWriter.Write(b)
+}
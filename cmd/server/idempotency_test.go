@@ -0,0 +1,101 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+type countingFetcher struct {
+    fakeProvider
+    calls atomic.Int32
+}
+
+func (c *countingFetcher) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    c.calls.Add(1)
+    return c.fakeProvider.Fetch(ctx, symbols)
+}
+
+func TestIdempotency_RepeatedKeyRunsFanOutOnce(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := &countingFetcher{fakeProvider: fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}}
+    h := withIdempotency(newIdempotencyStore(time.Minute, 10), func(w http.ResponseWriter, r *http.Request) {
+        handlePostQuotes(w, r, []provider.Provider{p})
+    })
+
+    post := func(key, body string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(body))
+        req.Header.Set("Idempotency-Key", key)
+        rr := httptest.NewRecorder()
+        h(rr, req)
+        return rr
+    }
+    body := `{"symbols":["` + sym + `"]}`
+    first := post("k1", body)
+    second := post("k1", body)
+    if first.Code != 200 || second.Code != 200 { t.Fatalf("status=%d,%d", first.Code, second.Code) }
+    if n := p.calls.Load(); n != 1 { t.Fatalf("want 1 fan-out, got %d", n) }
+    if first.Body.String() != second.Body.String() { t.Fatalf("replay differs:\n%s\n%s", first.Body.String(), second.Body.String()) }
+    if second.Header().Get("Idempotent-Replayed") != "true" { t.Fatalf("want replay header") }
+
+    if rr := post("k1", `{"symbols":["other"]}`); rr.Code != http.StatusUnprocessableEntity { t.Fatalf("want 422 for reused key, got %d", rr.Code) }
+    if post("k2", body); p.calls.Load() != 2 { t.Fatalf("new key should fan out again") }
+}
+
+func TestIdempotency_UnfinishedResponseIsNotReplayed(t *testing.T) {
+    var calls atomic.Int32
+    release := make(chan struct{})
+    h := withIdempotency(newIdempotencyStore(time.Minute, 10), func(w http.ResponseWriter, r *http.Request) {
+        if calls.Add(1) == 1 {
+            <-release
+            return // canceled: nothing written
+        }
+        w.WriteHeader(http.StatusOK)
+    })
+    post := func() *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(`{}`))
+        req.Header.Set("Idempotency-Key", "k1")
+        rr := httptest.NewRecorder()
+        h(rr, req)
+        return rr
+    }
+
+    done := make(chan *httptest.ResponseRecorder)
+    go post()
+    for calls.Load() == 0 { time.Sleep(time.Millisecond) }
+    go func() { done <- post() }() // waits on the leader, then must not replay status 0
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    if rr := <-done; rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "" { t.Fatalf("want the waiting retry run again, got %d %v", rr.Code, rr.Header()) }
+    if rr := post(); rr.Code != http.StatusOK || calls.Load() < 2 { t.Fatalf("want the key usable again, got %d after %d calls", rr.Code, calls.Load()) }
+}
+
+func TestIdempotency_KeyIsScopedToTheCaller(t *testing.T) {
+    var runs atomic.Int32
+    h := withIdempotency(newIdempotencyStore(time.Minute, 10), func(w http.ResponseWriter, r *http.Request) {
+        runs.Add(1)
+        _, _ = w.Write([]byte(r.Header.Get("X-API-Key")))
+    })
+    post := func(apiKey, body string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(http.MethodPost, "/api/quotes", strings.NewReader(body))
+        req.Header.Set("Idempotency-Key", "shared")
+        req.Header.Set("X-API-Key", apiKey)
+        rr := httptest.NewRecorder()
+        h(rr, req)
+        return rr
+    }
+    if rr := post("alice", `{}`); rr.Body.String() != "alice" { t.Fatalf("first: %q", rr.Body.String()) }
+    // another caller's use of the same key is its own request, not a conflict
+    if rr := post("bob", `{"x":1}`); rr.Code != http.StatusOK || rr.Body.String() != "bob" { t.Fatalf("want bob's own response, got %d %q", rr.Code, rr.Body.String()) }
+    if rr := post("alice", `{}`); rr.Body.String() != "alice" || rr.Header().Get("Idempotent-Replayed") != "true" { t.Fatalf("want alice's replay, got %q", rr.Body.String()) }
+    if n := runs.Load(); n != 2 { t.Fatalf("want one run per caller, got %d", n) }
+    // the same caller reusing its key for another body is still refused
+    if rr := post("bob", `{}`); rr.Code != http.StatusUnprocessableEntity { t.Fatalf("want 422 for a reused key, got %d %q", rr.Code, rr.Body.String()) }
+}
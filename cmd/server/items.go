@@ -0,0 +1,75 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/httpx"
+)
+
+// handleItems serves GET /api/items with every item name SkinstableXYZ lists
+// for the configured (or ?sites=) sites. It always asks upstream, so it
+// answers 503 in degraded mode (see upstreamsDisabled).
+func handleItems(c config.Skinstable, client *httpx.Client) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        if !c.Enabled {
+            writeError(w, "skinstable disabled", http.StatusBadRequest)
+            return
+        }
+        // the whole point of degraded mode: no upstream calls
+        if upstreamsDisabled.Load() {
+            writeError(w, "upstreams disabled", http.StatusServiceUnavailable)
+            return
+        }
+        sitesParam := strings.TrimSpace(r.URL.Query().Get("sites"))
+        var sites []string
+        if sitesParam != "" {
+            sites = splitCSV(sitesParam)
+        } else {
+            sites = append([]string(nil), c.Sites...)
+        }
+        if len(sites) == 0 { sites = []string{"CS.MONEY"} }
+        type apiResp struct { Items map[string]struct{ N string `json:"n"` } `json:"items"` }
+        ctx, cancel := context.WithTimeout(r.Context(), requestDeadline)
+        defer cancel()
+        names := make(map[string]struct{}, 64000)
+        for _, site := range sites {
+            u := c.Endpoint
+            if strings.TrimSpace(u) == "" { writeError(w, "skinstable endpoint missing", http.StatusBadRequest); return }
+            req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+            if err != nil { writeError(w, err.Error(), http.StatusInternalServerError); return }
+            q := req.URL.Query()
+            if c.APIKey != "" { q.Set("apikey", c.APIKey) }
+            if c.AppID > 0 { q.Set("app", fmt.Sprintf("%d", c.AppID)) }
+            if site != "" { q.Set("site", site) }
+            req.URL.RawQuery = q.Encode()
+            req.Header.Set("Accept", "application/json")
+            resp, err := client.Do(ctx, req)
+            if err != nil { writeError(w, err.Error(), http.StatusBadGateway); return }
+            func() {
+                defer resp.Body.Close()
+                if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+                    writeError(w, fmt.Sprintf("upstream %s -> %d", req.URL.String(), resp.StatusCode), http.StatusBadGateway)
+                    return
+                }
+                var body apiResp
+                dec := json.NewDecoder(resp.Body)
+                if err := dec.Decode(&body); err != nil { writeError(w, err.Error(), http.StatusBadGateway); return }
+                for k := range body.Items { if strings.TrimSpace(k) != "" { names[k] = struct{}{} } }
+            }()
+        }
+        list := make([]string, 0, len(names))
+        for k := range names { list = append(list, k) }
+        // sort for stable output
+        sort.Strings(list)
+        w.WriteHeader(http.StatusOK)
+        enc := json.NewEncoder(w)
+        enc.SetEscapeHTML(false)
+        _ = enc.Encode(struct { Items []string `json:"items"` }{Items: list})
+    }
+}
@@ -3,10 +3,13 @@ package main
 import (
     "context"
     "encoding/json"
+    "net/http"
     "net/http/httptest"
+    "net/url"
     "testing"
     "time"
 
+    "priceprovider/internal/aggregate"
     "priceprovider/internal/provider"
 )
 
@@ -32,7 +35,7 @@ func TestLatest_NewestAcrossProviders_SameMarket(t *testing.T) {
     p2 := fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: t2}}}
 
     rr := httptest.NewRecorder()
-    writeLatest(rr, t.Context(), []provider.Provider{p1, p2}, []string{sym}, "all", "")
+    writeLatest(rr, t.Context(), []provider.Provider{p1, p2}, []string{sym}, "all", "", latestOptions{})
     if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
     var resp latestResponse
     if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
@@ -54,7 +57,7 @@ func TestLatest_SideFiltering_SellAndBid(t *testing.T) {
 
     // sell only
     rrSell := httptest.NewRecorder()
-    writeLatest(rrSell, t.Context(), []provider.Provider{p}, []string{sym}, "sell", "")
+    writeLatest(rrSell, t.Context(), []provider.Provider{p}, []string{sym}, "sell", "", latestOptions{})
     var respSell latestResponse
     if err := json.Unmarshal(rrSell.Body.Bytes(), &respSell); err != nil { t.Fatalf("decode sell: %v", err) }
     if len(respSell.Latest) != 1 || respSell.Latest[0].Side != "sell" || respSell.Latest[0].Price != "10" {
@@ -63,7 +66,7 @@ func TestLatest_SideFiltering_SellAndBid(t *testing.T) {
 
     // bid only
     rrBid := httptest.NewRecorder()
-    writeLatest(rrBid, t.Context(), []provider.Provider{p}, []string{sym}, "bid", "")
+    writeLatest(rrBid, t.Context(), []provider.Provider{p}, []string{sym}, "bid", "", latestOptions{})
     var respBid latestResponse
     if err := json.Unmarshal(rrBid.Body.Bytes(), &respBid); err != nil { t.Fatalf("decode bid: %v", err) }
     if len(respBid.Latest) != 1 || respBid.Latest[0].Side != "bid" || respBid.Latest[0].Price != "9" {
@@ -80,7 +83,7 @@ func TestLatest_MixedCurrencies_DoNotCollapse_Server(t *testing.T) {
         {Symbol: sym, Price: "700", Currency: "CNY", Source: "Pricempire:buff.163", ReceivedAt: t2},
     }}
     rr := httptest.NewRecorder()
-    writeLatest(rr, t.Context(), []provider.Provider{p}, []string{sym}, "all", "")
+    writeLatest(rr, t.Context(), []provider.Provider{p}, []string{sym}, "all", "", latestOptions{})
     var resp latestResponse
     if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
     if len(resp.Latest) != 2 { t.Fatalf("want 2 rows, got %d: %+v", len(resp.Latest), resp.Latest) }
@@ -88,3 +91,49 @@ func TestLatest_MixedCurrencies_DoNotCollapse_Server(t *testing.T) {
     for _, r := range resp.Latest { seen[r.Currency] = true }
     if !seen["USD"] || !seen["CNY"] { t.Fatalf("currencies missing: %+v", resp.Latest) }
 }
+
+func TestLatest_PickLowest(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p1 := fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "9", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}
+    p2 := fakeProvider{"skinstable", []provider.Quote{{Symbol: sym, Price: "12", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts.Add(time.Minute)}}}
+
+    req := httptest.NewRequest(http.MethodGet, "/api/latest?symbols="+url.QueryEscape(sym)+"&pick=lowest", nil)
+    rr := httptest.NewRecorder()
+    handleGetLatest(rr, req, []provider.Provider{p1, p2})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    var resp struct{ Latest []aggregate.Latest `json:"latest"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Latest) != 1 || resp.Latest[0].Price != "9" || resp.Latest[0].Side != "sell" { t.Fatalf("unexpected: %+v", resp.Latest) }
+
+    req = httptest.NewRequest(http.MethodGet, "/api/latest?symbols=A&pick=cheapest", nil)
+    rr = httptest.NewRecorder()
+    handleGetLatest(rr, req, []provider.Provider{p1})
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for invalid pick, got %d", rr.Code) }
+}
+
+func TestLatest_MaxMarketsPerSymbol(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    var qs []provider.Quote
+    for i, site := range []string{"BUFF", "Steam", "C5GAME", "Skinport", "CS.MONEY"} {
+        qs = append(qs, provider.Quote{Symbol: sym, Price: "10", Currency: "USD", Source: "Pricempire:" + site, ReceivedAt: ts.Add(time.Duration(i) * time.Minute)})
+    }
+    p := fakeProvider{"pricempire", qs}
+
+    req := httptest.NewRequest(http.MethodGet, "/api/latest?symbols="+url.QueryEscape(sym)+"&max_markets_per_symbol=2", nil)
+    rr := httptest.NewRecorder()
+    handleGetLatest(rr, req, []provider.Provider{p})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    var resp struct{ Latest []aggregate.Latest `json:"latest"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Latest) != 2 { t.Fatalf("want the two freshest markets, got %+v", resp.Latest) }
+    for _, l := range resp.Latest {
+        if l.Market != "Skinport" && l.Market != "CS.MONEY" { t.Fatalf("want Skinport and CS.MONEY, got %+v", resp.Latest) }
+    }
+
+    req = httptest.NewRequest(http.MethodGet, "/api/latest?symbols=A&max_markets_per_symbol=0", nil)
+    rr = httptest.NewRecorder()
+    handleGetLatest(rr, req, []provider.Provider{p})
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for a non-positive limit, got %d", rr.Code) }
+}
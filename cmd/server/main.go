@@ -4,9 +4,11 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "encoding/xml"
     "fmt"
     "log"
     "net/http"
+    "net/url"
     "os"
     "os/signal"
     "strings"
@@ -14,115 +16,354 @@ import (
     "time"
     "compress/gzip"
     "io"
+    "math/big"
     "sync"
+    "sync/atomic"
     "sort"
+    "strconv"
+    "errors"
+    "slices"
 
     "priceprovider/internal/config"
     "priceprovider/internal/aggregate"
     "priceprovider/internal/httpx"
+    "priceprovider/internal/money"
     "priceprovider/internal/provider"
     "priceprovider/internal/provider/ratelimit"
+    "priceprovider/internal/provider/retry"
+    "priceprovider/internal/provider/alias"
+    "priceprovider/internal/provider/balancer"
     "priceprovider/internal/provider/cache"
+    "priceprovider/internal/provider/dedup"
+    "priceprovider/internal/provider/filter"
+    "priceprovider/internal/provider/health"
+    "priceprovider/internal/provider/hedge"
+    "priceprovider/internal/provider/multi"
     "priceprovider/internal/provider/steamdt"
+    "priceprovider/internal/provider/timing"
     pricempirepkg "priceprovider/internal/provider/pricempire"
     "priceprovider/internal/provider/pricempireadapter"
     "priceprovider/internal/provider/skinstablexyz"
+    "priceprovider/internal/quotepb"
 )
 
 type quotesResponse struct {
-    Quotes []provider.Quote `json:"quotes"`
+    Quotes  []provider.Quote `json:"quotes"`
+    // Missing lists requested symbols with no quotes; nil unless ?report_missing=true.
+    Missing []string         `json:"missing,omitempty"`
+    Meta    *responseMeta    `json:"meta,omitempty"`
+    format  formatOptions
+}
+
+// responseMeta carries request diagnostics alongside the data.
+type responseMeta struct {
+    // ProviderTimingsMs is how long each provider's Fetch took, by provider name.
+    ProviderTimingsMs map[string]int64 `json:"provider_timings_ms,omitempty"`
+    // OldestReceivedAt and NewestReceivedAt bound the ReceivedAt of the
+    // returned quotes, so clients can apply their own staleness policy.
+    OldestReceivedAt  *time.Time       `json:"oldest_received_at,omitempty"`
+    NewestReceivedAt  *time.Time       `json:"newest_received_at,omitempty"`
+    // PartialErrors holds, by provider name, failures a provider recovered
+    // from while still returning quotes (e.g. one timed-out SteamDT batch).
+    PartialErrors     map[string]string `json:"partial_errors,omitempty"`
+    // Stale lists providers that served cache entries past their TTL because
+    // the upstream failed (see serve_stale_during_outage_sec).
+    Stale             []string          `json:"stale,omitempty"`
+    format            formatOptions
+}
+
+// MarshalJSON renders the watermarks in the request's timestamp format.
+func (m responseMeta) MarshalJSON() ([]byte, error) {
+    var oldest, newest any
+    if m.OldestReceivedAt != nil { oldest, newest = m.format.present(*m.OldestReceivedAt), m.format.present(*m.NewestReceivedAt) }
+    return marshalNoEscape(struct {
+        ProviderTimingsMs map[string]int64 `json:"provider_timings_ms,omitempty"`
+        OldestReceivedAt  any              `json:"oldest_received_at,omitempty"`
+        NewestReceivedAt  any              `json:"newest_received_at,omitempty"`
+        PartialErrors     map[string]string `json:"partial_errors,omitempty"`
+        Stale             []string          `json:"stale,omitempty"`
+    }{m.ProviderTimingsMs, oldest, newest, m.PartialErrors, m.Stale})
+}
+
+// newResponseMeta returns nil when there is nothing to report. Quotes without
+// a timestamp do not count towards the watermarks.
+func newResponseMeta(rec *timing.Recorder, quotes []provider.Quote, f formatOptions) *responseMeta {
+    m := responseMeta{format: f}
+    m.ProviderTimingsMs = rec.Milliseconds()
+    m.PartialErrors = rec.Warnings()
+    m.Stale = rec.Stale()
+    for i := range quotes {
+        ts := quotes[i].ReceivedAt
+        if ts.IsZero() { continue }
+        if m.OldestReceivedAt == nil || ts.Before(*m.OldestReceivedAt) { m.OldestReceivedAt = &ts }
+        if m.NewestReceivedAt == nil || ts.After(*m.NewestReceivedAt) { m.NewestReceivedAt = &ts }
+    }
+    if m.ProviderTimingsMs == nil && m.OldestReceivedAt == nil && m.PartialErrors == nil && m.Stale == nil { return nil }
+    return &m
+}
+
+func (r quotesResponse) MarshalJSON() ([]byte, error) {
+    var missing any // a non-nil empty slice still renders as []
+    if r.Missing != nil { missing = r.Missing }
+    return marshalNoEscape(struct {
+        Quotes  []quoteJSON   `json:"quotes"`
+        Missing any           `json:"missing,omitempty"`
+        Meta    *responseMeta `json:"meta,omitempty"`
+    }{Quotes: viewQuotes(r.Quotes, r.format), Missing: missing, Meta: r.Meta})
+}
+
+// MarshalXML renders <quotes><quote>...</quote></quotes> for XML clients.
+func (r quotesResponse) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+    start := xml.StartElement{Name: xml.Name{Local: "quotes"}}
+    if err := enc.EncodeToken(start); err != nil { return err }
+    for _, q := range r.Quotes {
+        if err := encodeXMLRow(enc, "quote", q, quoteFields, r.format); err != nil { return err }
+    }
+    if r.Missing != nil {
+        if err := enc.EncodeElement(struct{ Symbols []string `xml:"symbol"` }{r.Missing}, xml.StartElement{Name: xml.Name{Local: "missing"}}); err != nil { return err }
+    }
+    return enc.EncodeToken(start.End())
 }
 
 type latestResponse struct {
     Latest []aggregate.Latest `json:"latest"`
+    format formatOptions
 }
 
-// apiTimeoutSec is the per-request timeout used when collecting quotes
-// from providers. It is initialized from config on startup.
-var apiTimeoutSec = 15
+func (r latestResponse) MarshalJSON() ([]byte, error) {
+    return marshalNoEscape(struct {
+        Latest []latestJSON `json:"latest"`
+    }{Latest: viewLatest(r.Latest, r.format)})
+}
+
+// requestDeadline bounds the whole provider fan-out of one API request
+// (server.request_deadline_sec). Each upstream HTTP call is additionally
+// bounded by the smaller httpx timeout (server.request_timeout_sec).
+// It is initialized from config on startup.
+var requestDeadline = 15 * time.Second
+
+// fetchConcurrency caps how many providers one request fetches at once
+// (server.fetch_concurrency; 0 = unlimited). It is initialized from config
+// on startup.
+var fetchConcurrency int
+
+// upstreamsDisabled puts the server in degraded mode: collectQuotes serves
+// provider caches only and never calls an upstream. It starts from
+// server.disable_all_upstreams and can be flipped via /admin/upstreams.
+var upstreamsDisabled atomic.Bool
+
+// marketPriority ranks markets (best first) when /api/latest trims them with
+// max_markets_per_symbol (server.market_priority). It is initialized from
+// config on startup.
+var marketPriority []string
+
+// providerPriority ranks providers (best first) for equal-timestamp ties in
+// the fan-out (server.provider_priority). It is initialized from config on
+// startup.
+var providerPriority []string
+
+// degradedHeader marks API responses served while upstreams are disabled.
+const degradedHeader = "X-Degraded-Mode"
 
 func main() {
     // Config
     cfgPath := os.Getenv("CONFIG_FILE")
     cfg, err := config.Load(cfgPath)
     if err != nil { log.Fatalf("config: %v", err) }
+    for _, w := range cfg.Validate() { log.Printf("warning: %s", w) }
     port := cfg.Server.Port
     timeoutSec := cfg.Server.RequestTimeoutSec
     if timeoutSec <= 0 { timeoutSec = 10 }
-    apiTimeoutSec = timeoutSec
+    if d := cfg.Server.RequestDeadlineSec; d > 0 { requestDeadline = time.Duration(d) * time.Second }
+    if r := cfg.Debug.LogSampleRate; r < 0 || r > 1 { log.Fatalf("config: invalid debug.log_sample_rate %g (0..1)", r) }
+    logSampleRate = cfg.Debug.LogSampleRate
+    panicStack, panicRef = cfg.Debug.PanicStack, cfg.Debug.PanicRef
+    fetchConcurrency = cfg.Server.FetchConcurrency
+    marketPriority = cfg.Server.MarketPriority
+    providerPriority = cfg.Server.ProviderPriority
+    strictParams = cfg.Server.StrictQueryParams
+    upstreamsDisabled.Store(cfg.Server.DisableAllUpstreams)
+    if upstreamsDisabled.Load() { log.Printf("warning: server.disable_all_upstreams is set; serving cached data only") }
+    if requestDeadline < time.Duration(timeoutSec)*time.Second {
+        log.Printf("warning: server.request_deadline_sec (%s) is below server.request_timeout_sec (%ds); upstream calls will be cut short by the deadline", requestDeadline, timeoutSec)
+    }
+    if c := strings.ToLower(strings.TrimSpace(cfg.Server.JSONCase)); c != "" {
+        if c != "snake" && c != "camel" { log.Fatalf("config: invalid server.json_case %q (snake|camel)", c) }
+        defaultCase = c
+    }
+    if f := strings.ToLower(strings.TrimSpace(cfg.Server.TimestampFormat)); f != "" {
+        if f != "rfc3339" && f != "epoch_ms" { log.Fatalf("config: invalid server.timestamp_format %q (rfc3339|epoch_ms)", f) }
+        defaultTimestamp = f
+    }
+    cfg.Pricempire.APIVersion = strings.ToLower(strings.TrimSpace(cfg.Pricempire.APIVersion))
+    if v := cfg.Pricempire.APIVersion; v != "" && v != "v3" && v != "v4" { log.Fatalf("config: invalid pricempire.api_version %q (v3|v4)", v) }
+    switch f := strings.ToLower(strings.TrimSpace(cfg.Server.ErrorFormat)); f {
+    case "", "text":
+    case "problem", "problem+json":
+        errorFormat = "problem"
+    default:
+        log.Fatalf("config: invalid server.error_format %q (text|problem)", f)
+    }
+    if d := cfg.Server.SanityMaxDeviation; d != 0 {
+        if d <= 1 { log.Fatalf("config: invalid server.sanity_max_deviation %g (must be > 1)", d) }
+        sanityMaxDeviation, sanityDrop = d, cfg.Server.SanityDrop
+    }
+    if quoteSink, err = newQuoteSink(cfg.Publish); err != nil { log.Fatalf("config: publish: %v", err) }
+    shared, err := newSharedCache(cfg.Cache)
+    if err != nil { log.Fatalf("config: %v", err) }
+    if shared != nil { log.Printf("sharing provider caches via redis at %s", cfg.Cache.RedisAddr) }
+    if quoteSink != nil { log.Printf("publishing quotes to kafka topic %q via %s", cfg.Publish.KafkaTopic, strings.Join(cfg.Publish.KafkaBrokers, ",")) }
 
     if cfg.SteamDT.Enabled && cfg.SteamDT.APIKey == "" {
         log.Println("warning: steamdt.enabled=true but STEAMDT_API_KEY not set")
     }
 
-    httpClient := httpx.New(time.Duration(timeoutSec) * time.Second)
-    httpClient.UserAgent = "price-provider/1.0"
+    if dir := strings.TrimSpace(cfg.Debug.FixturesDir); dir != "" {
+        log.Printf("debug: serving upstream HTTP from fixtures in %s", dir)
+    }
+    if cfg.Debug.DumpHTTP {
+        log.Printf("debug: dumping upstream HTTP traffic (secrets redacted)")
+    }
+    httpClient, err := newUpstreamClient(cfg, timeoutSec, nil, clientOptions{})
+    if err != nil { log.Fatalf("http client: %v", err) }
+    clientFor := func(name string, o clientOptions) *httpx.Client {
+        c, err := providerClient(cfg, timeoutSec, httpClient, name, o)
+        if err != nil { log.Fatalf("config: %s: %v", name, err) }
+        return c
+    }
+    // withTimeout bounds a provider's calls below the shared ceiling
+    // (server.request_timeout_sec); longer settings cannot raise it.
+    withTimeout := func(name string, c *httpx.Client, sec int) *httpx.Client {
+        if sec <= 0 { return c }
+        if sec > timeoutSec { log.Printf("warning: %s.request_timeout_sec (%ds) exceeds server.request_timeout_sec (%ds); the server value applies", name, sec, timeoutSec) }
+        return c.WithRequestTimeout(time.Duration(sec) * time.Second)
+    }
+    // withRequestIDHeader makes a provider's calls carry the API request's ID.
+    withRequestIDHeader := func(c *httpx.Client, name string) *httpx.Client {
+        if name = strings.TrimSpace(name); name == "" { return c }
+        return c.WithRequestIDHeader(name)
+    }
+
+    skinstableClient := withRequestIDHeader(withTimeout("skinstable", clientFor("skinstable", clientOptions{ProxyURL: cfg.Skinstable.ProxyURL, MaxConnsPerHost: cfg.Skinstable.MaxConnsPerHost, Signing: httpx.Signing{Secret: cfg.Skinstable.SigningSecret, Header: cfg.Skinstable.SigningHeader, TimestampHeader: cfg.Skinstable.SigningTimestampHeader, Algorithm: cfg.Skinstable.SigningAlgorithm}}), cfg.Skinstable.RequestTimeoutSec), cfg.Skinstable.RequestIDHeader)
+
+    // Global price floor applied uniformly to every provider.
+    var minPrice money.Amount
+    if v := strings.TrimSpace(cfg.Server.MinPrice.String()); v != "" {
+        r, ok := new(big.Rat).SetString(v)
+        if !ok { log.Fatalf("config: invalid server.min_price %q", v) }
+        minPrice = money.FromRat(r, 0)
+    }
 
     var providers []provider.Provider
     if cfg.SteamDT.Enabled {
-        steam := steamdt.New(steamdt.Config{
-            Name:        "SteamDT",
-            URL:         cfg.SteamDT.Endpoint,
-            Method:      http.MethodPost,
-            Headers:     map[string]string{"Authorization": "Bearer " + cfg.SteamDT.APIKey},
-            Currency:    cfg.SteamDT.Currency,
-            SymbolMap:   map[string]string{},
-            IncludeBids: cfg.SteamDT.IncludeBids,
-            MaxItemsPerRequest: cfg.SteamDT.MaxItemsPerRequest,
-            MaxConcurrency:     cfg.SteamDT.MaxConcurrency,
-        }, httpClient)
-        var p provider.Provider = steam
-        // Prefer token bucket with burst if RPM is set, otherwise use min-interval
-        if cfg.SteamDT.MaxRequestsPerMinute > 0 {
-            rate := float64(cfg.SteamDT.MaxRequestsPerMinute) / 60.0
-            burst := cfg.SteamDT.Burst
-            if burst <= 0 { burst = 1 }
-            p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
-        } else if cfg.SteamDT.MinRequestIntervalSec > 0 {
-            interval := time.Duration(cfg.SteamDT.MinRequestIntervalSec) * time.Second
-            p = &ratelimit.MinInterval{P: p, Interval: interval}
+        steamClient := withRequestIDHeader(withTimeout("steamdt", clientFor("steamdt", clientOptions{ProxyURL: cfg.SteamDT.ProxyURL, MaxConnsPerHost: cfg.SteamDT.MaxConnsPerHost, Signing: httpx.Signing{Secret: cfg.SteamDT.SigningSecret, Header: cfg.SteamDT.SigningHeader, TimestampHeader: cfg.SteamDT.SigningTimestampHeader, Algorithm: cfg.SteamDT.SigningAlgorithm}}), cfg.SteamDT.RequestTimeoutSec), cfg.SteamDT.RequestIDHeader)
+        newSteam := func(apiKey string) provider.Provider {
+            return steamdt.New(steamdt.Config{
+                Name:        "SteamDT",
+                URL:         cfg.SteamDT.Endpoint,
+                URLs:        cfg.SteamDT.Endpoints,
+                Method:      http.MethodPost,
+                Headers:     map[string]string{"Authorization": "Bearer " + apiKey},
+                Currency:    cfg.SteamDT.Currency,
+                SymbolMap:   map[string]string{},
+                IncludeBids: cfg.SteamDT.IncludeBids,
+                MaxItemsPerRequest: cfg.SteamDT.MaxItemsPerRequest,
+                MaxConcurrency:     cfg.SteamDT.MaxConcurrency,
+                BatchMemoTTL:       time.Duration(cfg.SteamDT.BatchMemoTTLMs) * time.Millisecond,
+                MinBatchTime:       time.Duration(cfg.SteamDT.MinBatchTimeMs) * time.Millisecond,
+                StrictErrors:       cfg.SteamDT.StrictErrors,
+                IncludePlatformItemID: cfg.SteamDT.IncludePlatformItemID,
+            }, steamClient)
+        }
+        wo := wrapOptions{
+            RPM:             cfg.SteamDT.MaxRequestsPerMinute,
+            Burst:           cfg.SteamDT.Burst,
+            StartEmpty:      cfg.SteamDT.StartEmpty,
+            MinIntervalSec:  cfg.SteamDT.MinRequestIntervalSec,
+            CacheTTLSec:     cfg.SteamDT.CacheTTLSeconds,
+            CacheMaxItems:   cfg.SteamDT.CacheMaxItems,
+            CacheMaxTTLSec:  cfg.SteamDT.CacheMaxTTLSeconds,
+            NegativeTTLSec:  cfg.SteamDT.CacheNegativeTTLSeconds,
+            ServeStaleSec:   cfg.SteamDT.ServeStaleDuringOutageSec,
+            ChunkSize:       cfg.SteamDT.CacheChunkSize,
+            ChunkConcurrency: cfg.SteamDT.CacheChunkConcurrency,
+            RefreshWaitMs:   cfg.SteamDT.CacheRefreshWaitMs,
+            Shared:          shared,
+            HedgeDelayMs:    cfg.SteamDT.HedgeDelayMs,
+            RetryAttempts:   cfg.SteamDT.RetryAttempts,
+            RetryBackoff:    cfg.SteamDT.RetryBackoff,
+            DegradeAfter:    cfg.SteamDT.DegradeAfterFailures,
+            DegradeProbeSec: cfg.SteamDT.DegradeProbeSec,
+            SymbolDenylist:  cfg.SteamDT.SymbolDenylist,
+            SymbolAllowlist: cfg.SteamDT.SymbolAllowlist,
+            Aliases:         aliasesFor("steamdt", cfg.Server.SymbolAliases),
+            SuppressZero:    cfg.Server.SuppressZero,
+            MinPrice:        minPrice,
         }
-        // Wrap with per-symbol cache if configured
-        if cfg.SteamDT.CacheTTLSeconds > 0 {
-            p = &cache.Provider{P: p, TTL: time.Duration(cfg.SteamDT.CacheTTLSeconds) * time.Second, MaxItems: cfg.SteamDT.CacheMaxItems}
+        if len(cfg.SteamDT.KeyPool) == 0 {
+            providers = append(providers, wrapProvider(newSteam(cfg.SteamDT.APIKey), wo))
+        } else {
+            // One instance per key, each behind its own limiter; the balancer
+            // sends every call to one of them, so their quotas add up.
+            keys := make([]string, 0, len(cfg.SteamDT.KeyPool))
+            for k := range cfg.SteamDT.KeyPool { keys = append(keys, k) }
+            sort.Strings(keys)
+            members := make([]balancer.Member, 0, len(keys))
+            for _, k := range keys {
+                members = append(members, balancer.Member{P: limitProvider(newSteam(k), wo), Weight: cfg.SteamDT.KeyPool[k]})
+            }
+            log.Printf("steamdt: balancing over %d API keys", len(members))
+            wo.RPM, wo.MinIntervalSec = 0, 0
+            providers = append(providers, wrapProvider(balancer.New("SteamDT", members...), wo))
         }
-        providers = append(providers, p)
     }
     if cfg.Pricempire.Enabled {
         if cfg.Pricempire.APIKey == "" {
             log.Println("warning: pricempire.enabled=true but PRICEMPIRE_API_KEY not set; skipping")
         } else {
-            peClient, err := pricempirepkg.NewPricempireAPIClient(
-                cfg.Pricempire.APIKey,
-                pricempirepkg.WithHTTPClient(httpClient.HTTP),
-                pricempirepkg.WithHeader(http.Header{
-                    "User-Agent": []string{"price-provider/1.0"},
-                }),
-            )
+            peHTTP := withRequestIDHeader(withTimeout("pricempire", clientFor("pricempire", clientOptions{ProxyURL: cfg.Pricempire.ProxyURL, MaxConnsPerHost: cfg.Pricempire.MaxConnsPerHost, Signing: httpx.Signing{Secret: cfg.Pricempire.SigningSecret, Header: cfg.Pricempire.SigningHeader, TimestampHeader: cfg.Pricempire.SigningTimestampHeader, Algorithm: cfg.Pricempire.SigningAlgorithm}}), cfg.Pricempire.RequestTimeoutSec), cfg.Pricempire.RequestIDHeader)
+            peClient, err := newPricempireClient(cfg.Pricempire, httpxDoer{peHTTP})
             if err != nil {
                 log.Printf("pricempire client error: %v", err)
             } else {
                 pe := pricempireadapter.New(pricempireadapter.Config{
                     Name:     "Pricempire",
                     AppID:    cfg.Pricempire.AppID,
+                    AppIDs:   cfg.Pricempire.AppIDs,
                     Currency: cfg.Pricempire.Currency,
                     Sources:  cfg.Pricempire.Sources,
                     ItemsCacheTTLSeconds: cfg.Pricempire.CacheTTLSeconds,
+                    EmitAvg30: cfg.Pricempire.EmitAvg30,
+                    CaseInsensitive: cfg.Pricempire.CaseInsensitive,
+                    SourceCurrency: cfg.Pricempire.SourceCurrency,
+                    APIVersion: cfg.Pricempire.APIVersion,
+                    PerSourceRequests: cfg.Pricempire.PerSourceRequests,
+                    MaxCachedItems: cfg.Pricempire.ItemsCacheMaxItems,
                 }, peClient)
-                var p provider.Provider = pe
-                if cfg.Pricempire.MaxRequestsPerMinute > 0 {
-                    rate := float64(cfg.Pricempire.MaxRequestsPerMinute) / 60.0
-                    burst := cfg.Pricempire.Burst
-                    if burst <= 0 { burst = 1 }
-                    p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
-                } else if cfg.Pricempire.MinRequestIntervalSec > 0 {
-                    interval := time.Duration(cfg.Pricempire.MinRequestIntervalSec) * time.Second
-                    p = &ratelimit.MinInterval{P: p, Interval: interval}
-                }
-                if cfg.Pricempire.CacheTTLSeconds > 0 {
-                    p = &cache.Provider{P: p, TTL: time.Duration(cfg.Pricempire.CacheTTLSeconds) * time.Second, MaxItems: cfg.Pricempire.CacheMaxItems}
-                }
-                providers = append(providers, p)
+                providers = append(providers, wrapProvider(pe, wrapOptions{
+                    RPM:             cfg.Pricempire.MaxRequestsPerMinute,
+                    Burst:           cfg.Pricempire.Burst,
+                    StartEmpty:      cfg.Pricempire.StartEmpty,
+                    MinIntervalSec:  cfg.Pricempire.MinRequestIntervalSec,
+                    CacheTTLSec:     cfg.Pricempire.CacheTTLSeconds,
+                    CacheMaxItems:   cfg.Pricempire.CacheMaxItems,
+                    CacheMaxTTLSec:  cfg.Pricempire.CacheMaxTTLSeconds,
+                    NegativeTTLSec:  cfg.Pricempire.CacheNegativeTTLSeconds,
+                    ServeStaleSec:   cfg.Pricempire.ServeStaleDuringOutageSec,
+                    ChunkSize:       cfg.Pricempire.CacheChunkSize,
+                    ChunkConcurrency: cfg.Pricempire.CacheChunkConcurrency,
+                    RefreshWaitMs:   cfg.Pricempire.CacheRefreshWaitMs,
+                    Shared:          shared,
+                    HedgeDelayMs:    cfg.Pricempire.HedgeDelayMs,
+                    RetryAttempts:   cfg.Pricempire.RetryAttempts,
+                    DegradeAfter:    cfg.Pricempire.DegradeAfterFailures,
+                    DegradeProbeSec: cfg.Pricempire.DegradeProbeSec,
+                    SymbolDenylist:  cfg.Pricempire.SymbolDenylist,
+                    SymbolAllowlist: cfg.Pricempire.SymbolAllowlist,
+                    Aliases:         aliasesFor("pricempire", cfg.Server.SymbolAliases),
+                    SuppressZero:    cfg.Server.SuppressZero,
+                    MinPrice:        minPrice,
+                }))
             }
         }
     }
@@ -130,47 +371,76 @@ func main() {
         if cfg.Skinstable.Endpoint == "" {
             log.Println("warning: skinstable.enabled=true but endpoint not set; skipping")
         } else {
+            skinstableSites = cfg.Skinstable.Sites
+            if len(skinstableSites) == 0 { skinstableSites = []string{"CS.MONEY"} }
             stx := skinstablexyz.New(skinstablexyz.Config{
-                Name:                "SkinstableXYZ",
+                Name:                skinstableName,
                 URL:                 cfg.Skinstable.Endpoint,
                 Currency:            cfg.Skinstable.Currency,
                 APIKey:              cfg.Skinstable.APIKey,
                 AppID:               cfg.Skinstable.AppID,
+                AppIDs:              cfg.Skinstable.AppIDs,
                 Sites:               cfg.Skinstable.Sites,
                 ItemsCacheTTLSeconds: cfg.Skinstable.ItemsCacheTTLSeconds,
-            }, httpClient)
-            var p provider.Provider = stx
-            if cfg.Skinstable.MaxRequestsPerMinute > 0 {
-                rate := float64(cfg.Skinstable.MaxRequestsPerMinute) / 60.0
-                burst := cfg.Skinstable.Burst
-                if burst <= 0 { burst = 1 }
-                p = &ratelimit.TokenBucketProvider{P: p, TB: ratelimit.NewTokenBucket(rate, burst)}
-            } else if cfg.Skinstable.MinRequestIntervalSec > 0 {
-                interval := time.Duration(cfg.Skinstable.MinRequestIntervalSec) * time.Second
-                p = &ratelimit.MinInterval{P: p, Interval: interval}
-            }
-            if cfg.Skinstable.CacheTTLSeconds > 0 {
-                p = &cache.Provider{P: p, TTL: time.Duration(cfg.Skinstable.CacheTTLSeconds) * time.Second, MaxItems: cfg.Skinstable.CacheMaxItems}
-            }
-            providers = append(providers, p)
+                PageSize:            cfg.Skinstable.PageSize,
+                IncludeBids:         cfg.Skinstable.IncludeBids,
+                SiteTimeoutSeconds:  cfg.Skinstable.SiteTimeoutSeconds,
+                SiteRetries:         cfg.Skinstable.SiteRetries,
+                StaleGraceSeconds:   cfg.Skinstable.StaleGraceSeconds,
+                Method:              cfg.Skinstable.Method,
+                ParamNames:          cfg.Skinstable.ParamNames,
+            }, skinstableClient)
+            providers = append(providers, wrapProvider(stx, wrapOptions{
+                RPM:             cfg.Skinstable.MaxRequestsPerMinute,
+                Burst:           cfg.Skinstable.Burst,
+                StartEmpty:      cfg.Skinstable.StartEmpty,
+                MinIntervalSec:  cfg.Skinstable.MinRequestIntervalSec,
+                CacheTTLSec:     cfg.Skinstable.CacheTTLSeconds,
+                CacheMaxItems:   cfg.Skinstable.CacheMaxItems,
+                CacheMaxTTLSec:  cfg.Skinstable.CacheMaxTTLSeconds,
+                NegativeTTLSec:  cfg.Skinstable.CacheNegativeTTLSeconds,
+                ServeStaleSec:   cfg.Skinstable.ServeStaleDuringOutageSec,
+                ChunkSize:       cfg.Skinstable.CacheChunkSize,
+                ChunkConcurrency: cfg.Skinstable.CacheChunkConcurrency,
+                RefreshWaitMs:   cfg.Skinstable.CacheRefreshWaitMs,
+                Shared:          shared,
+                HedgeDelayMs:    cfg.Skinstable.HedgeDelayMs,
+                RetryAttempts:   cfg.Skinstable.RetryAttempts,
+                DegradeAfter:    cfg.Skinstable.DegradeAfterFailures,
+                DegradeProbeSec: cfg.Skinstable.DegradeProbeSec,
+                SymbolDenylist:  cfg.Skinstable.SymbolDenylist,
+                SymbolAllowlist: cfg.Skinstable.SymbolAllowlist,
+                Aliases:         aliasesFor("skinstable", cfg.Server.SymbolAliases),
+                SuppressZero:    cfg.Server.SuppressZero,
+                MinPrice:        minPrice,
+            }))
         }
     }
 
+    var idem *idempotencyStore
+    if cfg.Server.IdempotencyTTLSec > 0 {
+        idem = newIdempotencyStore(time.Duration(cfg.Server.IdempotencyTTLSec)*time.Second, cfg.Server.IdempotencyMaxKeys)
+    }
+    postQuotes := withIdempotency(idem, func(w http.ResponseWriter, r *http.Request) { handlePostQuotes(w, r, providers) })
+    // Bounds concurrent /api/quotes fan-outs; extra requests queue briefly, then get 503.
+    admit := newAdmission(cfg.Server.MaxInFlight, time.Duration(cfg.Server.AdmissionWaitMs)*time.Millisecond)
+
+    keys, err := newAPIKeys(cfg.Auth)
+    if err != nil { log.Fatalf("config: %v", err) }
+
     mux := http.NewServeMux()
-    mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-        w.WriteHeader(http.StatusOK)
-        _, _ = w.Write([]byte("ok"))
-    })
-    mux.HandleFunc("/api/quotes", func(w http.ResponseWriter, r *http.Request) {
+    mux.HandleFunc("/healthz", handleHealthz)
+    mux.HandleFunc("/readyz", handleReadyz)
+    mux.HandleFunc("/api/quotes", withAdmission(admit, func(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
             handleGetQuotes(w, r, providers)
         case http.MethodPost:
-            handlePostQuotes(w, r, providers)
+            postQuotes(w, r)
         default:
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            writeError(w, "method not allowed", http.StatusMethodNotAllowed)
         }
-    })
+    }))
     mux.HandleFunc("/api/latest", func(w http.ResponseWriter, r *http.Request) {
         switch r.Method {
         case http.MethodGet:
@@ -178,61 +448,25 @@ func main() {
         case http.MethodPost:
             handlePostLatest(w, r, providers)
         default:
-            http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+            writeError(w, "method not allowed", http.StatusMethodNotAllowed)
         }
     })
     // Expose all item names from Skinstable (for bulk testing in the UI).
-    mux.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {
-        if !cfg.Skinstable.Enabled {
-            http.Error(w, "skinstable disabled", http.StatusBadRequest)
-            return
-        }
-        sitesParam := strings.TrimSpace(r.URL.Query().Get("sites"))
-        var sites []string
-        if sitesParam != "" {
-            sites = splitCSV(sitesParam)
-        } else {
-            sites = append([]string(nil), cfg.Skinstable.Sites...)
-        }
-        if len(sites) == 0 { sites = []string{"CS.MONEY"} }
-        type apiResp struct { Items map[string]struct{ N string `json:"n"` } `json:"items"` }
-        ctx, cancel := context.WithTimeout(r.Context(), time.Duration(apiTimeoutSec)*time.Second)
-        defer cancel()
-        names := make(map[string]struct{}, 64000)
-        for _, site := range sites {
-            u := cfg.Skinstable.Endpoint
-            if strings.TrimSpace(u) == "" { http.Error(w, "skinstable endpoint missing", http.StatusBadRequest); return }
-            req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-            if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
-            q := req.URL.Query()
-            if cfg.Skinstable.APIKey != "" { q.Set("apikey", cfg.Skinstable.APIKey) }
-            if cfg.Skinstable.AppID > 0 { q.Set("app", fmt.Sprintf("%d", cfg.Skinstable.AppID)) }
-            if site != "" { q.Set("site", site) }
-            req.URL.RawQuery = q.Encode()
-            req.Header.Set("Accept", "application/json")
-            resp, err := httpClient.Do(ctx, req)
-            if err != nil { http.Error(w, err.Error(), http.StatusBadGateway); return }
-            func() {
-                defer resp.Body.Close()
-                if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-                    http.Error(w, fmt.Sprintf("upstream %s -> %d", req.URL.String(), resp.StatusCode), http.StatusBadGateway)
-                    return
-                }
-                var body apiResp
-                dec := json.NewDecoder(resp.Body)
-                if err := dec.Decode(&body); err != nil { http.Error(w, err.Error(), http.StatusBadGateway); return }
-                for k := range body.Items { if strings.TrimSpace(k) != "" { names[k] = struct{}{} } }
-            }()
-        }
-        list := make([]string, 0, len(names))
-        for k := range names { list = append(list, k) }
-        // sort for stable output
-        sort.Strings(list)
-        w.WriteHeader(http.StatusOK)
-        enc := json.NewEncoder(w)
-        enc.SetEscapeHTML(false)
-        _ = enc.Encode(struct { Items []string `json:"items"` }{Items: list})
-    })
+    mux.HandleFunc("/api/items", handleItems(cfg.Skinstable, skinstableClient))
+
+    // Price deltas since the previous observation (in-memory, opt-in).
+    if cfg.Server.TrackChanges {
+        mux.HandleFunc("GET /api/changes", handleChanges(aggregate.NewChangeTracker(maxTrackedChanges), providers))
+    }
+
+    // Symbol discovery over the Pricempire item names.
+    mux.HandleFunc("GET /api/search", handleSearch(providers))
+
+    // Upstream limiter status per provider.
+    mux.HandleFunc("GET /debug/ratelimit", handleDebugRateLimit(providers))
+
+    // Runtime kill switch per provider (in-memory only).
+    registerAdmin(mux, cfg.Server.AdminToken, providers)
 
     // Static website for quick manual verification (served from ./web)
     // Registered last so that /api/* routes take precedence.
@@ -240,23 +474,60 @@ func main() {
 
     srv := &http.Server{
         Addr:              ":" + port,
-        Handler:           withJSONHeaders(withGzip(recoverPanic(limitBody(mux)))),
+        Handler:           withJSONHeaders(withGzip(withRequestID(withRequestLog(recoverPanic(limitBody(withAPIKeys(keys, withKnownParams(mux)))))), gzipOptions{Level: cfg.Server.GzipLevel, MinSize: cfg.Server.GzipMinBytes})),
         ReadHeaderTimeout: 5 * time.Second,
         ReadTimeout:       15 * time.Second,
         WriteTimeout:      20 * time.Second,
         IdleTimeout:       60 * time.Second,
     }
 
+    // graceful shutdown
+    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+    defer stop()
+
+    if l := cfg.Server.GzipLevel; l != 0 && (l < gzip.BestSpeed || l > gzip.BestCompression) {
+        log.Fatalf("invalid server.gzip_level %d (1..9)", l)
+    }
+
+    // Terminate TLS directly when both cert and key are configured.
+    certFile, keyFile := strings.TrimSpace(cfg.Server.TLSCertFile), strings.TrimSpace(cfg.Server.TLSKeyFile)
+    if (certFile == "") != (keyFile == "") {
+        log.Fatalf("server.tls_cert_file and server.tls_key_file must be set together")
+    }
+    if certFile != "" {
+        certs, err := newCertReloader(certFile, keyFile)
+        if err != nil { log.Fatalf("tls: %v", err) }
+        srv.TLSConfig = certs.TLSConfig()
+        certs.reloadOnSIGHUP(ctx)
+    }
+
     go func() {
-        log.Printf("server listening on :%s", port)
-        if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+        var err error
+        if srv.TLSConfig != nil {
+            log.Printf("server listening on :%s (https)", port)
+            err = srv.ListenAndServeTLS("", "")
+        } else {
+            log.Printf("server listening on :%s", port)
+            err = srv.ListenAndServe()
+        }
+        if err != nil && err != http.ErrServerClosed {
             log.Fatalf("server: %v", err)
         }
     }()
 
-    // graceful shutdown
-    ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-    defer stop()
+    // Warm provider caches; /readyz reports 503 until this finishes.
+    warm, err := loadWarmupSymbols(cfg.Server.WarmupSymbols, cfg.Server.WarmupFile)
+    if err != nil { log.Fatalf("warmup: %v", err) }
+    if len(warm) == 0 {
+        ready.Store(true)
+    } else {
+        go func() {
+            start := time.Now()
+            warmup(ctx, providers, warm)
+            log.Printf("warmup done: %d symbols in %s", len(warm), time.Since(start).Round(time.Millisecond))
+            ready.Store(true)
+        }()
+    }
 
     // Start push ticker if configured
     if cfg.Push.Enabled && strings.TrimSpace(cfg.Push.URL) != "" && len(cfg.Push.Symbols) > 0 {
@@ -271,7 +542,7 @@ func main() {
                 case <-ctx.Done():
                     return
                 case <-t.C:
-                    pctx, cancel := context.WithTimeout(ctx, time.Duration(apiTimeoutSec)*time.Second)
+                    pctx, cancel := context.WithTimeout(ctx, requestDeadline)
                     // collect quotes
                     qs, _ := collectQuotes(pctx, providers, cfg.Push.Symbols)
                     cancel()
@@ -320,20 +591,263 @@ func main() {
     shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
     defer cancel()
     _ = srv.Shutdown(shutdownCtx)
+    if quoteSink != nil { _ = quoteSink.Close() }
+}
+
+// clientOptions are the per-provider HTTP client settings.
+type clientOptions struct {
+    ProxyURL        string
+    MaxConnsPerHost int
+    Signing         httpx.Signing
+}
+
+// newUpstreamClient builds the HTTP client used for provider calls, applying
+// the optional egress proxy, per-host connection limit, request signing and
+// the debug fixtures/dump settings.
+func newUpstreamClient(cfg config.Config, timeoutSec int, proxy *url.URL, o clientOptions) (*httpx.Client, error) {
+    c := httpx.New(time.Duration(timeoutSec) * time.Second)
+    c.UserAgent = "price-provider/1.0"
+    if proxy != nil {
+        if err := c.SetProxy(proxy); err != nil { return nil, err }
+    }
+    if o.MaxConnsPerHost > 0 {
+        if err := c.SetMaxConnsPerHost(o.MaxConnsPerHost); err != nil { return nil, err }
+    }
+    if o.Signing.Secret != "" {
+        if err := c.EnableSigning(o.Signing); err != nil { return nil, err }
+    }
+    if dir := strings.TrimSpace(cfg.Debug.FixturesDir); dir != "" {
+        if err := c.UseFixtures(dir); err != nil { return nil, fmt.Errorf("debug: %w", err) }
+    }
+    if cfg.Debug.DumpHTTP { c.EnableDump(cfg.Debug.DumpBodyBytes) }
+    return c, nil
+}
+
+// providerClient returns the HTTP client for one provider: shared, unless the
+// provider egresses through a proxy, sets max_conns_per_host or signs its
+// requests, in which case it gets its own client so its settings never affect
+// the others.
+func providerClient(cfg config.Config, timeoutSec int, shared *httpx.Client, name string, o clientOptions) (*httpx.Client, error) {
+    proxy := strings.TrimSpace(o.ProxyURL)
+    if proxy == "" && o.MaxConnsPerHost <= 0 && o.Signing.Secret == "" { return shared, nil }
+    var u *url.URL
+    if proxy != "" {
+        var err error
+        if u, err = httpx.ParseProxyURL(proxy); err != nil { return nil, err }
+    }
+    c, err := newUpstreamClient(cfg, timeoutSec, u, o)
+    if err != nil { return nil, err }
+    if u != nil { log.Printf("%s: using proxy %s", name, httpx.RedactURL(u)) }
+    if o.MaxConnsPerHost > 0 { log.Printf("%s: at most %d connections per host", name, o.MaxConnsPerHost) }
+    if o.Signing.Secret != "" { log.Printf("%s: signing requests", name) }
+    return c, nil
+}
+
+// httpxDoer adapts an httpx.Client to clients that call Do(req), like
+// Pricempire's, so their requests get its timeout and request-ID header.
+type httpxDoer struct{ c *httpx.Client }
+
+func (d httpxDoer) Do(req *http.Request) (*http.Response, error) { return d.c.Do(req.Context(), req) }
+
+// newPricempireClient builds the Pricempire API client from config.
+// pricempire.base_url, when set, replaces the public API (e.g., a staging mock).
+func newPricempireClient(c config.Pricempire, hc pricempirepkg.HTTPClient) (*pricempirepkg.PricempireAPIClient, error) {
+    opts := []pricempirepkg.PricempireAPIClientOption{
+        pricempirepkg.WithHTTPClient(hc),
+        pricempirepkg.WithHeader(http.Header{
+            "User-Agent": []string{"price-provider/1.0"},
+        }),
+        pricempirepkg.WithSkipMalformed(c.SkipMalformed),
+    }
+    if u := strings.TrimRight(strings.TrimSpace(c.BaseURL), "/"); u != "" {
+        opts = append(opts, pricempirepkg.WithBaseURL(u))
+    }
+    return pricempirepkg.NewPricempireAPIClient(c.APIKey, opts...)
+}
+
+// wrapOptions holds the wrapper settings shared by every upstream provider.
+type wrapOptions struct {
+    RPM             int
+    Burst           int
+    StartEmpty      bool
+    MinIntervalSec  int
+    CacheTTLSec     int
+    CacheMaxItems   int
+    CacheMaxTTLSec  int
+    NegativeTTLSec  int
+    ServeStaleSec   int
+    ChunkSize       int
+    ChunkConcurrency int
+    RefreshWaitMs   int
+    Shared          cache.Store
+    HedgeDelayMs    int
+    RetryAttempts   int
+    RetryBackoff    string
+    DegradeAfter    int
+    DegradeProbeSec int
+    SymbolDenylist  []string
+    SymbolAllowlist []string
+    Aliases         map[string]string
+    SuppressZero    bool
+    MinPrice        money.Amount
+}
+
+// wrapProvider layers duplicate removal, price filtering, hedging, rate limiting, retries, health
+// degradation, caching, symbol aliasing, symbol filtering and timing around p (inside out). Hedging sits below the
+// limiter so a hedged attempt never costs an extra token; a degraded provider
+// sits above it so skipped calls do not either.
+func wrapProvider(p provider.Provider, o wrapOptions) provider.Provider {
+    p = &dedup.Provider{P: p}
+    if o.SuppressZero || o.MinPrice.Valid() {
+        p = &filter.Provider{P: p, SuppressZero: o.SuppressZero, MinPrice: o.MinPrice}
+    }
+    if o.HedgeDelayMs > 0 {
+        p = &hedge.Provider{P: p, Delay: time.Duration(o.HedgeDelayMs) * time.Millisecond}
+    }
+    p = limitProvider(p, o)
+    // Above the limiter so each retry pays for a token; below health so only
+    // the final outcome counts as a failure.
+    if o.RetryAttempts > 0 {
+        p = &retry.Provider{P: p, Attempts: o.RetryAttempts, Strategy: o.RetryBackoff}
+    }
+    if o.DegradeAfter > 0 {
+        probe := time.Duration(o.DegradeProbeSec) * time.Second
+        if probe <= 0 { probe = 30 * time.Second }
+        p = &health.Provider{P: p, Threshold: o.DegradeAfter, ProbeInterval: probe}
+    }
+    // Wrap with per-symbol cache if configured
+    if o.CacheTTLSec > 0 {
+        p = &cache.Provider{P: p, TTL: time.Duration(o.CacheTTLSec) * time.Second, MaxItems: o.CacheMaxItems, MaxTTL: time.Duration(o.CacheMaxTTLSec) * time.Second, NegativeTTL: time.Duration(o.NegativeTTLSec) * time.Second, ServeStale: time.Duration(o.ServeStaleSec) * time.Second, ChunkSize: o.ChunkSize, ChunkConcurrency: o.ChunkConcurrency, RefreshWait: time.Duration(o.RefreshWaitMs) * time.Millisecond, Shared: o.Shared}
+    }
+    // Above the cache so entries are kept under the upstream's names; below
+    // the symbol filter so deny and allow lists use canonical ones.
+    if len(o.Aliases) > 0 {
+        p = &alias.Provider{P: p, Names: o.Aliases}
+    }
+    if len(o.SymbolDenylist) > 0 || len(o.SymbolAllowlist) > 0 {
+        p = filter.NewSymbols(p, o.SymbolDenylist, o.SymbolAllowlist)
+    }
+    // Outermost, so the recorded time is what the fan-out actually waited.
+    return &timing.Provider{P: p}
+}
+
+// aliasesFor picks one provider's names out of server.symbol_aliases
+// (canonical symbol -> provider section -> name); nil when it has none.
+func aliasesFor(section string, all map[string]map[string]string) map[string]string {
+    var out map[string]string
+    for canonical, byProvider := range all {
+        for p, name := range byProvider {
+            if !strings.EqualFold(strings.TrimSpace(p), section) || name == "" || name == canonical { continue }
+            if out == nil { out = make(map[string]string) }
+            out[canonical] = name
+        }
+    }
+    return out
+}
+
+// limitProvider wraps p in the rate limiter o asks for, if any.
+func limitProvider(p provider.Provider, o wrapOptions) provider.Provider {
+    // Prefer token bucket with burst if RPM is set, otherwise use min-interval
+    if o.RPM > 0 {
+        rate := float64(o.RPM) / 60.0
+        burst := o.Burst
+        if burst <= 0 { burst = 1 }
+        tb := ratelimit.NewTokenBucket(rate, burst)
+        if o.StartEmpty { tb.WithTokens(0) }
+        return &ratelimit.TokenBucketProvider{P: p, TB: tb}
+    } else if o.MinIntervalSec > 0 {
+        interval := time.Duration(o.MinIntervalSec) * time.Second
+        return &ratelimit.MinInterval{P: p, Interval: interval}
+    }
+    return p
 }
 
 func handleGetQuotes(w http.ResponseWriter, r *http.Request, providers []provider.Provider) {
     q := r.URL.Query().Get("symbols")
     if strings.TrimSpace(q) == "" {
-        http.Error(w, "missing symbols query param", http.StatusBadRequest)
+        writeError(w, "missing symbols query param", http.StatusBadRequest)
         return
     }
     symbols := splitCSV(q)
-    if len(symbols) > 1000 {
-        http.Error(w, "too many symbols (max 1000)", http.StatusBadRequest)
+    if !checkSymbolCap(w, r, len(symbols)) { return }
+    opts, err := parseQuotesOptions(r)
+    if err != nil {
+        writeError(w, err.Error(), http.StatusBadRequest)
         return
     }
-    writeQuotes(w, r.Context(), providers, symbols)
+    writeQuotes(w, r.Context(), providers, symbols, opts)
+}
+
+// quotesOptions are the /api/quotes query parameters that shape the response.
+type quotesOptions struct {
+    formatOptions
+    // Group selects an alternate response shape; "symbol" returns bySymbol.
+    Group string
+    // Collapse keeps only the freshest quote per symbol/market/side across providers.
+    Collapse bool
+    // ReportMissing adds the requested symbols that produced no quotes.
+    ReportMissing bool
+    // MaxAge makes caches refetch entries older than this, even within TTL.
+    MaxAge time.Duration
+    // Prefer ranks providers (best first); per symbol/market/currency only
+    // the best-ranked provider's quotes are kept.
+    Prefer []string
+    // ChangedSince, when set, keeps only quotes received after it.
+    ChangedSince time.Time
+    // Sites narrows SkinstableXYZ to these of its configured sites.
+    Sites []string
+    // Fastest (mode=fastest) answers as soon as every requested symbol has a
+    // quote, canceling the providers still running.
+    Fastest bool
+}
+
+func parseQuotesOptions(r *http.Request) (quotesOptions, error) {
+    var o quotesOptions
+    f, err := parseFormatOptions(r)
+    if err != nil { return o, err }
+    if f.Fields, err = parseFieldList(r.URL.Query().Get("fields"), quoteFields); err != nil { return o, err }
+    o.formatOptions = f
+    o.Group = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("group")))
+    switch o.Group {
+    case "", "symbol":
+    default:
+        return o, fmt.Errorf("invalid group (symbol)")
+    }
+    if o.Group != "" && o.Protobuf { return o, fmt.Errorf("group is not available as protobuf") }
+    switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("collapse"))) {
+    case "", "0", "false", "no", "n":
+    case "1", "true", "yes", "y":
+        o.Collapse = true
+    default:
+        return o, fmt.Errorf("invalid collapse (true|false)")
+    }
+    switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("report_missing"))) {
+    case "", "0", "false", "no", "n":
+    case "1", "true", "yes", "y":
+        o.ReportMissing = true
+    default:
+        return o, fmt.Errorf("invalid report_missing (true|false)")
+    }
+    if v := strings.TrimSpace(r.URL.Query().Get("max_age_sec")); v != "" {
+        var n int
+        if _, err := fmt.Sscanf(v, "%d", &n); err != nil || n <= 0 { return o, fmt.Errorf("invalid max_age_sec (positive integer)") }
+        o.MaxAge = time.Duration(n) * time.Second
+    }
+    if v := strings.TrimSpace(r.URL.Query().Get("prefer")); v != "" { o.Prefer = splitCSV(v) }
+    if v := strings.TrimSpace(r.URL.Query().Get("changed_since")); v != "" {
+        if o.ChangedSince, err = time.Parse(time.RFC3339Nano, v); err != nil { return o, fmt.Errorf("invalid changed_since (RFC 3339 timestamp)") }
+    }
+    if v := strings.TrimSpace(r.URL.Query().Get("sites")); v != "" {
+        if o.Sites, err = parseSites(v); err != nil { return o, err }
+    }
+    switch strings.ToLower(strings.TrimSpace(r.URL.Query().Get("mode"))) {
+    case "", "all":
+    case "fastest":
+        o.Fastest = true
+    default:
+        return o, fmt.Errorf("invalid mode (all|fastest)")
+    }
+    return o, nil
 }
 
 type postBody struct {
@@ -345,55 +859,191 @@ func handlePostQuotes(w http.ResponseWriter, r *http.Request, providers []provid
     dec := json.NewDecoder(r.Body)
     dec.DisallowUnknownFields()
     if err := dec.Decode(&b); err != nil {
-        http.Error(w, "invalid JSON body", http.StatusBadRequest)
+        writeError(w, "invalid JSON body", http.StatusBadRequest)
         return
     }
     if len(b.Symbols) == 0 {
-        http.Error(w, "symbols cannot be empty", http.StatusBadRequest)
+        writeError(w, "symbols cannot be empty", http.StatusBadRequest)
         return
     }
-    if len(b.Symbols) > 1000 {
-        http.Error(w, "too many symbols (max 1000)", http.StatusBadRequest)
+    if !checkSymbolCap(w, r, len(b.Symbols)) { return }
+    opts, err := parseQuotesOptions(r)
+    if err != nil {
+        writeError(w, err.Error(), http.StatusBadRequest)
         return
     }
-    writeQuotes(w, r.Context(), providers, b.Symbols)
+    writeQuotes(w, r.Context(), providers, b.Symbols, opts)
 }
 
-func writeQuotes(w http.ResponseWriter, rctx context.Context, providers []provider.Provider, symbols []string) {
-    ctx, cancel := context.WithTimeout(rctx, time.Duration(apiTimeoutSec)*time.Second)
+func writeQuotes(w http.ResponseWriter, rctx context.Context, providers []provider.Provider, symbols []string, opts quotesOptions) {
+    ctx, cancel := context.WithTimeout(rctx, requestDeadline)
     defer cancel()
-    all, errs := collectQuotes(ctx, providers, symbols)
+    ctx, rec := timing.WithRecorder(withSites(cache.WithMaxAge(ctx, opts.MaxAge), opts.Sites))
+    all, errs := fanOut(ctx, providers, symbols, opts.Fastest)
     if len(all) == 0 && len(errs) > 0 {
-        msgs := make([]string, 0, len(errs))
-        for _, e := range errs { msgs = append(msgs, e.Error()) }
-        http.Error(w, strings.Join(msgs, "; "), http.StatusBadGateway)
+        writeUpstreamError(w, ctx, errs)
+        return
+    }
+    publishQuotes(ctx, all)
+    if len(opts.Prefer) > 0 { all = aggregate.PreferProviders(all, opts.Prefer) }
+    if opts.Collapse { all = aggregate.FreshestByMarket(all) }
+    // unchanged quotes were still found, so they do not count as missing
+    fetched := all
+    if !opts.ChangedSince.IsZero() { all = receivedAfter(all, opts.ChangedSince) }
+    qr := quotesResponse{Quotes: all, Meta: newResponseMeta(rec, all, opts.formatOptions), format: opts.formatOptions}
+    if opts.ReportMissing { qr.Missing = missingSymbols(symbols, fetched) }
+    var resp any = qr
+    if opts.Group == "symbol" {
+        g := groupBySymbol(all, symbols, opts.formatOptions)
+        g.Meta = qr.Meta
+        resp = g
+    }
+    if opts.Protobuf {
+        pb := &quotepb.QuotesResponse{Quotes: quotepb.FromQuotes(qr.Quotes), Missing: qr.Missing}
+        if m := qr.Meta; m != nil {
+            pb.ProviderTimingsMs, pb.PartialErrors, pb.Stale = m.ProviderTimingsMs, m.PartialErrors, m.Stale
+            if m.OldestReceivedAt != nil { pb.OldestReceivedAt, pb.NewestReceivedAt = quotepb.Timestamp(*m.OldestReceivedAt), quotepb.Timestamp(*m.NewestReceivedAt) }
+        }
+        b, err := quotepb.Marshal(pb)
+        if err != nil {
+            writeError(w, "encode protobuf: "+err.Error(), http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("Content-Type", quotepb.ContentType)
+        w.WriteHeader(http.StatusOK)
+        _, _ = w.Write(b)
+        return
+    }
+    if opts.XML {
+        writeXML(w, resp)
         return
     }
-    resp := quotesResponse{Quotes: all}
     w.WriteHeader(http.StatusOK)
     enc := json.NewEncoder(w)
     enc.SetEscapeHTML(false)
     enc.Encode(resp)
 }
 
+type groupedQuotesResponse struct {
+    BySymbol map[string][]provider.Quote `json:"bySymbol"`
+    Meta     *responseMeta               `json:"meta,omitempty"`
+    format   formatOptions
+}
+
+func (r groupedQuotesResponse) MarshalJSON() ([]byte, error) {
+    by := make(map[string][]quoteJSON, len(r.BySymbol))
+    for s, qs := range r.BySymbol { by[s] = viewQuotes(qs, r.format) }
+    return marshalNoEscape(struct {
+        BySymbol map[string][]quoteJSON `json:"bySymbol"`
+        Meta     *responseMeta          `json:"meta,omitempty"`
+    }{BySymbol: by, Meta: r.Meta})
+}
+
+// receivedAfter returns the quotes received strictly after t, in order.
+// Quotes without a timestamp are dropped.
+func receivedAfter(quotes []provider.Quote, t time.Time) []provider.Quote {
+    out := make([]provider.Quote, 0, len(quotes))
+    for _, q := range quotes {
+        if q.ReceivedAt.After(t) { out = append(out, q) }
+    }
+    return out
+}
+
+// missingSymbols returns the requested symbols (deduplicated, in request
+// order) for which no quote was produced. It never returns nil.
+func missingSymbols(symbols []string, quotes []provider.Quote) []string {
+    have := make(map[string]struct{}, len(quotes))
+    for _, q := range quotes { have[q.Symbol] = struct{}{} }
+    out := []string{}
+    for _, s := range symbols {
+        if _, ok := have[s]; ok { continue }
+        have[s] = struct{}{}
+        out = append(out, s)
+    }
+    return out
+}
+
+// MarshalXML renders <quotesBySymbol><symbol name="..."><quote/>...</symbol></quotesBySymbol>,
+// with symbols in sorted order.
+func (r groupedQuotesResponse) MarshalXML(enc *xml.Encoder, _ xml.StartElement) error {
+    start := xml.StartElement{Name: xml.Name{Local: "quotesBySymbol"}}
+    if err := enc.EncodeToken(start); err != nil { return err }
+    syms := make([]string, 0, len(r.BySymbol))
+    for s := range r.BySymbol { syms = append(syms, s) }
+    sort.Strings(syms)
+    for _, s := range syms {
+        el := xml.StartElement{Name: xml.Name{Local: "symbol"}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: s}}}
+        if err := enc.EncodeToken(el); err != nil { return err }
+        for _, q := range r.BySymbol[s] {
+            if err := encodeXMLRow(enc, "quote", q, quoteFields, r.format); err != nil { return err }
+        }
+        if err := enc.EncodeToken(el.End()); err != nil { return err }
+    }
+    return enc.EncodeToken(start.End())
+}
+
+// writeXML writes resp as an XML document; the gzip middleware still applies.
+func writeXML(w http.ResponseWriter, resp any) {
+    w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+    w.WriteHeader(http.StatusOK)
+    _, _ = io.WriteString(w, xml.Header)
+    enc := xml.NewEncoder(w)
+    if err := enc.Encode(resp); err != nil { log.Printf("xml encode: %v", err) }
+}
+
+// groupBySymbol keys quotes by symbol. Every requested symbol is present,
+// with an empty array when no provider returned data for it.
+func groupBySymbol(quotes []provider.Quote, symbols []string, f formatOptions) groupedQuotesResponse {
+    by := make(map[string][]provider.Quote, len(symbols))
+    for _, s := range symbols { by[s] = []provider.Quote{} }
+    for _, q := range quotes { by[q.Symbol] = append(by[q.Symbol], q) }
+    return groupedQuotesResponse{BySymbol: by, format: f}
+}
+
 // handleGetLatest parses query params and returns latest quotes by market.
 func handleGetLatest(w http.ResponseWriter, r *http.Request, providers []provider.Provider) {
     q := r.URL.Query().Get("symbols")
     if strings.TrimSpace(q) == "" {
-        http.Error(w, "missing symbols query param", http.StatusBadRequest)
+        writeError(w, "missing symbols query param", http.StatusBadRequest)
         return
     }
     symbols := splitCSV(q)
-    if len(symbols) > 1000 {
-        http.Error(w, "too many symbols (max 1000)", http.StatusBadRequest)
-        return
-    }
+    if !checkSymbolCap(w, r, len(symbols)) { return }
     side := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("side")))
     if side == "" { side = "all" }
     switch side { case "sell", "bid", "all": default:
-        http.Error(w, "invalid side (sell|bid|all)", http.StatusBadRequest); return }
+        writeError(w, "invalid side (sell|bid|all)", http.StatusBadRequest); return }
     marketsCSV := r.URL.Query().Get("markets")
-    writeLatest(w, r.Context(), providers, symbols, side, marketsCSV)
+    opts, err := parseLatestOptions(r)
+    if err != nil {
+        writeError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    writeLatest(w, r.Context(), providers, symbols, side, marketsCSV, opts)
+}
+
+// latestOptions are the /api/latest query parameters beyond side and markets.
+type latestOptions struct {
+    formatOptions
+    // Pick chooses the quote per market: newest (default), lowest or highest.
+    Pick aggregate.Pick
+    // MaxMarkets keeps at most this many markets per symbol, ranked by
+    // marketPriority then freshness (0 = all).
+    MaxMarkets int
+}
+
+func parseLatestOptions(r *http.Request) (latestOptions, error) {
+    f, err := parseFormatOptions(r)
+    if err != nil { return latestOptions{formatOptions: f}, err }
+    pick, err := aggregate.ParsePick(r.URL.Query().Get("pick"))
+    o := latestOptions{formatOptions: f, Pick: pick}
+    if err != nil { return o, err }
+    if v := strings.TrimSpace(r.URL.Query().Get("max_markets_per_symbol")); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n <= 0 { return o, fmt.Errorf("invalid max_markets_per_symbol %q (positive integer)", v) }
+        o.MaxMarkets = n
+    }
+    return o, nil
 }
 
 type latestPostBody struct {
@@ -405,37 +1055,43 @@ func handlePostLatest(w http.ResponseWriter, r *http.Request, providers []provid
     dec := json.NewDecoder(r.Body)
     dec.DisallowUnknownFields()
     if err := dec.Decode(&b); err != nil {
-        http.Error(w, "invalid JSON body", http.StatusBadRequest)
+        writeError(w, "invalid JSON body", http.StatusBadRequest)
         return
     }
     if len(b.Symbols) == 0 {
-        http.Error(w, "symbols cannot be empty", http.StatusBadRequest)
-        return
-    }
-    if len(b.Symbols) > 1000 {
-        http.Error(w, "too many symbols (max 1000)", http.StatusBadRequest)
+        writeError(w, "symbols cannot be empty", http.StatusBadRequest)
         return
     }
+    if !checkSymbolCap(w, r, len(b.Symbols)) { return }
     side := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("side")))
     if side == "" { side = "all" }
     switch side { case "sell", "bid", "all": default:
-        http.Error(w, "invalid side (sell|bid|all)", http.StatusBadRequest); return }
+        writeError(w, "invalid side (sell|bid|all)", http.StatusBadRequest); return }
     marketsCSV := r.URL.Query().Get("markets")
-    writeLatest(w, r.Context(), providers, b.Symbols, side, marketsCSV)
+    opts, err := parseLatestOptions(r)
+    if err != nil {
+        writeError(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    writeLatest(w, r.Context(), providers, b.Symbols, side, marketsCSV, opts)
 }
 
-func writeLatest(w http.ResponseWriter, rctx context.Context, providers []provider.Provider, symbols []string, side string, marketsCSV string) {
-    ctx, cancel := context.WithTimeout(rctx, time.Duration(apiTimeoutSec)*time.Second)
+func writeLatest(w http.ResponseWriter, rctx context.Context, providers []provider.Provider, symbols []string, side string, marketsCSV string, opts latestOptions) {
+    ctx, cancel := context.WithTimeout(rctx, requestDeadline)
     defer cancel()
     qs, errs := collectQuotes(ctx, providers, symbols)
     if len(qs) == 0 && len(errs) > 0 {
-        msgs := make([]string, 0, len(errs))
-        for _, e := range errs { msgs = append(msgs, e.Error()) }
-        http.Error(w, strings.Join(msgs, "; "), http.StatusBadGateway)
+        writeUpstreamError(w, ctx, errs)
         return
     }
     includeSides := side != "all"
-    agg := aggregate.LatestByMarket(qs, includeSides)
+    var agg []aggregate.Latest
+    if opts.Pick != "" && opts.Pick != aggregate.PickNewest {
+        // Price picks never compare a bid against a sell, so sides stay apart.
+        agg = aggregate.PickByMarket(qs, opts.Pick)
+    } else {
+        agg = aggregate.LatestByMarket(qs, includeSides)
+    }
     // filter by specific side if provided
     if side == "sell" || side == "bid" {
         f := agg[:0]
@@ -455,42 +1111,80 @@ func writeLatest(w http.ResponseWriter, rctx context.Context, providers []provid
         }
         agg = f
     }
-    resp := latestResponse{Latest: agg}
+    agg = aggregate.LimitMarkets(agg, opts.MaxMarkets, marketPriority)
+    resp := latestResponse{Latest: agg, format: opts.formatOptions}
     w.WriteHeader(http.StatusOK)
     enc := json.NewEncoder(w)
     enc.SetEscapeHTML(false)
     enc.Encode(resp)
 }
 
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write([]byte("ok"))
+}
+
+// upstreamStatus picks the response status when every provider failed. When
+// all errors share a kind it maps to 401, 429, 504 or 502; mixed kinds give 502.
+func upstreamStatus(errs []error) int {
+    var kind error
+    for i, err := range errs {
+        k := provider.Kind(err)
+        if i > 0 && k != kind { return http.StatusBadGateway }
+        kind = k
+    }
+    switch kind {
+    case provider.ErrUnauthorized:
+        return http.StatusUnauthorized
+    case provider.ErrRateLimited:
+        return http.StatusTooManyRequests
+    case provider.ErrTimeout:
+        return http.StatusGatewayTimeout
+    default:
+        return http.StatusBadGateway
+    }
+}
+
 // collectQuotes fans out requests to providers for the given symbols and returns combined quotes and partial errors.
 func collectQuotes(ctx context.Context, providers []provider.Provider, symbols []string) ([]provider.Quote, []error) {
-    type result struct { quotes []provider.Quote; err error }
-    ch := make(chan result, len(providers))
-    for _, p := range providers {
-        p := p
-        go func() {
-            qs, err := p.Fetch(ctx, symbols)
-            ch <- result{qs, err}
-        }()
+    return fanOut(ctx, providers, symbols, false)
+}
+
+// fanOut is collectQuotes; with untilCovered it returns once every symbol has
+// a quote, canceling the providers still running (see multi.Provider).
+func fanOut(ctx context.Context, providers []provider.Provider, symbols []string, untilCovered bool) ([]provider.Quote, []error) {
+    // Providers switched off via /admin are skipped entirely. In degraded
+    // mode only providers with a cache layer run, and those answer from it.
+    degraded := upstreamsDisabled.Load()
+    if degraded { ctx = cache.WithCachedOnly(ctx) }
+    skip := func(p provider.Provider) bool {
+        if toggles.Disabled(p.Name()) { return true }
+        return degraded && !hasCache(p)
     }
-    var all []provider.Quote
-    var errs []error
-    for i := 0; i < len(providers); i++ {
-        r := <-ch
-        if r.err != nil { errs = append(errs, r.err); continue }
-        all = append(all, r.quotes...)
+    m := &multi.Provider{Providers: providers, Skip: skip, Concurrency: fetchConcurrency, Priority: providerPriority, UntilCovered: untilCovered}
+    all, errs := multi.Merge(m.FetchEach(ctx, symbols))
+    // a degraded provider contributes no data, not a failure
+    errs = slices.DeleteFunc(errs, func(err error) bool { return errors.Is(err, health.ErrDegraded) })
+    return checkSanity(all), errs
+}
+
+// hasCache reports whether p's wrapper chain includes a cache layer.
+func hasCache(p provider.Provider) bool {
+    for _, l := range provider.Chain(p) {
+        if _, ok := l.(*cache.Provider); ok { return true }
     }
-    return all, errs
+    return false
 }
 
 func withJSONHeaders(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         if strings.HasPrefix(r.URL.Path, "/api/") {
+            if upstreamsDisabled.Load() { w.Header().Set(degradedHeader, "upstreams-disabled") }
             w.Header().Set("Content-Type", "application/json; charset=utf-8")
             // Basic CORS for browser usage; adjust as needed.
             w.Header().Set("Access-Control-Allow-Origin", "*")
             w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
-            w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization")
+            w.Header().Set("Access-Control-Allow-Headers", "Content-Type,Authorization,X-API-Key,Idempotency-Key")
             if r.Method == http.MethodOptions {
                 w.WriteHeader(http.StatusNoContent)
                 return
@@ -500,11 +1194,22 @@ func withJSONHeaders(next http.Handler) http.Handler {
     })
 }
 
-// withGzip compresses response when client supports gzip.
-func withGzip(next http.Handler) http.Handler {
+// gzipOptions configure withGzip.
+type gzipOptions struct {
+    // Level is a compress/gzip level (1..9); 0 means gzip.BestSpeed.
+    Level int
+    // MinSize is the body size in bytes below which responses are sent
+    // uncompressed; 0 compresses every non-empty body.
+    MinSize int
+}
+
+// withGzip compresses response when client supports gzip. Output is buffered
+// until it reaches o.MinSize, so small bodies skip the gzip overhead.
+func withGzip(next http.Handler, o gzipOptions) http.Handler {
+    level := o.Level
+    if level == 0 { level = gzip.BestSpeed }
     var gzPool = sync.Pool{New: func() any {
-        // Prefer best speed to reduce CPU usage since payloads are JSON
-        w, _ := gzip.NewWriterLevel(io.Discard, gzip.BestSpeed)
+        w, _ := gzip.NewWriterLevel(io.Discard, level)
         return w
     }}
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -512,48 +1217,86 @@ func withGzip(next http.Handler) http.Handler {
             next.ServeHTTP(w, r)
             return
         }
-        gz := gzPool.Get().(*gzip.Writer)
-        gz.Reset(w)
-        defer func() {
-            _ = gz.Close()
-            gz.Reset(io.Discard)
-            gzPool.Put(gz)
-        }()
-        w.Header().Set("Content-Encoding", "gzip")
         w.Header().Add("Vary", "Accept-Encoding")
-        gw := gzipResponseWriter{ResponseWriter: w, Writer: gz}
+        gw := &gzipResponseWriter{ResponseWriter: w, pool: &gzPool, minSize: o.MinSize}
+        defer gw.finish()
         next.ServeHTTP(gw, r)
     })
 }
 
+// gzipResponseWriter holds back the status and body until the body reaches
+// minSize, then switches to gzip; smaller bodies are flushed as-is by finish.
 type gzipResponseWriter struct {
     http.ResponseWriter
-    Writer io.Writer
+    pool    *sync.Pool
+    minSize int
+    status  int
+    buf     []byte
+    gz      *gzip.Writer
 }
 
-func (g gzipResponseWriter) Write(b []byte) (int, error) {
-    return g.Writer.Write(b)
+func (g *gzipResponseWriter) WriteHeader(code int) {
+    if g.status == 0 { g.status = code }
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+    if g.gz != nil { return g.gz.Write(b) }
+    g.buf = append(g.buf, b...)
+    if len(g.buf) >= g.minSize && len(g.buf) > 0 {
+        if err := g.startGzip(); err != nil { return 0, err }
+    }
+    return len(b), nil
 }
 
-// limitBody caps request body size to avoid memory abuse.
+func (g *gzipResponseWriter) writeStatus() {
+    if g.status == 0 { g.status = http.StatusOK }
+    g.ResponseWriter.WriteHeader(g.status)
+}
+
+func (g *gzipResponseWriter) startGzip() error {
+    h := g.Header()
+    h.Set("Content-Encoding", "gzip")
+    h.Del("Content-Length")
+    g.writeStatus()
+    g.gz = g.pool.Get().(*gzip.Writer)
+    g.gz.Reset(g.ResponseWriter)
+    _, err := g.gz.Write(g.buf)
+    g.buf = nil
+    return err
+}
+
+// finish closes the gzip stream, or writes the buffered small body uncompressed.
+func (g *gzipResponseWriter) finish() {
+    if g.gz != nil {
+        _ = g.gz.Close()
+        g.gz.Reset(io.Discard)
+        g.pool.Put(g.gz)
+        return
+    }
+    g.writeStatus()
+    if len(g.buf) > 0 { _, _ = g.ResponseWriter.Write(g.buf) }
+}
+
+// limitBody caps POST bodies at 1MB to avoid memory abuse. Bodies sent with
+// Content-Encoding: gzip are decompressed here; the limit applies to both the
+// compressed and the decompressed bytes.
 func limitBody(next http.Handler) http.Handler {
     const maxBody = 1 << 20 // 1MB
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         if r.Method == http.MethodPost && r.Body != nil {
             r.Body = http.MaxBytesReader(w, r.Body, maxBody)
-        }
-        next.ServeHTTP(w, r)
-    })
-}
-
-// recoverPanic protects handlers from panics.
-func recoverPanic(next http.Handler) http.Handler {
-    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        defer func() {
-            if rec := recover(); rec != nil {
-                http.Error(w, "internal server error", http.StatusInternalServerError)
+            if strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+                zr, err := gzip.NewReader(r.Body)
+                if err != nil {
+                    writeError(w, "invalid gzip body", http.StatusBadRequest)
+                    return
+                }
+                defer zr.Close()
+                r.Body = http.MaxBytesReader(w, zr, maxBody)
+                r.Header.Del("Content-Encoding")
+                r.ContentLength = -1
             }
-        }()
+        }
         next.ServeHTTP(w, r)
     })
 }
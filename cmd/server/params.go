@@ -0,0 +1,93 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+)
+
+// strictParams makes API endpoints reject query parameters they do not read
+// (server.strict_query_params), so a typo like ?symbol= is reported instead
+// of silently ignored. It is initialized from config on startup.
+var strictParams bool
+
+// knownParams lists the query parameters each API endpoint reads. Paths not
+// listed here are never checked.
+var knownParams = map[string][]string{
+    "/api/quotes":  {"symbols", "case", "ts", "fields", "group", "collapse", "report_missing", "max_age_sec", "prefer", "changed_since", "sites", "mode"},
+    "/api/latest":  {"symbols", "case", "ts", "side", "markets", "pick", "max_markets_per_symbol"},
+    "/api/items":   {"sites"},
+    "/api/changes": {"symbols"},
+    "/api/search":  {"q", "limit"},
+}
+
+// withKnownParams answers 400 for requests carrying unknown query parameters
+// while strictParams is set, naming each one with the closest known name.
+func withKnownParams(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if strictParams {
+            if msg := unknownParams(r.URL.Path, r.URL.Query()); msg != "" {
+                writeError(w, msg, http.StatusBadRequest)
+                return
+            }
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// unknownParams describes the parameters of query that path does not know,
+// or returns "" when there are none.
+func unknownParams(path string, query map[string][]string) string {
+    known, ok := knownParams[path]
+    if !ok { return "" }
+    var unknown []string
+    for name := range query {
+        if !containsString(known, name) { unknown = append(unknown, name) }
+    }
+    if len(unknown) == 0 { return "" }
+    sort.Strings(unknown)
+    parts := make([]string, 0, len(unknown))
+    for _, name := range unknown {
+        if s := suggestParam(name, known); s != "" {
+            parts = append(parts, fmt.Sprintf("%q (did you mean %q?)", name, s))
+        } else {
+            parts = append(parts, fmt.Sprintf("%q", name))
+        }
+    }
+    return fmt.Sprintf("unknown query parameter %s; %s accepts: %s", strings.Join(parts, ", "), path, strings.Join(known, ", "))
+}
+
+func containsString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s { return true }
+    }
+    return false
+}
+
+// suggestParam returns the known name closest to name (case-insensitive edit
+// distance of at most 2), or "".
+func suggestParam(name string, known []string) string {
+    best, bestDist := "", 3
+    for _, k := range known {
+        if d := editDistance(strings.ToLower(name), k); d < bestDist { best, bestDist = k, d }
+    }
+    return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+    prev := make([]int, len(b)+1)
+    cur := make([]int, len(b)+1)
+    for j := range prev { prev[j] = j }
+    for i := 1; i <= len(a); i++ {
+        cur[0] = i
+        for j := 1; j <= len(b); j++ {
+            cost := 1
+            if a[i-1] == b[j-1] { cost = 0 }
+            cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+        }
+        prev, cur = cur, prev
+    }
+    return prev[len(b)]
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func TestKnownParams_TypoGetsHelpfulError(t *testing.T) {
+    old := strictParams
+    strictParams = true
+    t.Cleanup(func() { strictParams = old })
+    ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+    h := withKnownParams(ok)
+
+    rr := httptest.NewRecorder()
+    h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbol=A&Case=camel", nil))
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for unknown params, got %d", rr.Code) }
+    body := rr.Body.String()
+    for _, want := range []string{`"symbol" (did you mean "symbols"?)`, `"Case" (did you mean "case"?)`, "/api/quotes accepts: symbols,"} {
+        if !strings.Contains(body, want) { t.Fatalf("want %q in error, got %q", want, body) }
+    }
+
+    rr = httptest.NewRecorder()
+    h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/latest?symbols=A&nonsense=1", nil))
+    if rr.Code != http.StatusBadRequest || strings.Contains(rr.Body.String(), "did you mean") { t.Fatalf("want 400 without a suggestion, got %d %q", rr.Code, rr.Body.String()) }
+
+    for _, target := range []string{"/api/latest?symbols=A&side=sell&max_markets_per_symbol=2", "/healthz?verbose=1"} {
+        rr = httptest.NewRecorder()
+        h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+        if rr.Code != http.StatusOK { t.Fatalf("%s: want known params and unchecked paths through, got %d %q", target, rr.Code, rr.Body.String()) }
+    }
+
+    strictParams = false
+    rr = httptest.NewRecorder()
+    h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbol=A", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("want unknown params ignored when not strict, got %d", rr.Code) }
+}
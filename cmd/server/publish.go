@@ -0,0 +1,32 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/publish"
+)
+
+// quoteSink receives the quotes of every successful /api/quotes fan-out
+// (nil disables). It is initialized from config on startup.
+var quoteSink publish.Sink
+
+// newQuoteSink builds the configured sink, or nil when publishing is off.
+// The Kafka producer sits behind publish.Async so requests never wait on it.
+func newQuoteSink(c config.Publish) (publish.Sink, error) {
+    if len(c.KafkaBrokers) == 0 || c.KafkaTopic == "" { return nil, nil }
+    timeout := time.Duration(c.TimeoutMs) * time.Millisecond
+    k, err := publish.NewKafka(publish.KafkaConfig{Brokers: c.KafkaBrokers, Topic: c.KafkaTopic, Timeout: timeout})
+    if err != nil { return nil, err }
+    return publish.NewAsync(k, c.QueueSize, timeout), nil
+}
+
+// publishQuotes hands qs to quoteSink. It is best-effort: failures are logged
+// and never change the HTTP response.
+func publishQuotes(ctx context.Context, qs []provider.Quote) {
+    if quoteSink == nil || len(qs) == 0 { return }
+    if err := quoteSink.Publish(ctx, qs); err != nil { log.Printf("publish: %v", err) }
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/publish"
+)
+
+type fakeSink struct {
+    mu     sync.Mutex
+    got    []provider.Quote
+    err    error
+    closed bool
+}
+
+func (s *fakeSink) Publish(_ context.Context, qs []provider.Quote) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.got = append(s.got, qs...)
+    return s.err
+}
+
+func (s *fakeSink) Close() error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.closed = true
+    return nil
+}
+
+func TestWriteQuotes_PublishesAllQuotesBestEffort(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    providers := []provider.Provider{
+        fakeProvider{"steamdt", []provider.Quote{{Symbol: "A", Price: "1", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}, {Symbol: "B", Price: "2", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}},
+        fakeProvider{"pricempire", []provider.Quote{{Symbol: "A", Price: "1.1", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: ts}}},
+    }
+    sink := &fakeSink{err: errors.New("broker down")}
+    async := publish.NewAsync(sink, 4, time.Second)
+    quoteSink = async
+    t.Cleanup(func() { quoteSink = nil })
+
+    // collapse trims the response, not what is published
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{"A", "B"}, quotesOptions{Collapse: true})
+    if rr.Code != http.StatusOK { t.Fatalf("publish failure must not fail the request: %d %s", rr.Code, rr.Body.String()) }
+
+    if err := async.Close(); err != nil { t.Fatalf("close: %v", err) }
+    sink.mu.Lock()
+    defer sink.mu.Unlock()
+    if !sink.closed { t.Fatalf("sink not closed") }
+    if len(sink.got) != 3 { t.Fatalf("want all 3 quotes published, got %+v", sink.got) }
+    seen := map[string]bool{}
+    for _, q := range sink.got { seen[q.Source+" "+q.Symbol] = true }
+    for _, want := range []string{"SteamDT:BUFF:sell A", "SteamDT:BUFF:sell B", "Pricempire:buff A"} {
+        if !seen[want] { t.Fatalf("missing %s in %+v", want, sink.got) }
+    }
+}
@@ -0,0 +1,513 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/httpx"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/health"
+    "priceprovider/internal/provider/pricempire"
+    "priceprovider/internal/provider/pricempireadapter"
+)
+
+func TestQuotes_GroupBySymbol_IncludesEmptyArrays(t *testing.T) {
+    found := "AK-47 | Redline (Field-Tested)"
+    missing := "AWP | Dragon Lore (Factory New)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"steamdt", []provider.Quote{
+        {Symbol: found, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+        {Symbol: found, Price: "9", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: ts},
+    }}
+
+    body := `{"symbols":["` + found + `","` + missing + `"]}`
+    req := httptest.NewRequest(http.MethodPost, "/api/quotes?group=symbol", strings.NewReader(body))
+    rr := httptest.NewRecorder()
+    handlePostQuotes(rr, req, []provider.Provider{p})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+
+    var resp struct{ BySymbol map[string][]provider.Quote `json:"bySymbol"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    by := resp.BySymbol
+    if by == nil { t.Fatalf("missing bySymbol: %s", rr.Body.String()) }
+    if len(by[found]) != 2 { t.Fatalf("want 2 quotes for %q, got %+v", found, by[found]) }
+    got, ok := by[missing]
+    if !ok || got == nil || len(got) != 0 { t.Fatalf("want empty array for %q, got %s", missing, rr.Body.String()) }
+    if !strings.Contains(rr.Body.String(), `"`+missing+`":[]`) { t.Fatalf("empty array not serialized as []: %s", rr.Body.String()) }
+}
+
+func TestQuotes_InvalidGroup(t *testing.T) {
+    req := httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A&group=market", nil)
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, req, nil)
+    if rr.Code != http.StatusBadRequest { t.Fatalf("want 400, got %d", rr.Code) }
+}
+
+func TestQuotes_CollapseKeepsFreshestPerMarket(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    steam := fakeProvider{"steamdt", []provider.Quote{
+        {Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t0},
+        {Symbol: sym, Price: "9", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: t0},
+    }}
+    pe := fakeProvider{"pricempire", []provider.Quote{
+        {Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff163", ReceivedAt: t0.Add(time.Minute)},
+        {Symbol: sym, Price: "12", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: t0},
+    }}
+    pe2 := fakeProvider{"skinstable", []provider.Quote{
+        {Symbol: sym, Price: "13", Currency: "USD", Source: "SkinstableXYZ:Steam", Provider: "Skinstable", ReceivedAt: t0.Add(-time.Minute)},
+    }}
+    providers := []provider.Provider{steam, pe, pe2}
+
+    get := func(path string) []provider.Quote {
+        rr := httptest.NewRecorder()
+        handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, path, nil), providers)
+        if rr.Code != 200 { t.Fatalf("%s: status=%d body=%s", path, rr.Code, rr.Body.String()) }
+        var resp struct{ Quotes []provider.Quote `json:"quotes"` }
+        if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+        return resp.Quotes
+    }
+    q := "?symbols=AK-47%20%7C%20Redline%20(Field-Tested)"
+    if n := len(get("/api/quotes" + q)); n != 5 { t.Fatalf("default: want all 5 quotes, got %d", n) }
+
+    got := map[string]string{}
+    for _, c := range get("/api/quotes" + q + "&collapse=true") {
+        got[c.Source] = c.Price
+        // the winner is attributed even when the provider left Provider empty
+        if want, _, _ := strings.Cut(c.Source, ":"); c.Provider != want { t.Fatalf("collapse: %s attributed to %q", c.Source, c.Provider) }
+    }
+    // BUFF sell vs Pricempire buff163 (no side) are different groups; Steam collapses to the newer Pricempire row
+    want := map[string]string{"SteamDT:BUFF:sell": "10", "SteamDT:BUFF:bid": "9", "Pricempire:buff163": "11", "Pricempire:steam": "12"}
+    if len(got) != len(want) { t.Fatalf("collapse: want %v, got %v", want, got) }
+    for k, v := range want {
+        if got[k] != v { t.Fatalf("collapse: want %v, got %v", want, got) }
+    }
+}
+
+func TestQuotes_ReportMissing(t *testing.T) {
+    found := "AK-47 | Redline (Field-Tested)"
+    missing := "AWP | Dragon Lore (Factory New)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"steamdt", []provider.Quote{{Symbol: found, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}
+
+    post := func(path string) string {
+        body := `{"symbols":["` + found + `","` + missing + `"]}`
+        rr := httptest.NewRecorder()
+        handlePostQuotes(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)), []provider.Provider{p})
+        if rr.Code != 200 { t.Fatalf("%s: status=%d body=%s", path, rr.Code, rr.Body.String()) }
+        return rr.Body.String()
+    }
+
+    if body := post("/api/quotes"); strings.Contains(body, "missing") { t.Fatalf("missing reported without opt-in: %s", body) }
+
+    var resp struct {
+        Quotes  []provider.Quote `json:"quotes"`
+        Missing []string         `json:"missing"`
+    }
+    body := post("/api/quotes?report_missing=true")
+    if err := json.Unmarshal([]byte(body), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 { t.Fatalf("want 1 quote, got %s", body) }
+    if len(resp.Missing) != 1 || resp.Missing[0] != missing { t.Fatalf("want missing=[%q], got %s", missing, body) }
+}
+
+// slowProvider delays every Fetch to make provider timings measurable.
+type slowProvider struct {
+    fakeProvider
+    delay time.Duration
+}
+
+func (s slowProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    time.Sleep(s.delay)
+    return s.fakeProvider.Fetch(ctx, symbols)
+}
+
+func TestQuotes_MetaIncludesProviderTimings(t *testing.T) {
+    slow := slowProvider{fakeProvider{"steamdt", []provider.Quote{{Symbol: "A", Price: "1", Source: "SteamDT:BUFF:sell"}}}, 30 * time.Millisecond}
+    p := wrapProvider(slow, wrapOptions{})
+
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A", nil), []provider.Provider{p})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    var resp struct {
+        Meta struct{ ProviderTimingsMs map[string]int64 `json:"provider_timings_ms"` } `json:"meta"`
+    }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if got := resp.Meta.ProviderTimingsMs["steamdt"]; got < 30 || got > 1000 { t.Fatalf("implausible timing %dms: %s", got, rr.Body.String()) }
+}
+
+type failingProvider struct {
+    name string
+    err  error
+}
+
+func (f failingProvider) Name() string { return f.name }
+func (f failingProvider) Fetch(context.Context, []string) ([]provider.Quote, error) { return nil, f.err }
+
+func TestQuotes_AllFailedStatusFollowsErrorKind(t *testing.T) {
+    unauthorized := provider.StatusError(http.StatusUnauthorized, errors.New("GET x -> 401"))
+    limited := provider.StatusError(http.StatusTooManyRequests, errors.New("GET x -> 429"))
+    cases := []struct {
+        name string
+        errs []error
+        want int
+    }{
+        {"auth", []error{unauthorized, fmt.Errorf("wrapped: %w", unauthorized)}, http.StatusUnauthorized},
+        {"rate", []error{limited}, http.StatusTooManyRequests},
+        {"timeout", []error{provider.TransportError(context.DeadlineExceeded)}, http.StatusGatewayTimeout},
+        {"upstream", []error{provider.StatusError(http.StatusInternalServerError, errors.New("GET x -> 500"))}, http.StatusBadGateway},
+        {"untyped", []error{errors.New("boom")}, http.StatusBadGateway},
+        {"mixed", []error{unauthorized, limited}, http.StatusBadGateway},
+    }
+    for _, c := range cases {
+        var providers []provider.Provider
+        for i, err := range c.errs { providers = append(providers, failingProvider{fmt.Sprintf("p%d", i), err}) }
+        rr := httptest.NewRecorder()
+        writeQuotes(rr, t.Context(), providers, []string{"A"}, quotesOptions{})
+        if rr.Code != c.want { t.Fatalf("%s: want %d, got %d (%s)", c.name, c.want, rr.Code, rr.Body.String()) }
+    }
+}
+
+type blockingProvider struct{ name string }
+
+func (b blockingProvider) Name() string { return b.name }
+func (b blockingProvider) Fetch(ctx context.Context, _ []string) ([]provider.Quote, error) {
+    <-ctx.Done()
+    return nil, ctx.Err()
+}
+
+func TestQuotes_RequestDeadlineBoundsFanOut(t *testing.T) {
+    old := requestDeadline
+    requestDeadline = 50 * time.Millisecond
+    t.Cleanup(func() { requestDeadline = old })
+
+    start := time.Now()
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), []provider.Provider{blockingProvider{"stuck"}}, []string{"A"}, quotesOptions{})
+    if took := time.Since(start); took > time.Second { t.Fatalf("handler took %s with a 50ms deadline", took) }
+    if rr.Code != http.StatusGatewayTimeout { t.Fatalf("want 504, got %d", rr.Code) }
+}
+
+// resetProvider waits for ctx like blockingProvider but reports the failure
+// as a plain I/O error, as a provider cut off mid-read would.
+type resetProvider struct{ name string }
+
+func (r resetProvider) Name() string { return r.name }
+func (r resetProvider) Fetch(ctx context.Context, _ []string) ([]provider.Quote, error) {
+    <-ctx.Done()
+    return nil, errors.New("read body: connection reset by peer")
+}
+
+func TestQuotes_DeadlineIs504AndCancelIsSilent(t *testing.T) {
+    old := requestDeadline
+    requestDeadline = 50 * time.Millisecond
+    t.Cleanup(func() { requestDeadline = old })
+    providers := []provider.Provider{blockingProvider{"stuck"}, resetProvider{"reset"}}
+
+    // mixed error kinds, but the request deadline fired: 504, not 502
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{"A"}, quotesOptions{})
+    if rr.Code != http.StatusGatewayTimeout { t.Fatalf("want 504 after the deadline, got %d (%s)", rr.Code, rr.Body.String()) }
+
+    // the client went away: nothing is written
+    ctx, cancel := context.WithCancel(t.Context())
+    time.AfterFunc(10*time.Millisecond, cancel)
+    rr = httptest.NewRecorder()
+    writeQuotes(rr, ctx, providers, []string{"A"}, quotesOptions{})
+    if rr.Body.Len() != 0 || rr.Result().Header.Get("Content-Type") != "" { t.Fatalf("want no response for a canceled request, got %d %q", rr.Code, rr.Body.String()) }
+}
+
+func TestQuotes_MaxAgeRefreshesOlderCacheEntries(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    up := &countingFetcher{fakeProvider: fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell"}}}}
+    providers := []provider.Provider{wrapProvider(up, wrapOptions{CacheTTLSec: 60})}
+    get := func(query string) int {
+        rr := httptest.NewRecorder()
+        handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=AK-47+%7C+Redline+(Field-Tested)"+query, nil), providers)
+        return rr.Code
+    }
+
+    if code := get(""); code != http.StatusOK { t.Fatalf("status=%d", code) }
+    if code := get("&max_age_sec=60"); code != http.StatusOK { t.Fatalf("status=%d", code) }
+    if n := up.calls.Load(); n != 1 { t.Fatalf("fresh entry: want 1 upstream call, got %d", n) }
+
+    time.Sleep(1100 * time.Millisecond)
+    if code := get("&max_age_sec=1"); code != http.StatusOK { t.Fatalf("status=%d", code) }
+    if n := up.calls.Load(); n != 2 { t.Fatalf("old entry: want a refresh, got %d upstream calls", n) }
+
+    if code := get("&max_age_sec=abc"); code != http.StatusBadRequest { t.Fatalf("want 400 for invalid max_age_sec, got %d", code) }
+}
+
+func TestQuotes_SanityDropsCrossProviderOutlier(t *testing.T) {
+    oldDev, oldDrop := sanityMaxDeviation, sanityDrop
+    sanityMaxDeviation, sanityDrop = 3, true
+    t.Cleanup(func() { sanityMaxDeviation, sanityDrop = oldDev, oldDrop })
+
+    sym := "AK-47 | Redline (Field-Tested)"
+    providers := []provider.Provider{
+        fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "72", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"}}},
+        fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"}}},
+        fakeProvider{"skinstable", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "SkinstableXYZ:csfloat", Provider: "SkinstableXYZ"}}},
+    }
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{sym}, quotesOptions{})
+    var resp quotesResponse
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 2 { t.Fatalf("want outlier dropped, got %+v", resp.Quotes) }
+    for _, q := range resp.Quotes {
+        if q.Provider == "SteamDT" { t.Fatalf("outlier kept: %+v", q) }
+    }
+}
+
+func TestQuotes_PreferParamSelectsProvider(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    providers := []provider.Provider{
+        fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"}}},
+        fakeProvider{"pricempire", []provider.Quote{{Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"}}},
+    }
+    rr := httptest.NewRecorder()
+    handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=AK-47+%7C+Redline+(Field-Tested)&prefer=Pricempire,SteamDT", nil), providers)
+    var resp quotesResponse
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 || resp.Quotes[0].Provider != "Pricempire" { t.Fatalf("want only the Pricempire quote, got %+v", resp.Quotes) }
+}
+
+func TestQuotes_MetaWatermarksSpanReceivedAt(t *testing.T) {
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    t2, t3 := t1.Add(time.Minute), t1.Add(time.Hour)
+    providers := []provider.Provider{
+        fakeProvider{"steamdt", []provider.Quote{
+            {Symbol: "A", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t2},
+            {Symbol: "A", Price: "9", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: t3},
+        }},
+        fakeProvider{"pricempire", []provider.Quote{{Symbol: "A", Price: "11", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: t1}}},
+        fakeProvider{"skinstable", []provider.Quote{{Symbol: "A", Price: "12", Currency: "USD", Source: "SkinstableXYZ:csfloat"}}},
+    }
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{"A"}, quotesOptions{})
+    var resp struct {
+        Meta struct {
+            Oldest time.Time `json:"oldest_received_at"`
+            Newest time.Time `json:"newest_received_at"`
+        } `json:"meta"`
+    }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if !resp.Meta.Oldest.Equal(t1) || !resp.Meta.Newest.Equal(t3) { t.Fatalf("want %s..%s, got %s", t1, t3, rr.Body.String()) }
+}
+
+// trackingProvider records the peak number of concurrent Fetch calls.
+type trackingProvider struct {
+    slowProvider
+    running, peak *atomic.Int32
+}
+
+func (p trackingProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    n := p.running.Add(1)
+    defer p.running.Add(-1)
+    for {
+        old := p.peak.Load()
+        if n <= old || p.peak.CompareAndSwap(old, n) { break }
+    }
+    return p.slowProvider.Fetch(ctx, symbols)
+}
+
+func TestQuotes_FetchConcurrencySerializesFanOut(t *testing.T) {
+    fetchConcurrency = 1
+    t.Cleanup(func() { fetchConcurrency = 0 })
+
+    var running, peak atomic.Int32
+    var providers []provider.Provider
+    for i := range 3 {
+        name := fmt.Sprintf("p%d", i)
+        slow := slowProvider{fakeProvider{name, []provider.Quote{{Symbol: "A", Price: "1", Source: name}}}, 20 * time.Millisecond}
+        providers = append(providers, trackingProvider{slow, &running, &peak})
+    }
+    providers = append(providers, failingProvider{"down", errors.New("boom")})
+
+    start := time.Now()
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{"A"}, quotesOptions{})
+    if rr.Code != 200 { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    if got := peak.Load(); got != 1 { t.Fatalf("want serialized fetches, peak concurrency %d", got) }
+    if took := time.Since(start); took < 60*time.Millisecond { t.Fatalf("fan-out took %s, want >= 3 x 20ms", took) }
+    var resp struct{ Quotes []provider.Quote `json:"quotes"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 3 { t.Fatalf("want partial results from the healthy providers, got %s", rr.Body.String()) }
+}
+
+func TestQuotes_DisableAllUpstreamsServesCacheOnly(t *testing.T) {
+    t.Cleanup(func() { upstreamsDisabled.Store(false) })
+    q := provider.Quote{Symbol: "A", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell"}
+    cached := &countingFetcher{fakeProvider: fakeProvider{"steamdt", []provider.Quote{q}}}
+    direct := &countingFetcher{fakeProvider: fakeProvider{"skinstable", []provider.Quote{q}}}
+    providers := []provider.Provider{wrapProvider(cached, wrapOptions{CacheTTLSec: 60}), direct}
+    h := withJSONHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handleGetQuotes(w, r, providers) }))
+    get := func(symbols string) *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols="+symbols, nil))
+        return rr
+    }
+
+    if rr := get("A"); rr.Code != http.StatusOK || rr.Header().Get(degradedHeader) != "" { t.Fatalf("normal mode: status=%d headers=%v", rr.Code, rr.Header()) }
+    cached.calls.Store(0)
+    direct.calls.Store(0)
+
+    upstreamsDisabled.Store(true)
+    rr := get("A,B")
+    if rr.Code != http.StatusOK { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    if rr.Header().Get(degradedHeader) == "" { t.Fatalf("want %s header in degraded mode", degradedHeader) }
+    if n := cached.calls.Load() + direct.calls.Load(); n != 0 { t.Fatalf("want no upstream fetches, got %d", n) }
+    var resp struct{ Quotes []provider.Quote `json:"quotes"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 || resp.Quotes[0].Symbol != "A" { t.Fatalf("want the cached quote only, got %s", rr.Body.String()) }
+}
+
+func TestDisableAllUpstreams_ItemsAndSearchMakeNoUpstreamCalls(t *testing.T) {
+    t.Cleanup(func() { upstreamsDisabled.Store(false) })
+    var calls atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        if r.URL.Query().Get("site") != "" {
+            fmt.Fprint(w, `{"items":{"AK-47 | Redline (Field-Tested)":{"n":"x"}}}`)
+            return
+        }
+        fmt.Fprint(w, `{"AK-47 | Redline (Field-Tested)":{"buff":{"price":1}}}`)
+    }))
+    defer srv.Close()
+    client, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL(srv.URL))
+    if err != nil { t.Fatalf("client: %v", err) }
+    adapter := pricempireadapter.New(pricempireadapter.Config{Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60}, client)
+    providers := []provider.Provider{adapter}
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/items", handleItems(config.Skinstable{Enabled: true, Endpoint: srv.URL}, httpx.New(5*time.Second)))
+    mux.HandleFunc("GET /api/search", handleSearch(providers))
+    h := withJSONHeaders(mux)
+    get := func(target string) *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+        return rr
+    }
+
+    if rr := get("/api/items"); rr.Code != http.StatusOK { t.Fatalf("normal mode: status=%d body=%s", rr.Code, rr.Body.String()) }
+    if _, err := adapter.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"}); err != nil { t.Fatalf("fetch: %v", err) }
+    calls.Store(0)
+
+    upstreamsDisabled.Store(true)
+    rr := get("/api/items")
+    if rr.Code != http.StatusServiceUnavailable || rr.Header().Get(degradedHeader) == "" { t.Fatalf("items: want 503 with %s, got %d %v", degradedHeader, rr.Code, rr.Header()) }
+    rr = get("/api/search?q=redline")
+    if rr.Code != http.StatusOK || rr.Header().Get(degradedHeader) == "" || !strings.Contains(rr.Body.String(), "Redline") { t.Fatalf("search: want the cached names, got %d %s", rr.Code, rr.Body.String()) }
+    if n := calls.Load(); n != 0 { t.Fatalf("want no upstream calls in degraded mode, got %d", n) }
+}
+
+func TestQuotes_ChangedSinceKeepsNewerQuotes(t *testing.T) {
+    t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := fakeProvider{"steamdt", []provider.Quote{
+        {Symbol: "A", Price: "1", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t0.Add(-time.Second)},
+        {Symbol: "A", Price: "2", Currency: "USD", Source: "SteamDT:YOUPIN:sell", ReceivedAt: t0},
+        {Symbol: "B", Price: "3", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t0.Add(time.Second)},
+    }}
+    get := func(query string) *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A,B"+query, nil), []provider.Provider{p})
+        return rr
+    }
+
+    rr := get("&report_missing=true&changed_since=2025-01-02T03:04:05Z")
+    if rr.Code != http.StatusOK { t.Fatalf("status=%d body=%s", rr.Code, rr.Body.String()) }
+    var resp struct {
+        Quotes  []provider.Quote `json:"quotes"`
+        Missing []string         `json:"missing"`
+    }
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 1 || resp.Quotes[0].Symbol != "B" { t.Fatalf("want only the quote after the boundary, got %+v", resp.Quotes) }
+    if len(resp.Missing) != 0 { t.Fatalf("unchanged symbols are not missing, got %v", resp.Missing) }
+
+    if rr := get("&changed_since=yesterday"); rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for invalid changed_since, got %d", rr.Code) }
+}
+
+func TestQuotes_SymbolAliasesRoutePerProvider(t *testing.T) {
+    canonical, steamName := "StatTrak™ AK-47 | Redline (Field-Tested)", "StatTrak AK-47 | Redline (Field-Tested)"
+    aliases := map[string]map[string]string{canonical: {"SteamDT": steamName}}
+    providers := []provider.Provider{
+        wrapProvider(fakeProvider{"steamdt", []provider.Quote{{Symbol: steamName, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"}}}, wrapOptions{Aliases: aliasesFor("steamdt", aliases)}),
+        wrapProvider(fakeProvider{"pricempire", []provider.Quote{{Symbol: canonical, Price: "11", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"}}}, wrapOptions{Aliases: aliasesFor("pricempire", aliases)}),
+    }
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), providers, []string{canonical}, quotesOptions{})
+    var resp quotesResponse
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+    if len(resp.Quotes) != 2 { t.Fatalf("want a quote from each provider, got %+v", resp.Quotes) }
+    for _, q := range resp.Quotes {
+        if q.Symbol != canonical { t.Fatalf("want the canonical symbol, got %+v", q) }
+    }
+}
+
+func TestQuotes_SitesParamNarrowsSkinstable(t *testing.T) {
+    oldSites := skinstableSites
+    skinstableSites = []string{"CS.MONEY", "BUFF.163"}
+    t.Cleanup(func() { skinstableSites = oldSites })
+
+    sym := "AK-47 | Redline (Field-Tested)"
+    providers := []provider.Provider{
+        wrapProvider(fakeProvider{skinstableName, []provider.Quote{
+            {Symbol: sym, Price: "10", Currency: "USD", Source: "SkinstableXYZ:CS.MONEY", Provider: "SkinstableXYZ"},
+            {Symbol: sym, Price: "11", Currency: "USD", Source: "SkinstableXYZ:BUFF.163", Provider: "SkinstableXYZ"},
+        }}, wrapOptions{CacheTTLSec: 60}),
+        fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "12", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"}}},
+    }
+    get := func(query string) (int, quotesResponse) {
+        rr := httptest.NewRecorder()
+        handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=AK-47+%7C+Redline+(Field-Tested)"+query, nil), providers)
+        var resp quotesResponse
+        if rr.Code == http.StatusOK {
+            if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil { t.Fatalf("decode: %v", err) }
+        }
+        return rr.Code, resp
+    }
+
+    if code, resp := get(""); code != http.StatusOK || len(resp.Quotes) != 3 { t.Fatalf("want every quote, got %d %+v", code, resp.Quotes) }
+    code, resp := get("&sites=buff.163")
+    if code != http.StatusOK || len(resp.Quotes) != 2 { t.Fatalf("want the BUFF.163 and SteamDT quotes, got %d %+v", code, resp.Quotes) }
+    for _, q := range resp.Quotes {
+        if q.Source == "SkinstableXYZ:CS.MONEY" { t.Fatalf("unselected site returned: %+v", q) }
+    }
+    if code, _ := get("&sites=DMARKET"); code != http.StatusBadRequest { t.Fatalf("want 400 for an unconfigured site, got %d", code) }
+}
+
+func TestQuotes_ModeFastestCancelsSlowProvider(t *testing.T) {
+    providers := []provider.Provider{
+        blockingProvider{"slow"},
+        fakeProvider{"fast", []provider.Quote{
+            {Symbol: "A", Price: "1", Currency: "USD", Source: "fast:m", Provider: "fast"},
+            {Symbol: "B", Price: "2", Currency: "USD", Source: "fast:m", Provider: "fast"},
+        }},
+    }
+    get := func(query string) *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        handleGetQuotes(rr, httptest.NewRequest(http.MethodGet, "/api/quotes?symbols=A,B"+query, nil), providers)
+        return rr
+    }
+
+    start := time.Now()
+    rr := get("&mode=fastest")
+    if took := time.Since(start); took > time.Second { t.Fatalf("slow provider not canceled (%s)", took) }
+    var resp quotesResponse
+    if err := json.Unmarshal(rr.Body.Bytes(), &resp); rr.Code != http.StatusOK || err != nil { t.Fatalf("want 200, got %d (%s)", rr.Code, rr.Body.String()) }
+    if len(resp.Quotes) != 2 { t.Fatalf("want the fast provider's quotes, got %+v", resp.Quotes) }
+    if rr := get("&mode=first"); rr.Code != http.StatusBadRequest { t.Fatalf("want 400 for an unknown mode, got %d", rr.Code) }
+}
+
+func TestQuotes_DegradedProviderIsNoData(t *testing.T) {
+    rr := httptest.NewRecorder()
+    writeQuotes(rr, t.Context(), []provider.Provider{failingProvider{"down", health.ErrDegraded}}, []string{"A"}, quotesOptions{})
+    if rr.Code != http.StatusOK { t.Fatalf("want 200 with no quotes from a degraded provider, got %d (%s)", rr.Code, rr.Body.String()) }
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+    "log"
+    "net/http"
+    "runtime/debug"
+)
+
+// panicStack logs the stack trace of recovered panics
+// (debug.panic_stack). It is initialized from config on startup.
+var panicStack = true
+
+// panicRef adds the panic's reference to the 500 body (debug.panic_ref)
+// so a report can be matched to the log line. Off in production.
+var panicRef bool
+
+// recoverPanic protects handlers from panics. Each recovered panic gets a
+// random reference that is logged with the panic value, the request and,
+// with panicStack, the stack trace. Clients only ever see a generic 500,
+// plus the reference when panicRef is set.
+func recoverPanic(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        defer func() {
+            rec := recover()
+            if rec == nil { return }
+            // let net/http abort the response as it would without us
+            if rec == http.ErrAbortHandler { panic(rec) }
+            ref := panicReference()
+            msg := "panic ref=" + ref
+            if id := r.Header.Get("X-Request-Id"); id != "" { msg += " request_id=" + id }
+            if panicStack {
+                log.Printf("%s method=%s path=%q: %v\n%s", msg, r.Method, r.URL.Path, rec, debug.Stack())
+            } else {
+                log.Printf("%s method=%s path=%q: %v", msg, r.Method, r.URL.Path, rec)
+            }
+            if panicRef {
+                writeError(w, "internal server error (ref "+ref+")", http.StatusInternalServerError)
+                return
+            }
+            writeError(w, "internal server error", http.StatusInternalServerError)
+        }()
+        next.ServeHTTP(w, r)
+    })
+}
+
+// panicReference returns a short random hex id.
+func panicReference() string {
+    var b [6]byte
+    _, _ = rand.Read(b[:])
+    return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+    "bytes"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "regexp"
+    "strings"
+    "testing"
+)
+
+func TestRecoverPanic_LogsStackAndReturns500(t *testing.T) {
+    var buf bytes.Buffer
+    out := log.Writer()
+    log.SetOutput(&buf)
+    t.Cleanup(func() { log.SetOutput(out); panicStack, panicRef = true, false })
+
+    h := recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("secret internal state") }))
+    serve := func() *httptest.ResponseRecorder {
+        buf.Reset()
+        req := httptest.NewRequest(http.MethodGet, "/api/quotes", nil)
+        req.Header.Set("X-Request-Id", "req-42")
+        rr := httptest.NewRecorder()
+        h.ServeHTTP(rr, req)
+        return rr
+    }
+
+    rr := serve()
+    if rr.Code != http.StatusInternalServerError { t.Fatalf("want 500, got %d", rr.Code) }
+    if strings.Contains(rr.Body.String(), "secret") || strings.Contains(rr.Body.String(), "ref") { t.Fatalf("production body leaks details: %q", rr.Body.String()) }
+    logged := buf.String()
+    for _, want := range []string{"request_id=req-42", `path="/api/quotes"`, "secret internal state", "goroutine ", "recover.go"} {
+        if !strings.Contains(logged, want) { t.Fatalf("log lacks %q:\n%s", want, logged) }
+    }
+
+    // debug mode: the body carries the reference from the log line, nothing more
+    panicRef = true
+    rr = serve()
+    ref := regexp.MustCompile(`panic ref=([0-9a-f]+)`).FindStringSubmatch(buf.String())
+    if ref == nil || !strings.Contains(rr.Body.String(), "(ref "+ref[1]+")") { t.Fatalf("want body to reference the log line, body=%q log=%s", rr.Body.String(), buf.String()) }
+    if strings.Contains(rr.Body.String(), "secret") { t.Fatalf("debug body leaks the panic value: %q", rr.Body.String()) }
+
+    // stack capture can be switched off
+    panicStack = false
+    serve()
+    if strings.Contains(buf.String(), "goroutine ") { t.Fatalf("want no stack trace, got:\n%s", buf.String()) }
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "math/rand/v2"
+    "net/http"
+    "time"
+
+    "priceprovider/internal/httpx"
+)
+
+// requestIDHeader carries the ID of an API request, taken from the client or
+// generated by withRequestID.
+const requestIDHeader = "X-Request-Id"
+
+// logSampleRate is the fraction of successful requests withRequestLog logs
+// (debug.log_sample_rate, 0..1). It is initialized from config on startup.
+var logSampleRate = 1.0
+
+// statusRecorder remembers the status code and body size of a response.
+type statusRecorder struct {
+    http.ResponseWriter
+    status int
+    bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+    if s.status == 0 { s.status = code }
+    s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+    if s.status == 0 { s.status = http.StatusOK }
+    n, err := s.ResponseWriter.Write(b)
+    s.bytes += n
+    return n, err
+}
+
+// withRequestLog logs one key=value line per request. Error responses
+// (status >= 400) are always logged; the rest only for a logSampleRate
+// fraction of requests, decided up front so skipped requests cost one
+// random draw.
+func withRequestLog(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        sampled := logSampleRate >= 1 || logSampleRate > 0 && rand.Float64() < logSampleRate
+        start := time.Now()
+        rec := &statusRecorder{ResponseWriter: w}
+        next.ServeHTTP(rec, r)
+        if rec.status == 0 { rec.status = http.StatusOK }
+        if !sampled && rec.status < 400 { return }
+        msg := "request"
+        if id := r.Header.Get(requestIDHeader); id != "" { msg += " request_id=" + id }
+        log.Printf("%s method=%s path=%q status=%d bytes=%d duration_ms=%d remote=%s",
+            msg, r.Method, r.URL.Path, rec.status, rec.bytes, time.Since(start).Milliseconds(), r.RemoteAddr)
+    })
+}
+
+// withRequestID gives every request an ID: the client's X-Request-Id when it
+// is a sane token, a random one otherwise. The ID is echoed in the response,
+// seen by the handlers below in the request header and put in the context,
+// where upstream clients with a request_id_header pick it up.
+func withRequestID(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get(requestIDHeader)
+        if !validRequestID(id) {
+            id = newRequestID()
+            r.Header.Set(requestIDHeader, id)
+        }
+        w.Header().Set(requestIDHeader, id)
+        next.ServeHTTP(w, r.WithContext(httpx.WithRequestID(r.Context(), id)))
+    })
+}
+
+// newRequestID returns a random 16-character hex ID.
+func newRequestID() string { return fmt.Sprintf("%016x", rand.Uint64()) }
+
+// validRequestID accepts 1-128 printable ASCII characters without spaces,
+// so a client's ID can be logged and forwarded upstream verbatim.
+func validRequestID(id string) bool {
+    if id == "" || len(id) > 128 { return false }
+    for i := 0; i < len(id); i++ {
+        if id[i] <= ' ' || id[i] > '~' { return false }
+    }
+    return true
+}
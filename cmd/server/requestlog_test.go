@@ -0,0 +1,64 @@
+package main
+
+import (
+    "bytes"
+    "log"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+
+    "priceprovider/internal/httpx"
+)
+
+func TestRequestLog_SampleRate(t *testing.T) {
+    var buf bytes.Buffer
+    out := log.Writer()
+    log.SetOutput(&buf)
+    t.Cleanup(func() { log.SetOutput(out); logSampleRate = 1 })
+
+    h := withRequestLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/fail" {
+            writeError(w, "boom", http.StatusBadGateway)
+            return
+        }
+        _, _ = w.Write([]byte("ok"))
+    }))
+    run := func() (ok, failed int) {
+        buf.Reset()
+        for range 20 {
+            h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/quotes", nil))
+            h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+        }
+        for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+            if strings.Contains(line, `path="/api/quotes" status=200 bytes=2`) { ok++ }
+            if strings.Contains(line, `path="/fail" status=502`) { failed++ }
+        }
+        return ok, failed
+    }
+
+    logSampleRate = 0
+    if ok, failed := run(); ok != 0 || failed != 20 { t.Fatalf("rate 0: want only the 20 errors logged, got ok=%d failed=%d:\n%s", ok, failed, buf.String()) }
+    logSampleRate = 1
+    if ok, failed := run(); ok != 20 || failed != 20 { t.Fatalf("rate 1: want every request logged, got ok=%d failed=%d:\n%s", ok, failed, buf.String()) }
+}
+
+func TestRequestID_EchoedAndPropagated(t *testing.T) {
+    var seen string
+    h := withRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        seen = httpx.RequestID(r.Context())
+    }))
+    serve := func(id string) (echoed string) {
+        req := httptest.NewRequest(http.MethodGet, "/api/quotes", nil)
+        if id != "" { req.Header.Set(requestIDHeader, id) }
+        rec := httptest.NewRecorder()
+        h.ServeHTTP(rec, req)
+        return rec.Header().Get(requestIDHeader)
+    }
+
+    if echoed := serve("abc-123"); echoed != "abc-123" || seen != "abc-123" { t.Fatalf("want the client's ID kept, got echoed=%q context=%q", echoed, seen) }
+    for _, bad := range []string{"", "has space", strings.Repeat("x", 129)} {
+        echoed := serve(bad)
+        if echoed == bad || len(echoed) != 16 || seen != echoed { t.Fatalf("%q: want a generated ID, got echoed=%q context=%q", bad, echoed, seen) }
+    }
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+    "log"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+)
+
+// sanityMaxDeviation flags quotes priced more than this factor away from the
+// cross-provider median of their symbol (server.sanity_max_deviation; 0
+// disables). sanityDrop also removes them from responses. Both are
+// initialized from config on startup.
+var (
+    sanityMaxDeviation float64
+    sanityDrop         bool
+)
+
+// checkSanity logs quotes that fail the cross-provider deviation check and,
+// with sanityDrop, returns qs without them.
+func checkSanity(qs []provider.Quote) []provider.Quote {
+    if sanityMaxDeviation <= 1 { return qs }
+    outliers := aggregate.FindOutliers(qs, sanityMaxDeviation)
+    if len(outliers) == 0 { return qs }
+    for _, o := range outliers {
+        log.Printf("sanity: %s quote for %q at %s %s deviates more than %gx from median %s (source %s)",
+            o.Quote.Provider, o.Quote.Symbol, o.Quote.Price, o.Quote.Currency, sanityMaxDeviation, o.Median, o.Quote.Source)
+    }
+    if !sanityDrop { return qs }
+    drop := make(map[int]bool, len(outliers))
+    for _, o := range outliers { drop[o.Index] = true }
+    out := make([]provider.Quote, 0, len(qs)-len(outliers))
+    for i, q := range qs {
+        if !drop[i] { out = append(out, q) }
+    }
+    return out
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "strings"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/pricempireadapter"
+)
+
+// symbolSearcher is implemented by providers that can list known item names
+// (currently the Pricempire adapter, from its cached dataset). Search must
+// not call upstream: it bypasses the limits of the provider chain.
+type symbolSearcher interface {
+    Search(ctx context.Context, q string, limit int) ([]string, error)
+}
+
+const (
+    defaultSearchLimit = 20
+    maxSearchLimit     = 100
+)
+
+// findSearcher returns the first searchable layer among providers' wrapper chains.
+func findSearcher(providers []provider.Provider) symbolSearcher {
+    for _, p := range providers {
+        for _, layer := range provider.Chain(p) {
+            if s, ok := layer.(symbolSearcher); ok { return s }
+        }
+    }
+    return nil
+}
+
+// handleSearch serves GET /api/search?q=redline&limit=20 with matching market hash names.
+func handleSearch(providers []provider.Provider) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        q := strings.TrimSpace(r.URL.Query().Get("q"))
+        if q == "" {
+            writeError(w, "missing q query param", http.StatusBadRequest)
+            return
+        }
+        limit := defaultSearchLimit
+        if v := r.URL.Query().Get("limit"); v != "" {
+            if _, err := fmt.Sscanf(v, "%d", &limit); err != nil || limit <= 0 {
+                writeError(w, "invalid limit", http.StatusBadRequest)
+                return
+            }
+            if limit > maxSearchLimit { limit = maxSearchLimit }
+        }
+        s := findSearcher(providers)
+        if s == nil {
+            writeError(w, "search requires the Pricempire provider", http.StatusNotImplemented)
+            return
+        }
+        ctx, cancel := context.WithTimeout(r.Context(), requestDeadline)
+        defer cancel()
+        items, err := s.Search(ctx, q, limit)
+        if errors.Is(err, pricempireadapter.ErrSearchCold) {
+            // filled by quote requests and warm-up, which go through the limits
+            w.Header().Set("Retry-After", "5")
+            writeError(w, err.Error(), http.StatusServiceUnavailable)
+            return
+        }
+        if err != nil {
+            writeError(w, err.Error(), http.StatusBadGateway)
+            return
+        }
+        w.WriteHeader(http.StatusOK)
+        enc := json.NewEncoder(w)
+        enc.SetEscapeHTML(false)
+        _ = enc.Encode(struct { Items []string `json:"items"` }{Items: items})
+    }
+}
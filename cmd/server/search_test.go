@@ -0,0 +1,40 @@
+package main
+
+import (
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/pricempire"
+    "priceprovider/internal/provider/pricempireadapter"
+)
+
+func TestSearch_ColdIndexIs503WithoutUpstreamCall(t *testing.T) {
+    var calls atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        _ = json.NewEncoder(w).Encode(map[string]any{"AK-47 | Redline (Field-Tested)": map[string]any{"buff": map[string]any{"price": 1.0}}})
+    }))
+    defer srv.Close()
+    client, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL(srv.URL))
+    if err != nil { t.Fatalf("client: %v", err) }
+    adapter := pricempireadapter.New(pricempireadapter.Config{Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60}, client)
+    h := handleSearch([]provider.Provider{adapter})
+
+    search := func() *httptest.ResponseRecorder {
+        rr := httptest.NewRecorder()
+        h(rr, httptest.NewRequest(http.MethodGet, "/api/search?q=redline", nil))
+        return rr
+    }
+    if rr := search(); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" { t.Fatalf("want 503 with Retry-After while cold, got %d %q", rr.Code, rr.Body.String()) }
+    if n := calls.Load(); n != 0 { t.Fatalf("search called upstream %d times", n) }
+
+    if _, err := adapter.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"}); err != nil { t.Fatalf("fetch: %v", err) }
+    rr := search()
+    var body struct{ Items []string `json:"items"` }
+    if err := json.Unmarshal(rr.Body.Bytes(), &body); rr.Code != http.StatusOK || err != nil || len(body.Items) != 1 { t.Fatalf("want the cached name, got %d %s", rr.Code, rr.Body.String()) }
+    if n := calls.Load(); n != 1 { t.Fatalf("want only the fetch upstream, got %d calls", n) }
+}
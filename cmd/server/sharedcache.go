@@ -0,0 +1,30 @@
+package main
+
+import (
+    "fmt"
+    "strings"
+    "time"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/provider/cache"
+)
+
+// newSharedCache builds the store shared by every provider cache, or nil for
+// the default memory backend where each instance keeps to itself.
+func newSharedCache(c config.Cache) (cache.Store, error) {
+    switch b := strings.ToLower(strings.TrimSpace(c.Backend)); b {
+    case "", "memory":
+        return nil, nil
+    case "redis":
+        if strings.TrimSpace(c.RedisAddr) == "" { return nil, fmt.Errorf("cache.backend=redis needs cache.redis_addr") }
+        return cache.NewRedis(cache.RedisConfig{
+            Addr:     c.RedisAddr,
+            Password: c.RedisPassword,
+            DB:       c.RedisDB,
+            Prefix:   c.RedisPrefix,
+            Timeout:  time.Duration(c.RedisTimeoutMs) * time.Millisecond,
+        })
+    default:
+        return nil, fmt.Errorf("invalid cache.backend %q (memory|redis)", b)
+    }
+}
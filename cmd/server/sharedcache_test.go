@@ -0,0 +1,18 @@
+package main
+
+import (
+    "testing"
+
+    "priceprovider/internal/config"
+)
+
+func TestNewSharedCache_Backends(t *testing.T) {
+    for _, b := range []string{"", "memory", " Memory "} {
+        s, err := newSharedCache(config.Cache{Backend: b})
+        if err != nil || s != nil { t.Fatalf("backend %q: want no shared store, got %v, %v", b, s, err) }
+    }
+    if _, err := newSharedCache(config.Cache{Backend: "redis"}); err == nil { t.Fatalf("want an error for redis without an address") }
+    if _, err := newSharedCache(config.Cache{Backend: "memcached"}); err == nil { t.Fatalf("want an error for an unknown backend") }
+    s, err := newSharedCache(config.Cache{Backend: "redis", RedisAddr: "127.0.0.1:6379"})
+    if err != nil || s == nil { t.Fatalf("want a redis store, got %v, %v", s, err) }
+}
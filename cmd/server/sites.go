@@ -0,0 +1,43 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "strings"
+
+    "priceprovider/internal/provider/cache"
+    "priceprovider/internal/provider/skinstablexyz"
+)
+
+// skinstableSites are the configured SkinstableXYZ sites /api/quotes?sites=
+// may pick from (empty while skinstable is disabled), and skinstableName the
+// provider they belong to. They are initialized from config on startup.
+var (
+    skinstableSites []string
+    skinstableName  = "SkinstableXYZ"
+)
+
+// parseSites resolves a ?sites= list against skinstableSites, returning the
+// configured spelling of each.
+func parseSites(v string) ([]string, error) {
+    if len(skinstableSites) == 0 { return nil, fmt.Errorf("sites requires skinstable to be enabled") }
+    var out []string
+    for _, s := range splitCSV(v) {
+        found := ""
+        for _, site := range skinstableSites {
+            if strings.EqualFold(s, site) { found = site; break }
+        }
+        if found == "" { return nil, fmt.Errorf("unknown site %q (configured: %s)", s, strings.Join(skinstableSites, ", ")) }
+        out = append(out, found)
+    }
+    return out, nil
+}
+
+// withSites narrows SkinstableXYZ to sites for one request: the provider
+// refreshes and serves only those, and its cache filters stored entries
+// instead of storing the narrowed answer.
+func withSites(ctx context.Context, sites []string) context.Context {
+    if len(sites) == 0 { return ctx }
+    ctx = skinstablexyz.WithSites(ctx, sites)
+    return cache.WithScope(ctx, skinstableName, skinstablexyz.InSites(sites))
+}
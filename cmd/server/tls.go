@@ -0,0 +1,70 @@
+package main
+
+import (
+    "context"
+    "crypto/tls"
+    "fmt"
+    "log"
+    "os"
+    "os/signal"
+    "sync"
+    "syscall"
+)
+
+// certReloader serves the certificate loaded from certFile/keyFile and can
+// swap it at runtime (SIGHUP) without dropping the listener.
+type certReloader struct {
+    certFile string
+    keyFile  string
+
+    mu   sync.RWMutex
+    cert *tls.Certificate
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+    r := &certReloader{certFile: certFile, keyFile: keyFile}
+    if err := r.Load(); err != nil { return nil, err }
+    return r, nil
+}
+
+// Load reads the key pair from disk. On error the previous certificate stays in use.
+func (r *certReloader) Load() error {
+    cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+    if err != nil { return fmt.Errorf("load tls key pair: %w", err) }
+    r.mu.Lock()
+    r.cert = &cert
+    r.mu.Unlock()
+    return nil
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+    r.mu.RLock()
+    defer r.mu.RUnlock()
+    return r.cert, nil
+}
+
+// TLSConfig returns a server config that always presents the current certificate.
+func (r *certReloader) TLSConfig() *tls.Config {
+    return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: r.GetCertificate}
+}
+
+// reloadOnSIGHUP reloads the certificate whenever the process receives SIGHUP.
+func (r *certReloader) reloadOnSIGHUP(ctx context.Context) {
+    ch := make(chan os.Signal, 1)
+    signal.Notify(ch, syscall.SIGHUP)
+    go func() {
+        defer signal.Stop(ch)
+        for {
+            select {
+            case <-ctx.Done():
+                return
+            case <-ch:
+                if err := r.Load(); err != nil {
+                    log.Printf("tls reload: %v (keeping previous certificate)", err)
+                    continue
+                }
+                log.Printf("tls: reloaded certificate from %s", r.certFile)
+            }
+        }
+    }()
+}
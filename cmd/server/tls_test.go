@@ -0,0 +1,82 @@
+package main
+
+import (
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/tls"
+    "crypto/x509"
+    "crypto/x509/pkix"
+    "encoding/pem"
+    "io"
+    "math/big"
+    "net"
+    "net/http"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// writeSelfSigned writes a self-signed localhost cert/key pair and returns the parsed cert.
+func writeSelfSigned(t *testing.T, certFile, keyFile string, serial int64) *x509.Certificate {
+    t.Helper()
+    key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil { t.Fatalf("key: %v", err) }
+    tmpl := &x509.Certificate{
+        SerialNumber: big.NewInt(serial),
+        Subject:      pkix.Name{CommonName: "localhost"},
+        NotBefore:    time.Now().Add(-time.Hour),
+        NotAfter:     time.Now().Add(time.Hour),
+        IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+        KeyUsage:     x509.KeyUsageDigitalSignature,
+        ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+        IsCA:         true,
+        BasicConstraintsValid: true,
+    }
+    der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+    if err != nil { t.Fatalf("cert: %v", err) }
+    kb, err := x509.MarshalECPrivateKey(key)
+    if err != nil { t.Fatalf("marshal key: %v", err) }
+    if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil { t.Fatalf("write cert: %v", err) }
+    if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kb}), 0o600); err != nil { t.Fatalf("write key: %v", err) }
+    cert, _ := x509.ParseCertificate(der)
+    return cert
+}
+
+func TestTLS_ServesHealthzAndReloadsCert(t *testing.T) {
+    dir := t.TempDir()
+    certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+    first := writeSelfSigned(t, certFile, keyFile, 1)
+
+    certs, err := newCertReloader(certFile, keyFile)
+    if err != nil { t.Fatalf("reloader: %v", err) }
+    mux := http.NewServeMux()
+    mux.HandleFunc("/healthz", handleHealthz)
+    srv := &http.Server{Handler: mux, TLSConfig: certs.TLSConfig()}
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil { t.Fatalf("listen: %v", err) }
+    go func() { _ = srv.ServeTLS(ln, "", "") }()
+    t.Cleanup(func() { _ = srv.Close() })
+
+    get := func(trusted *x509.Certificate) (*http.Response, error) {
+        pool := x509.NewCertPool()
+        pool.AddCert(trusted)
+        client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+        return client.Get("https://" + ln.Addr().String() + "/healthz")
+    }
+
+    res, err := get(first)
+    if err != nil { t.Fatalf("https get: %v", err) }
+    body, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if res.StatusCode != 200 || string(body) != "ok" { t.Fatalf("unexpected healthz: %d %q", res.StatusCode, body) }
+
+    // swap the cert on disk; new connections present it after Load
+    second := writeSelfSigned(t, certFile, keyFile, 2)
+    if err := certs.Load(); err != nil { t.Fatalf("reload: %v", err) }
+    res, err = get(second)
+    if err != nil { t.Fatalf("https get after reload: %v", err) }
+    res.Body.Close()
+    if got := res.TLS.PeerCertificates[0].SerialNumber.Int64(); got != 2 { t.Fatalf("want reloaded cert serial 2, got %d", got) }
+}
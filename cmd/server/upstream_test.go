@@ -0,0 +1,78 @@
+package main
+
+import (
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "priceprovider/internal/config"
+    "priceprovider/internal/httpx"
+)
+
+func TestNewPricempireClient_UsesConfiguredBaseURL(t *testing.T) {
+    var gotPath, gotKey string
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotPath, gotKey = r.URL.Path, r.URL.Query().Get("api_key")
+        w.Header().Set("Content-Type", "application/json")
+        _, _ = io.WriteString(w, `{"AK-47 | Redline (Field-Tested)":{"buff":{"price":1234}}}`)
+    }))
+    defer ts.Close()
+
+    c, err := newPricempireClient(config.Pricempire{APIKey: "k", BaseURL: ts.URL + "/"}, ts.Client())
+    if err != nil { t.Fatalf("client: %v", err) }
+    items, err := c.GetAllItemsV3(t.Context(), 730, "USD", []string{"buff"})
+    if err != nil { t.Fatalf("get: %v", err) }
+    if gotPath != "/v3/items/prices" || gotKey != "k" { t.Fatalf("unexpected request path=%q api_key=%q", gotPath, gotKey) }
+    if len(items) != 1 || items[0].Name != "AK-47 | Redline (Field-Tested)" { t.Fatalf("unexpected items: %+v", items) }
+}
+
+func TestNewPricempireClient_GoesThroughHTTPXDo(t *testing.T) {
+    var gotID string
+    ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotID = r.Header.Get("X-Request-Id")
+        if r.URL.Query().Get("sources") == "slow" { time.Sleep(200 * time.Millisecond) }
+        w.Header().Set("Content-Type", "application/json")
+        _, _ = io.WriteString(w, `{}`)
+    }))
+    defer ts.Close()
+
+    hc := httpx.New(5 * time.Second).WithRequestTimeout(50 * time.Millisecond).WithRequestIDHeader("X-Request-Id")
+    c, err := newPricempireClient(config.Pricempire{APIKey: "k", BaseURL: ts.URL}, httpxDoer{hc})
+    if err != nil { t.Fatalf("client: %v", err) }
+    if _, err := c.GetAllItemsV3(httpx.WithRequestID(t.Context(), "req-1"), 730, "USD", []string{"buff"}); err != nil { t.Fatalf("get: %v", err) }
+    if gotID != "req-1" { t.Fatalf("want the request ID upstream, got %q", gotID) }
+    if _, err := c.GetAllItemsV3(t.Context(), 730, "USD", []string{"slow"}); err == nil { t.Fatalf("want the per-provider request timeout to cut off a slow call") }
+}
+
+func TestProviderClient_PerHostLimitsPerProvider(t *testing.T) {
+    cfg := config.Default()
+    shared, err := newUpstreamClient(cfg, 10, nil, clientOptions{})
+    if err != nil { t.Fatalf("shared: %v", err) }
+    limit := func(c *httpx.Client) int { return c.HTTP.Transport.(*http.Transport).MaxConnsPerHost }
+
+    steam, err := providerClient(cfg, 10, shared, "steamdt", clientOptions{MaxConnsPerHost: 2})
+    if err != nil { t.Fatalf("steamdt: %v", err) }
+    skins, err := providerClient(cfg, 10, shared, "skinstable", clientOptions{MaxConnsPerHost: 50})
+    if err != nil { t.Fatalf("skinstable: %v", err) }
+    pe, err := providerClient(cfg, 10, shared, "pricempire", clientOptions{})
+    if err != nil { t.Fatalf("pricempire: %v", err) }
+
+    if steam == shared || skins == shared || steam.HTTP == skins.HTTP { t.Fatalf("want separate clients for providers with a connection limit") }
+    if got := limit(steam); got != 2 { t.Fatalf("steamdt: want 2 connections per host, got %d", got) }
+    if got := limit(skins); got != 50 { t.Fatalf("skinstable: want 50 connections per host, got %d", got) }
+    if pe != shared || limit(shared) != 100 { t.Fatalf("want pricempire on the untouched shared client, got limit %d", limit(pe)) }
+}
+
+func TestProviderClient_SigningGetsOwnClient(t *testing.T) {
+    cfg := config.Default()
+    shared, err := newUpstreamClient(cfg, 10, nil, clientOptions{})
+    if err != nil { t.Fatalf("shared: %v", err) }
+    c, err := providerClient(cfg, 10, shared, "steamdt", clientOptions{Signing: httpx.Signing{Secret: "s", Header: "X-Mirror-Sig"}})
+    if err != nil { t.Fatalf("steamdt: %v", err) }
+    st, ok := c.HTTP.Transport.(*httpx.SignTransport)
+    if c == shared || !ok || st.Signing.Header != "X-Mirror-Sig" { t.Fatalf("want a separate signing client, got %T", c.HTTP.Transport) }
+    if _, ok := shared.HTTP.Transport.(*http.Transport); !ok { t.Fatalf("shared client must stay unsigned, got %T", shared.HTTP.Transport) }
+    if _, err := providerClient(cfg, 10, shared, "steamdt", clientOptions{Signing: httpx.Signing{Secret: "s", Algorithm: "md5"}}); err == nil { t.Fatalf("want an error for an unsupported algorithm") }
+}
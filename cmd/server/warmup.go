@@ -0,0 +1,89 @@
+package main
+
+import (
+    "bufio"
+    "context"
+    "log"
+    "net/http"
+    "os"
+    "strings"
+    "sync"
+    "sync/atomic"
+
+    "priceprovider/internal/provider"
+)
+
+// ready gates /readyz. It flips to true once startup warm-up has finished
+// (immediately when no warm-up symbols are configured).
+var ready atomic.Bool
+
+// warmupBatch is the number of symbols fetched per warm-up call.
+const warmupBatch = 100
+
+// loadWarmupSymbols merges the configured list with the symbols in path (one
+// per line; blank lines and lines starting with '#' are skipped). Duplicates are dropped.
+func loadWarmupSymbols(list []string, path string) ([]string, error) {
+    all := append([]string(nil), list...)
+    if path = strings.TrimSpace(path); path != "" {
+        f, err := os.Open(path)
+        if err != nil { return nil, err }
+        defer f.Close()
+        sc := bufio.NewScanner(f)
+        for sc.Scan() {
+            line := strings.TrimSpace(sc.Text())
+            if line == "" || strings.HasPrefix(line, "#") { continue }
+            all = append(all, line)
+        }
+        if err := sc.Err(); err != nil { return nil, err }
+    }
+    seen := make(map[string]struct{}, len(all))
+    out := all[:0]
+    for _, s := range all {
+        s = strings.TrimSpace(s)
+        if s == "" { continue }
+        if _, ok := seen[s]; ok { continue }
+        seen[s] = struct{}{}
+        out = append(out, s)
+    }
+    return out, nil
+}
+
+// warmup fetches symbols through every enabled provider so their caches are
+// populated before traffic arrives. Calls go through the full wrapper chain,
+// so rate limits apply; providers run in parallel, batches within one provider
+// run in sequence. Errors are logged and do not stop the warm-up.
+func warmup(ctx context.Context, providers []provider.Provider, symbols []string) {
+    if upstreamsDisabled.Load() {
+        log.Printf("warmup skipped: upstreams disabled")
+        return
+    }
+    var wg sync.WaitGroup
+    for _, p := range providers {
+        if toggles.Disabled(p.Name()) { continue }
+        wg.Add(1)
+        go func(p provider.Provider) {
+            defer wg.Done()
+            n := 0
+            for i := 0; i < len(symbols); i += warmupBatch {
+                if ctx.Err() != nil { return }
+                batch := symbols[i:min(i+warmupBatch, len(symbols))]
+                fctx, cancel := context.WithTimeout(ctx, requestDeadline)
+                qs, err := p.Fetch(fctx, batch)
+                cancel()
+                if err != nil { log.Printf("warmup %s: %v", p.Name(), err) }
+                n += len(qs)
+            }
+            log.Printf("warmup %s: %d quotes for %d symbols", p.Name(), n, len(symbols))
+        }(p)
+    }
+    wg.Wait()
+}
+
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+    if !ready.Load() {
+        http.Error(w, "warming up", http.StatusServiceUnavailable)
+        return
+    }
+    w.WriteHeader(http.StatusOK)
+    _, _ = w.Write([]byte("ready"))
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+func TestWarmup_PopulatesProviderCache(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    upstream := &countingFetcher{fakeProvider: fakeProvider{"steamdt", []provider.Quote{{Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts}}}}
+    p := wrapProvider(upstream, wrapOptions{CacheTTLSec: 60})
+
+    warmup(t.Context(), []provider.Provider{p}, []string{sym})
+    if n := upstream.calls.Load(); n != 1 { t.Fatalf("want 1 warm-up fetch, got %d", n) }
+
+    qs, err := p.Fetch(t.Context(), []string{sym})
+    if err != nil || len(qs) != 1 { t.Fatalf("fetch: %v %+v", err, qs) }
+    if n := upstream.calls.Load(); n != 1 { t.Fatalf("want request served from warmed cache, got %d upstream calls", n) }
+}
+
+func TestLoadWarmupSymbols_MergesFile(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "symbols.txt")
+    if err := os.WriteFile(path, []byte("# popular\nB\n\nA\nC\n"), 0o644); err != nil { t.Fatalf("write: %v", err) }
+    got, err := loadWarmupSymbols([]string{"A", " B "}, path)
+    if err != nil { t.Fatalf("load: %v", err) }
+    if len(got) != 3 || got[0] != "A" || got[1] != "B" || got[2] != "C" { t.Fatalf("unexpected symbols: %q", got) }
+}
+
+func TestReadyz_GatedOnWarmup(t *testing.T) {
+    t.Cleanup(func() { ready.Store(false) })
+    ready.Store(false)
+    rr := httptest.NewRecorder()
+    handleReadyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+    if rr.Code != http.StatusServiceUnavailable { t.Fatalf("want 503 before warm-up, got %d", rr.Code) }
+    ready.Store(true)
+    rr = httptest.NewRecorder()
+    handleReadyz(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+    if rr.Code != http.StatusOK { t.Fatalf("want 200 after warm-up, got %d", rr.Code) }
+}
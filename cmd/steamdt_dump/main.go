@@ -1,7 +1,6 @@
 package main
 
 import (
-    "bufio"
     "bytes"
     "context"
     "encoding/json"
@@ -15,6 +14,7 @@ import (
     "sync"
     "time"
 
+    "priceprovider/internal/backoff"
     "priceprovider/internal/config"
 )
 
@@ -44,6 +44,7 @@ func main() {
         timeoutSec  int
         maxRetries  int
         rpm         int
+        backoffName string
     )
     flag.StringVar(&symbolsFile, "symbols-file", "pricempire_all_prices.json", "JSON file with keys as marketHashNames")
     flag.StringVar(&outPath, "out", "steamdt_all_prices.json", "output JSON file path")
@@ -53,6 +54,7 @@ func main() {
     flag.IntVar(&timeoutSec, "timeout", 20, "HTTP timeout seconds")
     flag.IntVar(&maxRetries, "retries", 3, "max retries on 429/5xx")
     flag.IntVar(&rpm, "rpm", 0, "max requests per minute (0 = unlimited)")
+    flag.StringVar(&backoffName, "backoff", "", "retry jitter strategy: full|equal|decorrelated|none (default steamdt.retry_backoff or full)")
     flag.Parse()
 
     // Load config/env
@@ -63,6 +65,11 @@ func main() {
     if cfg.SteamDT.APIKey == "" {
         log.Fatal("STEAMDT_API_KEY missing (set in config.json or env)")
     }
+    if backoffName == "" { backoffName = cfg.SteamDT.RetryBackoff }
+    // validate once up front; each retry loop gets its own instance
+    if _, err := backoff.New(backoffName, 250*time.Millisecond, 30*time.Second); err != nil {
+        log.Fatalf("backoff: %v", err)
+    }
     endpoint := cfg.SteamDT.Endpoint
     if endpoint == "" {
         endpoint = "https://open.steamdt.com/open/cs2/v1/price/batch"
@@ -81,19 +88,15 @@ func main() {
     // Prepare HTTP client
     hc := &http.Client{Timeout: time.Duration(timeoutSec) * time.Second}
 
-    // Prepare output writer (streaming)
-    outFile, err := os.Create(outPath)
+    // Prepare output writer (streaming into <out>.partial until done)
+    out, err := createDump(outPath)
     if err != nil {
         log.Fatalf("create out: %v", err)
     }
-    defer outFile.Close()
-    bw := bufio.NewWriterSize(outFile, 1<<20)
-    defer bw.Flush()
-
-    // Start JSON envelope
-    _, _ = bw.WriteString("{\"success\":true,\"data\":[")
-    first := true
-    var writeMu sync.Mutex
+    var (
+        fatalOnce sync.Once
+        fatalErr  error
+    )
 
     // Request rate limiter by RPM, if provided
     var tokenCh <-chan time.Time
@@ -140,6 +143,7 @@ func main() {
     fetchSplit = func(ctx context.Context, names []string) ([]json.RawMessage, error) {
         // retry loop for 429/5xx
         attempt := 0
+        bo, _ := backoff.New(backoffName, 250*time.Millisecond, 30*time.Second)
         for {
             data, err := doReq(ctx, names)
             if err == nil {
@@ -165,8 +169,7 @@ func main() {
                 // 429/5xx -> retry with backoff
                 if hs.code == 429 || (hs.code >= 500 && hs.code < 600) {
                     if attempt < maxRetries {
-                        back := time.Duration(250*(1<<attempt)) * time.Millisecond
-                        time.Sleep(back)
+                        time.Sleep(bo.Next(attempt))
                         attempt++
                         continue
                     }
@@ -180,6 +183,7 @@ func main() {
     worker := func() {
         defer wg.Done()
         for j := range jobs {
+            if out.Failed() { continue }
             ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSec)*time.Second)
             data, err := fetchSplit(ctx, j.batch)
             cancel()
@@ -190,13 +194,10 @@ func main() {
             if len(data) == 0 {
                 continue
             }
-            // write entries
-            writeMu.Lock()
-            for _, raw := range data {
-                if !first { _, _ = bw.WriteString(",") } else { first = false }
-                _, _ = bw.Write(raw)
+            // a write error is fatal; remaining batches are drained unfetched
+            if err := out.Write(data); err != nil {
+                fatalOnce.Do(func() { fatalErr = fmt.Errorf("write: %w", err) })
             }
-            writeMu.Unlock()
         }
     }
 
@@ -218,10 +219,14 @@ func main() {
     close(jobs)
     wg.Wait()
 
-    // Close JSON envelope
-    _, _ = bw.WriteString("],\"errorCode\":0,\"errorMsg\":null,\"errorData\":null,\"errorCodeStr\":null}")
-    if err := bw.Flush(); err != nil {
-        log.Fatalf("flush: %v", err)
+    // Close JSON envelope; only a complete dump replaces outPath
+    if fatalErr != nil {
+        partial, err := out.Abort(fatalErr)
+        if err != nil { log.Fatalf("%v (partial output %s may be incomplete: %v)", fatalErr, partial, err) }
+        log.Fatalf("%v (partial output kept in %s)", fatalErr, partial)
+    }
+    if err := out.Commit(); err != nil {
+        log.Fatalf("finalize: %v", err)
     }
     log.Printf("done: wrote %s", outPath)
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+    "bufio"
+    "encoding/json"
+    "fmt"
+    "os"
+    "sync"
+)
+
+// dumpWriter streams the SteamDT-shaped envelope to "<path>.partial" and
+// renames it to path only once Commit has closed the envelope, so the
+// output path never holds unbalanced JSON. Abort closes the envelope with a
+// non-zero errorCode and the error message and leaves the ".partial" file as
+// a valid (partial) document for inspection.
+type dumpWriter struct {
+    path  string
+    f     *os.File
+    bw    *bufio.Writer
+    mu    sync.Mutex
+    first bool
+    done  bool
+    err   error // first write error; later writes are skipped
+}
+
+func createDump(path string) (*dumpWriter, error) {
+    f, err := os.Create(path + ".partial")
+    if err != nil { return nil, err }
+    d := &dumpWriter{path: path, f: f, bw: bufio.NewWriterSize(f, 1<<20), first: true}
+    _, d.err = d.bw.WriteString("{\"success\":true,\"data\":[")
+    return d, nil
+}
+
+// Write appends entries to the data array. Entries that are not valid JSON
+// are dropped so a bad upstream payload cannot corrupt the document.
+func (d *dumpWriter) Write(entries []json.RawMessage) error {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if d.err != nil { return d.err }
+    if d.done { return fmt.Errorf("dump already closed") }
+    for _, raw := range entries {
+        if !json.Valid(raw) { continue }
+        if !d.first {
+            if d.err = d.bw.WriteByte(','); d.err != nil { return d.err }
+        }
+        d.first = false
+        if _, d.err = d.bw.Write(raw); d.err != nil { return d.err }
+    }
+    return nil
+}
+
+// Failed reports whether a write has failed.
+func (d *dumpWriter) Failed() bool {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    return d.err != nil
+}
+
+// Commit closes the envelope and moves the file into place.
+func (d *dumpWriter) Commit() error {
+    if err := d.finish("],\"errorCode\":0,\"errorMsg\":null,\"errorData\":null,\"errorCodeStr\":null}"); err != nil { return err }
+    return os.Rename(d.path+".partial", d.path)
+}
+
+// Abort closes the envelope as a failed dump, keeping what was written so far
+// in "<path>.partial". It returns the path of that file.
+func (d *dumpWriter) Abort(cause error) (string, error) {
+    msg, _ := json.Marshal(cause.Error())
+    err := d.finish("],\"errorCode\":1,\"errorMsg\":" + string(msg) + ",\"errorData\":null,\"errorCodeStr\":null}")
+    return d.path + ".partial", err
+}
+
+func (d *dumpWriter) finish(tail string) error {
+    d.mu.Lock()
+    defer d.mu.Unlock()
+    if d.done { return fmt.Errorf("dump already closed") }
+    d.done = true
+    if d.err == nil { _, d.err = d.bw.WriteString(tail) }
+    if d.err == nil { d.err = d.bw.Flush() }
+    if err := d.f.Close(); d.err == nil { d.err = err }
+    return d.err
+}
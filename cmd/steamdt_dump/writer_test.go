@@ -0,0 +1,40 @@
+package main
+
+import (
+    "encoding/json"
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func TestDumpWriter_AbortLeavesValidPartialJSON(t *testing.T) {
+    out := filepath.Join(t.TempDir(), "dump.json")
+    d, err := createDump(out)
+    if err != nil { t.Fatalf("create: %v", err) }
+    if err := d.Write([]json.RawMessage{json.RawMessage(`{"marketHashName":"A"}`), json.RawMessage(`{"marketHash`)}); err != nil { t.Fatalf("write: %v", err) }
+    partial, err := d.Abort(errors.New("boom"))
+    if err != nil { t.Fatalf("abort: %v", err) }
+
+    if _, err := os.Stat(out); !os.IsNotExist(err) { t.Fatalf("output path must not exist after a failed run: %v", err) }
+    b, err := os.ReadFile(partial)
+    if err != nil { t.Fatalf("read partial: %v", err) }
+    var doc apiResp
+    if err := json.Unmarshal(b, &doc); err != nil { t.Fatalf("partial output is not valid JSON: %v\n%s", err, b) }
+    if len(doc.Data) != 1 || doc.ErrorCode == 0 || doc.ErrorMsg != "boom" { t.Fatalf("unexpected partial document: %s", b) }
+}
+
+func TestDumpWriter_CommitRenamesIntoPlace(t *testing.T) {
+    out := filepath.Join(t.TempDir(), "dump.json")
+    d, err := createDump(out)
+    if err != nil { t.Fatalf("create: %v", err) }
+    _ = d.Write([]json.RawMessage{json.RawMessage(`{"marketHashName":"A"}`)})
+    _ = d.Write([]json.RawMessage{json.RawMessage(`{"marketHashName":"B"}`)})
+    if err := d.Commit(); err != nil { t.Fatalf("commit: %v", err) }
+
+    if _, err := os.Stat(out + ".partial"); !os.IsNotExist(err) { t.Fatalf("partial file left behind: %v", err) }
+    b, err := os.ReadFile(out)
+    if err != nil { t.Fatalf("read: %v", err) }
+    var doc apiResp
+    if err := json.Unmarshal(b, &doc); err != nil || len(doc.Data) != 2 || !doc.Success { t.Fatalf("unexpected output (%v): %s", err, b) }
+}
@@ -1,10 +1,12 @@
 package aggregate
 
 import (
+    "fmt"
     "sort"
     "strings"
     "time"
 
+    "priceprovider/internal/money"
     "priceprovider/internal/provider"
 )
 
@@ -14,6 +16,7 @@ type MarketKey struct {
     Market   string
     Side     string
     Currency string
+    AppID    int
 }
 
 // Latest is the latest quote per MarketKey.
@@ -25,14 +28,18 @@ type Latest struct {
     Price      string    `json:"price"`
     Provider   string    `json:"provider"`
     ReceivedAt time.Time `json:"received_at"`
+    AppID      int       `json:"app_id,omitempty"`
+    // Amount is Price as parsed by the provider; zero when it did not parse.
+    Amount     money.Amount `json:"-"`
 }
 
 // NormalizeSource extracts market and side from a quote Source.
 // Rules:
 // - Split on ':'
 // - SteamDT: parts[1]=market, parts[2]=side (sell|bid) when len>=3
-// - Pricempire: parts[1]=market when len>=2 (no side)
-// - SkinstableXYZ: parts[1]=market when len>=2 (no side)
+// - Pricempire: parts[1]=market when len>=2; parts[2] ("avg30") keeps the
+//   30-day average apart from the spot price
+// - SkinstableXYZ: parts[1]=market when len>=2; parts[2]="bid" for buy orders
 // - Normalize case and aliases for market; side lower-cased; trim spaces.
 //   Aliases:
 //     BUFF, buff, BUFF.163, BUFF163 -> BUFF
@@ -53,25 +60,20 @@ var aliasMap = map[string]string{
     "skinport": "Skinport",
 }
 
+// SideAvg30 is the side NormalizeSource reports for Pricempire's 30-day
+// averages. They are not live prices: consensus and stats leave them out,
+// and LatestByMarket keeps them apart even when sides are merged.
+const SideAvg30 = "avg30"
+
 func NormalizeSource(src string) (market string, side string) {
     s := strings.TrimSpace(src)
     if s == "" { return "", "" }
     parts := strings.Split(s, ":")
     if len(parts) == 0 { return "", "" }
-    pref := strings.TrimSpace(parts[0])
-    lpref := strings.ToLower(pref)
 
     var mraw, sraw string
-    switch lpref {
-    case "steamdt":
-        if len(parts) >= 2 { mraw = parts[1] }
-        if len(parts) >= 3 { sraw = parts[2] }
-    case "pricempire", "skinstablexyz":
-        if len(parts) >= 2 { mraw = parts[1] }
-    default:
-        if len(parts) >= 2 { mraw = parts[1] }
-        if len(parts) >= 3 { sraw = parts[2] }
-    }
+    if len(parts) >= 2 { mraw = parts[1] }
+    if len(parts) >= 3 { sraw = parts[2] }
 
     m := strings.TrimSpace(mraw)
     if norm, ok := aliasMap[strings.ToLower(m)]; ok {
@@ -91,50 +93,70 @@ func NormalizeSource(src string) (market string, side string) {
     return market, side
 }
 
-// LatestByMarket collapses quotes by (Symbol, Market, Side?, Currency) keeping the newest.
-// If includeSides is false, side is forced to "" for grouping.
+// LatestByMarket collapses quotes by (Symbol, Market, Side?, Currency, AppID) keeping the newest.
+// If includeSides is false, side is forced to "" for grouping, except for
+// SideAvg30, which never shares a bucket with a live price.
 // For equal timestamps, later input wins. Zero timestamps are replaced with time.Now().UTC().
 func LatestByMarket(quotes []provider.Quote, includeSides bool) []Latest {
+    return collapseByMarket(quotes, includeSides, PickNewest)
+}
+
+// Pick selects which quote represents a market bucket.
+type Pick string
+
+const (
+    // PickNewest keeps the most recently received quote (the default).
+    PickNewest Pick = "newest"
+    // PickLowest keeps the lowest price (best case for a buyer).
+    PickLowest Pick = "lowest"
+    // PickHighest keeps the highest price (worst case for a buyer).
+    PickHighest Pick = "highest"
+)
+
+// ParsePick validates a pick mode; empty means PickNewest.
+func ParsePick(s string) (Pick, error) {
+    switch p := Pick(strings.ToLower(strings.TrimSpace(s))); p {
+    case "":
+        return PickNewest, nil
+    case PickNewest, PickLowest, PickHighest:
+        return p, nil
+    default:
+        return "", fmt.Errorf("invalid pick %q (newest|lowest|highest)", s)
+    }
+}
+
+// PickByMarket collapses quotes by (Symbol, Market, Side, Currency, AppID) like
+// LatestByMarket, choosing the representative quote by pick. Prices are compared
+// numerically; unparsable prices lose to parsable ones and ties go to the newer quote.
+func PickByMarket(quotes []provider.Quote, pick Pick) []Latest {
+    return collapseByMarket(quotes, true, pick)
+}
+
+func collapseByMarket(quotes []provider.Quote, includeSides bool, pick Pick) []Latest {
     now := time.Now().UTC()
     latest := make(map[MarketKey]Latest, len(quotes))
 
     for _, q := range quotes {
         market, side := NormalizeSource(q.Source)
-        if !includeSides { side = "" }
+        if !includeSides && side != SideAvg30 { side = "" }
         ts := q.ReceivedAt
         if ts.IsZero() { ts = now }
 
-        // Provider is the prefix before ':' from Source
-        providerName := ""
-        if idx := strings.Index(q.Source, ":"); idx > 0 {
-            providerName = q.Source[:idx]
-        } else {
-            providerName = q.Source
-        }
+        providerName := attribution(q)
 
-        key := MarketKey{Symbol: q.Symbol, Market: market, Side: side, Currency: q.Currency}
-        if cur, ok := latest[key]; ok {
-            if ts.After(cur.ReceivedAt) || ts.Equal(cur.ReceivedAt) {
-                latest[key] = Latest{
-                    Symbol:     q.Symbol,
-                    Market:     market,
-                    Side:       side,
-                    Currency:   q.Currency,
-                    Price:      q.Price,
-                    Provider:   providerName,
-                    ReceivedAt: ts,
-                }
-            }
-        } else {
-            latest[key] = Latest{
-                Symbol:     q.Symbol,
-                Market:     market,
-                Side:       side,
-                Currency:   q.Currency,
-                Price:      q.Price,
-                Provider:   providerName,
-                ReceivedAt: ts,
-            }
+        key := MarketKey{Symbol: q.Symbol, Market: market, Side: side, Currency: q.Currency, AppID: q.AppID}
+        price := q.PriceAmount()
+        if cur, ok := latest[key]; ok && !replaces(pick, price, cur.Amount, ts, cur.ReceivedAt) { continue }
+        latest[key] = Latest{
+            Symbol:     q.Symbol,
+            Market:     market,
+            Side:       side,
+            Currency:   q.Currency,
+            Price:      q.Price,
+            Provider:   providerName,
+            ReceivedAt: ts,
+            AppID:      q.AppID,
+            Amount:     price,
         }
     }
 
@@ -144,7 +166,248 @@ func LatestByMarket(quotes []provider.Quote, includeSides bool) []Latest {
         if out[i].Symbol != out[j].Symbol { return out[i].Symbol < out[j].Symbol }
         if out[i].Market != out[j].Market { return out[i].Market < out[j].Market }
         if out[i].Side != out[j].Side { return out[i].Side < out[j].Side }
+        if out[i].AppID != out[j].AppID { return out[i].AppID < out[j].AppID }
         return false
     })
     return out
 }
+
+// replaces reports whether a candidate quote should replace the current one.
+func replaces(pick Pick, price, curPrice money.Amount, ts, curTS time.Time) bool {
+    newer := ts.After(curTS) || ts.Equal(curTS)
+    if pick == PickNewest { return newer }
+    if !price.Valid() || !curPrice.Valid() {
+        if price.Valid() { return true }
+        if curPrice.Valid() { return false }
+        return newer
+    }
+    c := price.Cmp(curPrice)
+    if pick == PickHighest { c = -c }
+    if c != 0 { return c < 0 }
+    return newer
+}
+
+// attribution names the provider behind q: q.Provider, falling back to the
+// prefix before ':' from Source (or all of Source).
+func attribution(q provider.Quote) string {
+    if q.Provider != "" { return q.Provider }
+    if idx := strings.Index(q.Source, ":"); idx > 0 { return q.Source[:idx] }
+    return q.Source
+}
+
+// FreshestByMarket keeps the newest raw quote per (Symbol, Market, Side, Currency, AppID)
+// across providers, grouping with NormalizeSource like LatestByMarket. Sides stay
+// apart so a bid never replaces a sell. Output keeps the order in which each
+// group first appeared; for equal timestamps, later input wins. Each winner
+// keeps its Source and has Provider set (see attribution) so clients can tell
+// which provider won the bucket.
+func FreshestByMarket(quotes []provider.Quote) []provider.Quote {
+    idx := make(map[MarketKey]int, len(quotes))
+    out := make([]provider.Quote, 0, len(quotes))
+    for _, q := range quotes {
+        market, side := NormalizeSource(q.Source)
+        key := MarketKey{Symbol: q.Symbol, Market: market, Side: side, Currency: q.Currency, AppID: q.AppID}
+        i, ok := idx[key]
+        if !ok {
+            idx[key] = len(out)
+            out = append(out, q)
+            continue
+        }
+        if !q.ReceivedAt.Before(out[i].ReceivedAt) { out[i] = q }
+    }
+    for i := range out { out[i].Provider = attribution(out[i]) }
+    return out
+}
+
+// PreferProviders keeps, for each (Symbol, Market, Currency, AppID), only the
+// quotes of the highest-ranked provider present. prefer lists provider names
+// (case-insensitive) best first; unlisted providers rank after every listed
+// one and tie with each other. Sides are not part of the key, so a preferred
+// provider's sell and bid rows both survive. Input order is kept.
+func PreferProviders(quotes []provider.Quote, prefer []string) []provider.Quote {
+    if len(prefer) == 0 { return quotes }
+    rank := make(map[string]int, len(prefer))
+    for i, name := range prefer {
+        name = strings.ToLower(strings.TrimSpace(name))
+        if _, dup := rank[name]; !dup && name != "" { rank[name] = i }
+    }
+    rankOf := func(q provider.Quote) int {
+        if r, ok := rank[providerOf(q)]; ok { return r }
+        return len(prefer)
+    }
+    keyOf := func(q provider.Quote) MarketKey {
+        market, _ := NormalizeSource(q.Source)
+        return MarketKey{Symbol: q.Symbol, Market: market, Currency: q.Currency, AppID: q.AppID}
+    }
+    best := make(map[MarketKey]int, len(quotes))
+    for _, q := range quotes {
+        k, r := keyOf(q), rankOf(q)
+        if cur, ok := best[k]; !ok || r < cur { best[k] = r }
+    }
+    out := make([]provider.Quote, 0, len(quotes))
+    for _, q := range quotes {
+        if rankOf(q) == best[keyOf(q)] { out = append(out, q) }
+    }
+    return out
+}
+
+// LimitMarkets keeps the rows of at most n markets per (Symbol, AppID).
+// Markets are ranked by priority (market names, case-insensitive, best
+// first), then by their newest ReceivedAt; unlisted markets rank after every
+// listed one. All rows of a kept market survive (sides, currencies). Input
+// order is kept; n <= 0 keeps everything.
+func LimitMarkets(rows []Latest, n int, priority []string) []Latest {
+    if n <= 0 { return rows }
+    rank := make(map[string]int, len(priority))
+    for i, m := range priority {
+        m = strings.ToLower(strings.TrimSpace(m))
+        if _, dup := rank[m]; !dup && m != "" { rank[m] = i }
+    }
+    type symbolKey struct {
+        symbol string
+        appID  int
+    }
+    type market struct {
+        name   string
+        rank   int
+        newest time.Time
+    }
+    bySymbol := make(map[symbolKey][]*market)
+    index := make(map[symbolKey]map[string]*market)
+    for _, r := range rows {
+        k, name := symbolKey{r.Symbol, r.AppID}, strings.ToLower(r.Market)
+        if index[k] == nil { index[k] = make(map[string]*market) }
+        m := index[k][name]
+        if m == nil {
+            m = &market{name: name, rank: len(priority)}
+            if rk, ok := rank[name]; ok { m.rank = rk }
+            index[k][name] = m
+            bySymbol[k] = append(bySymbol[k], m)
+        }
+        if r.ReceivedAt.After(m.newest) { m.newest = r.ReceivedAt }
+    }
+    keep := make(map[symbolKey]map[string]bool, len(bySymbol))
+    for k, ms := range bySymbol {
+        sort.SliceStable(ms, func(i, j int) bool {
+            if ms[i].rank != ms[j].rank { return ms[i].rank < ms[j].rank }
+            return ms[i].newest.After(ms[j].newest)
+        })
+        keep[k] = make(map[string]bool, n)
+        for _, m := range ms[:min(n, len(ms))] { keep[k][m.name] = true }
+    }
+    out := make([]Latest, 0, len(rows))
+    for _, r := range rows {
+        if keep[symbolKey{r.Symbol, r.AppID}][strings.ToLower(r.Market)] { out = append(out, r) }
+    }
+    return out
+}
+
+// Spread is the bid-ask spread for one (Symbol, Market, Currency, AppID).
+// Prices are decimal strings; SpreadPct is relative to the ask.
+type Spread struct {
+    Symbol    string `json:"symbol"`
+    Market    string `json:"market"`
+    Currency  string `json:"currency"`
+    Bid       string `json:"bid"`
+    Ask       string `json:"ask"`
+    Spread    string `json:"spread"`
+    SpreadPct string `json:"spread_pct"`
+    AppID     int    `json:"app_id,omitempty"`
+}
+
+// SpreadByMarket pairs the latest sell (ask) and bid per market and computes
+// ask-bid and (ask-bid)/ask*100 exactly. Markets missing either side, or
+// with an unparseable price, are skipped.
+func SpreadByMarket(quotes []provider.Quote) []Spread {
+    type pair struct{ bid, ask *Latest }
+    pairs := make(map[MarketKey]*pair)
+    var order []MarketKey
+    latest := LatestByMarket(quotes, true)
+    for i := range latest {
+        l := &latest[i]
+        if l.Side != "sell" && l.Side != "bid" { continue }
+        k := MarketKey{Symbol: l.Symbol, Market: l.Market, Currency: l.Currency, AppID: l.AppID}
+        p, ok := pairs[k]
+        if !ok {
+            p = &pair{}
+            pairs[k] = p
+            order = append(order, k)
+        }
+        if l.Side == "sell" { p.ask = l } else { p.bid = l }
+    }
+
+    out := make([]Spread, 0, len(order))
+    for _, k := range order {
+        p := pairs[k]
+        if p.ask == nil || p.bid == nil { continue }
+        if !p.ask.Amount.Valid() || !p.bid.Amount.Valid() { continue }
+        diff := p.ask.Amount.Sub(p.bid.Amount)
+        out = append(out, Spread{
+            Symbol:    k.Symbol,
+            Market:    k.Market,
+            Currency:  k.Currency,
+            Bid:       p.bid.Price,
+            Ask:       p.ask.Price,
+            Spread:    diff.String(),
+            SpreadPct: diff.PercentOf(p.ask.Amount).String(),
+            AppID:     k.AppID,
+        })
+    }
+    return out
+}
+
+// Consensus is a volume-weighted average price per (Symbol, Currency, AppID).
+type Consensus struct {
+    Symbol   string `json:"symbol"`
+    Currency string `json:"currency"`
+    Price    string `json:"price"`
+    Weight   int    `json:"weight"` // sum of weights used
+    Quotes   int    `json:"quotes"` // number of quotes contributing
+    AppID    int    `json:"app_id,omitempty"`
+}
+
+// WeightedConsensus computes sum(price*w)/sum(w) per (Symbol, Currency, AppID)
+// exactly, where w is the quote's Volume (1 when unknown). Bid quotes and
+// 30-day averages are excluded so the consensus reflects live asks only;
+// unparseable or non-positive prices are skipped. Prices are rendered with
+// the most decimals seen among the inputs (at least 2). Output is sorted by symbol, currency, app id.
+func WeightedConsensus(quotes []provider.Quote) []Consensus {
+    type acc struct {
+        sum    money.Amount
+        weight int
+        n      int
+    }
+    type key struct {
+        symbol, currency string
+        appID            int
+    }
+    accs := make(map[key]*acc)
+    for _, q := range quotes {
+        if _, side := NormalizeSource(q.Source); side == "bid" || side == SideAvg30 { continue }
+        price := q.PriceAmount()
+        if !price.Valid() || price.Sign() <= 0 { continue }
+        w := q.Volume
+        if w <= 0 { w = 1 }
+        k := key{q.Symbol, q.Currency, q.AppID}
+        a, ok := accs[k]
+        if !ok {
+            a = &acc{sum: money.MustParse("0.00")}
+            accs[k] = a
+        }
+        a.sum = a.sum.Add(price.MulInt(int64(w)))
+        a.weight += w
+        a.n++
+    }
+
+    out := make([]Consensus, 0, len(accs))
+    for k, a := range accs {
+        avg := a.sum.QuoInt(int64(a.weight))
+        out = append(out, Consensus{Symbol: k.symbol, Currency: k.currency, AppID: k.appID, Price: avg.String(), Weight: a.weight, Quotes: a.n})
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Symbol != out[j].Symbol { return out[i].Symbol < out[j].Symbol }
+        if out[i].Currency != out[j].Currency { return out[i].Currency < out[j].Currency }
+        return out[i].AppID < out[j].AppID
+    })
+    return out
+}
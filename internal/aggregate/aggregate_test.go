@@ -1,6 +1,7 @@
 package aggregate
 
 import (
+    "fmt"
     "testing"
     "time"
 
@@ -130,7 +131,241 @@ func TestNormalizeSource_MarketAliases_Casing(t *testing.T) {
     m, s = NormalizeSource("Pricempire:cs.money")
     if m != "CS.MONEY" || s != "" { t.Fatalf("csmoney mapping: %s %s", m, s) }
 
+    // Pricempire avg30 stays apart from the spot price
+    m, s = NormalizeSource("Pricempire:buff:avg30")
+    if m != "BUFF" || s != "avg30" { t.Fatalf("avg30 mapping: %s %s", m, s) }
+
     // SkinstableXYZ site pass-through normalization
     m, s = NormalizeSource("SkinstableXYZ:BUFF.163")
     if m != "BUFF" || s != "" { t.Fatalf("buff163 mapping: %s %s", m, s) }
 }
+
+func TestLatestByMarket_PrefersQuoteProvider(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    out := LatestByMarket([]provider.Quote{
+        {Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT-Backup", ReceivedAt: t1},
+        {Symbol: sym, Price: "3", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: t1},
+    }, true)
+    if len(out) != 2 { t.Fatalf("want 2 rows, got %+v", out) }
+    if out[0].Provider != "SteamDT-Backup" { t.Fatalf("want quote provider, got %q", out[0].Provider) }
+    if out[1].Provider != "Pricempire" { t.Fatalf("want Source prefix fallback, got %q", out[1].Provider) }
+}
+
+func TestFreshestByMarket_AttributesWinner(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    out := FreshestByMarket([]provider.Quote{
+        {Symbol: sym, Price: "12", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: t1},
+        {Symbol: sym, Price: "13", Currency: "USD", Source: "SkinstableXYZ:Steam", Provider: "Skinstable-EU", ReceivedAt: t1.Add(time.Minute)},
+        {Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+    })
+    if len(out) != 2 { t.Fatalf("want 2 buckets, got %+v", out) }
+    if out[0].Source != "SkinstableXYZ:Steam" || out[0].Provider != "Skinstable-EU" { t.Fatalf("want the newer Skinstable row with its provider, got %+v", out[0]) }
+    if out[1].Provider != "SteamDT" { t.Fatalf("want Source prefix fallback, got %q", out[1].Provider) }
+}
+
+func TestSpreadByMarket_SteamDTSellAndBid(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    t2 := t1.Add(time.Minute)
+    out := SpreadByMarket([]provider.Quote{
+        {Symbol: sym, Price: "260.00", Currency: "CNY", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+        {Symbol: sym, Price: "250.5", Currency: "CNY", Source: "SteamDT:BUFF:bid", ReceivedAt: t1},
+        {Symbol: sym, Price: "255", Currency: "CNY", Source: "SteamDT:BUFF:bid", ReceivedAt: t2}, // newer bid wins
+        {Symbol: sym, Price: "300", Currency: "CNY", Source: "SteamDT:YOUPIN:sell", ReceivedAt: t1}, // no bid
+        {Symbol: sym, Price: "270", Currency: "CNY", Source: "Pricempire:buff", ReceivedAt: t2},      // no side
+    })
+    if len(out) != 1 { t.Fatalf("want 1 spread row, got %+v", out) }
+    s := out[0]
+    if s.Market != "BUFF" || s.Ask != "260.00" || s.Bid != "255" { t.Fatalf("unexpected pair: %+v", s) }
+    if s.Spread != "5.00" || s.SpreadPct != "1.92" { t.Fatalf("want spread 5.00 (1.92%%), got %s (%s)", s.Spread, s.SpreadPct) }
+}
+
+func TestWeightedConsensus_HighVolumeDominates(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    out := WeightedConsensus([]provider.Quote{
+        {Symbol: sym, Price: "10.00", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1, Volume: 98},
+        {Symbol: sym, Price: "9.00", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: t1, Volume: 500}, // bids ignored
+        {Symbol: sym, Price: "20", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: t1, Volume: 1},
+        {Symbol: sym, Price: "30", Currency: "USD", Source: "SkinstableXYZ:CS.MONEY", ReceivedAt: t1}, // no volume -> weight 1
+        {Symbol: sym, Price: "100", Currency: "CNY", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+    })
+    if len(out) != 2 { t.Fatalf("want CNY and USD rows, got %+v", out) }
+    usd := out[1]
+    if usd.Currency != "USD" || usd.Weight != 100 || usd.Quotes != 3 { t.Fatalf("unexpected USD row: %+v", usd) }
+    // (10*98 + 20 + 30) / 100 = 10.30
+    if usd.Price != "10.30" { t.Fatalf("want 10.30, got %s", usd.Price) }
+}
+
+func TestAvg30_KeptOutOfLivePrices(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    in := []provider.Quote{
+        {Symbol: sym, Price: "10", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: t1, Volume: 5},
+        {Symbol: sym, Price: "50", Currency: "USD", Source: "Pricempire:buff:avg30", ReceivedAt: t1.Add(time.Minute), Volume: 5},
+    }
+    if out := WeightedConsensus(append(in, provider.Quote{Symbol: sym, Price: "20", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1, Volume: 5})); len(out) != 1 || out[0].Price != "15.00" || out[0].Quotes != 2 {
+        t.Fatalf("want the 30-day average left out of the consensus, got %+v", out)
+    }
+    if s := AggregateStats(in); len(s) != 1 || s[0].Listings != 5 { t.Fatalf("want the 30-day average left out of the stats, got %+v", s) }
+    out := LatestByMarket(in, false)
+    if len(out) != 2 || out[0].Side != "" || out[0].Price != "10" || out[1].Side != SideAvg30 { t.Fatalf("want the spot price and the 30-day average in separate rows, got %+v", out) }
+}
+
+func TestPickByMarket_Modes(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    in := []provider.Quote{
+        {Symbol: sym, Price: "9.5", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+        {Symbol: sym, Price: "12", Currency: "USD", Source: "Pricempire:buff", ReceivedAt: t1.Add(time.Minute)},
+        {Symbol: sym, Price: "10.25", Currency: "USD", Source: "SkinstableXYZ:BUFF163", ReceivedAt: t1.Add(2 * time.Minute)},
+        {Symbol: sym, Price: "n/a", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1.Add(3 * time.Minute)},
+        {Symbol: sym, Price: "8", Currency: "USD", Source: "SteamDT:BUFF:bid", ReceivedAt: t1},
+    }
+    cases := []struct {
+        pick     Pick
+        sell     string // price picked for the sell/sideless BUFF buckets
+        sideless string
+    }{
+        {PickNewest, "n/a", "10.25"},
+        {PickLowest, "9.5", "10.25"},
+        {PickHighest, "9.5", "12"},
+    }
+    for _, c := range cases {
+        out := PickByMarket(in, c.pick)
+        // Sides stay apart: "" (pricempire/skinstable), bid and sell.
+        if len(out) != 3 { t.Fatalf("%s: want 3 buckets, got %+v", c.pick, out) }
+        if out[0].Side != "" || out[0].Price != c.sideless { t.Fatalf("%s: sideless bucket %+v", c.pick, out[0]) }
+        if out[1].Side != "bid" || out[1].Price != "8" { t.Fatalf("%s: bid bucket %+v", c.pick, out[1]) }
+        if out[2].Side != "sell" || out[2].Price != c.sell { t.Fatalf("%s: sell bucket %+v", c.pick, out[2]) }
+    }
+    if _, err := ParsePick("cheapest"); err == nil { t.Fatalf("want error for unknown pick") }
+    if p, _ := ParsePick(""); p != PickNewest { t.Fatalf("want newest by default, got %q", p) }
+}
+
+func TestChangeTracker_ReportsOnlyMovedPrices(t *testing.T) {
+    t1 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    round := func(a, b string) []Latest {
+        return LatestByMarket([]provider.Quote{
+            {Symbol: "A", Price: a, Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+            {Symbol: "B", Price: b, Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: t1},
+        }, true)
+    }
+    tr := NewChangeTracker(0)
+    if got := tr.Observe(round("10", "5")); len(got) != 0 { t.Fatalf("first round should only record, got %+v", got) }
+    got := tr.Observe(round("12.5", "5.00"))
+    if len(got) != 1 { t.Fatalf("want only A changed, got %+v", got) }
+    if c := got[0]; c.Symbol != "A" || c.OldPrice != "10" || c.NewPrice != "12.5" || c.DeltaPct != "25.00" { t.Fatalf("unexpected change: %+v", c) }
+    if got := tr.Observe(round("12.5", "5")); len(got) != 0 { t.Fatalf("unchanged round reported %+v", got) }
+}
+
+func TestFindOutliers_FlagsProviderOffByCurrencyFactor(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    in := []provider.Quote{
+        {Symbol: sym, Price: "72.10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"},
+        {Symbol: sym, Price: "73.40", Currency: "USD", Source: "SteamDT:YOUPIN:sell", Provider: "SteamDT"},
+        {Symbol: sym, Price: "10.05", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"},
+        {Symbol: sym, Price: "10.20", Currency: "USD", Source: "SkinstableXYZ:csfloat", Provider: "SkinstableXYZ"},
+    }
+    out := FindOutliers(in, 3)
+    if len(out) != 2 || out[0].Index != 0 || out[1].Index != 1 { t.Fatalf("want both SteamDT quotes flagged, got %+v", out) }
+    if out[0].Median != "10.20" { t.Fatalf("unexpected median %q", out[0].Median) }
+}
+
+func TestFindOutliers_IgnoresBidsAndAvg30(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    in := []provider.Quote{
+        {Symbol: sym, Price: "10.00", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"},
+        {Symbol: sym, Price: "6.00", Currency: "USD", Source: "SteamDT:BUFF:bid", Provider: "SteamDT"},
+        {Symbol: sym, Price: "10.40", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"},
+        {Symbol: sym, Price: "4.00", Currency: "USD", Source: "Pricempire:buff:avg30", Provider: "Pricempire"},
+        {Symbol: sym, Price: "10.20", Currency: "USD", Source: "SkinstableXYZ:csfloat", Provider: "SkinstableXYZ"},
+    }
+    if out := FindOutliers(in, 1.5); len(out) != 0 { t.Fatalf("want the bid and the 30-day average left alone, got %+v", out) }
+
+    // the sell quotes alone set the median
+    in[2].Price = "30"
+    out := FindOutliers(in, 1.5)
+    if len(out) != 1 || out[0].Index != 2 || out[0].Median != "10.20" { t.Fatalf("want only the off sell quote flagged against 10.20, got %+v", out) }
+}
+
+func TestFindOutliers_NeedsThreeProviders(t *testing.T) {
+    in := []provider.Quote{
+        {Symbol: "A", Price: "70", Currency: "USD", Provider: "SteamDT"},
+        {Symbol: "A", Price: "10", Currency: "USD", Provider: "Pricempire"},
+    }
+    if out := FindOutliers(in, 3); len(out) != 0 { t.Fatalf("want no outliers with two providers, got %+v", out) }
+}
+
+func TestPreferProviders_KeepsHighestPriorityPerMarket(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    in := []provider.Quote{
+        {Symbol: sym, Price: "11", Currency: "USD", Source: "Pricempire:buff", Provider: "Pricempire"},
+        {Symbol: sym, Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT"},
+        {Symbol: sym, Price: "9", Currency: "USD", Source: "SteamDT:BUFF:bid", Provider: "SteamDT"},
+        {Symbol: sym, Price: "12", Currency: "USD", Source: "Pricempire:csfloat", Provider: "Pricempire"},
+        {Symbol: sym, Price: "13", Currency: "USD", Source: "SkinstableXYZ:csfloat", Provider: "SkinstableXYZ"},
+    }
+    out := PreferProviders(in, []string{"steamdt", "Pricempire"})
+    if len(out) != 3 { t.Fatalf("want 3 quotes, got %+v", out) }
+    if out[0].Source != "SteamDT:BUFF:sell" || out[1].Source != "SteamDT:BUFF:bid" || out[2].Source != "Pricempire:csfloat" {
+        t.Fatalf("unexpected selection: %+v", out)
+    }
+
+    out = PreferProviders(in, []string{"SkinstableXYZ"})
+    if len(out) != 4 { t.Fatalf("unlisted providers should tie, got %+v", out) }
+    for _, q := range out {
+        if q.Source == "Pricempire:csfloat" { t.Fatalf("preferred provider did not win csfloat: %+v", out) }
+    }
+}
+
+func TestLimitMarkets_PriorityThenFreshness(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    rows := []Latest{
+        {Symbol: "A", Market: "BUFF", Side: "sell", ReceivedAt: ts},
+        {Symbol: "A", Market: "Steam", ReceivedAt: ts.Add(4 * time.Minute)},
+        {Symbol: "A", Market: "BUFF", Side: "bid", ReceivedAt: ts.Add(time.Minute)},
+        {Symbol: "A", Market: "C5GAME", ReceivedAt: ts.Add(2 * time.Minute)},
+        {Symbol: "A", Market: "Skinport", ReceivedAt: ts.Add(3 * time.Minute)},
+        {Symbol: "A", Market: "CS.MONEY", ReceivedAt: ts.Add(5 * time.Minute)},
+        {Symbol: "B", Market: "BUFF", ReceivedAt: ts},
+    }
+    markets := func(rs []Latest) []string {
+        var out []string
+        for _, r := range rs { out = append(out, r.Symbol+":"+r.Market) }
+        return out
+    }
+
+    got := markets(LimitMarkets(rows, 2, nil))
+    if want := []string{"A:Steam", "A:CS.MONEY", "B:BUFF"}; fmt.Sprint(got) != fmt.Sprint(want) { t.Fatalf("freshest two: got %v, want %v", got, want) }
+
+    // a listed market outranks fresher ones, and keeps both of its sides
+    got = markets(LimitMarkets(rows, 2, []string{"buff"}))
+    if want := []string{"A:BUFF", "A:BUFF", "A:CS.MONEY", "B:BUFF"}; fmt.Sprint(got) != fmt.Sprint(want) { t.Fatalf("priority: got %v, want %v", got, want) }
+
+    if len(LimitMarkets(rows, 0, nil)) != len(rows) { t.Fatalf("n=0 should keep every row") }
+}
+
+func TestAggregateStats_SumsKnownCountsAndFlagsPartial(t *testing.T) {
+    in := []provider.Quote{
+        {Symbol: "A", Currency: "USD", Price: "10", Source: "SteamDT:BUFF:sell", Volume: 120},
+        {Symbol: "A", Currency: "USD", Price: "10", Source: "Pricempire:buff", Volume: 100}, // same market: not added
+        {Symbol: "A", Currency: "USD", Price: "9", Source: "SteamDT:BUFF:bid", Volume: 500},  // bids are not listings
+        {Symbol: "A", Currency: "USD", Price: "11", Source: "Pricempire:steam", Volume: 300},
+        {Symbol: "A", Currency: "USD", Price: "12", Source: "Pricempire:skinport"},          // count unknown
+        {Symbol: "B", Currency: "USD", Price: "1", Source: "Pricempire:buff", Volume: 7},
+    }
+    got := AggregateStats(in)
+    if len(got) != 2 { t.Fatalf("want stats for A and B, got %+v", got) }
+    a := got[0]
+    if a.Symbol != "A" || a.Markets != 3 || a.Listings != 420 || !a.Partial { t.Fatalf("A: unexpected %+v", a) }
+    if a.DeepestMarket != "Steam" || a.DeepestListings != 300 { t.Fatalf("A: want Steam deepest, got %+v", a) }
+    b := got[1]
+    if b.Listings != 7 || b.Partial || b.DeepestMarket != "BUFF" { t.Fatalf("B: unexpected %+v", b) }
+
+    // no count anywhere: nothing summed, no deepest market
+    got = AggregateStats([]provider.Quote{{Symbol: "C", Currency: "USD", Price: "1", Source: "Pricempire:buff"}})
+    if len(got) != 1 || got[0].Listings != 0 || !got[0].Partial || got[0].DeepestMarket != "" { t.Fatalf("C: unexpected %+v", got) }
+}
@@ -0,0 +1,77 @@
+package aggregate
+
+import (
+    "sync"
+    "time"
+
+    "priceprovider/internal/money"
+)
+
+// Change is a market row whose price moved since the previous observation.
+type Change struct {
+    Symbol     string    `json:"symbol"`
+    Market     string    `json:"market"`
+    Side       string    `json:"side"`
+    Currency   string    `json:"currency"`
+    OldPrice   string    `json:"old_price"`
+    NewPrice   string    `json:"new_price"`
+    // DeltaPct is (new-old)/old in percent with 2 decimals; empty when old is 0.
+    DeltaPct   string    `json:"delta_pct"`
+    Provider   string    `json:"provider"`
+    ReceivedAt time.Time `json:"received_at"`
+    AppID      int       `json:"app_id,omitempty"`
+}
+
+// ChangeTracker remembers the last seen price per MarketKey in memory.
+// It holds at most max keys; once full, new keys are not tracked.
+type ChangeTracker struct {
+    mu   sync.Mutex
+    max  int
+    last map[MarketKey]observed
+}
+
+type observed struct {
+    price  string
+    amount money.Amount
+}
+
+func NewChangeTracker(max int) *ChangeTracker {
+    return &ChangeTracker{max: max, last: make(map[MarketKey]observed)}
+}
+
+// Observe records rows (as returned by LatestByMarket) and returns the ones
+// whose price differs numerically from the previous observation. Rows seen
+// for the first time are recorded but not reported.
+func (t *ChangeTracker) Observe(rows []Latest) []Change {
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    out := []Change{}
+    for _, l := range rows {
+        k := MarketKey{Symbol: l.Symbol, Market: l.Market, Side: l.Side, Currency: l.Currency, AppID: l.AppID}
+        cur := observed{l.Price, l.Amount}
+        if !cur.amount.Valid() { cur.amount = money.Of(l.Price) }
+        prev, seen := t.last[k]
+        if !seen {
+            if t.max <= 0 || len(t.last) < t.max { t.last[k] = cur }
+            continue
+        }
+        t.last[k] = cur
+        ok1, ok2 := prev.amount.Valid(), cur.amount.Valid()
+        if ok1 && ok2 && prev.amount.Cmp(cur.amount) == 0 { continue }
+        if !ok1 && !ok2 && prev.price == l.Price { continue }
+        pct := cur.amount.Sub(prev.amount).PercentOf(prev.amount).String()
+        out = append(out, Change{
+            Symbol:     l.Symbol,
+            Market:     l.Market,
+            Side:       l.Side,
+            Currency:   l.Currency,
+            OldPrice:   prev.price,
+            NewPrice:   l.Price,
+            DeltaPct:   pct,
+            Provider:   l.Provider,
+            ReceivedAt: l.ReceivedAt,
+            AppID:      l.AppID,
+        })
+    }
+    return out
+}
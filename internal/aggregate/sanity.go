@@ -0,0 +1,84 @@
+package aggregate
+
+import (
+    "math/big"
+    "sort"
+    "strings"
+
+    "priceprovider/internal/money"
+    "priceprovider/internal/provider"
+)
+
+// Outlier is a quote priced more than a factor away from the cross-provider
+// median of its symbol.
+type Outlier struct {
+    // Index is the position of Quote in the slice given to FindOutliers.
+    Index  int
+    Quote  provider.Quote
+    // Median is the cross-provider median the quote was compared against.
+    Median string
+}
+
+// FindOutliers groups quotes by symbol, app and currency, takes the median
+// price of each provider within a group, and reports the quotes priced more
+// than factor times above or below the median of those provider medians.
+// Groups quoted by fewer than three providers are skipped, since with two
+// neither side can be called the outlier. Bids and 30-day averages are
+// neither compared nor flagged: they are not live asks. Prices that do not
+// parse or are not positive are ignored. factor <= 1 reports nothing.
+func FindOutliers(qs []provider.Quote, factor float64) []Outlier {
+    if factor <= 1 { return nil }
+    f := new(big.Rat).SetFloat64(factor)
+    if f == nil { return nil }
+
+    type groupKey struct {
+        symbol, currency string
+        appID            int
+    }
+    prices := make([]money.Amount, len(qs))
+    groups := make(map[groupKey]map[string][]money.Amount)
+    for i, q := range qs {
+        if _, side := NormalizeSource(q.Source); side == "bid" || side == SideAvg30 { continue }
+        v := q.PriceAmount()
+        if !v.Valid() || v.Sign() <= 0 { continue }
+        prices[i] = v
+        k := groupKey{q.Symbol, strings.ToUpper(q.Currency), q.AppID}
+        if groups[k] == nil { groups[k] = make(map[string][]money.Amount) }
+        name := providerOf(q)
+        groups[k][name] = append(groups[k][name], v)
+    }
+
+    medians := make(map[groupKey]money.Amount, len(groups))
+    for k, byProvider := range groups {
+        if len(byProvider) < 3 { continue }
+        perProvider := make([]money.Amount, 0, len(byProvider))
+        for _, vs := range byProvider { perProvider = append(perProvider, median(vs)) }
+        medians[k] = median(perProvider)
+    }
+
+    var out []Outlier
+    for i, q := range qs {
+        if !prices[i].Valid() { continue }
+        m := medians[groupKey{q.Symbol, strings.ToUpper(q.Currency), q.AppID}]
+        if !m.Valid() { continue }
+        if prices[i].Cmp(m.MulRat(f)) > 0 || prices[i].MulRat(f).Cmp(m) < 0 {
+            out = append(out, Outlier{Index: i, Quote: q, Median: m.WithScale(2).String()})
+        }
+    }
+    return out
+}
+
+// providerOf names the provider behind q, falling back to the Source prefix.
+func providerOf(q provider.Quote) string {
+    if q.Provider != "" { return strings.ToLower(q.Provider) }
+    return strings.ToLower(strings.SplitN(q.Source, ":", 2)[0])
+}
+
+// median returns the middle value of vs (the mean of the two middle values
+// for an even count). vs is sorted in place.
+func median(vs []money.Amount) money.Amount {
+    sort.Slice(vs, func(i, j int) bool { return vs[i].Cmp(vs[j]) < 0 })
+    n := len(vs)
+    if n%2 == 1 { return vs[n/2] }
+    return vs[n/2-1].Add(vs[n/2]).QuoInt(2)
+}
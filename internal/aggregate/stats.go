@@ -0,0 +1,68 @@
+package aggregate
+
+import (
+    "sort"
+    "strings"
+
+    "priceprovider/internal/provider"
+)
+
+// Stats summarizes the ask-side depth of one (Symbol, Currency, AppID).
+type Stats struct {
+    Symbol   string `json:"symbol"`
+    Currency string `json:"currency"`
+    AppID    int    `json:"app_id,omitempty"`
+    // Markets is the number of markets quoting the symbol.
+    Markets  int    `json:"markets"`
+    // Listings is the sum of the markets' listing counts. Markets whose
+    // count is unknown are left out, and Partial is set.
+    Listings int    `json:"listings"`
+    Partial  bool   `json:"partial,omitempty"`
+    // DeepestMarket is the market with the most listings ("" when no count
+    // is known), with its count in DeepestListings.
+    DeepestMarket   string `json:"deepest_market,omitempty"`
+    DeepestListings int    `json:"deepest_listings,omitempty"`
+}
+
+// AggregateStats computes Stats per (Symbol, Currency, AppID) from the
+// quotes' Volume. Bid quotes and 30-day averages are excluded, so counts
+// are listings (asks).
+// Several providers quoting the same market (see NormalizeSource) are not
+// added up: the market counts with the largest volume any of them reported.
+// A Volume of 0 means unknown. Ties for the deepest market go to the market
+// name that sorts first. Output is sorted by symbol, currency, app id.
+func AggregateStats(quotes []provider.Quote) []Stats {
+    type key struct {
+        symbol, currency string
+        appID            int
+    }
+    depth := make(map[key]map[string]int) // market -> listings, 0 = unknown
+    for _, q := range quotes {
+        market, side := NormalizeSource(q.Source)
+        if side == "bid" || side == SideAvg30 { continue }
+        k := key{q.Symbol, strings.ToUpper(q.Currency), q.AppID}
+        if depth[k] == nil { depth[k] = make(map[string]int) }
+        if n, ok := depth[k][market]; !ok || q.Volume > n { depth[k][market] = max(q.Volume, 0) }
+    }
+
+    out := make([]Stats, 0, len(depth))
+    for k, markets := range depth {
+        s := Stats{Symbol: k.symbol, Currency: k.currency, AppID: k.appID, Markets: len(markets)}
+        names := make([]string, 0, len(markets))
+        for m := range markets { names = append(names, m) }
+        sort.Strings(names)
+        for _, m := range names {
+            n := markets[m]
+            if n == 0 { s.Partial = true; continue }
+            s.Listings += n
+            if n > s.DeepestListings { s.DeepestMarket, s.DeepestListings = m, n }
+        }
+        out = append(out, s)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Symbol != out[j].Symbol { return out[i].Symbol < out[j].Symbol }
+        if out[i].Currency != out[j].Currency { return out[i].Currency < out[j].Currency }
+        return out[i].AppID < out[j].AppID
+    })
+    return out
+}
@@ -0,0 +1,78 @@
+// Package backoff computes retry delays with the jitter strategies from the
+// AWS Architecture Blog post "Exponential Backoff And Jitter".
+package backoff
+
+import (
+    "fmt"
+    "math/rand/v2"
+    "strings"
+    "time"
+)
+
+// Strategy names accepted by New.
+const (
+    None         = "none"         // base*2^attempt, capped; no jitter
+    Full         = "full"         // random in [0, base*2^attempt)
+    Equal        = "equal"        // half the exponential delay plus random in [0, half)
+    Decorrelated = "decorrelated" // random in [base, prev*3), capped
+)
+
+// Backoff returns successive retry delays. Decorrelated jitter depends on the
+// previous delay, so use one Backoff per retry loop; it is not safe for
+// concurrent use.
+type Backoff struct {
+    Strategy string
+    Base     time.Duration
+    Cap      time.Duration
+
+    prev time.Duration
+}
+
+// New validates the strategy name ("" means Full) and returns a Backoff.
+func New(strategy string, base, cap time.Duration) (*Backoff, error) {
+    s := strings.ToLower(strings.TrimSpace(strategy))
+    if s == "" { s = Full }
+    switch s {
+    case None, Full, Equal, Decorrelated:
+    default:
+        return nil, fmt.Errorf("unknown backoff strategy %q (none|full|equal|decorrelated)", strategy)
+    }
+    if base <= 0 { return nil, fmt.Errorf("backoff base must be positive") }
+    if cap < base { cap = base }
+    return &Backoff{Strategy: s, Base: base, Cap: cap}, nil
+}
+
+// exp returns min(Cap, Base*2^attempt) without overflowing.
+func (b *Backoff) exp(attempt int) time.Duration {
+    d := b.Base
+    for i := 0; i < attempt && d < b.Cap; i++ { d *= 2 }
+    return min(d, b.Cap)
+}
+
+// Next returns the delay before retry number attempt (0-based).
+func (b *Backoff) Next(attempt int) time.Duration {
+    if attempt < 0 { attempt = 0 }
+    switch b.Strategy {
+    case None:
+        return b.exp(attempt)
+    case Equal:
+        half := b.exp(attempt) / 2
+        return half + randBelow(half)
+    case Decorrelated:
+        prev := b.prev
+        if prev < b.Base { prev = b.Base }
+        d := min(b.Cap, b.Base+randBelow(prev*3-b.Base))
+        b.prev = d
+        return d
+    default: // Full
+        return randBelow(b.exp(attempt))
+    }
+}
+
+// Reset clears the state kept by decorrelated jitter.
+func (b *Backoff) Reset() { b.prev = 0 }
+
+func randBelow(d time.Duration) time.Duration {
+    if d <= 0 { return 0 }
+    return time.Duration(rand.Int64N(int64(d)))
+}
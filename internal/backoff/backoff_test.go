@@ -0,0 +1,43 @@
+package backoff
+
+import (
+    "testing"
+    "time"
+)
+
+func TestBackoff_DelaysStayWithinBounds(t *testing.T) {
+    base, cap := 100*time.Millisecond, 2*time.Second
+    exp := func(a int) time.Duration { return min(cap, base<<a) }
+
+    for _, name := range []string{None, Full, Equal, Decorrelated} {
+        b, err := New(name, base, cap)
+        if err != nil { t.Fatalf("%s: %v", name, err) }
+        prev := base
+        for round := 0; round < 200; round++ {
+            for a := 0; a < 8; a++ {
+                d := b.Next(a)
+                var lo, hi time.Duration
+                switch name {
+                case None:
+                    lo, hi = exp(a), exp(a)
+                case Full:
+                    lo, hi = 0, exp(a)
+                case Equal:
+                    lo, hi = exp(a)/2, exp(a)
+                case Decorrelated:
+                    lo, hi = base, min(cap, prev*3)
+                    prev = d
+                }
+                if d < lo || d > hi { t.Fatalf("%s attempt %d: %s outside [%s, %s]", name, a, d, lo, hi) }
+            }
+            b.Reset()
+            prev = base
+        }
+    }
+}
+
+func TestBackoff_New(t *testing.T) {
+    if b, err := New("", time.Millisecond, time.Second); err != nil || b.Strategy != Full { t.Fatalf("default: %+v %v", b, err) }
+    if _, err := New("linear", time.Millisecond, time.Second); err == nil { t.Fatalf("want error for unknown strategy") }
+    if _, err := New(Full, 0, time.Second); err == nil { t.Fatalf("want error for zero base") }
+}
@@ -0,0 +1,120 @@
+// Package clock abstracts time.Now and timers so time-dependent code (rate
+// limiters, caches) can be tested with a Fake clock instead of real sleeps.
+package clock
+
+import (
+    "sort"
+    "sync"
+    "time"
+)
+
+// Clock tells the time and makes timers.
+type Clock interface {
+    Now() time.Time
+    NewTimer(d time.Duration) Timer
+}
+
+// Timer is the part of *time.Timer that clock users need.
+type Timer interface {
+    C() <-chan time.Time
+    Stop() bool
+}
+
+// Real is the wall clock.
+var Real Clock = realClock{}
+
+// Or returns c, or Real when c is nil, so a zero-value field means the wall
+// clock.
+func Or(c Clock) Clock {
+    if c == nil { return Real }
+    return c
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool { return r.t.Stop() }
+
+// Fake is a Clock that only moves when Advance is called. Timers fire once
+// Advance reaches their deadline.
+type Fake struct {
+    mu     sync.Mutex
+    cond   *sync.Cond
+    now    time.Time
+    timers []*fakeTimer
+}
+
+// NewFake returns a Fake clock set to now.
+func NewFake(now time.Time) *Fake {
+    f := &Fake{now: now}
+    f.cond = sync.NewCond(&f.mu)
+    return f
+}
+
+func (f *Fake) Now() time.Time {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    return f.now
+}
+
+// NewTimer returns a timer firing at Now()+d; d <= 0 fires immediately.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    t := &fakeTimer{f: f, at: f.now.Add(d), c: make(chan time.Time, 1)}
+    if d <= 0 {
+        t.c <- f.now
+        return t
+    }
+    f.timers = append(f.timers, t)
+    f.cond.Broadcast()
+    return t
+}
+
+// Advance moves the clock forward by d and fires every timer that is due,
+// earliest first.
+func (f *Fake) Advance(d time.Duration) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.now = f.now.Add(d)
+    sort.SliceStable(f.timers, func(i, j int) bool { return f.timers[i].at.Before(f.timers[j].at) })
+    i := 0
+    for i < len(f.timers) && !f.timers[i].at.After(f.now) {
+        f.timers[i].c <- f.now
+        i++
+    }
+    f.timers = f.timers[i:]
+}
+
+// BlockUntil waits until n timers are pending, so a test can advance the
+// clock only once the code under test is actually waiting.
+func (f *Fake) BlockUntil(n int) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    for len(f.timers) < n { f.cond.Wait() }
+}
+
+type fakeTimer struct {
+    f  *Fake
+    at time.Time
+    c  chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+    t.f.mu.Lock()
+    defer t.f.mu.Unlock()
+    for i, o := range t.f.timers {
+        if o == t {
+            t.f.timers = append(t.f.timers[:i], t.f.timers[i+1:]...)
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,28 @@
+package clock
+
+import (
+    "testing"
+    "time"
+)
+
+func TestFake_TimersFireOnAdvance(t *testing.T) {
+    start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    f := NewFake(start)
+    late, early, stopped := f.NewTimer(2*time.Second), f.NewTimer(time.Second), f.NewTimer(time.Second)
+    if !stopped.Stop() { t.Fatal("want a pending timer stopped") }
+
+    f.Advance(time.Second)
+    if got := <-early.C(); !got.Equal(start.Add(time.Second)) { t.Fatalf("early fired at %s", got) }
+    select {
+    case <-late.C():
+        t.Fatal("late timer fired before its deadline")
+    case <-stopped.C():
+        t.Fatal("stopped timer fired")
+    default:
+    }
+
+    f.Advance(time.Second)
+    <-late.C()
+    if !f.Now().Equal(start.Add(2 * time.Second)) { t.Fatalf("now=%s", f.Now()) }
+    <-f.NewTimer(0).C() // fires at once, without Advance
+}
@@ -5,40 +5,226 @@ import (
     "errors"
     "fmt"
     "os"
+    "path/filepath"
     "strings"
+
+    "gopkg.in/yaml.v3"
 )
 
 type Server struct {
     Port               string `json:"port"`
+    // RequestTimeoutSec is the timeout of each upstream HTTP call.
     RequestTimeoutSec  int    `json:"request_timeout_sec"`
+    // RequestDeadlineSec bounds the whole fan-out of one API request; keep it
+    // >= RequestTimeoutSec.
+    RequestDeadlineSec int    `json:"request_deadline_sec"`
+    // AdminToken guards the /admin endpoints (Bearer token). Empty disables them.
+    AdminToken         string `json:"admin_token"`
+    // SuppressZero drops quotes priced <= 0 from every provider.
+    SuppressZero       bool        `json:"suppress_zero"`
+    // MinPrice drops quotes priced <= this floor (decimal; empty disables).
+    MinPrice           json.Number `json:"min_price"`
+    // JSONCase is the default response key style: snake (default) or camel.
+    JSONCase           string      `json:"json_case"`
+    // TimestampFormat is the default timestamp rendering: rfc3339 (default)
+    // or epoch_ms.
+    TimestampFormat    string      `json:"timestamp_format"`
+    // ErrorFormat renders error responses as "text" (default) or "problem"
+    // (RFC 7807 application/problem+json).
+    ErrorFormat        string      `json:"error_format"`
+    // TLSCertFile and TLSKeyFile enable HTTPS when both are set.
+    TLSCertFile        string      `json:"tls_cert_file"`
+    TLSKeyFile         string      `json:"tls_key_file"`
+    // GzipLevel is the response compression level (1..9; 0 = best speed).
+    GzipLevel          int         `json:"gzip_level"`
+    // GzipMinBytes leaves responses smaller than this uncompressed.
+    GzipMinBytes       int         `json:"gzip_min_bytes"`
+    // IdempotencyTTLSec is how long POST responses are replayed for a repeated
+    // Idempotency-Key (0 disables); IdempotencyMaxKeys bounds the stored keys.
+    IdempotencyTTLSec  int         `json:"idempotency_ttl_sec"`
+    IdempotencyMaxKeys int         `json:"idempotency_max_keys"`
+    // WarmupSymbols (plus WarmupFile, one symbol per line) are fetched at
+    // startup to fill provider caches; /readyz waits for them.
+    WarmupSymbols      []string    `json:"warmup_symbols"`
+    WarmupFile         string      `json:"warmup_file"`
+    // TrackChanges keeps the last seen price per market to serve /api/changes.
+    TrackChanges       bool        `json:"track_changes"`
+    // SanityMaxDeviation logs quotes priced more than this factor away from
+    // the cross-provider median of their symbol (0 disables); SanityDrop also
+    // removes them from responses.
+    SanityMaxDeviation float64     `json:"sanity_max_deviation"`
+    SanityDrop         bool        `json:"sanity_drop"`
+    // MaxInFlight bounds concurrent /api/quotes requests (0 disables). Extra
+    // requests wait up to AdmissionWaitMs for a slot, then get 503.
+    MaxInFlight        int         `json:"max_in_flight"`
+    AdmissionWaitMs    int         `json:"admission_wait_ms"`
+    // FetchConcurrency caps how many providers one request fetches at once
+    // (0 = all together).
+    FetchConcurrency   int         `json:"fetch_concurrency"`
+    // RaiseCacheTTL lifts a provider's cache_ttl_sec to its rate-limit
+    // interval instead of only warning about it (see Validate).
+    RaiseCacheTTL      bool        `json:"raise_cache_ttl"`
+    // DisableAllUpstreams is the emergency switch: requests are answered from
+    // provider caches only (stale entries included) and no upstream is called.
+    DisableAllUpstreams bool       `json:"disable_all_upstreams"`
+    // MarketPriority ranks markets (best first) for /api/latest's
+    // max_markets_per_symbol; unlisted markets rank by freshness after them.
+    MarketPriority     []string    `json:"market_priority"`
+    // ProviderPriority ranks providers (best first) for quotes of the same
+    // market with equal timestamps; otherwise the configured order decides.
+    ProviderPriority   []string    `json:"provider_priority"`
+    // StrictQueryParams rejects API requests with query parameters the
+    // endpoint does not know (400, with a suggestion for typos).
+    StrictQueryParams  bool        `json:"strict_query_params"`
+    // SymbolAliases maps a canonical symbol to the name each provider
+    // (steamdt, pricempire, skinstable) knows it by. Quotes are returned under
+    // the canonical symbol. Config file only.
+    SymbolAliases      map[string]map[string]string `json:"symbol_aliases"`
 }
 
 type SteamDT struct {
     Enabled               bool   `json:"enabled"`
     APIKey                string `json:"api_key"`
+    // KeyPool, when set, replaces APIKey with several keys and their weights;
+    // each key gets its own instance and rate limiter, and every call goes to
+    // one of them by weighted round-robin.
+    KeyPool               map[string]int `json:"key_pool"`
     Endpoint              string `json:"endpoint"`
+    // Endpoints, when set, replaces Endpoint with a failover list.
+    Endpoints             []string `json:"endpoints"`
     IncludeBids           bool   `json:"include_bids"`
     Currency              string `json:"currency"`
     MaxRequestsPerMinute  int    `json:"max_requests_per_minute"`
     MinRequestIntervalSec int    `json:"min_request_interval_sec"`
     Burst                 int    `json:"burst"`
+    StartEmpty            bool   `json:"start_empty"`
     MaxItemsPerRequest    int    `json:"max_items_per_request"`
     MaxConcurrency        int    `json:"max_concurrency"`
     CacheTTLSeconds       int    `json:"cache_ttl_sec"`
     CacheMaxItems         int    `json:"cache_max_items"`
+    // CacheMaxTTLSeconds lets frequently requested symbols stay cached longer:
+    // the TTL doubles as request counts double, up to this cap. 0 keeps a fixed TTL.
+    CacheMaxTTLSeconds    int    `json:"cache_max_ttl_sec"`
+    // CacheNegativeTTLSeconds caches "no quotes" answers for this long so
+    // unknown symbols do not reach upstream on every request. 0 disables.
+    CacheNegativeTTLSeconds int  `json:"cache_negative_ttl_sec"`
+    // ServeStaleDuringOutageSec serves cache entries up to this long past
+    // their TTL when the upstream fails. 0 disables.
+    ServeStaleDuringOutageSec int `json:"serve_stale_during_outage_sec"`
+    // CacheChunkSize splits cache misses into upstream calls of at most
+    // this many symbols, CacheChunkConcurrency at a time. 0 disables.
+    CacheChunkSize        int    `json:"cache_chunk_size"`
+    CacheChunkConcurrency int    `json:"cache_chunk_concurrency"`
+    // CacheRefreshWaitMs lets one request refresh a missing symbol while
+    // concurrent requests for it wait up to this long. 0 disables.
+    CacheRefreshWaitMs    int    `json:"cache_refresh_wait_ms"`
+    // HedgeDelayMs issues a second upstream attempt when the first has not
+    // returned within this many milliseconds. 0 disables hedging.
+    HedgeDelayMs          int    `json:"hedge_delay_ms"`
+    // RetryAttempts re-issues a fetch that failed with a retryable error
+    // (timeouts, 429/5xx, non-JSON bodies) up to this many extra times. 0 disables.
+    RetryAttempts         int    `json:"retry_attempts"`
+    // DegradeAfterFailures returns empty results instead of errors after this
+    // many consecutive failures, probing every DegradeProbeSec. 0 disables.
+    DegradeAfterFailures  int    `json:"degrade_after_failures"`
+    DegradeProbeSec       int    `json:"degrade_probe_sec"`
+    // SymbolDenylist symbols are never sent to this provider; a non-empty
+    // SymbolAllowlist restricts it to the listed symbols.
+    SymbolDenylist        []string `json:"symbol_denylist"`
+    SymbolAllowlist       []string `json:"symbol_allowlist"`
+    // BatchMemoTTLMs memoizes identical batch responses for this long.
+    BatchMemoTTLMs        int    `json:"batch_memo_ttl_ms"`
+    // MinBatchTimeMs skips batches that would start with less time than this left.
+    MinBatchTimeMs        int    `json:"min_batch_time_ms"`
+    // StrictErrors treats success:false with an errorCode as a failure even
+    // when the response carries data (default: use the data, warn).
+    StrictErrors          bool   `json:"strict_errors"`
+    // IncludePlatformItemID emits each listing's platformItemId as the
+    // quote's external_id.
+    IncludePlatformItemID bool   `json:"include_platform_item_id"`
+    // RetryBackoff is the jitter strategy for retries: full (default), equal,
+    // decorrelated or none.
+    RetryBackoff          string `json:"retry_backoff"`
+    // ProxyURL sends this provider's requests through an egress proxy
+    // (http, https or socks5). Empty uses the environment (HTTPS_PROXY etc.).
+    ProxyURL              string `json:"proxy_url"`
+    // MaxConnsPerHost gives this provider its own HTTP client with at most
+    // this many connections per upstream host (0 shares the default client,
+    // limited to 100).
+    MaxConnsPerHost       int    `json:"max_conns_per_host"`
+    // SigningSecret, when set, signs every request of this provider with
+    // HMAC(secret, timestamp+method+path+body) in SigningHeader (default
+    // X-Signature), the timestamp in SigningTimestampHeader (default
+    // X-Signature-Timestamp). SigningAlgorithm is sha256 (default) or sha512.
+    SigningSecret         string `json:"signing_secret"`
+    SigningHeader         string `json:"signing_header"`
+    SigningTimestampHeader string `json:"signing_timestamp_header"`
+    SigningAlgorithm      string `json:"signing_algorithm"`
+    // RequestTimeoutSec bounds each upstream call of this provider below
+    // server.request_timeout_sec, which stays the ceiling. 0 uses the ceiling.
+    RequestTimeoutSec     int    `json:"request_timeout_sec"`
+    // RequestIDHeader names the header each upstream call carries the ID of
+    // the API request that caused it in (e.g. X-Request-Id). Empty sends none.
+    RequestIDHeader       string `json:"request_id_header"`
 }
 
 type Pricempire struct {
     Enabled               bool     `json:"enabled"`
     APIKey                string   `json:"api_key"`
+    // BaseURL overrides the API base (default https://api.pricempire.com),
+    // e.g. to point at a staging mock.
+    BaseURL               string   `json:"base_url"`
     AppID                 int      `json:"app_id"`
+    // AppIDs serves several games at once (e.g., [730, 570, 440]); overrides AppID.
+    AppIDs                []int    `json:"app_ids"`
     Currency              string   `json:"currency"`
     Sources               []string `json:"sources"`
     MaxRequestsPerMinute  int      `json:"max_requests_per_minute"`
     MinRequestIntervalSec int      `json:"min_request_interval_sec"`
     Burst                 int      `json:"burst"`
+    StartEmpty            bool     `json:"start_empty"`
     CacheTTLSeconds       int      `json:"cache_ttl_sec"`
     CacheMaxItems         int      `json:"cache_max_items"`
+    CacheMaxTTLSeconds    int      `json:"cache_max_ttl_sec"`
+    CacheNegativeTTLSeconds int    `json:"cache_negative_ttl_sec"`
+    ServeStaleDuringOutageSec int  `json:"serve_stale_during_outage_sec"`
+    CacheChunkSize        int      `json:"cache_chunk_size"`
+    CacheChunkConcurrency int      `json:"cache_chunk_concurrency"`
+    CacheRefreshWaitMs    int      `json:"cache_refresh_wait_ms"`
+    HedgeDelayMs          int      `json:"hedge_delay_ms"`
+    RetryAttempts         int      `json:"retry_attempts"`
+    DegradeAfterFailures  int      `json:"degrade_after_failures"`
+    DegradeProbeSec       int      `json:"degrade_probe_sec"`
+    SymbolDenylist        []string `json:"symbol_denylist"`
+    SymbolAllowlist       []string `json:"symbol_allowlist"`
+    // EmitAvg30 adds a "<source>:avg30" quote priced at the 30-day average.
+    EmitAvg30             bool     `json:"emit_avg30"`
+    // CaseInsensitive matches requested symbols to item names ignoring case
+    // when there is no exact match.
+    CaseInsensitive       bool     `json:"case_insensitive"`
+    // SourceCurrency overrides Currency per source when a source reports in
+    // another currency and the API does not say so itself.
+    SourceCurrency        map[string]string `json:"source_currency"`
+    // APIVersion selects the items endpoint: "v3" (default) or "v4".
+    APIVersion            string   `json:"api_version"`
+    // PerSourceRequests fetches each source separately, in parallel, so one
+    // failing source does not fail the others.
+    PerSourceRequests     bool     `json:"per_source_requests"`
+    // ItemsCacheMaxItems caps the items the adapter keeps cached across app
+    // ids, evicting the oldest refresh first (0 = unlimited).
+    ItemsCacheMaxItems    int      `json:"items_cache_max_items"`
+    // SkipMalformed drops items or sources that fail to parse (counted,
+    // sampled log) instead of failing the whole items response.
+    SkipMalformed         bool     `json:"skip_malformed"`
+    ProxyURL              string   `json:"proxy_url"`
+    MaxConnsPerHost       int      `json:"max_conns_per_host"`
+    SigningSecret         string   `json:"signing_secret"`
+    SigningHeader         string   `json:"signing_header"`
+    SigningTimestampHeader string  `json:"signing_timestamp_header"`
+    SigningAlgorithm      string   `json:"signing_algorithm"`
+    // RequestTimeoutSec and RequestIDHeader work as for SteamDT.
+    RequestTimeoutSec     int      `json:"request_timeout_sec"`
+    RequestIDHeader       string   `json:"request_id_header"`
 }
 
 type Push struct {
@@ -58,12 +244,69 @@ type Skinstable struct {
     Currency              string `json:"currency"`
     ItemsCacheTTLSeconds  int    `json:"items_cache_ttl_sec"`
     AppID                 int    `json:"app_id"`
+    AppIDs                []int  `json:"app_ids"`
     Sites                 []string `json:"sites"`
+    // PageSize > 0 fetches each site in pages (offset/limit) of this many items.
+    PageSize              int    `json:"page_size"`
+    // IncludeBids emits buy-order quotes ("<site>:bid") when the API has them.
+    IncludeBids           bool   `json:"include_bids"`
+    // SiteTimeoutSeconds bounds one refresh attempt of a site (default 7).
+    SiteTimeoutSeconds    int    `json:"site_timeout_sec"`
+    // SiteRetries re-attempts a site refresh on retryable failures.
+    SiteRetries           int    `json:"site_retries"`
+    // StaleGraceSeconds keeps serving a site's last good payload this long
+    // past expiry when its refresh fails.
+    StaleGraceSeconds     int    `json:"stale_grace_sec"`
+    // Method (GET or POST) and ParamNames adapt the request to the upstream's
+    // schema; see skinstablexyz.Config.
+    Method                string            `json:"method"`
+    ParamNames            map[string]string `json:"param_names"`
     MaxRequestsPerMinute  int    `json:"max_requests_per_minute"`
     MinRequestIntervalSec int    `json:"min_request_interval_sec"`
     Burst                 int    `json:"burst"`
+    StartEmpty            bool   `json:"start_empty"`
     CacheTTLSeconds       int    `json:"cache_ttl_sec"`
     CacheMaxItems         int    `json:"cache_max_items"`
+    CacheMaxTTLSeconds    int    `json:"cache_max_ttl_sec"`
+    CacheNegativeTTLSeconds int  `json:"cache_negative_ttl_sec"`
+    ServeStaleDuringOutageSec int `json:"serve_stale_during_outage_sec"`
+    CacheChunkSize        int    `json:"cache_chunk_size"`
+    CacheChunkConcurrency int    `json:"cache_chunk_concurrency"`
+    CacheRefreshWaitMs    int    `json:"cache_refresh_wait_ms"`
+    HedgeDelayMs          int    `json:"hedge_delay_ms"`
+    RetryAttempts         int    `json:"retry_attempts"`
+    DegradeAfterFailures  int    `json:"degrade_after_failures"`
+    DegradeProbeSec       int    `json:"degrade_probe_sec"`
+    SymbolDenylist        []string `json:"symbol_denylist"`
+    SymbolAllowlist       []string `json:"symbol_allowlist"`
+    ProxyURL              string `json:"proxy_url"`
+    MaxConnsPerHost       int    `json:"max_conns_per_host"`
+    SigningSecret         string `json:"signing_secret"`
+    SigningHeader         string `json:"signing_header"`
+    SigningTimestampHeader string `json:"signing_timestamp_header"`
+    SigningAlgorithm      string `json:"signing_algorithm"`
+    RequestTimeoutSec     int    `json:"request_timeout_sec"`
+    RequestIDHeader       string `json:"request_id_header"`
+}
+
+// Debug holds troubleshooting switches; keep them off in production.
+type Debug struct {
+    // DumpHTTP logs outbound provider requests (secrets redacted) and
+    // truncated response bodies.
+    DumpHTTP      bool `json:"dump_http"`
+    // DumpBodyBytes caps the logged body size (default 2048).
+    DumpBodyBytes int  `json:"dump_body_bytes"`
+    // FixturesDir serves upstream HTTP from recorded fixtures (see
+    // httpx.FixtureTransport) instead of the network.
+    FixturesDir   string `json:"fixtures_dir"`
+    // LogSampleRate is the fraction (0..1) of successful requests that get a
+    // request log line; error responses are always logged. Default 1.
+    LogSampleRate float64 `json:"log_sample_rate"`
+    // PanicStack logs the stack trace of panics recovered from handlers
+    // (default true); PanicRef also puts the panic's log reference in the
+    // 500 response. Neither ever sends the panic value to clients.
+    PanicStack    bool    `json:"panic_stack"`
+    PanicRef      bool    `json:"panic_ref"`
 }
 
 type Config struct {
@@ -72,11 +315,55 @@ type Config struct {
     Pricempire Pricempire `json:"pricempire"`
     Skinstable Skinstable `json:"skinstable"`
     Push       Push       `json:"push"`
+    Debug      Debug      `json:"debug"`
+    Auth       Auth       `json:"auth"`
+    Publish    Publish    `json:"publish"`
+    Cache      Cache      `json:"cache"`
+}
+
+// Publish forwards the quotes served by /api/quotes to a Kafka topic when
+// both KafkaBrokers and KafkaTopic are set.
+type Publish struct {
+    KafkaBrokers []string `json:"kafka_brokers"`
+    KafkaTopic   string   `json:"kafka_topic"`
+    // TimeoutMs bounds each delivery (default 5000).
+    TimeoutMs    int      `json:"timeout_ms"`
+    // QueueSize is how many batches may wait for delivery before new ones
+    // are dropped (default 64).
+    QueueSize    int      `json:"queue_size"`
+}
+
+// Cache selects the shared level behind the per-provider caches. With
+// Backend "memory" (the default) each instance keeps only its own cache; with
+// "redis" misses are looked up in, and fresh quotes written to, Redis so
+// instances behind a load balancer share upstream results.
+type Cache struct {
+    Backend       string `json:"backend"`
+    RedisAddr     string `json:"redis_addr"`
+    RedisPassword string `json:"redis_password"`
+    RedisDB       int    `json:"redis_db"`
+    // RedisPrefix is prepended to every key (default "priceprovider:cache:").
+    RedisPrefix   string `json:"redis_prefix"`
+    // RedisTimeoutMs bounds each Redis round trip (default 2000).
+    RedisTimeoutMs int   `json:"redis_timeout_ms"`
+}
+
+// Auth ties API keys (sent as X-API-Key) to tiers with their own limits.
+type Auth struct {
+    // AnonymousMaxSymbols caps requests without an API key (default 1000).
+    AnonymousMaxSymbols int             `json:"anonymous_max_symbols"`
+    // Tiers holds the limits per tier name; APIKeys maps each key to a tier.
+    Tiers               map[string]Tier   `json:"tiers"`
+    APIKeys             map[string]string `json:"api_keys"`
+}
+
+type Tier struct {
+    MaxSymbols int `json:"max_symbols"`
 }
 
 func Default() Config {
     return Config{
-        Server: Server{Port: "8080", RequestTimeoutSec: 10},
+        Server: Server{Port: "8080", RequestTimeoutSec: 10, RequestDeadlineSec: 15, IdempotencyTTLSec: 60, IdempotencyMaxKeys: 1000, AdmissionWaitMs: 250},
         SteamDT: SteamDT{
             Enabled:     true,
             Endpoint:    "https://open.steamdt.com/open/cs2/v1/price/batch",
@@ -116,16 +403,21 @@ func Default() Config {
             IntervalSec: 60,
             Side:        "all",
         },
+        Debug: Debug{LogSampleRate: 1, PanicStack: true},
     }
 }
 
-// Load reads JSON config from path. If path is empty or file does not exist,
-// it returns defaults. Environment variables override select fields for secrecy.
+// Load reads JSON or YAML (.yaml/.yml) config from path. If path is empty it
+// looks for config.json, config.yaml or config.yml; if no file exists, it
+// returns defaults. Environment variables override select fields for secrecy.
 func Load(path string) (Config, error) {
     cfg := Default()
     if path == "" {
-        if _, err := os.Stat("config.json"); err == nil {
-            path = "config.json"
+        for _, name := range []string{"config.json", "config.yaml", "config.yml"} {
+            if _, err := os.Stat(name); err == nil {
+                path = name
+                break
+            }
         }
     }
     if path != "" {
@@ -134,7 +426,7 @@ func Load(path string) (Config, error) {
             return cfg, fmt.Errorf("read config: %w", err)
         }
         if err == nil {
-            if err := json.Unmarshal(b, &cfg); err != nil {
+            if err := parse(path, b, &cfg); err != nil {
                 return cfg, fmt.Errorf("parse config: %w", err)
             }
         }
@@ -143,13 +435,163 @@ func Load(path string) (Config, error) {
     return cfg, nil
 }
 
+// parse decodes b into cfg by file extension. YAML is converted to JSON first
+// so the json tags remain the only key names to maintain.
+func parse(path string, b []byte, cfg *Config) error {
+    switch strings.ToLower(filepath.Ext(path)) {
+    case ".yaml", ".yml":
+        var doc any
+        if err := yaml.Unmarshal(b, &doc); err != nil { return err }
+        if doc == nil { return nil } // empty file: keep defaults
+        j, err := json.Marshal(doc)
+        if err != nil { return err }
+        return json.Unmarshal(j, cfg)
+    }
+    return json.Unmarshal(b, cfg)
+}
+
+// Validate reports settings that load fine but work against each other, one
+// message per problem. Currently that is a provider cache TTL shorter than the
+// interval its rate limit allows between requests: cached symbols expire before
+// the limiter lets a refresh through, so requests queue on the limiter and time
+// out. With server.raise_cache_ttl the TTL is raised to the interval.
+func (c *Config) Validate() []string {
+    var warnings []string
+    check := func(name string, enabled bool, rpm, minIntervalSec int, ttl *int) {
+        if !enabled || *ttl <= 0 { return }
+        interval, by := 0, ""
+        switch {
+        case rpm > 0:
+            interval, by = (60+rpm-1)/rpm, fmt.Sprintf("max_requests_per_minute=%d", rpm)
+        case minIntervalSec > 0:
+            interval, by = minIntervalSec, fmt.Sprintf("min_request_interval_sec=%d", minIntervalSec)
+        }
+        if *ttl >= interval { return }
+        msg := fmt.Sprintf("%s.cache_ttl_sec (%ds) is shorter than the %ds between requests allowed by %s; cached symbols expire before the limiter allows a refresh", name, *ttl, interval, by)
+        if c.Server.RaiseCacheTTL {
+            *ttl = interval
+            msg += fmt.Sprintf(" (raised to %ds)", interval)
+        }
+        warnings = append(warnings, msg)
+    }
+    check("steamdt", c.SteamDT.Enabled, c.SteamDT.MaxRequestsPerMinute, c.SteamDT.MinRequestIntervalSec, &c.SteamDT.CacheTTLSeconds)
+    check("pricempire", c.Pricempire.Enabled, c.Pricempire.MaxRequestsPerMinute, c.Pricempire.MinRequestIntervalSec, &c.Pricempire.CacheTTLSeconds)
+    check("skinstable", c.Skinstable.Enabled, c.Skinstable.MaxRequestsPerMinute, c.Skinstable.MinRequestIntervalSec, &c.Skinstable.CacheTTLSeconds)
+    return warnings
+}
+
 func applyEnv(cfg *Config) {
     if v := os.Getenv("PORT"); v != "" { cfg.Server.Port = v }
     if v := os.Getenv("REQUEST_TIMEOUT_SEC"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Server.RequestTimeoutSec = x }
     }
+    if v := os.Getenv("REQUEST_DEADLINE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Server.RequestDeadlineSec = x }
+    }
+    if v := os.Getenv("ADMIN_TOKEN"); v != "" { cfg.Server.AdminToken = v }
+    if v := os.Getenv("SUPPRESS_ZERO"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.SuppressZero = true
+        case "0","false","no","n": cfg.Server.SuppressZero = false
+        }
+    }
+    if v := os.Getenv("MIN_PRICE"); v != "" { cfg.Server.MinPrice = json.Number(v) }
+    if v := os.Getenv("JSON_CASE"); v != "" { cfg.Server.JSONCase = v }
+    if v := os.Getenv("TIMESTAMP_FORMAT"); v != "" { cfg.Server.TimestampFormat = v }
+    if v := os.Getenv("ERROR_FORMAT"); v != "" { cfg.Server.ErrorFormat = v }
+    if v := os.Getenv("ANONYMOUS_MAX_SYMBOLS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Auth.AnonymousMaxSymbols = x }
+    }
+    // API_KEYS is a CSV of key=tier pairs; tiers still come from the config file.
+    if v := os.Getenv("API_KEYS"); v != "" {
+        cfg.Auth.APIKeys = make(map[string]string)
+        for _, pair := range splitCSV(v) {
+            if key, tier, ok := strings.Cut(pair, "="); ok { cfg.Auth.APIKeys[strings.TrimSpace(key)] = strings.TrimSpace(tier) }
+        }
+    }
+    if v := os.Getenv("SANITY_MAX_DEVIATION"); v != "" {
+        var x float64; fmt.Sscanf(v, "%g", &x); if x >= 0 { cfg.Server.SanityMaxDeviation = x }
+    }
+    if v := os.Getenv("MAX_IN_FLIGHT"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Server.MaxInFlight = x }
+    }
+    if v := os.Getenv("ADMISSION_WAIT_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Server.AdmissionWaitMs = x }
+    }
+    if v := os.Getenv("FETCH_CONCURRENCY"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Server.FetchConcurrency = x }
+    }
+    if v := os.Getenv("SANITY_DROP"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.SanityDrop = true
+        case "0","false","no","n": cfg.Server.SanityDrop = false
+        }
+    }
+    if v := os.Getenv("KAFKA_BROKERS"); v != "" { cfg.Publish.KafkaBrokers = splitCSV(v) }
+    if v := os.Getenv("KAFKA_TOPIC"); v != "" { cfg.Publish.KafkaTopic = v }
+    if v := os.Getenv("CACHE_BACKEND"); v != "" { cfg.Cache.Backend = strings.ToLower(strings.TrimSpace(v)) }
+    if v := os.Getenv("CACHE_REDIS_ADDR"); v != "" { cfg.Cache.RedisAddr = v }
+    if v := os.Getenv("CACHE_REDIS_PASSWORD"); v != "" { cfg.Cache.RedisPassword = v }
+    if v := os.Getenv("CACHE_REDIS_DB"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Cache.RedisDB = x }
+    }
+    if v := os.Getenv("CACHE_REDIS_PREFIX"); v != "" { cfg.Cache.RedisPrefix = v }
+    if v := os.Getenv("RAISE_CACHE_TTL"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.RaiseCacheTTL = true
+        case "0","false","no","n": cfg.Server.RaiseCacheTTL = false
+        }
+    }
+    if v := os.Getenv("DISABLE_ALL_UPSTREAMS"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.DisableAllUpstreams = true
+        case "0","false","no","n": cfg.Server.DisableAllUpstreams = false
+        }
+    }
+    if v := os.Getenv("TLS_CERT_FILE"); v != "" { cfg.Server.TLSCertFile = v }
+    if v := os.Getenv("TLS_KEY_FILE"); v != "" { cfg.Server.TLSKeyFile = v }
+    if v := os.Getenv("GZIP_LEVEL"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); cfg.Server.GzipLevel = x
+    }
+    if v := os.Getenv("IDEMPOTENCY_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Server.IdempotencyTTLSec = x }
+    }
+    if v := os.Getenv("IDEMPOTENCY_MAX_KEYS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Server.IdempotencyMaxKeys = x }
+    }
+    if v := os.Getenv("WARMUP_SYMBOLS"); v != "" { cfg.Server.WarmupSymbols = splitCSV(v) }
+    if v := os.Getenv("MARKET_PRIORITY"); v != "" { cfg.Server.MarketPriority = splitCSV(v) }
+    if v := os.Getenv("PROVIDER_PRIORITY"); v != "" { cfg.Server.ProviderPriority = splitCSV(v) }
+    if v := os.Getenv("STRICT_QUERY_PARAMS"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.StrictQueryParams = true
+        case "0","false","no","n": cfg.Server.StrictQueryParams = false
+        }
+    }
+    if v := os.Getenv("WARMUP_FILE"); v != "" { cfg.Server.WarmupFile = v }
+    if v := os.Getenv("TRACK_CHANGES"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Server.TrackChanges = true
+        case "0","false","no","n": cfg.Server.TrackChanges = false
+        }
+    }
+    if v := os.Getenv("GZIP_MIN_BYTES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Server.GzipMinBytes = x }
+    }
     if v := os.Getenv("STEAMDT_API_KEY"); v != "" { cfg.SteamDT.APIKey = v }
+    // STEAMDT_KEY_POOL is a CSV of key=weight pairs; a bare key weighs 1.
+    if v := os.Getenv("STEAMDT_KEY_POOL"); v != "" {
+        cfg.SteamDT.KeyPool = make(map[string]int)
+        for _, pair := range splitCSV(v) {
+            key, w, _ := strings.Cut(pair, "=")
+            weight := 1
+            if w != "" { fmt.Sscanf(strings.TrimSpace(w), "%d", &weight) }
+            cfg.SteamDT.KeyPool[strings.TrimSpace(key)] = weight
+        }
+    }
     if v := os.Getenv("STEAMDT_ENDPOINT"); v != "" { cfg.SteamDT.Endpoint = v }
+    if v := os.Getenv("STEAMDT_ENDPOINTS"); v != "" { cfg.SteamDT.Endpoints = splitCSV(v) }
+    if v := os.Getenv("STEAMDT_RETRY_BACKOFF"); v != "" { cfg.SteamDT.RetryBackoff = v }
     if v := os.Getenv("INCLUDE_BIDS"); v != "" {
         switch strings.ToLower(v) {
         case "1","true","yes","y": cfg.SteamDT.IncludeBids = true
@@ -166,6 +608,12 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("STEAMDT_BURST"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.SteamDT.Burst = x }
     }
+    if v := os.Getenv("STEAMDT_START_EMPTY"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.SteamDT.StartEmpty = true
+        case "0","false","no","n": cfg.SteamDT.StartEmpty = false
+        }
+    }
     if v := os.Getenv("STEAMDT_MAX_ITEMS_PER_REQUEST"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.SteamDT.MaxItemsPerRequest = x }
     }
@@ -178,10 +626,71 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("STEAMDT_CACHE_MAX_ITEMS"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.SteamDT.CacheMaxItems = x }
     }
+    if v := os.Getenv("STEAMDT_CACHE_MAX_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.CacheMaxTTLSeconds = x }
+    }
+    if v := os.Getenv("STEAMDT_CACHE_NEGATIVE_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.CacheNegativeTTLSeconds = x }
+    }
+    if v := os.Getenv("STEAMDT_SERVE_STALE_DURING_OUTAGE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.ServeStaleDuringOutageSec = x }
+    }
+    if v := os.Getenv("STEAMDT_CACHE_CHUNK_SIZE"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.CacheChunkSize = x }
+    }
+    if v := os.Getenv("STEAMDT_CACHE_CHUNK_CONCURRENCY"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.CacheChunkConcurrency = x }
+    }
+    if v := os.Getenv("STEAMDT_CACHE_REFRESH_WAIT_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.CacheRefreshWaitMs = x }
+    }
+    if v := os.Getenv("STEAMDT_HEDGE_DELAY_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.HedgeDelayMs = x }
+    }
+    if v := os.Getenv("STEAMDT_RETRY_ATTEMPTS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.RetryAttempts = x }
+    }
+    if v := os.Getenv("STEAMDT_DEGRADE_AFTER_FAILURES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.DegradeAfterFailures = x }
+    }
+    if v := os.Getenv("STEAMDT_DEGRADE_PROBE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.DegradeProbeSec = x }
+    }
+    if v := os.Getenv("STEAMDT_SYMBOL_DENYLIST"); v != "" { cfg.SteamDT.SymbolDenylist = splitCSV(v) }
+    if v := os.Getenv("STEAMDT_SYMBOL_ALLOWLIST"); v != "" { cfg.SteamDT.SymbolAllowlist = splitCSV(v) }
+    if v := os.Getenv("STEAMDT_PROXY_URL"); v != "" { cfg.SteamDT.ProxyURL = v }
+    if v := os.Getenv("STEAMDT_SIGNING_SECRET"); v != "" { cfg.SteamDT.SigningSecret = v }
+    if v := os.Getenv("STEAMDT_MAX_CONNS_PER_HOST"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.MaxConnsPerHost = x }
+    }
+    if v := os.Getenv("STEAMDT_REQUEST_TIMEOUT_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.RequestTimeoutSec = x }
+    }
+    if v := os.Getenv("STEAMDT_REQUEST_ID_HEADER"); v != "" { cfg.SteamDT.RequestIDHeader = strings.TrimSpace(v) }
+    if v := os.Getenv("STEAMDT_BATCH_MEMO_TTL_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.BatchMemoTTLMs = x }
+    }
+    if v := os.Getenv("STEAMDT_MIN_BATCH_TIME_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.SteamDT.MinBatchTimeMs = x }
+    }
+    if v := os.Getenv("STEAMDT_STRICT_ERRORS"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.SteamDT.StrictErrors = true
+        case "0","false","no","n": cfg.SteamDT.StrictErrors = false
+        }
+    }
+    if v := os.Getenv("STEAMDT_INCLUDE_PLATFORM_ITEM_ID"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.SteamDT.IncludePlatformItemID = true
+        case "0","false","no","n": cfg.SteamDT.IncludePlatformItemID = false
+        }
+    }
     if v := os.Getenv("PRICEMPIRE_API_KEY"); v != "" { cfg.Pricempire.APIKey = v }
+    if v := os.Getenv("PRICEMPIRE_BASE_URL"); v != "" { cfg.Pricempire.BaseURL = v }
     if v := os.Getenv("PRICEMPIRE_APP_ID"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Pricempire.AppID = x }
     }
+    if v := os.Getenv("PRICEMPIRE_APP_IDS"); v != "" { cfg.Pricempire.AppIDs = splitInts(v) }
     if v := os.Getenv("PRICEMPIRE_CURRENCY"); v != "" { cfg.Pricempire.Currency = v }
     if v := os.Getenv("PRICEMPIRE_SOURCES"); v != "" { cfg.Pricempire.Sources = splitCSV(v) }
     if v := os.Getenv("PRICEMPIRE_MIN_INTERVAL_SEC"); v != "" {
@@ -193,12 +702,94 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("PRICEMPIRE_BURST"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Pricempire.Burst = x }
     }
+    if v := os.Getenv("PRICEMPIRE_START_EMPTY"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Pricempire.StartEmpty = true
+        case "0","false","no","n": cfg.Pricempire.StartEmpty = false
+        }
+    }
     if v := os.Getenv("PRICEMPIRE_CACHE_TTL_SEC"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheTTLSeconds = x }
     }
     if v := os.Getenv("PRICEMPIRE_CACHE_MAX_ITEMS"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Pricempire.CacheMaxItems = x }
     }
+    if v := os.Getenv("PRICEMPIRE_CACHE_MAX_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheMaxTTLSeconds = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_CACHE_NEGATIVE_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheNegativeTTLSeconds = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_SERVE_STALE_DURING_OUTAGE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.ServeStaleDuringOutageSec = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_CACHE_CHUNK_SIZE"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheChunkSize = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_CACHE_CHUNK_CONCURRENCY"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheChunkConcurrency = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_CACHE_REFRESH_WAIT_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.CacheRefreshWaitMs = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_HEDGE_DELAY_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.HedgeDelayMs = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_RETRY_ATTEMPTS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.RetryAttempts = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_DEGRADE_AFTER_FAILURES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.DegradeAfterFailures = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_DEGRADE_PROBE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.DegradeProbeSec = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_SYMBOL_DENYLIST"); v != "" { cfg.Pricempire.SymbolDenylist = splitCSV(v) }
+    if v := os.Getenv("PRICEMPIRE_SYMBOL_ALLOWLIST"); v != "" { cfg.Pricempire.SymbolAllowlist = splitCSV(v) }
+    if v := os.Getenv("PRICEMPIRE_PROXY_URL"); v != "" { cfg.Pricempire.ProxyURL = v }
+    if v := os.Getenv("PRICEMPIRE_SIGNING_SECRET"); v != "" { cfg.Pricempire.SigningSecret = v }
+    if v := os.Getenv("PRICEMPIRE_MAX_CONNS_PER_HOST"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.MaxConnsPerHost = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_REQUEST_TIMEOUT_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.RequestTimeoutSec = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_REQUEST_ID_HEADER"); v != "" { cfg.Pricempire.RequestIDHeader = strings.TrimSpace(v) }
+    if v := os.Getenv("PRICEMPIRE_API_VERSION"); v != "" { cfg.Pricempire.APIVersion = v }
+    if v := os.Getenv("PRICEMPIRE_SKIP_MALFORMED"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Pricempire.SkipMalformed = true
+        case "0","false","no","n": cfg.Pricempire.SkipMalformed = false
+        }
+    }
+    if v := os.Getenv("PRICEMPIRE_PER_SOURCE_REQUESTS"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Pricempire.PerSourceRequests = true
+        case "0","false","no","n": cfg.Pricempire.PerSourceRequests = false
+        }
+    }
+    if v := os.Getenv("PRICEMPIRE_ITEMS_CACHE_MAX_ITEMS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Pricempire.ItemsCacheMaxItems = x }
+    }
+    if v := os.Getenv("PRICEMPIRE_EMIT_AVG30"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Pricempire.EmitAvg30 = true
+        case "0","false","no","n": cfg.Pricempire.EmitAvg30 = false
+        }
+    }
+    // PRICEMPIRE_SOURCE_CURRENCY is a CSV of source=currency pairs.
+    if v := os.Getenv("PRICEMPIRE_SOURCE_CURRENCY"); v != "" {
+        cfg.Pricempire.SourceCurrency = make(map[string]string)
+        for _, pair := range splitCSV(v) {
+            if src, cur, ok := strings.Cut(pair, "="); ok { cfg.Pricempire.SourceCurrency[strings.TrimSpace(src)] = strings.ToUpper(strings.TrimSpace(cur)) }
+        }
+    }
+    if v := os.Getenv("PRICEMPIRE_CASE_INSENSITIVE"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Pricempire.CaseInsensitive = true
+        case "0","false","no","n": cfg.Pricempire.CaseInsensitive = false
+        }
+    }
 
     // Skinstable env
     if v := os.Getenv("SKINSTABLE_ENABLED"); v != "" {
@@ -216,6 +807,33 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("SKINSTABLE_APP_ID"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Skinstable.AppID = x }
     }
+    if v := os.Getenv("SKINSTABLE_PAGE_SIZE"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.PageSize = x }
+    }
+    if v := os.Getenv("SKINSTABLE_INCLUDE_BIDS"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Skinstable.IncludeBids = true
+        case "0","false","no","n": cfg.Skinstable.IncludeBids = false
+        }
+    }
+    if v := os.Getenv("SKINSTABLE_SITE_TIMEOUT_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Skinstable.SiteTimeoutSeconds = x }
+    }
+    if v := os.Getenv("SKINSTABLE_SITE_RETRIES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.SiteRetries = x }
+    }
+    if v := os.Getenv("SKINSTABLE_STALE_GRACE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.StaleGraceSeconds = x }
+    }
+    if v := os.Getenv("SKINSTABLE_METHOD"); v != "" { cfg.Skinstable.Method = strings.ToUpper(strings.TrimSpace(v)) }
+    // SKINSTABLE_PARAM_NAMES is a CSV of default=upstream parameter names.
+    if v := os.Getenv("SKINSTABLE_PARAM_NAMES"); v != "" {
+        cfg.Skinstable.ParamNames = make(map[string]string)
+        for _, pair := range splitCSV(v) {
+            if from, to, ok := strings.Cut(pair, "="); ok { cfg.Skinstable.ParamNames[strings.TrimSpace(from)] = strings.TrimSpace(to) }
+        }
+    }
+    if v := os.Getenv("SKINSTABLE_APP_IDS"); v != "" { cfg.Skinstable.AppIDs = splitInts(v) }
     if v := os.Getenv("SKINSTABLE_SITES"); v != "" { cfg.Skinstable.Sites = splitCSV(v) }
     if v := os.Getenv("SKINSTABLE_MIN_INTERVAL_SEC"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.MinRequestIntervalSec = x }
@@ -226,12 +844,59 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("SKINSTABLE_BURST"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Skinstable.Burst = x }
     }
+    if v := os.Getenv("SKINSTABLE_START_EMPTY"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Skinstable.StartEmpty = true
+        case "0","false","no","n": cfg.Skinstable.StartEmpty = false
+        }
+    }
     if v := os.Getenv("SKINSTABLE_CACHE_TTL_SEC"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheTTLSeconds = x }
     }
     if v := os.Getenv("SKINSTABLE_CACHE_MAX_ITEMS"); v != "" {
         var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Skinstable.CacheMaxItems = x }
     }
+    if v := os.Getenv("SKINSTABLE_CACHE_MAX_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheMaxTTLSeconds = x }
+    }
+    if v := os.Getenv("SKINSTABLE_CACHE_NEGATIVE_TTL_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheNegativeTTLSeconds = x }
+    }
+    if v := os.Getenv("SKINSTABLE_SERVE_STALE_DURING_OUTAGE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.ServeStaleDuringOutageSec = x }
+    }
+    if v := os.Getenv("SKINSTABLE_CACHE_CHUNK_SIZE"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheChunkSize = x }
+    }
+    if v := os.Getenv("SKINSTABLE_CACHE_CHUNK_CONCURRENCY"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheChunkConcurrency = x }
+    }
+    if v := os.Getenv("SKINSTABLE_CACHE_REFRESH_WAIT_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.CacheRefreshWaitMs = x }
+    }
+    if v := os.Getenv("SKINSTABLE_HEDGE_DELAY_MS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.HedgeDelayMs = x }
+    }
+    if v := os.Getenv("SKINSTABLE_RETRY_ATTEMPTS"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.RetryAttempts = x }
+    }
+    if v := os.Getenv("SKINSTABLE_DEGRADE_AFTER_FAILURES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.DegradeAfterFailures = x }
+    }
+    if v := os.Getenv("SKINSTABLE_DEGRADE_PROBE_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.DegradeProbeSec = x }
+    }
+    if v := os.Getenv("SKINSTABLE_SYMBOL_DENYLIST"); v != "" { cfg.Skinstable.SymbolDenylist = splitCSV(v) }
+    if v := os.Getenv("SKINSTABLE_SYMBOL_ALLOWLIST"); v != "" { cfg.Skinstable.SymbolAllowlist = splitCSV(v) }
+    if v := os.Getenv("SKINSTABLE_PROXY_URL"); v != "" { cfg.Skinstable.ProxyURL = v }
+    if v := os.Getenv("SKINSTABLE_SIGNING_SECRET"); v != "" { cfg.Skinstable.SigningSecret = v }
+    if v := os.Getenv("SKINSTABLE_MAX_CONNS_PER_HOST"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.MaxConnsPerHost = x }
+    }
+    if v := os.Getenv("SKINSTABLE_REQUEST_TIMEOUT_SEC"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x >= 0 { cfg.Skinstable.RequestTimeoutSec = x }
+    }
+    if v := os.Getenv("SKINSTABLE_REQUEST_ID_HEADER"); v != "" { cfg.Skinstable.RequestIDHeader = strings.TrimSpace(v) }
 
     // Push env
     if v := os.Getenv("PUSH_ENABLED"); v != "" {
@@ -248,6 +913,31 @@ func applyEnv(cfg *Config) {
     if v := os.Getenv("PUSH_SYMBOLS"); v != "" { cfg.Push.Symbols = splitCSV(v) }
     if v := os.Getenv("PUSH_SIDE"); v != "" { cfg.Push.Side = strings.ToLower(strings.TrimSpace(v)) }
     if v := os.Getenv("PUSH_MARKETS"); v != "" { cfg.Push.Markets = splitCSV(v) }
+    if v := os.Getenv("DEBUG_DUMP_HTTP"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Debug.DumpHTTP = true
+        case "0","false","no","n": cfg.Debug.DumpHTTP = false
+        }
+    }
+    if v := os.Getenv("DEBUG_DUMP_BODY_BYTES"); v != "" {
+        var x int; fmt.Sscanf(v, "%d", &x); if x > 0 { cfg.Debug.DumpBodyBytes = x }
+    }
+    if v := os.Getenv("DEBUG_FIXTURES_DIR"); v != "" { cfg.Debug.FixturesDir = v }
+    if v := os.Getenv("DEBUG_LOG_SAMPLE_RATE"); v != "" {
+        var x float64; fmt.Sscanf(v, "%g", &x); if x >= 0 && x <= 1 { cfg.Debug.LogSampleRate = x }
+    }
+    if v := os.Getenv("DEBUG_PANIC_STACK"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Debug.PanicStack = true
+        case "0","false","no","n": cfg.Debug.PanicStack = false
+        }
+    }
+    if v := os.Getenv("DEBUG_PANIC_REF"); v != "" {
+        switch strings.ToLower(v) {
+        case "1","true","yes","y": cfg.Debug.PanicRef = true
+        case "0","false","no","n": cfg.Debug.PanicRef = false
+        }
+    }
 }
 
 func splitCSV(s string) []string {
@@ -259,3 +949,14 @@ func splitCSV(s string) []string {
     }
     return out
 }
+
+// splitInts parses a CSV of positive integers, skipping invalid entries.
+func splitInts(s string) []int {
+    parts := splitCSV(s)
+    out := make([]int, 0, len(parts))
+    for _, p := range parts {
+        var x int
+        if _, err := fmt.Sscanf(p, "%d", &x); err == nil && x > 0 { out = append(out, x) }
+    }
+    return out
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func TestLoad_YAMLMatchesJSON(t *testing.T) {
+    dir := t.TempDir()
+    jsonPath := filepath.Join(dir, "config.json")
+    yamlPath := filepath.Join(dir, "config.yaml")
+    if err := os.WriteFile(jsonPath, []byte(`{
+  "server": {"port": "9090", "min_price": 0.05, "sanity_max_deviation": 2.5, "suppress_zero": true},
+  "steamdt": {"api_key": "k", "include_bids": false, "max_items_per_request": 50},
+  "pricempire": {"enabled": true, "sources": ["buff", "steam"], "app_ids": [730, 570]},
+  "skinstable": {"enabled": true, "endpoint": "https://example.com/items", "site_retries": 2},
+  "auth": {"tiers": {"pro": {"max_symbols": 5000}}, "api_keys": {"abc": "pro"}}
+}`), 0o644); err != nil { t.Fatal(err) }
+    if err := os.WriteFile(yamlPath, []byte(`# same settings as config.json
+server:
+  port: "9090"
+  min_price: 0.05
+  sanity_max_deviation: 2.5
+  suppress_zero: true
+steamdt:
+  api_key: k
+  include_bids: false
+  max_items_per_request: 50
+pricempire:
+  enabled: true
+  sources: [buff, steam]
+  app_ids:
+    - 730
+    - 570
+skinstable:
+  enabled: true
+  endpoint: https://example.com/items
+  site_retries: 2
+auth:
+  tiers:
+    pro: {max_symbols: 5000}
+  api_keys:
+    abc: pro
+`), 0o644); err != nil { t.Fatal(err) }
+
+    fromJSON, err := Load(jsonPath)
+    if err != nil { t.Fatalf("json: %v", err) }
+    fromYAML, err := Load(yamlPath)
+    if err != nil { t.Fatalf("yaml: %v", err) }
+    if !reflect.DeepEqual(fromJSON, fromYAML) { t.Fatalf("configs differ:\njson: %+v\nyaml: %+v", fromJSON, fromYAML) }
+    if fromYAML.Server.Port != "9090" || fromYAML.Server.MinPrice != "0.05" || fromYAML.Auth.Tiers["pro"].MaxSymbols != 5000 { t.Fatalf("yaml values not applied: %+v", fromYAML) }
+    if fromYAML.SteamDT.Currency != Default().SteamDT.Currency { t.Fatalf("defaults lost for unset keys: %+v", fromYAML.SteamDT) }
+
+    // .yml works too, and env overrides still apply after parsing
+    ymlPath := filepath.Join(dir, "config.yml")
+    if err := os.WriteFile(ymlPath, []byte("server:\n  port: \"9090\"\n"), 0o644); err != nil { t.Fatal(err) }
+    t.Setenv("PORT", "7070")
+    cfg, err := Load(ymlPath)
+    if err != nil || cfg.Server.Port != "7070" { t.Fatalf("want env override, got %v %q", err, cfg.Server.Port) }
+}
+
+func TestValidate_CacheTTLShorterThanRateLimitInterval(t *testing.T) {
+    cfg := Default()
+    cfg.SteamDT.MaxRequestsPerMinute, cfg.SteamDT.CacheTTLSeconds = 1, 3
+    cfg.Pricempire.Enabled, cfg.Pricempire.MaxRequestsPerMinute, cfg.Pricempire.CacheTTLSeconds = true, 2, 30
+
+    warnings := cfg.Validate()
+    if len(warnings) != 1 { t.Fatalf("want 1 warning, got %q", warnings) }
+    if !strings.Contains(warnings[0], "steamdt.cache_ttl_sec (3s) is shorter than the 60s") { t.Fatalf("unexpected warning: %q", warnings[0]) }
+    if cfg.SteamDT.CacheTTLSeconds != 3 { t.Fatalf("ttl changed without raise_cache_ttl: %d", cfg.SteamDT.CacheTTLSeconds) }
+
+    cfg.Server.RaiseCacheTTL = true
+    warnings = cfg.Validate()
+    if len(warnings) != 1 || !strings.Contains(warnings[0], "raised to 60s") { t.Fatalf("want raise notice, got %q", warnings) }
+    if cfg.SteamDT.CacheTTLSeconds != 60 { t.Fatalf("want ttl raised to 60, got %d", cfg.SteamDT.CacheTTLSeconds) }
+    if w := cfg.Validate(); len(w) != 0 { t.Fatalf("want no warnings after raise, got %q", w) }
+}
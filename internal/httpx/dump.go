@@ -0,0 +1,97 @@
+package httpx
+
+import (
+    "bytes"
+    "io"
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// DefaultDumpBodyBytes caps how much of each response body is logged.
+const DefaultDumpBodyBytes = 2048
+
+// sensitiveHeaders and sensitiveParams are replaced with "REDACTED" in dumps.
+var sensitiveHeaders = map[string]bool{
+    "authorization": true,
+    "cookie":        true,
+    "x-api-key":     true,
+}
+
+var sensitiveParams = map[string]bool{
+    "api_key": true,
+    "apikey":  true,
+    "key":     true,
+    "token":   true,
+}
+
+// DumpTransport logs every outbound request (URL and headers, with secrets
+// redacted) and the first MaxBody bytes of the response body. The response
+// body is left intact for the caller.
+type DumpTransport struct {
+    Base    http.RoundTripper
+    MaxBody int
+    Logf    func(format string, args ...any) // defaults to log.Printf
+}
+
+func (d *DumpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    logf := d.Logf
+    if logf == nil { logf = log.Printf }
+    base := d.Base
+    if base == nil { base = http.DefaultTransport }
+
+    logf("http dump: > %s %s headers=%v", req.Method, RedactURL(req.URL), RedactHeaders(req.Header))
+    res, err := base.RoundTrip(req)
+    if err != nil {
+        logf("http dump: < %s %s error: %v", req.Method, RedactURL(req.URL), err)
+        return res, err
+    }
+    max := d.MaxBody
+    if max <= 0 { max = DefaultDumpBodyBytes }
+    head, rerr := io.ReadAll(io.LimitReader(res.Body, int64(max)))
+    // stitch the consumed prefix back in front of the unread remainder
+    res.Body = readCloser{io.MultiReader(bytes.NewReader(head), res.Body), res.Body}
+    suffix := ""
+    if len(head) == max { suffix = "...(truncated)" }
+    if rerr != nil { suffix += " (read error: " + rerr.Error() + ")" }
+    logf("http dump: < %s %s status=%d body=%s%s", req.Method, RedactURL(req.URL), res.StatusCode, head, suffix)
+    return res, nil
+}
+
+type readCloser struct {
+    io.Reader
+    io.Closer
+}
+
+// RedactURL returns u as a string with sensitive query parameters masked.
+func RedactURL(u *url.URL) string {
+    if u == nil { return "" }
+    c := *u
+    q := c.Query()
+    changed := false
+    for k := range q {
+        if sensitiveParams[strings.ToLower(k)] {
+            q.Set(k, "REDACTED")
+            changed = true
+        }
+    }
+    if changed { c.RawQuery = q.Encode() }
+    c.User = nil
+    return c.String()
+}
+
+// RedactHeaders returns a copy of h with sensitive values masked.
+func RedactHeaders(h http.Header) http.Header {
+    out := h.Clone()
+    for k := range out {
+        if sensitiveHeaders[strings.ToLower(k)] { out[k] = []string{"REDACTED"} }
+    }
+    return out
+}
+
+// EnableDump wraps the client's transport with a DumpTransport. Clients that
+// share c.HTTP (e.g., the Pricempire client) are covered as well.
+func (c *Client) EnableDump(maxBody int) {
+    c.HTTP.Transport = &DumpTransport{Base: c.HTTP.Transport, MaxBody: maxBody}
+}
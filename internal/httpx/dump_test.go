@@ -0,0 +1,38 @@
+package httpx
+
+import (
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestDumpTransport_RedactsSecretsAndKeepsBody(t *testing.T) {
+    body := strings.Repeat("x", 100)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, body)
+    }))
+    defer srv.Close()
+
+    var logs []string
+    c := New(5 * time.Second)
+    c.HTTP.Transport = &DumpTransport{Base: c.HTTP.Transport, MaxBody: 10, Logf: func(f string, a ...any) { logs = append(logs, fmt.Sprintf(f, a...)) }}
+
+    req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, srv.URL+"/items?app=730&api_key=s3cret-key", nil)
+    req.Header.Set("Authorization", "Bearer s3cret-token")
+    req.Header.Set("X-Trace", "visible")
+    res, err := c.Do(t.Context(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    got, _ := io.ReadAll(res.Body)
+    res.Body.Close()
+    if string(got) != body { t.Fatalf("body altered by dump: %q", got) }
+
+    all := strings.Join(logs, "\n")
+    if strings.Contains(all, "s3cret") { t.Fatalf("secret leaked into logs:\n%s", all) }
+    for _, want := range []string{"api_key=REDACTED", "Authorization:[REDACTED]", "X-Trace:[visible]", "app=730", "status=200", "xxxxxxxxxx...(truncated)"} {
+        if !strings.Contains(all, want) { t.Fatalf("want %q in logs:\n%s", want, all) }
+    }
+}
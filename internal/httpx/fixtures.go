@@ -0,0 +1,103 @@
+package httpx
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "os"
+    "path/filepath"
+    "strings"
+)
+
+// FixtureManifest is the file FixtureTransport reads from its directory.
+const FixtureManifest = "fixtures.json"
+
+// Fixture maps a request to a recorded response. URL query parameters act as
+// a subset match: the request must carry each listed value but may have more.
+type Fixture struct {
+    Method  string            `json:"method"`  // empty matches any method
+    URL     string            `json:"url"`
+    Status  int               `json:"status"`  // default 200
+    Headers map[string]string `json:"headers"`
+    File    string            `json:"file"`    // body, relative to the fixtures dir
+    Body    string            `json:"body"`    // inline body when File is empty
+}
+
+// FixtureTransport serves responses from recorded fixtures instead of the
+// network, so the whole pipeline can run deterministically (e.g., in CI).
+// Requests without a matching fixture fail with an error.
+type FixtureTransport struct {
+    Dir      string
+    Fixtures []Fixture
+}
+
+// LoadFixtures reads dir/fixtures.json.
+func LoadFixtures(dir string) (*FixtureTransport, error) {
+    b, err := os.ReadFile(filepath.Join(dir, FixtureManifest))
+    if err != nil { return nil, fmt.Errorf("fixtures: %w", err) }
+    var fs []Fixture
+    if err := json.Unmarshal(b, &fs); err != nil { return nil, fmt.Errorf("fixtures: %s: %w", FixtureManifest, err) }
+    for i, f := range fs {
+        if _, err := url.Parse(f.URL); err != nil { return nil, fmt.Errorf("fixtures: entry %d: %w", i, err) }
+    }
+    return &FixtureTransport{Dir: dir, Fixtures: fs}, nil
+}
+
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+    if req.Body != nil { req.Body.Close() }
+    for _, f := range t.Fixtures {
+        if !f.matches(req) { continue }
+        body := []byte(f.Body)
+        if f.File != "" {
+            var err error
+            body, err = os.ReadFile(filepath.Join(t.Dir, f.File))
+            if err != nil { return nil, fmt.Errorf("fixtures: %w", err) }
+        }
+        status := f.Status
+        if status == 0 { status = http.StatusOK }
+        h := make(http.Header, len(f.Headers))
+        for k, v := range f.Headers { h.Set(k, v) }
+        return &http.Response{
+            Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+            StatusCode:    status,
+            Proto:         "HTTP/1.1",
+            ProtoMajor:    1,
+            ProtoMinor:    1,
+            Header:        h,
+            Body:          io.NopCloser(bytes.NewReader(body)),
+            ContentLength: int64(len(body)),
+            Request:       req,
+        }, nil
+    }
+    return nil, fmt.Errorf("fixtures: no fixture for %s %s", req.Method, RedactURL(req.URL))
+}
+
+func (f Fixture) matches(req *http.Request) bool {
+    if f.Method != "" && !strings.EqualFold(f.Method, req.Method) { return false }
+    u, err := url.Parse(f.URL)
+    if err != nil { return false }
+    if !strings.EqualFold(u.Host, req.URL.Host) || u.Path != req.URL.Path { return false }
+    got := req.URL.Query()
+    for k, want := range u.Query() {
+        for _, v := range want {
+            found := false
+            for _, g := range got[k] {
+                if g == v { found = true; break }
+            }
+            if !found { return false }
+        }
+    }
+    return true
+}
+
+// UseFixtures replaces the client's transport with a FixtureTransport loaded
+// from dir. Clients that share c.HTTP (e.g., the Pricempire client) are covered as well.
+func (c *Client) UseFixtures(dir string) error {
+    t, err := LoadFixtures(dir)
+    if err != nil { return err }
+    c.HTTP.Transport = t
+    return nil
+}
@@ -0,0 +1,36 @@
+package httpx
+
+import (
+    "io"
+    "net/http"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestFixtureTransport_MatchesByURLSubset(t *testing.T) {
+    dir := t.TempDir()
+    manifest := `[
+        {"method":"GET","url":"https://api.example.com/v1/items?app=730","file":"items.json"},
+        {"url":"https://api.example.com/v1/items?app=570","status":429,"body":"slow down"}
+    ]`
+    if err := os.WriteFile(filepath.Join(dir, FixtureManifest), []byte(manifest), 0o644); err != nil { t.Fatalf("write: %v", err) }
+    if err := os.WriteFile(filepath.Join(dir, "items.json"), []byte(`{"a":1}`), 0o644); err != nil { t.Fatalf("write: %v", err) }
+
+    c := New(5 * time.Second)
+    if err := c.UseFixtures(dir); err != nil { t.Fatalf("load: %v", err) }
+    get := func(u string) (*http.Response, error) {
+        req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, u, nil)
+        return c.Do(t.Context(), req)
+    }
+
+    res, err := get("https://api.example.com/v1/items?currency=USD&app=730&api_key=x")
+    if err != nil { t.Fatalf("get: %v", err) }
+    b, _ := io.ReadAll(res.Body)
+    if res.StatusCode != 200 || string(b) != `{"a":1}` { t.Fatalf("unexpected response %d %s", res.StatusCode, b) }
+
+    if res, err := get("https://api.example.com/v1/items?app=570"); err != nil || res.StatusCode != 429 { t.Fatalf("want recorded 429, got %v %v", res, err) }
+    if _, err := get("https://api.example.com/v1/items?app=440"); err == nil || !strings.Contains(err.Error(), "no fixture") { t.Fatalf("want no-fixture error, got %v", err) }
+}
@@ -2,16 +2,48 @@ package httpx
 
 import (
     "context"
+    "fmt"
+    "io"
     "net"
     "net/http"
+    "net/url"
+    "strings"
     "time"
 )
 
 // Client is a small wrapper around http.Client with sane defaults.
+//
+// Timeouts: HTTP.Timeout (set by New) is the ceiling for every request,
+// body reads included. A request is cut off earlier by a shorter deadline on
+// its context, whether set by the caller or by RequestTimeout; the shortest
+// of the three always wins, and neither can extend a request past the ceiling.
 type Client struct {
     HTTP      *http.Client
     UserAgent string
     Headers   map[string]string
+    // RequestTimeout bounds each request made through Do (0 = only the
+    // ceiling applies).
+    RequestTimeout time.Duration
+    // RequestIDHeader, when set, names the header Do fills with the request
+    // ID found in the context (see WithRequestID), so upstream logs can be
+    // matched to ours.
+    RequestIDHeader string
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context carrying id as the ID of the inbound
+// request that outbound calls made with it serve. An empty id leaves ctx
+// unchanged.
+func WithRequestID(ctx context.Context, id string) context.Context {
+    if id == "" { return ctx }
+    return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID set by WithRequestID, or "".
+func RequestID(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey{}).(string)
+    return id
 }
 
 func New(timeout time.Duration) *Client {
@@ -30,6 +62,23 @@ func New(timeout time.Duration) *Client {
     return &Client{HTTP: &http.Client{Timeout: timeout, Transport: transport}, UserAgent: "price-provider/1.0"}
 }
 
+// WithRequestTimeout returns a copy of c whose requests are bounded by d.
+// The copy shares c's http.Client, so connections and the ceiling are shared
+// too; a d above the ceiling has no effect.
+func (c *Client) WithRequestTimeout(d time.Duration) *Client {
+    cp := *c
+    cp.RequestTimeout = d
+    return &cp
+}
+
+// WithRequestIDHeader returns a copy of c that sends the context's request ID
+// in header name. Like WithRequestTimeout the copy shares c's http.Client.
+func (c *Client) WithRequestIDHeader(name string) *Client {
+    cp := *c
+    cp.RequestIDHeader = name
+    return &cp
+}
+
 func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
     if c.UserAgent != "" && req.Header.Get("User-Agent") == "" {
         req.Header.Set("User-Agent", c.UserAgent)
@@ -39,5 +88,64 @@ func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, err
             req.Header.Set(k, v)
         }
     }
-    return c.HTTP.Do(req)
+    if c.RequestIDHeader != "" && req.Header.Get(c.RequestIDHeader) == "" {
+        id := RequestID(ctx)
+        if id == "" { id = RequestID(req.Context()) }
+        if id != "" { req.Header.Set(c.RequestIDHeader, id) }
+    }
+    if c.RequestTimeout <= 0 { return c.HTTP.Do(req) }
+    rctx, cancel := context.WithTimeout(req.Context(), c.RequestTimeout)
+    resp, err := c.HTTP.Do(req.WithContext(rctx))
+    if err != nil {
+        cancel()
+        return nil, err
+    }
+    // the timeout covers reading the body, like the ceiling does
+    resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+    return resp, nil
+}
+
+// cancelOnClose releases a per-request context once the body is closed.
+type cancelOnClose struct {
+    io.ReadCloser
+    cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+    err := b.ReadCloser.Close()
+    b.cancel()
+    return err
+}
+
+// ParseProxyURL validates an egress proxy URL (http, https or socks5 with a host).
+func ParseProxyURL(raw string) (*url.URL, error) {
+    u, err := url.Parse(strings.TrimSpace(raw))
+    if err != nil { return nil, fmt.Errorf("proxy url: %w", err) }
+    switch strings.ToLower(u.Scheme) {
+    case "http", "https", "socks5", "socks5h":
+    default:
+        return nil, fmt.Errorf("proxy url %q: unsupported scheme %q (http|https|socks5)", RedactURL(u), u.Scheme)
+    }
+    if u.Host == "" { return nil, fmt.Errorf("proxy url %q: missing host", RedactURL(u)) }
+    return u, nil
+}
+
+// SetMaxConnsPerHost caps c's connections to each host at n (idle ones
+// included), replacing New's default of 100. Call it before EnableDump or
+// UseFixtures.
+func (c *Client) SetMaxConnsPerHost(n int) error {
+    t, ok := c.HTTP.Transport.(*http.Transport)
+    if !ok { return fmt.Errorf("httpx: cannot set connection limit on %T", c.HTTP.Transport) }
+    t.MaxConnsPerHost = n
+    if t.MaxIdleConnsPerHost > n { t.MaxIdleConnsPerHost = n }
+    return nil
+}
+
+// SetProxy routes every request of c through proxy instead of the
+// environment's proxy settings. Call it before EnableDump or UseFixtures.
+func (c *Client) SetProxy(proxy *url.URL) error {
+    t, ok := c.HTTP.Transport.(*http.Transport)
+    if !ok { return fmt.Errorf("httpx: cannot set proxy on %T", c.HTTP.Transport) }
+    t.Proxy = http.ProxyURL(proxy)
+    return nil
 }
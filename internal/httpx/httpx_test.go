@@ -0,0 +1,97 @@
+package httpx
+
+import (
+    "context"
+    "errors"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+)
+
+func TestSetProxy_TransportUsesConfiguredProxy(t *testing.T) {
+    proxy, err := ParseProxyURL("http://user:pw@proxy.example:3128")
+    if err != nil { t.Fatalf("parse: %v", err) }
+    c := New(5 * time.Second)
+    if err := c.SetProxy(proxy); err != nil { t.Fatalf("set proxy: %v", err) }
+
+    req, _ := http.NewRequest(http.MethodGet, "https://skinstable.xyz/api/items", nil)
+    got, err := c.HTTP.Transport.(*http.Transport).Proxy(req)
+    if err != nil || got == nil || got.String() != "http://user:pw@proxy.example:3128" { t.Fatalf("proxy for request = %v, %v", got, err) }
+
+    for _, bad := range []string{"proxy.example:3128", "ftp://proxy.example", "http://"} {
+        if _, err := ParseProxyURL(bad); err == nil { t.Fatalf("want error for %q", bad) }
+    }
+}
+
+func TestSetMaxConnsPerHost_CapsIdleToo(t *testing.T) {
+    c := New(5 * time.Second)
+    if err := c.SetMaxConnsPerHost(2); err != nil { t.Fatalf("set: %v", err) }
+    tr := c.HTTP.Transport.(*http.Transport)
+    if tr.MaxConnsPerHost != 2 || tr.MaxIdleConnsPerHost != 2 { t.Fatalf("want 2/2, got %d/%d", tr.MaxConnsPerHost, tr.MaxIdleConnsPerHost) }
+    if New(5*time.Second).HTTP.Transport.(*http.Transport).MaxConnsPerHost != 100 { t.Fatalf("want other clients untouched") }
+}
+
+func TestDo_ShorterTimeoutWins(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Path == "/fast" {
+            w.Write([]byte("ok"))
+            return
+        }
+        select {
+        case <-r.Context().Done():
+        case <-time.After(2 * time.Second):
+        }
+    }))
+    defer srv.Close()
+    get := func(c *Client, ctx context.Context, path string) (time.Duration, error) {
+        req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+        start := time.Now()
+        resp, err := c.Do(ctx, req)
+        if err == nil {
+            _, err = io.ReadAll(resp.Body)
+            resp.Body.Close()
+        }
+        return time.Since(start), err
+    }
+
+    // a short context deadline cancels well before the client timeout
+    ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+    defer cancel()
+    took, err := get(New(5*time.Second), ctx, "/slow")
+    if !errors.Is(err, context.DeadlineExceeded) || took > time.Second { t.Fatalf("context deadline: took %s, err %v", took, err) }
+
+    // so does a per-request timeout below the ceiling
+    took, err = get(New(5*time.Second).WithRequestTimeout(50*time.Millisecond), t.Context(), "/slow")
+    if err == nil || took > time.Second { t.Fatalf("request timeout: took %s, err %v", took, err) }
+
+    // the ceiling wins over a longer per-request timeout
+    took, err = get(New(50*time.Millisecond).WithRequestTimeout(5*time.Second), t.Context(), "/slow")
+    if err == nil || took > time.Second { t.Fatalf("ceiling: took %s, err %v", took, err) }
+
+    // the body stays readable after Do returns
+    if _, err := get(New(5*time.Second).WithRequestTimeout(time.Second), t.Context(), "/fast"); err != nil { t.Fatalf("fast: %v", err) }
+}
+
+func TestDo_PropagatesRequestID(t *testing.T) {
+    got := make(chan string, 1)
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got <- r.Header.Get("X-Correlation-Id")
+    }))
+    defer srv.Close()
+    call := func(c *Client, ctx context.Context) string {
+        req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+        resp, err := c.Do(ctx, req)
+        if err != nil { t.Fatalf("do: %v", err) }
+        resp.Body.Close()
+        return <-got
+    }
+
+    c := New(5 * time.Second)
+    tagged := c.WithRequestIDHeader("X-Correlation-Id")
+    ctx := WithRequestID(t.Context(), "req-42")
+    if h := call(tagged, ctx); h != "req-42" { t.Fatalf("want the context's request ID upstream, got %q", h) }
+    if h := call(tagged, t.Context()); h != "" { t.Fatalf("want no header without a request ID, got %q", h) }
+    if h := call(c, ctx); h != "" { t.Fatalf("want the original client untouched, got %q", h) }
+}
@@ -0,0 +1,44 @@
+package httpx
+
+import (
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "priceprovider/internal/clock"
+)
+
+func TestSign_KnownVector(t *testing.T) {
+    // echo -n '1735787045POST/v1/prices{"symbols":["A"]}' | openssl dgst -sha256 -hmac secret
+    got, err := Sign("sha256", "secret", "1735787045", "POST", "/v1/prices", []byte(`{"symbols":["A"]}`))
+    if err != nil { t.Fatalf("sign: %v", err) }
+    if want := "e539e7a2cfb50f409d1d1d351d58246a895b0e966d949918bae26fdc6ab2ca8d"; got != want { t.Fatalf("signature = %s, want %s", got, want) }
+    if _, err := Sign("md5", "secret", "", "", "", nil); err == nil { t.Fatalf("want an error for an unsupported algorithm") }
+}
+
+func TestEnableSigning_SetsHeadersAndKeepsBody(t *testing.T) {
+    var gotSig, gotTS, gotBody string
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        gotSig, gotTS = r.Header.Get("X-Mirror-Sig"), r.Header.Get("X-Signature-Timestamp")
+        b := new(strings.Builder)
+        _, _ = io.Copy(b, r.Body)
+        gotBody = b.String()
+    }))
+    defer srv.Close()
+
+    c := New(5 * time.Second)
+    if err := c.EnableSigning(Signing{Secret: "secret", Header: "X-Mirror-Sig"}); err != nil { t.Fatalf("enable: %v", err) }
+    c.HTTP.Transport.(*SignTransport).Clock = clock.NewFake(time.Unix(1735787045, 0))
+    req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/prices?x=1", strings.NewReader(`{"symbols":["A"]}`))
+    resp, err := c.Do(t.Context(), req)
+    if err != nil { t.Fatalf("do: %v", err) }
+    resp.Body.Close()
+
+    if gotTS != "1735787045" || gotSig != "e539e7a2cfb50f409d1d1d351d58246a895b0e966d949918bae26fdc6ab2ca8d" { t.Fatalf("signature headers = %q, %q", gotSig, gotTS) }
+    if gotBody != `{"symbols":["A"]}` { t.Fatalf("body = %q", gotBody) }
+    if req.Header.Get("X-Mirror-Sig") != "" { t.Fatalf("caller's request was modified") }
+    if err := New(time.Second).EnableSigning(Signing{}); err == nil { t.Fatalf("want an error without a secret") }
+}
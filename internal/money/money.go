@@ -0,0 +1,159 @@
+// Package money provides Amount, an exact decimal parsed once from a quote's
+// price string so aggregation does not re-parse it.
+package money
+
+import (
+    "fmt"
+    "math/big"
+    "strings"
+)
+
+// Amount is an exact decimal with the number of digits after the decimal
+// point it was written with, so String reproduces its canonical form
+// ("1.50" stays "1.50"). The zero Amount is "no price": it is not Valid,
+// formats as "" and makes arithmetic return the zero Amount.
+//
+// Amounts are immutable values and safe to share between goroutines.
+type Amount struct {
+    r     *big.Rat
+    scale int
+}
+
+// Parse reads a plain decimal: an optional sign, digits and an optional
+// fractional part ("12", "-0.5", "1.50"). Exponents, fractions and grouping
+// separators are rejected; normalize upstream values with provider.ParsePrice.
+func Parse(s string) (Amount, error) {
+    t := strings.TrimSpace(s)
+    digits := strings.TrimLeft(t, "+-")
+    if len(t)-len(digits) > 1 { return Amount{}, fmt.Errorf("invalid amount %q", s) }
+    intPart, frac, _ := strings.Cut(digits, ".")
+    if intPart == "" && frac == "" || !allDigits(intPart) || !allDigits(frac) {
+        return Amount{}, fmt.Errorf("invalid amount %q", s)
+    }
+    r, ok := new(big.Rat).SetString(t)
+    if !ok { return Amount{}, fmt.Errorf("invalid amount %q", s) }
+    return Amount{r: r, scale: len(frac)}, nil
+}
+
+// Of is Parse for callers that treat an unparsable price as absent: it
+// returns the zero Amount on error.
+func Of(s string) Amount {
+    a, _ := Parse(s)
+    return a
+}
+
+// MustParse is Parse that panics on error, for constants and tests.
+func MustParse(s string) Amount {
+    a, err := Parse(s)
+    if err != nil { panic(err) }
+    return a
+}
+
+// FromRat returns an Amount equal to r rendered with scale decimals. r is
+// copied.
+func FromRat(r *big.Rat, scale int) Amount {
+    if r == nil { return Amount{} }
+    return Amount{r: new(big.Rat).Set(r), scale: max(scale, 0)}
+}
+
+func allDigits(s string) bool {
+    for i := 0; i < len(s); i++ {
+        if s[i] < '0' || s[i] > '9' { return false }
+    }
+    return true
+}
+
+// Valid reports whether a holds a price.
+func (a Amount) Valid() bool { return a.r != nil }
+
+// Scale is the number of digits String renders after the decimal point.
+func (a Amount) Scale() int { return a.scale }
+
+// WithScale returns a rendered with scale decimals (rounding half away from
+// zero when formatting).
+func (a Amount) WithScale(scale int) Amount {
+    if a.r == nil { return a }
+    return Amount{r: a.r, scale: max(scale, 0)}
+}
+
+// Sign returns -1, 0 or +1; 0 for the zero Amount.
+func (a Amount) Sign() int {
+    if a.r == nil { return 0 }
+    return a.r.Sign()
+}
+
+// Cmp compares a and b numerically. An invalid Amount sorts before every
+// valid one.
+func (a Amount) Cmp(b Amount) int {
+    switch {
+    case a.r == nil && b.r == nil:
+        return 0
+    case a.r == nil:
+        return -1
+    case b.r == nil:
+        return 1
+    }
+    return a.r.Cmp(b.r)
+}
+
+// Add returns a+b with the larger scale of the two.
+func (a Amount) Add(b Amount) Amount {
+    if a.r == nil || b.r == nil { return Amount{} }
+    return Amount{r: new(big.Rat).Add(a.r, b.r), scale: max(a.scale, b.scale)}
+}
+
+// Sub returns a-b with the larger scale of the two.
+func (a Amount) Sub(b Amount) Amount {
+    if a.r == nil || b.r == nil { return Amount{} }
+    return Amount{r: new(big.Rat).Sub(a.r, b.r), scale: max(a.scale, b.scale)}
+}
+
+// MulInt returns a*n with a's scale.
+func (a Amount) MulInt(n int64) Amount {
+    if a.r == nil { return Amount{} }
+    return Amount{r: new(big.Rat).Mul(a.r, big.NewRat(n, 1)), scale: a.scale}
+}
+
+// MulRat returns a*r with a's scale.
+func (a Amount) MulRat(r *big.Rat) Amount {
+    if a.r == nil || r == nil { return Amount{} }
+    return Amount{r: new(big.Rat).Mul(a.r, r), scale: a.scale}
+}
+
+// QuoInt returns a/n with a's scale; the zero Amount when n is 0.
+func (a Amount) QuoInt(n int64) Amount {
+    if a.r == nil || n == 0 { return Amount{} }
+    return Amount{r: new(big.Rat).Quo(a.r, big.NewRat(n, 1)), scale: a.scale}
+}
+
+// PercentOf returns a/b*100 with 2 decimals; the zero Amount when b is 0.
+func (a Amount) PercentOf(b Amount) Amount {
+    if a.r == nil || b.r == nil || b.r.Sign() == 0 { return Amount{} }
+    pct := new(big.Rat).Quo(a.r, b.r)
+    return Amount{r: pct.Mul(pct, big.NewRat(100, 1)), scale: 2}
+}
+
+// Rat returns a copy of a's value, or nil for the zero Amount.
+func (a Amount) Rat() *big.Rat {
+    if a.r == nil { return nil }
+    return new(big.Rat).Set(a.r)
+}
+
+// String renders a as a plain decimal with Scale digits after the point,
+// or "" for the zero Amount.
+func (a Amount) String() string {
+    if a.r == nil { return "" }
+    return a.r.FloatString(a.scale)
+}
+
+// MarshalText renders the canonical string form, so JSON carries "1.50".
+func (a Amount) MarshalText() ([]byte, error) { return []byte(a.String()), nil }
+
+// UnmarshalText parses a plain decimal; empty input yields the zero Amount.
+func (a *Amount) UnmarshalText(b []byte) error {
+    if strings.TrimSpace(string(b)) == "" { *a = Amount{}; return nil }
+    v, err := Parse(string(b))
+    if err != nil { return err }
+    *a = v
+    return nil
+}
@@ -0,0 +1,60 @@
+package money
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestParse_RoundTripsCanonicalForm(t *testing.T) {
+    cases := map[string]string{
+        "12":       "12",
+        "1.50":     "1.50",
+        "+1.50":    "1.50",
+        "007.10":   "7.10",
+        "-0.5":     "-0.5",
+        "-0.00":    "0.00",
+        ".5":       "0.5",
+        "5.":       "5",
+        " 42.000 ": "42.000",
+        "123456789012345678901234.000000001": "123456789012345678901234.000000001",
+    }
+    for in, want := range cases {
+        a, err := Parse(in)
+        if err != nil { t.Fatalf("%q: %v", in, err) }
+        if got := a.String(); got != want { t.Errorf("%q: got %q, want %q", in, got, want) }
+        if again := MustParse(a.String()); again.Cmp(a) != 0 || again.String() != want { t.Errorf("%q: round trip gave %q", in, again) }
+    }
+    for _, in := range []string{"", ".", "-", "1e3", "1/2", "1,5", "1.2.3", "--1", "0x10", "abc"} {
+        if _, err := Parse(in); err == nil { t.Errorf("%q: want error", in) }
+        if Of(in).Valid() { t.Errorf("%q: Of should yield the zero Amount", in) }
+    }
+}
+
+func TestAmount_Arithmetic(t *testing.T) {
+    a, b := MustParse("10.50"), MustParse("0.125")
+    if got := a.Add(b).String(); got != "10.625" { t.Fatalf("add: %s", got) }
+    if got := b.Sub(a).String(); got != "-10.375" { t.Fatalf("sub: %s", got) }
+    if got := a.MulInt(3).String(); got != "31.50" { t.Fatalf("mul: %s", got) }
+    if got := a.QuoInt(4).String(); got != "2.63" { t.Fatalf("quo rounds half away from zero: %s", got) }
+    if got := a.QuoInt(4).WithScale(4).String(); got != "2.6250" { t.Fatalf("quo exact: %s", got) }
+    if got := MustParse("12").Sub(MustParse("10")).PercentOf(MustParse("10")).String(); got != "20.00" { t.Fatalf("pct: %s", got) }
+    if a.Cmp(b) <= 0 || b.Cmp(a) >= 0 || a.Cmp(MustParse("10.5")) != 0 { t.Fatalf("cmp") }
+    if MustParse("-1").Sign() != -1 || MustParse("0.0").Sign() != 0 { t.Fatalf("sign") }
+
+    var zero Amount
+    if zero.Valid() || zero.String() != "" || zero.Add(a).Valid() || a.QuoInt(0).Valid() || a.PercentOf(MustParse("0")).Valid() {
+        t.Fatalf("zero Amount should propagate")
+    }
+    if zero.Cmp(a) >= 0 || a.Cmp(zero) <= 0 { t.Fatalf("invalid should sort first") }
+    // operands are not modified
+    if a.String() != "10.50" || b.String() != "0.125" { t.Fatalf("operands changed: %s %s", a, b) }
+}
+
+func TestAmount_JSONUsesCanonicalString(t *testing.T) {
+    var v struct{ P Amount `json:"p"` }
+    if err := json.Unmarshal([]byte(`{"p":"+3.10"}`), &v); err != nil { t.Fatalf("unmarshal: %v", err) }
+    b, err := json.Marshal(v)
+    if err != nil { t.Fatalf("marshal: %v", err) }
+    if string(b) != `{"p":"3.10"}` { t.Fatalf("got %s", b) }
+    if err := json.Unmarshal([]byte(`{"p":"1e3"}`), &v); err == nil { t.Fatalf("want error for exponent") }
+}
@@ -0,0 +1,57 @@
+package alias
+
+import (
+    "context"
+
+    "priceprovider/internal/provider"
+)
+
+// Provider renames symbols for one upstream that knows an item under a
+// different name (StatTrak spelling, for example). Names maps the canonical
+// symbol clients request to the upstream's name; unlisted symbols pass
+// through unchanged. Quotes come back under the requested canonical symbol,
+// so callers never see the upstream's spelling. Unlike steamdt's SymbolMap
+// this works for every provider.
+type Provider struct {
+    P     provider.Provider
+    Names map[string]string
+}
+
+func (a *Provider) Name() string { return a.P.Name() }
+func (a *Provider) Unwrap() provider.Provider { return a.P }
+
+func (a *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if len(a.Names) == 0 { return a.P.Fetch(ctx, symbols) }
+    // canonical lists the requested symbols behind each upstream name; two
+    // canonical symbols may share one, and it is then fetched once.
+    canonical := make(map[string][]string, len(symbols))
+    upstream := make([]string, 0, len(symbols))
+    for _, s := range symbols {
+        name := s
+        if v := a.Names[s]; v != "" { name = v }
+        if _, ok := canonical[name]; !ok { upstream = append(upstream, name) }
+        if !contains(canonical[name], s) { canonical[name] = append(canonical[name], s) }
+    }
+    qs, err := a.P.Fetch(ctx, upstream)
+    if len(qs) == 0 { return qs, err }
+    out := make([]provider.Quote, 0, len(qs))
+    for _, q := range qs {
+        syms, ok := canonical[q.Symbol]
+        if !ok {
+            out = append(out, q)
+            continue
+        }
+        for _, s := range syms {
+            q.Symbol = s
+            out = append(out, q)
+        }
+    }
+    return out, err
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s { return true }
+    }
+    return false
+}
@@ -0,0 +1,37 @@
+package alias
+
+import (
+    "context"
+    "testing"
+
+    "priceprovider/internal/provider"
+)
+
+// recordingProvider remembers every symbol list it was asked for and quotes
+// each symbol under the name it was given.
+type recordingProvider struct{ seen [][]string }
+
+func (r *recordingProvider) Name() string { return "recording" }
+func (r *recordingProvider) Fetch(_ context.Context, symbols []string) ([]provider.Quote, error) {
+    r.seen = append(r.seen, append([]string(nil), symbols...))
+    out := make([]provider.Quote, 0, len(symbols))
+    for _, s := range symbols { out = append(out, provider.Quote{Symbol: s, Price: "1", Source: "recording:" + s}) }
+    return out, nil
+}
+
+func TestProvider_RoutesAliasAndReturnsCanonical(t *testing.T) {
+    const canonical = "StatTrak™ AK-47 | Redline (Field-Tested)"
+    const upstreamName = "StatTrak AK-47 | Redline (Field-Tested)"
+    up := &recordingProvider{}
+    a := &Provider{P: up, Names: map[string]string{canonical: upstreamName, "ST AK Redline FT": upstreamName}}
+
+    qs, err := a.Fetch(t.Context(), []string{canonical, "plain", "ST AK Redline FT"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(up.seen) != 1 || len(up.seen[0]) != 2 || up.seen[0][0] != upstreamName || up.seen[0][1] != "plain" {
+        t.Fatalf("want the upstream name fetched once next to plain, got %q", up.seen)
+    }
+    if len(qs) != 3 || qs[0].Symbol != canonical || qs[1].Symbol != "ST AK Redline FT" || qs[2].Symbol != "plain" {
+        t.Fatalf("want quotes under the requested symbols, got %+v", qs)
+    }
+    if qs[0].Source != "recording:"+upstreamName { t.Fatalf("want the rest of the quote untouched, got %+v", qs[0]) }
+}
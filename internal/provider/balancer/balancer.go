@@ -0,0 +1,64 @@
+package balancer
+
+import (
+    "context"
+    "sync"
+
+    "priceprovider/internal/provider"
+)
+
+// Member is one of several equivalent providers behind a Provider, e.g. a
+// SteamDT instance with its own API key and rate limiter.
+type Member struct {
+    P      provider.Provider
+    // Weight is the member's share of calls relative to the others; <= 0 counts as 1.
+    Weight int
+}
+
+// Provider routes each Fetch to exactly one member, chosen by smooth weighted
+// round-robin, so the members' quotas add up instead of every member being
+// asked for the same symbols. Members keep their own wrappers (rate limits
+// in particular); put shared layers such as the cache above the Provider.
+type Provider struct {
+    name    string
+    members []Member
+
+    mu      sync.Mutex
+    current []int
+    total   int
+}
+
+// New balances over members under name. It panics without members.
+func New(name string, members ...Member) *Provider {
+    if len(members) == 0 { panic("balancer: no members") }
+    b := &Provider{name: name, members: append([]Member(nil), members...), current: make([]int, len(members))}
+    for i := range b.members {
+        if b.members[i].Weight <= 0 { b.members[i].Weight = 1 }
+        b.total += b.members[i].Weight
+    }
+    return b
+}
+
+func (b *Provider) Name() string { return b.name }
+
+// Members returns the balanced providers, e.g. to inspect their wrappers.
+func (b *Provider) Members() []Member { return append([]Member(nil), b.members...) }
+
+func (b *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    return b.members[b.next()].P.Fetch(ctx, symbols)
+}
+
+// next picks a member the way nginx does: every member gains its weight,
+// the highest current value wins and pays back the total. Over total calls
+// each member is picked exactly Weight times, interleaved rather than in runs.
+func (b *Provider) next() int {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    best := 0
+    for i, m := range b.members {
+        b.current[i] += m.Weight
+        if b.current[i] > b.current[best] { best = i }
+    }
+    b.current[best] -= b.total
+    return best
+}
@@ -0,0 +1,45 @@
+package balancer
+
+import (
+    "context"
+    "fmt"
+    "testing"
+
+    "priceprovider/internal/provider"
+)
+
+// namedProvider tags its quotes with its name and counts calls.
+type namedProvider struct {
+    name  string
+    calls int
+}
+
+func (n *namedProvider) Name() string { return n.name }
+func (n *namedProvider) Fetch(_ context.Context, symbols []string) ([]provider.Quote, error) {
+    n.calls++
+    return []provider.Quote{{Symbol: symbols[0], Price: "1", Source: n.name}}, nil
+}
+
+func TestFetch_DistributesByWeight(t *testing.T) {
+    a, b, c := &namedProvider{name: "a"}, &namedProvider{name: "b"}, &namedProvider{name: "c"}
+    p := New("SteamDT", Member{P: a, Weight: 3}, Member{P: b, Weight: 1}, Member{P: c})
+
+    var order []string
+    for i := 0; i < 500; i++ {
+        qs, err := p.Fetch(t.Context(), []string{"X"})
+        if err != nil || len(qs) != 1 { t.Fatalf("fetch: %v %+v", err, qs) }
+        if i < 5 { order = append(order, qs[0].Source) }
+    }
+    // 500 calls = 100 rounds of total weight 5
+    if a.calls != 300 || b.calls != 100 || c.calls != 100 { t.Fatalf("want 300/100/100 calls, got %d/%d/%d", a.calls, b.calls, c.calls) }
+    // smooth: the heavy member is interleaved, not called three times in a row
+    if got := fmt.Sprint(order); got != "[a b a c a]" { t.Fatalf("first round order = %s", got) }
+}
+
+func TestFetch_EqualWeightsAlternate(t *testing.T) {
+    a, b := &namedProvider{name: "a"}, &namedProvider{name: "b"}
+    p := New("SteamDT", Member{P: a}, Member{P: b})
+    for i := 0; i < 10; i++ { _, _ = p.Fetch(t.Context(), []string{"X"}) }
+    if a.calls != 5 || b.calls != 5 { t.Fatalf("want 5/5 calls, got %d/%d", a.calls, b.calls) }
+    if p.Name() != "SteamDT" { t.Fatalf("name = %q", p.Name()) }
+}
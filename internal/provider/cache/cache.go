@@ -2,39 +2,161 @@ package cache
 
 import (
     "context"
+    "errors"
+    "log"
+    "math/bits"
+    "strings"
     "sync"
     "time"
 
+    "priceprovider/internal/clock"
     "priceprovider/internal/provider"
+    "priceprovider/internal/provider/timing"
 )
 
 // entry stores cached quotes for a single symbol with expiry.
 type entry struct {
+    storedAt  time.Time
     expiresAt time.Time
     quotes    []provider.Quote
 }
 
+// usable reports whether e may be served at now. maxAge > 0 additionally
+// rejects entries stored longer ago than that, even within their TTL.
+func (e entry) usable(now time.Time, maxAge time.Duration) bool {
+    if !now.Before(e.expiresAt) { return false }
+    return maxAge <= 0 || now.Sub(e.storedAt) <= maxAge
+}
+
+type maxAgeKey struct{}
+
+// WithMaxAge returns a context asking every cache layer below it to refetch
+// symbols whose entries are older than d. d <= 0 leaves ctx unchanged.
+func WithMaxAge(ctx context.Context, d time.Duration) context.Context {
+    if d <= 0 { return ctx }
+    return context.WithValue(ctx, maxAgeKey{}, d)
+}
+
+// MaxAge returns the freshness bound set by WithMaxAge, or 0.
+func MaxAge(ctx context.Context) time.Duration {
+    d, _ := ctx.Value(maxAgeKey{}).(time.Duration)
+    return d
+}
+
+type cachedOnlyKey struct{}
+
+// WithCachedOnly returns a context asking every cache layer below it to
+// answer from stored entries only, expired ones included, and never call the
+// provider it wraps. Symbols without an entry are simply left out.
+func WithCachedOnly(ctx context.Context) context.Context {
+    return context.WithValue(ctx, cachedOnlyKey{}, true)
+}
+
+// CachedOnly reports whether ctx was marked by WithCachedOnly.
+func CachedOnly(ctx context.Context) bool {
+    v, _ := ctx.Value(cachedOnlyKey{}).(bool)
+    return v
+}
+
+type scopeKey struct{}
+
+// WithScope returns a context narrowing what the named provider answers
+// (e.g. skinstable ?sites=). That provider's cache layers keep only the
+// stored quotes keep accepts, and fetch the symbols they lack without storing
+// the result, since it is not the symbol's full answer.
+func WithScope(ctx context.Context, name string, keep func(provider.Quote) bool) context.Context {
+    scopes := map[string]func(provider.Quote) bool{strings.ToLower(name): keep}
+    if prev, ok := ctx.Value(scopeKey{}).(map[string]func(provider.Quote) bool); ok {
+        for k, v := range prev {
+            if _, set := scopes[k]; !set { scopes[k] = v }
+        }
+    }
+    return context.WithValue(ctx, scopeKey{}, scopes)
+}
+
+// scope returns the filter WithScope set for the named provider, or nil.
+func scope(ctx context.Context, name string) func(provider.Quote) bool {
+    scopes, _ := ctx.Value(scopeKey{}).(map[string]func(provider.Quote) bool)
+    return scopes[strings.ToLower(name)]
+}
+
 // Provider caches results per symbol for a TTL.
 // It requests only missing symbols from the underlying provider and
 // combines cached + fresh results.
+//
+// When MaxTTL > TTL the effective TTL grows with how often a symbol is
+// requested: TTL for a one-off symbol, doubling with each doubling of its
+// request count, capped at MaxTTL. Counters are halved every MaxTTL so
+// popularity fades and the counter map stays bounded.
+//
+// NegativeTTL > 0 also caches "no data": a requested symbol that the
+// upstream answered without any quote is not asked for again until
+// NegativeTTL passes. Keep it short so new listings show up soon.
+//
+// ServeStale > 0 keeps expired entries around for that long after expiry:
+// when the upstream fails, they are served for the symbols it could not
+// answer, and the request's timing.Recorder marks the provider stale.
+//
+// ChunkSize > 0 splits the missing symbols into upstream calls of at most
+// that many, ChunkConcurrency (default 1) at a time. A failed chunk only
+// costs its own symbols: the others are still stored and returned, and the
+// error is reported on the request's timing.Recorder.
+//
+// RefreshWait > 0 lets only one caller refresh a missing symbol at a time:
+// concurrent callers missing the same symbol wait up to RefreshWait for that
+// refresh and then read the cache, fetching themselves only if it did not
+// produce an entry in time.
+//
+// Clock, when set, replaces the wall clock for expiry and waits (tests).
+//
+// Shared, when set, is a second cache level (e.g. Redis) consulted for the
+// symbols missing locally before the upstream is asked, and given every
+// fresh entry, so several instances reuse each other's fetches.
 type Provider struct {
-    P        provider.Provider
-    TTL      time.Duration
-    MaxItems int
+    P           provider.Provider
+    TTL         time.Duration
+    MaxTTL      time.Duration
+    MaxItems    int
+    NegativeTTL time.Duration
+    ServeStale  time.Duration
+
+    ChunkSize        int
+    ChunkConcurrency int
+    RefreshWait      time.Duration
+    Clock            clock.Clock
+    Shared           Store
 
     mu    sync.RWMutex
     items map[string]entry // key: symbol
+
+    flightMu sync.Mutex
+    inflight map[string]chan struct{} // symbols being refreshed; closed when stored
+
+    hitsMu    sync.Mutex
+    hits      map[string]uint32 // approximate request counts per symbol
+    lastDecay time.Time
 }
 
 func (c *Provider) Name() string { return c.P.Name() }
+func (c *Provider) Unwrap() provider.Provider { return c.P }
 
-// Fetch returns quotes for requested symbols using cache when valid.
+// Fetch returns quotes for requested symbols using cache when valid. A
+// MaxAge on ctx forces a refresh of entries older than it; CachedOnly serves
+// whatever is stored, however old, without calling the upstream.
 func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if keep := scope(ctx, c.Name()); keep != nil && c.TTL > 0 {
+        return c.fetchScoped(ctx, symbols, keep)
+    }
+    if CachedOnly(ctx) {
+        return c.stored(symbols), nil
+    }
     if c.P == nil || c.TTL <= 0 {
         return c.P.Fetch(ctx, symbols)
     }
 
-    now := time.Now()
+    now := clock.Or(c.Clock).Now()
+    maxAge := MaxAge(ctx)
+    c.countHits(symbols, now)
 
     // Split into cached and missing symbols
     cached := make([]provider.Quote, 0, len(symbols))
@@ -42,7 +164,7 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
 
     c.mu.RLock()
     for _, s := range symbols {
-        if e, ok := c.items[s]; ok && now.Before(e.expiresAt) {
+        if e, ok := c.items[s]; ok && e.usable(now, maxAge) {
             cached = append(cached, e.quotes...)
             continue
         }
@@ -64,14 +186,56 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
         }
     }
 
-    fresh, err := c.P.Fetch(ctx, missing)
-    if err != nil {
+    if c.RefreshWait > 0 {
+        owned, waiting := c.claim(missing)
+        defer c.release(owned)
+        if len(waiting) > 0 {
+            c.awaitRefresh(ctx, waiting)
+            missing = owned
+            c.mu.RLock()
+            for sym := range waiting {
+                if e, ok := c.items[sym]; ok && e.usable(now, maxAge) {
+                    cached = append(cached, e.quotes...)
+                    continue
+                }
+                missing = append(missing, sym)
+            }
+            c.mu.RUnlock()
+            if len(missing) == 0 { return c.usable(symbols, now, maxAge), nil }
+        }
+    }
+
+    if c.Shared != nil {
+        var hits []provider.Quote
+        missing, hits = c.fromShared(ctx, missing, now, maxAge)
+        cached = append(cached, hits...)
+        if len(missing) == 0 { return c.usable(symbols, now, maxAge), nil }
+    }
+
+    fresh, failed, err := c.fetchMissing(ctx, missing)
+    if len(failed) == len(missing) {
+        stale := c.staleFor(missing, now)
+        if len(stale) > 0 {
+            if r := timing.FromContext(ctx); r != nil {
+                r.MarkStale(c.P.Name())
+                r.Warn(c.P.Name(), err)
+            }
+            cached = append(cached, stale...)
+        }
         // If we have at least some cached data, return it rather than failing entirely
         if len(cached) > 0 {
             return cached, nil
         }
         return nil, err
     }
+    failedSet := make(map[string]struct{}, len(failed))
+    for _, s := range failed { failedSet[s] = struct{}{} }
+    if len(failed) > 0 {
+        if r := timing.FromContext(ctx); r != nil {
+            if len(c.staleFor(failed, now)) > 0 { r.MarkStale(c.P.Name()) }
+            r.Warn(c.P.Name(), err)
+        }
+    }
 
     // Index fresh quotes by symbol for storage and output ordering
     bySymbol := make(map[string][]provider.Quote, len(missing))
@@ -80,22 +244,27 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     }
 
     // Update cache
-    if c.items == nil {
-        c.mu.Lock()
-        if c.items == nil { c.items = make(map[string]entry, len(bySymbol)) }
-        c.mu.Unlock()
-    }
-
-    expiry := now.Add(c.TTL)
     c.mu.Lock()
+    if c.items == nil { c.items = make(map[string]entry, len(bySymbol)) }
+    var shared []Item
+    store := func(sym string, e entry) {
+        c.items[sym] = e
+        if c.Shared != nil { shared = append(shared, Item{Key: c.sharedKey(sym), Entry: Entry{StoredAt: e.storedAt, ExpiresAt: e.expiresAt, Quotes: e.quotes}, TTL: e.expiresAt.Sub(now)}) }
+    }
     for sym, qs := range bySymbol {
-        c.items[sym] = entry{expiresAt: expiry, quotes: qs}
+        store(sym, entry{storedAt: now, expiresAt: now.Add(c.ttlFor(sym)), quotes: qs})
+    }
+    if c.NegativeTTL > 0 {
+        for _, sym := range missing {
+            if _, bad := failedSet[sym]; bad { continue }
+            if _, ok := bySymbol[sym]; !ok { store(sym, entry{storedAt: now, expiresAt: now.Add(c.NegativeTTL)}) }
+        }
     }
     // best-effort cap cache size
     if c.MaxItems > 0 && len(c.items) > c.MaxItems {
         // simple random/oldest eviction: remove expired first, then arbitrary
         for k, v := range c.items {
-            if time.Now().After(v.expiresAt) {
+            if now.After(v.expiresAt.Add(c.ServeStale)) {
                 delete(c.items, k)
             }
             if len(c.items) <= c.MaxItems {
@@ -109,6 +278,9 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
         }
     }
     c.mu.Unlock()
+    if len(shared) > 0 {
+        if err := c.Shared.Set(ctx, shared); err != nil { log.Printf("cache %s: shared store: %v", c.P.Name(), err) }
+    }
 
     // Merge cached and fresh preserving request order
     out := make([]provider.Quote, 0, len(cached)+len(fresh))
@@ -118,8 +290,10 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
             continue
         }
         // pull from cached per-symbol
+        // symbols of a failed chunk fall back to stale entries
+        _, bad := failedSet[s]
         c.mu.RLock()
-        if e, ok := c.items[s]; ok && now.Before(e.expiresAt) {
+        if e, ok := c.items[s]; ok && (e.usable(now, maxAge) || bad && c.ServeStale > 0 && now.Before(e.expiresAt.Add(c.ServeStale))) {
             out = append(out, e.quotes...)
         }
         c.mu.RUnlock()
@@ -127,3 +301,250 @@ func (c *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     return out, nil
 }
 
+// fetchMissing asks the upstream for symbols, in ChunkSize pieces when set.
+// It returns the quotes of the calls that succeeded, the symbols of those
+// that failed and the first error. A provider.PartialError fails only the
+// symbols it names; its quotes are kept.
+func (c *Provider) fetchMissing(ctx context.Context, symbols []string) ([]provider.Quote, []string, error) {
+    if c.ChunkSize <= 0 || len(symbols) <= c.ChunkSize {
+        qs, err := c.P.Fetch(ctx, symbols)
+        qs, failed := splitFailed(symbols, qs, err)
+        return qs, failed, err
+    }
+    var chunks [][]string
+    for i := 0; i < len(symbols); i += c.ChunkSize {
+        chunks = append(chunks, symbols[i:min(i+c.ChunkSize, len(symbols))])
+    }
+    type result struct {
+        qs  []provider.Quote
+        err error
+    }
+    results := make([]result, len(chunks))
+    workers := c.ChunkConcurrency
+    if workers <= 0 { workers = 1 }
+    sem := make(chan struct{}, workers)
+    var wg sync.WaitGroup
+    for i, chunk := range chunks {
+        wg.Add(1)
+        sem <- struct{}{}
+        go func() {
+            defer wg.Done()
+            defer func() { <-sem }()
+            qs, err := c.P.Fetch(ctx, chunk)
+            results[i] = result{qs, err}
+        }()
+    }
+    wg.Wait()
+
+    var (
+        fresh  []provider.Quote
+        failed []string
+        first  error
+    )
+    for i, r := range results {
+        qs, bad := splitFailed(chunks[i], r.qs, r.err)
+        fresh = append(fresh, qs...)
+        failed = append(failed, bad...)
+        if first == nil { first = r.err }
+    }
+    return fresh, failed, first
+}
+
+// splitFailed returns the usable quotes of one upstream call for symbols and
+// the symbols that failed.
+func splitFailed(symbols []string, qs []provider.Quote, err error) ([]provider.Quote, []string) {
+    if err == nil { return qs, nil }
+    var pe *provider.PartialError
+    if errors.As(err, &pe) { return qs, pe.Symbols }
+    return nil, symbols
+}
+
+// sharedKey is the Shared store key of sym: symbols are only shared between
+// caches in front of providers of the same name.
+func (c *Provider) sharedKey(sym string) string { return c.P.Name() + ":" + sym }
+
+// fromShared looks symbols up in the Shared store, copies the servable
+// entries into the local cache and returns the symbols still missing plus
+// the quotes found. A failing store is logged and treated as empty.
+func (c *Provider) fromShared(ctx context.Context, symbols []string, now time.Time, maxAge time.Duration) ([]string, []provider.Quote) {
+    keys := make([]string, len(symbols))
+    for i, s := range symbols { keys[i] = c.sharedKey(s) }
+    found, err := c.Shared.Get(ctx, keys)
+    if err != nil {
+        log.Printf("cache %s: shared store: %v", c.P.Name(), err)
+        return symbols, nil
+    }
+    var missing []string
+    var hits []provider.Quote
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.items == nil { c.items = make(map[string]entry, len(found)) }
+    for i, s := range symbols {
+        se, ok := found[keys[i]]
+        e := entry{storedAt: se.StoredAt, expiresAt: se.ExpiresAt, quotes: se.Quotes}
+        if !ok || !e.usable(now, maxAge) {
+            missing = append(missing, s)
+            continue
+        }
+        c.items[s] = e
+        hits = append(hits, e.quotes...)
+    }
+    return missing, hits
+}
+
+// claim marks the symbols nobody is refreshing yet as refreshed by the
+// caller and returns them, plus the refresh signals of the others.
+func (c *Provider) claim(symbols []string) (owned []string, waiting map[string]chan struct{}) {
+    c.flightMu.Lock()
+    defer c.flightMu.Unlock()
+    if c.inflight == nil { c.inflight = make(map[string]chan struct{}) }
+    for _, s := range symbols {
+        if ch, ok := c.inflight[s]; ok {
+            if waiting == nil { waiting = make(map[string]chan struct{}) }
+            waiting[s] = ch
+            continue
+        }
+        c.inflight[s] = make(chan struct{})
+        owned = append(owned, s)
+    }
+    return owned, waiting
+}
+
+// release wakes the callers waiting on a claim.
+func (c *Provider) release(owned []string) {
+    c.flightMu.Lock()
+    defer c.flightMu.Unlock()
+    for _, s := range owned {
+        close(c.inflight[s])
+        delete(c.inflight, s)
+    }
+}
+
+// awaitRefresh blocks until every refresh in waiting is done, RefreshWait
+// passes or ctx ends, whichever comes first.
+func (c *Provider) awaitRefresh(ctx context.Context, waiting map[string]chan struct{}) {
+    timer := clock.Or(c.Clock).NewTimer(c.RefreshWait)
+    defer timer.Stop()
+    for _, ch := range waiting {
+        select {
+        case <-ch:
+        case <-timer.C():
+            return
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// usable returns the quotes of the servable entries for symbols in request
+// order.
+func (c *Provider) usable(symbols []string, now time.Time, maxAge time.Duration) []provider.Quote {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    var out []provider.Quote
+    for _, s := range symbols {
+        if e, ok := c.items[s]; ok && e.usable(now, maxAge) { out = append(out, e.quotes...) }
+    }
+    return out
+}
+
+// stored returns every cached quote for symbols in request order, ignoring
+// expiry.
+func (c *Provider) stored(symbols []string) []provider.Quote {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    var out []provider.Quote
+    for _, s := range symbols {
+        if e, ok := c.items[s]; ok { out = append(out, e.quotes...) }
+    }
+    return out
+}
+
+// fetchScoped answers a call narrowed by WithScope: entries serve the quotes
+// keep accepts, and symbols without a usable entry go to the provider (with
+// ctx, so it narrows its answer too) but are not stored.
+func (c *Provider) fetchScoped(ctx context.Context, symbols []string, keep func(provider.Quote) bool) ([]provider.Quote, error) {
+    cachedOnly := CachedOnly(ctx)
+    now := clock.Or(c.Clock).Now()
+    maxAge := MaxAge(ctx)
+    out := []provider.Quote{}
+    var missing []string
+    seen := make(map[string]struct{}, len(symbols))
+    c.mu.RLock()
+    for _, s := range symbols {
+        if _, dup := seen[s]; dup { continue }
+        seen[s] = struct{}{}
+        if e, ok := c.items[s]; ok && (cachedOnly || e.usable(now, maxAge)) {
+            for _, q := range e.quotes {
+                if keep(q) { out = append(out, q) }
+            }
+            continue
+        }
+        if !cachedOnly { missing = append(missing, s) }
+    }
+    c.mu.RUnlock()
+    if len(missing) == 0 { return out, nil }
+    qs, err := c.P.Fetch(ctx, missing)
+    if err != nil {
+        // like unscoped calls, answer from the entries we have
+        if !provider.IsPartial(err) && len(out) == 0 { return nil, err }
+        if r := timing.FromContext(ctx); r != nil { r.Warn(c.P.Name(), err) }
+        if !provider.IsPartial(err) { return out, nil }
+    }
+    for _, q := range qs {
+        if keep(q) { out = append(out, q) }
+    }
+    return out, nil
+}
+
+// staleFor returns the quotes of expired entries for symbols that are still
+// within ServeStale of their expiry.
+func (c *Provider) staleFor(symbols []string, now time.Time) []provider.Quote {
+    if c.ServeStale <= 0 { return nil }
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    var out []provider.Quote
+    for _, s := range symbols {
+        if e, ok := c.items[s]; ok && now.Before(e.expiresAt.Add(c.ServeStale)) { out = append(out, e.quotes...) }
+    }
+    return out
+}
+
+// adaptive reports whether TTLs scale with request frequency.
+func (c *Provider) adaptive() bool { return c.MaxTTL > c.TTL }
+
+// countHits bumps the request counter of each symbol and decays all
+// counters once per MaxTTL.
+func (c *Provider) countHits(symbols []string, now time.Time) {
+    if !c.adaptive() { return }
+    c.hitsMu.Lock()
+    defer c.hitsMu.Unlock()
+    if c.hits == nil {
+        c.hits = make(map[string]uint32, len(symbols))
+        c.lastDecay = now
+    }
+    if now.Sub(c.lastDecay) >= c.MaxTTL {
+        for k, v := range c.hits {
+            if v >>= 1; v == 0 { delete(c.hits, k) } else { c.hits[k] = v }
+        }
+        c.lastDecay = now
+    }
+    for _, s := range symbols {
+        n, ok := c.hits[s]
+        // bounded: once full, only symbols already tracked keep counting
+        if !ok && c.MaxItems > 0 && len(c.hits) >= c.MaxItems { continue }
+        if n < 1<<16 { c.hits[s] = n + 1 }
+    }
+}
+
+// ttlFor returns the effective TTL for a symbol: TTL << log2(hits), capped at MaxTTL.
+func (c *Provider) ttlFor(sym string) time.Duration {
+    if !c.adaptive() { return c.TTL }
+    c.hitsMu.Lock()
+    n := c.hits[sym]
+    c.hitsMu.Unlock()
+    if n <= 1 { return c.TTL }
+    ttl := c.TTL << (bits.Len32(n) - 1)
+    if ttl > c.MaxTTL || ttl <= 0 { ttl = c.MaxTTL }
+    return ttl
+}
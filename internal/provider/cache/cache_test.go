@@ -0,0 +1,364 @@
+package cache
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+
+    "priceprovider/internal/clock"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/health"
+    "priceprovider/internal/provider/timing"
+)
+
+// countingProvider returns one quote per symbol and counts upstream requests per symbol.
+type countingProvider struct {
+    mu    sync.Mutex
+    calls map[string]int
+}
+
+func (c *countingProvider) Name() string { return "counting" }
+
+func (c *countingProvider) Fetch(_ context.Context, symbols []string) ([]provider.Quote, error) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.calls == nil { c.calls = map[string]int{} }
+    out := make([]provider.Quote, 0, len(symbols))
+    for _, s := range symbols {
+        c.calls[s]++
+        out = append(out, provider.Quote{Symbol: s, Price: "1", Source: "counting:x"})
+    }
+    return out, nil
+}
+
+func (c *countingProvider) count(s string) int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.calls[s]
+}
+
+func TestCache_AdaptiveTTL_HotSymbolOutlivesColdOne(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &countingProvider{}
+    c := &Provider{P: up, Clock: clk, TTL: 20 * time.Millisecond, MaxTTL: time.Second}
+
+    for i := 0; i < 8; i++ {
+        if _, err := c.Fetch(t.Context(), []string{"hot"}); err != nil { t.Fatalf("fetch: %v", err) }
+    }
+    clk.Advance(30 * time.Millisecond) // both past the base TTL
+
+    // refresh both: hot has ~9 hits (8x TTL), cold has 1 (base TTL)
+    if _, err := c.Fetch(t.Context(), []string{"hot", "cold"}); err != nil { t.Fatalf("fetch: %v", err) }
+    clk.Advance(50 * time.Millisecond)
+    if _, err := c.Fetch(t.Context(), []string{"hot", "cold"}); err != nil { t.Fatalf("fetch: %v", err) }
+
+    if n := up.count("hot"); n != 2 { t.Fatalf("hot: want 2 upstream calls, got %d", n) }
+    if n := up.count("cold"); n != 2 { t.Fatalf("cold: want 2 upstream calls (expired), got %d", n) }
+}
+
+func TestCache_FixedTTLWithoutMaxTTL(t *testing.T) {
+    c := &Provider{P: &countingProvider{}, TTL: time.Minute}
+    for i := 0; i < 5; i++ { _, _ = c.Fetch(t.Context(), []string{"a"}) }
+    if got := c.ttlFor("a"); got != time.Minute { t.Fatalf("want fixed TTL, got %s", got) }
+}
+
+func TestCache_MaxAgeForcesRefreshOfOlderEntry(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &countingProvider{}
+    c := &Provider{P: up, Clock: clk, TTL: time.Minute}
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    clk.Advance(30 * time.Millisecond)
+
+    // within TTL and within a generous max age: served from cache
+    if _, err := c.Fetch(WithMaxAge(t.Context(), time.Second), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    if n := up.count("a"); n != 1 { t.Fatalf("want cached entry served, got %d upstream calls", n) }
+
+    // older than the requested max age: refreshed despite the TTL
+    got, err := c.Fetch(WithMaxAge(t.Context(), 10*time.Millisecond), []string{"a"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if n := up.count("a"); n != 2 { t.Fatalf("want a refresh, got %d upstream calls", n) }
+    if len(got) != 1 { t.Fatalf("want 1 quote, got %+v", got) }
+}
+
+func TestCache_CachedOnlyServesStaleWithoutUpstream(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &countingProvider{}
+    c := &Provider{P: up, Clock: clk, TTL: 10 * time.Millisecond}
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    clk.Advance(20 * time.Millisecond)
+
+    got, err := c.Fetch(WithCachedOnly(t.Context()), []string{"a", "b"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(got) != 1 || got[0].Symbol != "a" { t.Fatalf("want the expired entry for a only, got %+v", got) }
+    if n := up.count("a") + up.count("b"); n != 1 { t.Fatalf("want no upstream calls in cached-only mode, got %d total", n) }
+}
+
+// flakyProvider answers like countingProvider until down is set, then fails.
+type flakyProvider struct {
+    countingProvider
+    down bool
+}
+
+func (f *flakyProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if f.down { return nil, errors.New("upstream down") }
+    return f.countingProvider.Fetch(ctx, symbols)
+}
+
+func TestCache_ServeStaleDuringOutage(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &flakyProvider{}
+    c := &Provider{P: up, Clock: clk, TTL: 10 * time.Millisecond, ServeStale: 50 * time.Millisecond}
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    up.down = true
+    clk.Advance(20 * time.Millisecond) // expired, within the stale window
+
+    ctx, rec := timing.WithRecorder(t.Context())
+    got, err := c.Fetch(ctx, []string{"a"})
+    if err != nil { t.Fatalf("want stale entry served, got %v", err) }
+    if len(got) != 1 || got[0].Symbol != "a" { t.Fatalf("want the expired quote, got %+v", got) }
+    if s := rec.Stale(); len(s) != 1 || s[0] != "counting" { t.Fatalf("want provider marked stale, got %v", s) }
+
+    clk.Advance(50 * time.Millisecond) // past the stale window
+    if got, err := c.Fetch(t.Context(), []string{"a"}); err == nil { t.Fatalf("want the upstream error beyond the window, got %+v", got) }
+
+    // without ServeStale an expired entry is never served
+    up.down = false
+    c = &Provider{P: up, Clock: clk, TTL: 10 * time.Millisecond}
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    up.down = true
+    clk.Advance(20 * time.Millisecond)
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err == nil { t.Fatalf("want error without serve-stale") }
+}
+
+func TestCache_DegradedUpstreamIsAFailure(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &flakyProvider{}
+    c := &Provider{P: &health.Provider{P: up, Threshold: 1, ProbeInterval: time.Hour}, Clock: clk, TTL: 10 * time.Millisecond, ServeStale: 50 * time.Millisecond, NegativeTTL: time.Hour}
+    if _, err := c.Fetch(t.Context(), []string{"a"}); err != nil { t.Fatalf("fetch: %v", err) }
+    up.down = true
+    clk.Advance(20 * time.Millisecond)
+
+    got, err := c.Fetch(t.Context(), []string{"a", "b"})
+    if err != nil || len(got) != 1 || got[0].Symbol != "a" { t.Fatalf("want the stale quote of a while degraded, got %+v %v", got, err) }
+    c.mu.RLock()
+    _, cachedB := c.items["b"]
+    c.mu.RUnlock()
+    if cachedB { t.Fatalf("want no negative entry for a symbol the degraded provider never answered") }
+}
+
+// emptyProvider never has quotes and counts upstream calls.
+type emptyProvider struct{ calls int }
+
+func (e *emptyProvider) Name() string { return "empty" }
+func (e *emptyProvider) Fetch(context.Context, []string) ([]provider.Quote, error) {
+    e.calls++
+    return nil, nil
+}
+
+func TestCache_NegativeTTLCachesEmptyResults(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &emptyProvider{}
+    c := &Provider{P: up, Clock: clk, TTL: time.Minute, NegativeTTL: 30 * time.Millisecond}
+    for i := 0; i < 3; i++ {
+        got, err := c.Fetch(t.Context(), []string{"nope"})
+        if err != nil || len(got) != 0 { t.Fatalf("want empty result, got %+v, %v", got, err) }
+    }
+    if up.calls != 1 { t.Fatalf("want empty result negatively cached, got %d upstream calls", up.calls) }
+
+    clk.Advance(40 * time.Millisecond)
+    if _, err := c.Fetch(t.Context(), []string{"nope"}); err != nil { t.Fatalf("fetch: %v", err) }
+    if up.calls != 2 { t.Fatalf("want re-query after negative TTL, got %d upstream calls", up.calls) }
+}
+
+func TestCache_NoNegativeTTLRequeriesEmpty(t *testing.T) {
+    up := &emptyProvider{}
+    c := &Provider{P: up, TTL: time.Minute}
+    for i := 0; i < 2; i++ { _, _ = c.Fetch(t.Context(), []string{"nope"}) }
+    if up.calls != 2 { t.Fatalf("want every request to reach upstream, got %d", up.calls) }
+}
+
+// chunkProvider records the size of every upstream call and fails those
+// that include fail.
+type chunkProvider struct {
+    countingProvider
+    fail  string
+    sizes []int
+}
+
+func (p *chunkProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    p.mu.Lock()
+    p.sizes = append(p.sizes, len(symbols))
+    p.mu.Unlock()
+    for _, s := range symbols {
+        if s == p.fail { return nil, errors.New("chunk failed") }
+    }
+    return p.countingProvider.Fetch(ctx, symbols)
+}
+
+func TestCache_ChunksLargeRequests(t *testing.T) {
+    symbols := make([]string, 1000)
+    for i := range symbols { symbols[i] = fmt.Sprintf("s%04d", i) }
+    up := &chunkProvider{fail: "s0420"}
+    c := &Provider{P: up, TTL: time.Minute, NegativeTTL: time.Minute, ChunkSize: 100, ChunkConcurrency: 4}
+
+    ctx, rec := timing.WithRecorder(t.Context())
+    got, err := c.Fetch(ctx, symbols)
+    if err != nil { t.Fatalf("want the healthy chunks served, got %v", err) }
+    if len(up.sizes) != 10 { t.Fatalf("want 10 chunked calls, got %v", up.sizes) }
+    for _, n := range up.sizes {
+        if n != 100 { t.Fatalf("want chunks of 100, got %v", up.sizes) }
+    }
+    if len(got) != 900 { t.Fatalf("want 900 quotes (one chunk failed), got %d", len(got)) }
+    if got[0].Symbol != "s0000" || got[899].Symbol != "s0999" { t.Fatalf("want request order kept, got %s..%s", got[0].Symbol, got[899].Symbol) }
+    if w := rec.Warnings()["counting"]; w == "" { t.Fatalf("want the chunk error reported, got %v", rec.Warnings()) }
+
+    // the failed chunk is not negatively cached: only it is asked for again
+    up.fail = ""
+    up.sizes = nil
+    got, err = c.Fetch(t.Context(), symbols)
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(up.sizes) != 1 || up.sizes[0] != 100 || len(got) != 1000 { t.Fatalf("want one retry call for the failed chunk, got calls %v and %d quotes", up.sizes, len(got)) }
+}
+
+// partialProvider answers like countingProvider but leaves out the symbols in
+// fail, naming them in a provider.PartialError.
+type partialProvider struct {
+    countingProvider
+    fail map[string]bool
+}
+
+func (p *partialProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    var ok, failed []string
+    for _, s := range symbols {
+        if p.fail[s] { failed = append(failed, s) } else { ok = append(ok, s) }
+    }
+    qs, _ := p.countingProvider.Fetch(ctx, ok)
+    if len(failed) > 0 { return qs, &provider.PartialError{Symbols: failed, Err: context.Canceled} }
+    return qs, nil
+}
+
+func TestCache_PartialErrorFailsOnlyNamedSymbols(t *testing.T) {
+    clk := clock.NewFake(time.Now())
+    up := &partialProvider{fail: map[string]bool{"b": true}}
+    c := &Provider{P: up, Clock: clk, TTL: 10 * time.Millisecond, ServeStale: time.Minute, NegativeTTL: time.Hour}
+    up.fail = nil
+    if _, err := c.Fetch(t.Context(), []string{"b"}); err != nil { t.Fatalf("fetch: %v", err) }
+    clk.Advance(20 * time.Millisecond)
+
+    // b's batch was canceled: its stale entry is served, not a negative one
+    up.fail = map[string]bool{"b": true}
+    ctx, rec := timing.WithRecorder(t.Context())
+    got, err := c.Fetch(ctx, []string{"a", "b"})
+    if err != nil || len(got) != 2 || got[0].Symbol != "a" || got[1].Symbol != "b" { t.Fatalf("want a fresh and b stale, got %+v %v", got, err) }
+    if w := rec.Warnings()["counting"]; w == "" { t.Fatalf("want the partial error reported, got %v", rec.Warnings()) }
+
+    // and b is asked for again next time
+    up.fail = nil
+    if _, err := c.Fetch(t.Context(), []string{"a", "b"}); err != nil { t.Fatalf("fetch: %v", err) }
+    if n := up.count("b"); n != 2 { t.Fatalf("want b fetched again, got %d upstream calls", n) }
+    if n := up.count("a"); n != 1 { t.Fatalf("want a served from cache, got %d upstream calls", n) }
+}
+
+// slowProvider answers like countingProvider after delay.
+type slowProvider struct {
+    countingProvider
+    delay time.Duration
+}
+
+func (p *slowProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    time.Sleep(p.delay)
+    return p.countingProvider.Fetch(ctx, symbols)
+}
+
+func TestCache_RefreshWaitCollapsesConcurrentMisses(t *testing.T) {
+    up := &slowProvider{delay: 50 * time.Millisecond}
+    c := &Provider{P: up, TTL: time.Minute, RefreshWait: time.Second}
+
+    var wg sync.WaitGroup
+    errs := make(chan error, 50)
+    for range 50 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            got, err := c.Fetch(t.Context(), []string{"hot", "other"})
+            if err == nil && len(got) != 2 { err = fmt.Errorf("want 2 quotes, got %+v", got) }
+            errs <- err
+        }()
+    }
+    wg.Wait()
+    close(errs)
+    for err := range errs {
+        if err != nil { t.Fatal(err) }
+    }
+    if n := up.count("hot"); n != 1 { t.Fatalf("want one upstream call for concurrent misses, got %d", n) }
+
+    // a waiter gives up after RefreshWait and fetches itself
+    up = &slowProvider{delay: 50 * time.Millisecond}
+    c = &Provider{P: up, TTL: time.Minute, RefreshWait: time.Millisecond}
+    wg.Add(2)
+    for range 2 {
+        go func() { defer wg.Done(); _, _ = c.Fetch(t.Context(), []string{"hot"}) }()
+    }
+    wg.Wait()
+    if n := up.count("hot"); n != 2 { t.Fatalf("want the timed-out waiter to fetch, got %d calls", n) }
+}
+
+func TestCache_SharedStoreServesOtherInstances(t *testing.T) {
+    shared := NewMemoryStore()
+    upA, upB := &countingProvider{}, &countingProvider{}
+    a := &Provider{P: upA, TTL: time.Minute, Shared: shared}
+    b := &Provider{P: upB, TTL: time.Minute, Shared: shared}
+
+    if _, err := a.Fetch(t.Context(), []string{"x", "y"}); err != nil { t.Fatalf("fetch a: %v", err) }
+    got, err := b.Fetch(t.Context(), []string{"y", "x", "z"})
+    if err != nil { t.Fatalf("fetch b: %v", err) }
+    if len(got) != 3 || got[0].Symbol != "y" || got[1].Symbol != "x" || got[2].Symbol != "z" { t.Fatalf("want y, x, z in request order, got %+v", got) }
+    if upB.count("x")+upB.count("y") != 0 || upB.count("z") != 1 { t.Fatalf("want only z fetched by b, got x=%d y=%d z=%d", upB.count("x"), upB.count("y"), upB.count("z")) }
+
+    // b's fetch of z is shared back to a
+    if _, err := a.Fetch(t.Context(), []string{"z"}); err != nil { t.Fatalf("fetch a: %v", err) }
+    if n := upA.count("z"); n != 0 { t.Fatalf("want z served from the shared store, got %d upstream calls", n) }
+}
+
+// twoSiteProvider quotes every symbol on sites a and b, or only on the sites
+// its caller narrowed to via ctx.
+type twoSiteProvider struct{ countingProvider }
+
+type onlySiteKey struct{}
+
+func (p *twoSiteProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    qs, _ := p.countingProvider.Fetch(ctx, symbols)
+    out := make([]provider.Quote, 0, 2*len(qs))
+    for _, q := range qs {
+        for _, site := range []string{"a", "b"} {
+            if only, _ := ctx.Value(onlySiteKey{}).(string); only != "" && only != site { continue }
+            q.Source = "counting:" + site
+            out = append(out, q)
+        }
+    }
+    return out, nil
+}
+
+func TestCache_ScopedCallsFilterEntriesAndDoNotStore(t *testing.T) {
+    up := &twoSiteProvider{}
+    c := &Provider{P: up, TTL: time.Minute}
+    onlyB := func(ctx context.Context) context.Context {
+        ctx = context.WithValue(ctx, onlySiteKey{}, "b")
+        return WithScope(ctx, "Counting", func(q provider.Quote) bool { return q.Source == "counting:b" })
+    }
+
+    if qs, err := c.Fetch(t.Context(), []string{"x"}); err != nil || len(qs) != 2 { t.Fatalf("full fetch: %+v, %v", qs, err) }
+    qs, err := c.Fetch(onlyB(t.Context()), []string{"x", "y"})
+    if err != nil { t.Fatalf("scoped fetch: %v", err) }
+    if len(qs) != 2 || qs[0].Symbol != "x" || qs[1].Symbol != "y" || qs[0].Source != "counting:b" || qs[1].Source != "counting:b" { t.Fatalf("want site b only, got %+v", qs) }
+    if up.count("x") != 1 || up.count("y") != 1 { t.Fatalf("want x from the cache and y fetched, got x=%d y=%d", up.count("x"), up.count("y")) }
+
+    // y's narrowed answer was not stored as its full answer
+    if qs, err := c.Fetch(t.Context(), []string{"y"}); err != nil || len(qs) != 2 || up.count("y") != 2 { t.Fatalf("want y fetched in full, got %+v, %v, %d calls", qs, err, up.count("y")) }
+    // scopes for other providers leave this cache alone
+    other := WithScope(t.Context(), "other", func(provider.Quote) bool { return false })
+    if qs, err := c.Fetch(other, []string{"x"}); err != nil || len(qs) != 2 { t.Fatalf("want an unscoped answer, got %+v, %v", qs, err) }
+}
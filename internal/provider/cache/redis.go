@@ -0,0 +1,82 @@
+package cache
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// RedisConfig selects the Redis server a Redis store talks to.
+type RedisConfig struct {
+    Addr     string // host:port
+    Password string // sent with AUTH when set
+    DB       int    // selected with SELECT when > 0
+    // Prefix is prepended to every key (default "priceprovider:cache:").
+    Prefix   string
+    // Timeout bounds dialing and each read and write (default 2s).
+    Timeout  time.Duration
+}
+
+// Redis is a Store on a Redis server, so replicas share what they fetched
+// and the cache survives restarts. Each Entry is stored as JSON under
+// Prefix+key with the item's TTL. Connections come from go-redis's pool, so
+// concurrent calls do not wait on each other.
+type Redis struct {
+    client *redis.Client
+    prefix string
+}
+
+func NewRedis(cfg RedisConfig) (*Redis, error) {
+    if cfg.Addr == "" { return nil, errors.New("redis: no address") }
+    if cfg.Prefix == "" { cfg.Prefix = "priceprovider:cache:" }
+    if cfg.Timeout <= 0 { cfg.Timeout = 2 * time.Second }
+    client := redis.NewClient(&redis.Options{
+        Addr:          cfg.Addr,
+        Password:      cfg.Password,
+        DB:            cfg.DB,
+        DialTimeout:   cfg.Timeout,
+        ReadTimeout:   cfg.Timeout,
+        WriteTimeout:  cfg.Timeout,
+        // the shared level is best-effort: fail fast and let the upstream answer
+        MaxRetries:    -1,
+        DialerRetries: 1,
+    })
+    return &Redis{client: client, prefix: cfg.Prefix}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, keys []string) (map[string]Entry, error) {
+    if len(keys) == 0 { return nil, nil }
+    full := make([]string, len(keys))
+    for i, k := range keys { full[i] = r.prefix + k }
+    values, err := r.client.MGet(ctx, full...).Result()
+    if err != nil { return nil, fmt.Errorf("redis: %w", err) }
+    out := make(map[string]Entry, len(keys))
+    for i, v := range values {
+        s, ok := v.(string)
+        if !ok { continue } // nil: not stored
+        var e Entry
+        if err := json.Unmarshal([]byte(s), &e); err != nil { continue }
+        out[keys[i]] = e
+    }
+    return out, nil
+}
+
+// Set writes items in one pipeline.
+func (r *Redis) Set(ctx context.Context, items []Item) error {
+    if len(items) == 0 { return nil }
+    pipe := r.client.Pipeline()
+    for _, it := range items {
+        b, err := json.Marshal(it.Entry)
+        if err != nil { return err }
+        pipe.Set(ctx, r.prefix+it.Key, b, max(it.TTL, 0))
+    }
+    if _, err := pipe.Exec(ctx); err != nil { return fmt.Errorf("redis: %w", err) }
+    return nil
+}
+
+// Close closes the pool.
+func (r *Redis) Close() error { return r.client.Close() }
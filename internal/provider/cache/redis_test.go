@@ -0,0 +1,69 @@
+package cache
+
+import (
+    "fmt"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+)
+
+func TestRedis_SharesEntriesAcrossInstances(t *testing.T) {
+    srv := miniredis.RunT(t)
+    srv.RequireAuth("secret")
+    addr := srv.Addr()
+    newStore := func() *Redis {
+        r, err := NewRedis(RedisConfig{Addr: addr, Password: "secret", DB: 2})
+        if err != nil { t.Fatalf("redis: %v", err) }
+        t.Cleanup(func() { _ = r.Close() })
+        return r
+    }
+    // two "instances": separate caches, upstreams and connections
+    upA, upB := &countingProvider{}, &countingProvider{}
+    a := &Provider{P: upA, TTL: time.Minute, Shared: newStore()}
+    b := &Provider{P: upB, TTL: time.Minute, Shared: newStore()}
+
+    if _, err := a.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"}); err != nil { t.Fatalf("fetch a: %v", err) }
+    srv.Select(2)
+    if ttl := srv.TTL("priceprovider:cache:counting:AK-47 | Redline (Field-Tested)"); ttl <= 0 || ttl > time.Minute { t.Fatalf("want the entry stored with the cache TTL, got %s (keys %v)", ttl, srv.Keys()) }
+
+    got, err := b.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"})
+    if err != nil { t.Fatalf("fetch b: %v", err) }
+    if len(got) != 1 || got[0].Price != "1" || got[0].PriceAmount().String() != "1" { t.Fatalf("want the shared quote, got %+v", got) }
+    if n := upB.count("AK-47 | Redline (Field-Tested)"); n != 0 { t.Fatalf("want b served from redis, got %d upstream calls", n) }
+
+    // once redis expires the entry, a new instance goes upstream again
+    srv.FastForward(2 * time.Minute)
+    upC := &countingProvider{}
+    c := &Provider{P: upC, TTL: time.Minute, Shared: newStore()}
+    if _, err := c.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"}); err != nil { t.Fatalf("fetch c: %v", err) }
+    if n := upC.count("AK-47 | Redline (Field-Tested)"); n != 1 { t.Fatalf("want an upstream call after expiry, got %d", n) }
+
+    // a broken redis only costs the shared level
+    srv.Close()
+    upD := &countingProvider{}
+    d := &Provider{P: upD, TTL: time.Minute, Shared: newStore()}
+    if got, err := d.Fetch(t.Context(), []string{"x"}); err != nil || len(got) != 1 { t.Fatalf("want the upstream answer without redis, got %+v, %v", got, err) }
+}
+
+func TestRedis_ConcurrentCallsShareThePool(t *testing.T) {
+    srv := miniredis.RunT(t)
+    r, err := NewRedis(RedisConfig{Addr: srv.Addr()})
+    if err != nil { t.Fatalf("redis: %v", err) }
+    defer r.Close()
+
+    var wg sync.WaitGroup
+    for i := range 16 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            key := fmt.Sprintf("k%d", i)
+            if err := r.Set(t.Context(), []Item{{Key: key, Entry: Entry{}, TTL: time.Minute}}); err != nil { t.Errorf("set %s: %v", key, err) }
+            got, err := r.Get(t.Context(), []string{key, "missing"})
+            if err != nil || len(got) != 1 { t.Errorf("get %s: %v %v", key, got, err) }
+        }()
+    }
+    wg.Wait()
+    if n := len(srv.Keys()); n != 16 { t.Fatalf("want 16 keys, got %d", n) }
+}
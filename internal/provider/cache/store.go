@@ -0,0 +1,76 @@
+package cache
+
+import (
+    "context"
+    "sync"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// Entry is what a Store keeps per key: the quotes of one symbol (none for a
+// cached "no data" answer) and when they were stored and expire.
+type Entry struct {
+    StoredAt  time.Time        `json:"stored_at"`
+    ExpiresAt time.Time        `json:"expires_at"`
+    Quotes    []provider.Quote `json:"quotes"`
+}
+
+// Item is one Entry to store under Key. The store may drop it after TTL.
+type Item struct {
+    Key   string
+    Entry Entry
+    TTL   time.Duration
+}
+
+// Store is a second cache level shared by several Providers, possibly across
+// processes (see Redis). Get returns the entries found and leaves missing
+// keys out; Set stores items. Both are best-effort for the cache: errors are
+// logged and the upstream answers instead.
+type Store interface {
+    Get(ctx context.Context, keys []string) (map[string]Entry, error)
+    Set(ctx context.Context, items []Item) error
+}
+
+// MemoryStore is an in-process Store, e.g. to share one cache level between
+// several Providers, and the stand-in for Redis in tests.
+type MemoryStore struct {
+    mu    sync.Mutex
+    items map[string]memoryItem
+}
+
+type memoryItem struct {
+    entry   Entry
+    expires time.Time // zero: no TTL
+}
+
+func NewMemoryStore() *MemoryStore { return &MemoryStore{items: make(map[string]memoryItem)} }
+
+func (m *MemoryStore) Get(_ context.Context, keys []string) (map[string]Entry, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := time.Now()
+    out := make(map[string]Entry, len(keys))
+    for _, k := range keys {
+        it, ok := m.items[k]
+        if !ok { continue }
+        if !it.expires.IsZero() && !now.Before(it.expires) {
+            delete(m.items, k)
+            continue
+        }
+        out[k] = it.entry
+    }
+    return out, nil
+}
+
+func (m *MemoryStore) Set(_ context.Context, items []Item) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := time.Now()
+    for _, it := range items {
+        mi := memoryItem{entry: it.Entry}
+        if it.TTL > 0 { mi.expires = now.Add(it.TTL) }
+        m.items[it.Key] = mi
+    }
+    return nil
+}
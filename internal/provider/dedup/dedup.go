@@ -0,0 +1,47 @@
+package dedup
+
+import (
+    "context"
+
+    "priceprovider/internal/provider"
+)
+
+// Provider collapses exact-duplicate quotes from one upstream response: the
+// same symbol, app, source, currency and price. The newest ReceivedAt wins and
+// the first occurrence keeps its position. This runs on raw quotes, before
+// any newest-per-market aggregation.
+type Provider struct {
+    P provider.Provider
+}
+
+func (d *Provider) Name() string { return d.P.Name() }
+func (d *Provider) Unwrap() provider.Provider { return d.P }
+
+func (d *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    qs, err := d.P.Fetch(ctx, symbols)
+    if err != nil && !provider.IsPartial(err) { return qs, err }
+    return Quotes(qs), err
+}
+
+type key struct {
+    symbol, source, currency, price string
+    appID                           int
+}
+
+// Quotes returns qs without exact duplicates (see Provider). It reuses the
+// backing array of qs.
+func Quotes(qs []provider.Quote) []provider.Quote {
+    if len(qs) < 2 { return qs }
+    seen := make(map[key]int, len(qs))
+    out := qs[:0]
+    for _, q := range qs {
+        k := key{q.Symbol, q.Source, q.Currency, q.Price, q.AppID}
+        if i, ok := seen[k]; ok {
+            if q.ReceivedAt.After(out[i].ReceivedAt) { out[i] = q }
+            continue
+        }
+        seen[k] = len(out)
+        out = append(out, q)
+    }
+    return out
+}
@@ -0,0 +1,41 @@
+package dedup
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+type staticProvider []provider.Quote
+
+func (s staticProvider) Name() string { return "static" }
+func (s staticProvider) Fetch(context.Context, []string) ([]provider.Quote, error) {
+    return append([]provider.Quote(nil), s...), nil
+}
+
+func TestDedup_CollapsesExactDuplicatesKeepingNewest(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    t0 := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    p := &Provider{P: staticProvider{
+        {Symbol: sym, Price: "10", Currency: "CNY", Source: "SteamDT:BUFF:sell", ReceivedAt: t0},
+        {Symbol: sym, Price: "11", Currency: "CNY", Source: "SteamDT:YOUPIN:sell", ReceivedAt: t0},
+        {Symbol: sym, Price: "10", Currency: "CNY", Source: "SteamDT:BUFF:sell", ReceivedAt: t0.Add(time.Second)},
+    }}
+    got, err := p.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(got) != 2 { t.Fatalf("want 2 quotes, got %+v", got) }
+    if got[0].Source != "SteamDT:BUFF:sell" || !got[0].ReceivedAt.Equal(t0.Add(time.Second)) {
+        t.Fatalf("want newest BUFF quote first, got %+v", got[0])
+    }
+    if got[1].Source != "SteamDT:YOUPIN:sell" { t.Fatalf("unexpected second quote: %+v", got[1]) }
+}
+
+func TestDedup_KeepsDifferingPrices(t *testing.T) {
+    qs := Quotes([]provider.Quote{
+        {Symbol: "A", Price: "10", Currency: "USD", Source: "X"},
+        {Symbol: "A", Price: "10.5", Currency: "USD", Source: "X"},
+    })
+    if len(qs) != 2 { t.Fatalf("want both quotes kept, got %+v", qs) }
+}
@@ -0,0 +1,136 @@
+package provider
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "strings"
+)
+
+// Error kinds shared by all providers. Match them with errors.Is.
+var (
+    ErrUnauthorized = errors.New("unauthorized")
+    ErrRateLimited  = errors.New("rate limited")
+    ErrUpstream     = errors.New("upstream error")
+    ErrTimeout      = errors.New("timeout")
+)
+
+// Error is an upstream failure tagged with one of the Err* kinds.
+type Error struct {
+    Kind   error // ErrUnauthorized, ErrRateLimited, ErrUpstream or ErrTimeout
+    Status int   // upstream HTTP status; 0 when the failure was not an HTTP response
+    Err    error // underlying cause, used for the message
+    // Retryable marks transient failures (e.g., an HTML error page served
+    // with 200) that are worth another attempt.
+    Retryable bool
+}
+
+func (e *Error) Error() string {
+    if e.Err != nil { return e.Err.Error() }
+    if e.Status != 0 { return fmt.Sprintf("%v (status %d)", e.Kind, e.Status) }
+    return e.Kind.Error()
+}
+
+// Unwrap exposes both the kind and the cause to errors.Is/As.
+func (e *Error) Unwrap() []error {
+    if e.Err == nil { return []error{e.Kind} }
+    return []error{e.Kind, e.Err}
+}
+
+// PartialError is returned together with quotes when only some symbols
+// failed, e.g. one timed-out or canceled batch out of several. The quotes are
+// usable; Symbols names the requested symbols that have none.
+type PartialError struct {
+    Symbols []string
+    Err     error
+}
+
+func (e *PartialError) Error() string {
+    names, more := e.Symbols, ""
+    if len(names) > 5 { names, more = names[:5], fmt.Sprintf(" and %d more", len(e.Symbols)-5) }
+    return fmt.Sprintf("no quotes for %s%s: %v", strings.Join(names, ", "), more, e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// IsPartial reports whether err is a PartialError, i.e. the quotes returned
+// with it should be kept.
+func IsPartial(err error) bool {
+    var pe *PartialError
+    return errors.As(err, &pe)
+}
+
+// StatusError classifies a non-2xx upstream response: 401/403 are
+// ErrUnauthorized, 429 is ErrRateLimited, 408/504 are ErrTimeout and anything
+// else is ErrUpstream.
+func StatusError(status int, err error) *Error {
+    kind := ErrUpstream
+    switch status {
+    case http.StatusUnauthorized, http.StatusForbidden:
+        kind = ErrUnauthorized
+    case http.StatusTooManyRequests:
+        kind = ErrRateLimited
+    case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+        kind = ErrTimeout
+    }
+    return &Error{Kind: kind, Status: status, Err: err}
+}
+
+// TransportError classifies a failed round trip: deadlines and network
+// timeouts are ErrTimeout, everything else ErrUpstream. Cancellation by the
+// caller is returned unchanged.
+func TransportError(err error) error {
+    if err == nil || errors.Is(err, context.Canceled) { return err }
+    var ne net.Error
+    if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+        return &Error{Kind: ErrTimeout, Err: err}
+    }
+    return &Error{Kind: ErrUpstream, Err: err}
+}
+
+// IsRetryable reports whether another attempt may succeed: errors marked
+// Retryable, timeouts and upstream 429/502/503/504 responses. Partial results
+// are kept rather than fetched again.
+func IsRetryable(err error) bool {
+    if IsPartial(err) { return false }
+    var pe *Error
+    if !errors.As(err, &pe) { return false }
+    if pe.Retryable || pe.Kind == ErrTimeout { return true }
+    switch pe.Status {
+    case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+        return true
+    }
+    return false
+}
+
+// JSONBody returns a reader over resp.Body after checking that the response
+// looks like JSON: a non-HTML content type and a body starting with '{' or
+// '[' (after whitespace). Anything else, such as an HTML error page served with
+// 200, yields a retryable ErrUpstream instead of a later decode error.
+func JSONBody(resp *http.Response) (io.Reader, error) {
+    br := bufio.NewReader(resp.Body)
+    ct := resp.Header.Get("Content-Type")
+    head, _ := br.Peek(512)
+    trimmed := bytes.TrimLeft(head, " \t\r\n\ufeff")
+    if strings.Contains(strings.ToLower(ct), "html") || len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+        snippet := trimmed[:min(len(trimmed), 64)]
+        return nil, &Error{Kind: ErrUpstream, Status: resp.StatusCode, Retryable: true, Err: fmt.Errorf("non-JSON response (content-type %q): %q", ct, snippet)}
+    }
+    return br, nil
+}
+
+// Kind returns the Err* kind of err. Untyped errors count as ErrUpstream,
+// except deadline errors which count as ErrTimeout.
+func Kind(err error) error {
+    for _, k := range []error{ErrUnauthorized, ErrRateLimited, ErrTimeout, ErrUpstream} {
+        if errors.Is(err, k) { return k }
+    }
+    var ne net.Error
+    if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) { return ErrTimeout }
+    return ErrUpstream
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "testing"
+)
+
+func TestStatusError_Kinds(t *testing.T) {
+    cases := map[int]error{401: ErrUnauthorized, 403: ErrUnauthorized, 429: ErrRateLimited, 504: ErrTimeout, 500: ErrUpstream, 404: ErrUpstream}
+    for status, want := range cases {
+        err := fmt.Errorf("steamdt: %w", StatusError(status, fmt.Errorf("GET x -> %d", status)))
+        if !errors.Is(err, want) { t.Fatalf("status %d: want %v, got %v", status, want, Kind(err)) }
+        var pe *Error
+        if !errors.As(err, &pe) || pe.Status != status { t.Fatalf("status %d: want *Error with status", status) }
+    }
+}
+
+func TestKind_UntypedAndTransport(t *testing.T) {
+    if Kind(errors.New("boom")) != ErrUpstream { t.Fatalf("untyped error should be upstream") }
+    if Kind(fmt.Errorf("skipped: %w", context.DeadlineExceeded)) != ErrTimeout { t.Fatalf("deadline should be timeout") }
+    if err := TransportError(context.DeadlineExceeded); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) { t.Fatalf("want timeout wrapping cause, got %v", err) }
+    if err := TransportError(context.Canceled); err != context.Canceled { t.Fatalf("cancel should pass through, got %v", err) }
+}
+
+func TestPartialError_NamesSymbols(t *testing.T) {
+    cause := &Error{Kind: ErrTimeout, Err: context.DeadlineExceeded}
+    err := fmt.Errorf("steamdt: %w", &PartialError{Symbols: []string{"A", "B"}, Err: cause})
+    if !IsPartial(err) || !errors.Is(err, ErrTimeout) { t.Fatalf("want partial wrapping the timeout, got %v", err) }
+    if got, want := err.Error(), "steamdt: no quotes for A, B: context deadline exceeded"; got != want { t.Fatalf("want %q, got %q", want, got) }
+    if IsRetryable(err) { t.Fatalf("a partial result should not be retried") }
+    if IsPartial(cause) { t.Fatalf("a plain error is not partial") }
+
+    many := &PartialError{Symbols: []string{"A", "B", "C", "D", "E", "F", "G"}, Err: context.Canceled}
+    if got, want := many.Error(), "no quotes for A, B, C, D, E and 2 more: context canceled"; got != want { t.Fatalf("want %q, got %q", want, got) }
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+    "context"
+
+    "priceprovider/internal/money"
+    "priceprovider/internal/provider"
+)
+
+// Provider drops quotes whose price is not above a floor, so every upstream
+// suppresses zero and junk prices the same way.
+// - SuppressZero drops prices <= 0.
+// - MinPrice, when valid, drops prices <= MinPrice.
+// Prices that do not parse as decimals are dropped whenever filtering is active.
+type Provider struct {
+    P            provider.Provider
+    SuppressZero bool
+    MinPrice     money.Amount
+}
+
+func (f *Provider) Name() string { return f.P.Name() }
+func (f *Provider) Unwrap() provider.Provider { return f.P }
+
+func (f *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    qs, err := f.P.Fetch(ctx, symbols)
+    if (err != nil && !provider.IsPartial(err)) || (!f.SuppressZero && !f.MinPrice.Valid()) {
+        return qs, err
+    }
+    // a new slice: qs may be shared, e.g. with a cache below us
+    out := make([]provider.Quote, 0, len(qs))
+    for _, q := range qs {
+        if f.Keep(q) { out = append(out, q) }
+    }
+    return out, err
+}
+
+// Keep reports whether a quote's price passes the configured floor.
+func (f *Provider) Keep(q provider.Quote) bool {
+    v := q.PriceAmount()
+    if !v.Valid() { return false }
+    if f.SuppressZero && v.Sign() <= 0 { return false }
+    if f.MinPrice.Valid() && v.Cmp(f.MinPrice) <= 0 { return false }
+    return true
+}
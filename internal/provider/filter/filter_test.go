@@ -0,0 +1,69 @@
+package filter
+
+import (
+    "context"
+    "testing"
+
+    "priceprovider/internal/money"
+    "priceprovider/internal/provider"
+)
+
+type staticProvider []provider.Quote
+
+func (s staticProvider) Name() string { return "static" }
+func (s staticProvider) Fetch(context.Context, []string) ([]provider.Quote, error) {
+    return append([]provider.Quote(nil), s...), nil
+}
+
+var prices = staticProvider{
+    {Symbol: "zero", Price: "0"},
+    {Symbol: "zero-dec", Price: "0.00"},
+    {Symbol: "negative", Price: "-1.5"},
+    {Symbol: "tiny", Price: "0.001"},
+    {Symbol: "normal", Price: "12.34"},
+    {Symbol: "garbage", Price: "n/a"},
+}
+
+func symbols(qs []provider.Quote) map[string]bool {
+    m := make(map[string]bool, len(qs))
+    for _, q := range qs { m[q.Symbol] = true }
+    return m
+}
+
+func TestFilter_SuppressZero(t *testing.T) {
+    f := &Provider{P: prices, SuppressZero: true}
+    got, err := f.Fetch(t.Context(), nil)
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    s := symbols(got)
+    if len(got) != 2 || !s["tiny"] || !s["normal"] { t.Fatalf("unexpected: %+v", got) }
+}
+
+func TestFilter_MinPrice(t *testing.T) {
+    f := &Provider{P: prices, MinPrice: money.MustParse("0.01")}
+    got, err := f.Fetch(t.Context(), nil)
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(got) != 1 || got[0].Symbol != "normal" { t.Fatalf("unexpected: %+v", got) }
+}
+
+func TestFilter_DisabledPassesThrough(t *testing.T) {
+    f := &Provider{P: prices}
+    got, err := f.Fetch(t.Context(), nil)
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(got) != len(prices) { t.Fatalf("want all %d quotes, got %d", len(prices), len(got)) }
+}
+
+// sharedProvider returns the same backing slice on every call, as a cache
+// layer would.
+type sharedProvider []provider.Quote
+
+func (s sharedProvider) Name() string { return "shared" }
+func (s sharedProvider) Fetch(context.Context, []string) ([]provider.Quote, error) { return s, nil }
+
+func TestFilter_LeavesUpstreamSliceIntact(t *testing.T) {
+    upstream := sharedProvider(append([]provider.Quote(nil), prices...))
+    f := &Provider{P: upstream, SuppressZero: true}
+    if _, err := f.Fetch(t.Context(), nil); err != nil { t.Fatalf("fetch: %v", err) }
+    for i, q := range upstream {
+        if q != prices[i] { t.Fatalf("upstream quote %d overwritten: %+v", i, q) }
+    }
+}
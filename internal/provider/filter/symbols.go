@@ -0,0 +1,52 @@
+package filter
+
+import (
+    "context"
+
+    "priceprovider/internal/provider"
+)
+
+// Symbols strips symbols a provider cannot serve before they reach Fetch.
+// - Deny drops the listed symbols.
+// - Allow, when non-empty, keeps only the listed symbols.
+// Matching is exact (market hash names are case-sensitive upstream). When no
+// symbols remain, Fetch returns an empty result without calling upstream.
+type Symbols struct {
+    P     provider.Provider
+    Deny  map[string]struct{}
+    Allow map[string]struct{}
+}
+
+// NewSymbols builds a Symbols filter from lists; nil maps mean "no rule".
+func NewSymbols(p provider.Provider, deny, allow []string) *Symbols {
+    return &Symbols{P: p, Deny: toSet(deny), Allow: toSet(allow)}
+}
+
+func toSet(list []string) map[string]struct{} {
+    if len(list) == 0 { return nil }
+    m := make(map[string]struct{}, len(list))
+    for _, s := range list { m[s] = struct{}{} }
+    return m
+}
+
+func (f *Symbols) Name() string { return f.P.Name() }
+func (f *Symbols) Unwrap() provider.Provider { return f.P }
+
+// Permits reports whether sym may be sent to the wrapped provider.
+func (f *Symbols) Permits(sym string) bool {
+    if _, denied := f.Deny[sym]; denied { return false }
+    if f.Allow != nil {
+        if _, ok := f.Allow[sym]; !ok { return false }
+    }
+    return true
+}
+
+func (f *Symbols) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if f.Deny == nil && f.Allow == nil { return f.P.Fetch(ctx, symbols) }
+    keep := make([]string, 0, len(symbols))
+    for _, s := range symbols {
+        if f.Permits(s) { keep = append(keep, s) }
+    }
+    if len(keep) == 0 { return []provider.Quote{}, nil }
+    return f.P.Fetch(ctx, keep)
+}
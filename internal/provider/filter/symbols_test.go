@@ -0,0 +1,42 @@
+package filter
+
+import (
+    "context"
+    "testing"
+
+    "priceprovider/internal/provider"
+)
+
+// recordingProvider remembers every symbol list it was asked for.
+type recordingProvider struct{ seen [][]string }
+
+func (r *recordingProvider) Name() string { return "recording" }
+func (r *recordingProvider) Fetch(_ context.Context, symbols []string) ([]provider.Quote, error) {
+    r.seen = append(r.seen, append([]string(nil), symbols...))
+    out := make([]provider.Quote, 0, len(symbols))
+    for _, s := range symbols { out = append(out, provider.Quote{Symbol: s, Price: "1"}) }
+    return out, nil
+}
+
+func TestSymbols_DenylistNeverReachesProvider(t *testing.T) {
+    up := &recordingProvider{}
+    f := NewSymbols(up, []string{"bad"}, nil)
+
+    qs, err := f.Fetch(t.Context(), []string{"good", "bad", "other"})
+    if err != nil || len(qs) != 2 { t.Fatalf("fetch: %v %+v", err, qs) }
+    // only denied symbols requested: upstream is not called at all
+    qs, err = f.Fetch(t.Context(), []string{"bad"})
+    if err != nil || qs == nil || len(qs) != 0 { t.Fatalf("want empty result, got %v %v", qs, err) }
+
+    if len(up.seen) != 1 { t.Fatalf("want 1 upstream call, got %v", up.seen) }
+    for _, s := range up.seen[0] {
+        if s == "bad" { t.Fatalf("denylisted symbol reached provider: %v", up.seen) }
+    }
+}
+
+func TestSymbols_Allowlist(t *testing.T) {
+    up := &recordingProvider{}
+    f := NewSymbols(up, []string{"b"}, []string{"a", "b"})
+    if _, err := f.Fetch(t.Context(), []string{"a", "b", "c"}); err != nil { t.Fatalf("fetch: %v", err) }
+    if len(up.seen) != 1 || len(up.seen[0]) != 1 || up.seen[0][0] != "a" { t.Fatalf("want only [a] upstream, got %v", up.seen) }
+}
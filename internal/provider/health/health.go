@@ -0,0 +1,79 @@
+package health
+
+import (
+    "context"
+    "errors"
+    "log"
+    "sync"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// ErrDegraded is returned instead of calling upstream while a Provider is
+// tripped. It is a failure to the layers above (a cache serves stale entries
+// and caches nothing), but the fan-out treats it as "no data" rather than
+// failing the request.
+var ErrDegraded = errors.New("provider degraded")
+
+// Provider degrades quietly: after Threshold consecutive failures it stops
+// calling upstream and returns ErrDegraded. While tripped, one probe call is
+// let through every ProbeInterval; a successful probe restores it.
+type Provider struct {
+    P             provider.Provider
+    Threshold     int           // consecutive failures before tripping; 0 disables
+    ProbeInterval time.Duration // how often a tripped provider is retried
+
+    mu        sync.Mutex
+    failures  int
+    tripped   bool
+    nextProbe time.Time
+}
+
+func (h *Provider) Name() string { return h.P.Name() }
+func (h *Provider) Unwrap() provider.Provider { return h.P }
+
+// Tripped reports whether the provider is currently degraded.
+func (h *Provider) Tripped() bool {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    return h.tripped
+}
+
+func (h *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if h.Threshold <= 0 {
+        return h.P.Fetch(ctx, symbols)
+    }
+    now := time.Now()
+    h.mu.Lock()
+    if h.tripped {
+        if now.Before(h.nextProbe) {
+            h.mu.Unlock()
+            return nil, ErrDegraded
+        }
+        // this call is the probe; push the next one out so concurrent calls stay quiet
+        h.nextProbe = now.Add(h.ProbeInterval)
+    }
+    h.mu.Unlock()
+
+    qs, err := h.P.Fetch(ctx, symbols)
+
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    // a partial result still shows the upstream answering
+    if err == nil || provider.IsPartial(err) {
+        if h.tripped { log.Printf("%s: recovered after probe", h.P.Name()) }
+        h.failures, h.tripped = 0, false
+        return qs, err
+    }
+    // the caller giving up says nothing about upstream health
+    if ctx.Err() != nil { return qs, err }
+    h.failures++
+    if h.failures >= h.Threshold {
+        if !h.tripped { log.Printf("%s: degraded after %d consecutive failures: %v", h.P.Name(), h.failures, err) }
+        h.tripped = true
+        h.nextProbe = time.Now().Add(h.ProbeInterval)
+        return nil, ErrDegraded
+    }
+    return qs, err
+}
@@ -0,0 +1,60 @@
+package health
+
+import (
+    "context"
+    "errors"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// flakyProvider fails while failing is set and counts every upstream call.
+type flakyProvider struct {
+    failing atomic.Bool
+    calls   atomic.Int32
+}
+
+func (f *flakyProvider) Name() string { return "flaky" }
+func (f *flakyProvider) Fetch(context.Context, []string) ([]provider.Quote, error) {
+    f.calls.Add(1)
+    if f.failing.Load() { return nil, errors.New("upstream down") }
+    return []provider.Quote{{Symbol: "A", Price: "1"}}, nil
+}
+
+func TestHealth_TripsAfterThresholdAndReturnsErrDegraded(t *testing.T) {
+    up := &flakyProvider{}
+    up.failing.Store(true)
+    h := &Provider{P: up, Threshold: 2, ProbeInterval: time.Hour}
+
+    if _, err := h.Fetch(t.Context(), []string{"A"}); err == nil { t.Fatalf("first failure should surface") }
+    qs, err := h.Fetch(t.Context(), []string{"A"})
+    if !errors.Is(err, ErrDegraded) || len(qs) != 0 { t.Fatalf("want ErrDegraded on trip, got %v %v", qs, err) }
+    if !h.Tripped() { t.Fatalf("want tripped") }
+
+    // tripped: no upstream traffic until the probe is due
+    qs, err = h.Fetch(t.Context(), []string{"A"})
+    if !errors.Is(err, ErrDegraded) || len(qs) != 0 { t.Fatalf("tripped fetch: %v %v", qs, err) }
+    if n := up.calls.Load(); n != 2 { t.Fatalf("want 2 upstream calls, got %d", n) }
+}
+
+func TestHealth_RecoversOnSuccessfulProbe(t *testing.T) {
+    up := &flakyProvider{}
+    up.failing.Store(true)
+    h := &Provider{P: up, Threshold: 1, ProbeInterval: 20 * time.Millisecond}
+
+    if _, err := h.Fetch(t.Context(), []string{"A"}); !errors.Is(err, ErrDegraded) { t.Fatalf("want ErrDegraded on trip, got %v", err) }
+    if !h.Tripped() { t.Fatalf("want tripped") }
+
+    // failed probe keeps it tripped
+    time.Sleep(25 * time.Millisecond)
+    if qs, err := h.Fetch(t.Context(), []string{"A"}); !errors.Is(err, ErrDegraded) || len(qs) != 0 { t.Fatalf("failed probe: %v %v", qs, err) }
+    if !h.Tripped() || up.calls.Load() != 2 { t.Fatalf("want still tripped after 2 calls, got %v %d", h.Tripped(), up.calls.Load()) }
+
+    up.failing.Store(false)
+    time.Sleep(25 * time.Millisecond)
+    qs, err := h.Fetch(t.Context(), []string{"A"})
+    if err != nil || len(qs) != 1 { t.Fatalf("probe should pass through: %v %v", qs, err) }
+    if h.Tripped() { t.Fatalf("want recovered") }
+}
@@ -0,0 +1,69 @@
+package hedge
+
+import (
+    "context"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// Provider issues a second attempt when the first has not returned within
+// Delay and returns whichever finishes first, canceling the other.
+// Place it below any rate limiter so a hedged attempt never consumes an
+// extra token from the limiter.
+type Provider struct {
+    P     provider.Provider
+    Delay time.Duration
+}
+
+func (h *Provider) Name() string { return h.P.Name() }
+func (h *Provider) Unwrap() provider.Provider { return h.P }
+
+type result struct {
+    quotes []provider.Quote
+    err    error
+}
+
+func (h *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if h.Delay <= 0 {
+        return h.P.Fetch(ctx, symbols)
+    }
+    // canceling on return stops whichever attempt lost the race
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    ch := make(chan result, 2)
+    launch := func() {
+        go func() {
+            qs, err := h.P.Fetch(ctx, symbols)
+            ch <- result{qs, err}
+        }()
+    }
+    launch()
+
+    timer := time.NewTimer(h.Delay)
+    defer timer.Stop()
+    select {
+    case r := <-ch:
+        return r.quotes, r.err
+    case <-timer.C:
+        launch()
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+
+    // Two attempts in flight: first success wins; an error only wins if both fail.
+    // a partial result is kept in case the other attempt does no better
+    var first result
+    for i := 0; i < 2; i++ {
+        select {
+        case r := <-ch:
+            if r.err == nil { return r.quotes, nil }
+            if first.err == nil || (provider.IsPartial(r.err) && !provider.IsPartial(first.err)) { first = r }
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    if provider.IsPartial(first.err) { return first.quotes, first.err }
+    return nil, first.err
+}
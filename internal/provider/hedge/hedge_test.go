@@ -0,0 +1,54 @@
+package hedge
+
+import (
+    "context"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// slowFirst stalls its first call until canceled and answers later calls immediately.
+type slowFirst struct {
+    calls    atomic.Int32
+    canceled atomic.Bool
+}
+
+func (s *slowFirst) Name() string { return "slow" }
+
+func (s *slowFirst) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    if s.calls.Add(1) == 1 {
+        <-ctx.Done()
+        s.canceled.Store(true)
+        return nil, ctx.Err()
+    }
+    return []provider.Quote{{Symbol: symbols[0], Price: "1", Source: "slow:hedge"}}, nil
+}
+
+func TestHedge_SecondAttemptWinsWhenFirstIsSlow(t *testing.T) {
+    up := &slowFirst{}
+    h := &Provider{P: up, Delay: 20 * time.Millisecond}
+
+    start := time.Now()
+    qs, err := h.Fetch(t.Context(), []string{"A"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 1 || qs[0].Source != "slow:hedge" { t.Fatalf("unexpected quotes: %+v", qs) }
+    if el := time.Since(start); el > time.Second { t.Fatalf("hedge took too long: %s", el) }
+    if n := up.calls.Load(); n != 2 { t.Fatalf("want 2 attempts, got %d", n) }
+
+    // the losing attempt must be canceled
+    deadline := time.Now().Add(time.Second)
+    for !up.canceled.Load() {
+        if time.Now().After(deadline) { t.Fatal("slow attempt was not canceled") }
+        time.Sleep(time.Millisecond)
+    }
+}
+
+func TestHedge_NoSecondAttemptWhenFast(t *testing.T) {
+    up := &slowFirst{}
+    up.calls.Store(1) // every call answers immediately
+    h := &Provider{P: up, Delay: 50 * time.Millisecond}
+    if _, err := h.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch: %v", err) }
+    if n := up.calls.Load(); n != 2 { t.Fatalf("want exactly one upstream call, got %d", n-1) }
+}
@@ -0,0 +1,161 @@
+package multi
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// Provider fans Fetch out to several providers concurrently and merges their
+// quotes in provider order. It is a provider.Provider itself, so a group of
+// upstreams can be used wherever one is expected.
+//
+// Fetch returns the quotes of every provider that succeeded together with a
+// combined error (errors.Join) of the ones that failed; callers that need the
+// split per provider use FetchEach. Only when every provider fails are the
+// quotes nil.
+type Provider struct {
+    // Label is returned by Name; empty yields "multi".
+    Label     string
+    Providers []provider.Provider
+    // Timeout, when > 0, bounds each provider's Fetch separately.
+    Timeout time.Duration
+    // Skip, when set, leaves out providers it returns true for (e.g., ones
+    // switched off at runtime).
+    Skip func(provider.Provider) bool
+    // Concurrency, when > 0, caps how many providers are fetched at once;
+    // the rest wait for a free worker. 0 runs them all together.
+    Concurrency int
+    // Priority names providers (case-insensitive, best first) whose results
+    // FetchEach moves to the end, the best one last, so that wherever later
+    // input wins (aggregate.LatestByMarket) equal timestamps go to the best
+    // provider. Unlisted providers keep their order ahead of them.
+    Priority []string
+    // UntilCovered makes FetchEach return as soon as the providers that
+    // succeeded so far quote every requested symbol. The providers still
+    // running are canceled and the ones not yet started are never run; both
+    // are left out of the results unless they succeeded anyway.
+    UntilCovered bool
+}
+
+func (m *Provider) Name() string {
+    if m.Label == "" { return "multi" }
+    return m.Label
+}
+
+// Result is one provider's outcome.
+type Result struct {
+    Name   string
+    Quotes []provider.Quote
+    Err    error
+    // Elapsed is how long the provider's Fetch took.
+    Elapsed time.Duration
+}
+
+// OK reports whether r's quotes are usable: the provider succeeded, or failed
+// only for some symbols (provider.PartialError).
+func (r Result) OK() bool { return r.Err == nil || provider.IsPartial(r.Err) }
+
+// FetchEach runs every provider that is not skipped and returns their results
+// in provider order, adjusted by Priority. The order never depends on which
+// provider answers first.
+func (m *Provider) FetchEach(ctx context.Context, symbols []string) []Result {
+    var run []provider.Provider
+    for _, p := range m.Providers {
+        if m.Skip != nil && m.Skip(p) { continue }
+        run = append(run, p)
+    }
+    out := make([]Result, len(run))
+    workers := len(run)
+    if m.Concurrency > 0 && m.Concurrency < workers { workers = m.Concurrency }
+    // cut cancels the providers still running once UntilCovered is met
+    fctx, cut := context.WithCancel(ctx)
+    defer cut()
+    var covered atomic.Bool
+    next := make(chan int, len(run))
+    for i := range run { next <- i }
+    close(next)
+    finished := make(chan int, len(run))
+    var wg sync.WaitGroup
+    for range workers {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range next {
+                if covered.Load() { continue }
+                out[i] = m.fetchOne(fctx, run[i], symbols)
+                finished <- i
+            }
+        }()
+    }
+    go func() { wg.Wait(); close(finished) }()
+
+    need := make(map[string]struct{}, len(symbols))
+    for _, s := range symbols { need[s] = struct{}{} }
+    keep := make([]bool, len(run))
+    for i := range finished {
+        keep[i] = !covered.Load() || out[i].OK()
+        if !m.UntilCovered || covered.Load() || !out[i].OK() { continue }
+        for _, q := range out[i].Quotes { delete(need, q.Symbol) }
+        if len(need) == 0 {
+            covered.Store(true)
+            cut()
+        }
+    }
+    if covered.Load() {
+        kept := out[:0]
+        for i, r := range out {
+            if keep[i] { kept = append(kept, r) }
+        }
+        out = kept
+    }
+    if len(m.Priority) > 0 {
+        rank := make(map[string]int, len(m.Priority))
+        for i, name := range m.Priority { rank[strings.ToLower(strings.TrimSpace(name))] = len(m.Priority) - i }
+        sort.SliceStable(out, func(i, j int) bool { return rank[strings.ToLower(out[i].Name)] < rank[strings.ToLower(out[j].Name)] })
+    }
+    return out
+}
+
+func (m *Provider) fetchOne(ctx context.Context, p provider.Provider, symbols []string) Result {
+    if m.Timeout > 0 {
+        var cancel context.CancelFunc
+        ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+        defer cancel()
+    }
+    start := time.Now()
+    qs, err := p.Fetch(ctx, symbols)
+    return Result{Name: p.Name(), Quotes: qs, Err: err, Elapsed: time.Since(start)}
+}
+
+// Fetch merges the results of FetchEach (see Provider). Each error in the
+// combined error is prefixed with its provider name.
+func (m *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    results := m.FetchEach(ctx, symbols)
+    quotes, _ := Merge(results)
+    var errs []error
+    for _, r := range results {
+        if r.Err != nil { errs = append(errs, fmt.Errorf("%s: %w", r.Name, r.Err)) }
+    }
+    return quotes, errors.Join(errs...)
+}
+
+// Merge concatenates the quotes of usable results (see Result.OK) and collects
+// the errors of failed ones. Partial errors are left out: the timing layer
+// reports them as warnings.
+func Merge(results []Result) ([]provider.Quote, []error) {
+    var quotes []provider.Quote
+    var errs []error
+    for _, r := range results {
+        if !r.OK() { errs = append(errs, r.Err); continue }
+        quotes = append(quotes, r.Quotes...)
+    }
+    return quotes, errs
+}
@@ -0,0 +1,124 @@
+package multi
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "priceprovider/internal/aggregate"
+    "priceprovider/internal/provider"
+)
+
+type staticProvider struct {
+    name   string
+    quotes []provider.Quote
+    err    error
+    delay  time.Duration
+}
+
+func (s staticProvider) Name() string { return s.name }
+func (s staticProvider) Fetch(ctx context.Context, _ []string) ([]provider.Quote, error) {
+    if s.delay > 0 {
+        select {
+        case <-time.After(s.delay):
+        case <-ctx.Done():
+            return nil, ctx.Err()
+        }
+    }
+    return s.quotes, s.err
+}
+
+func TestMulti_MergesInProviderOrder(t *testing.T) {
+    m := &Provider{Label: "all", Providers: []provider.Provider{
+        staticProvider{name: "slow", quotes: []provider.Quote{{Symbol: "A", Source: "slow"}}, delay: 20 * time.Millisecond},
+        staticProvider{name: "fast", quotes: []provider.Quote{{Symbol: "A", Source: "fast"}, {Symbol: "B", Source: "fast"}}},
+    }}
+    got, err := m.Fetch(t.Context(), []string{"A", "B"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(got) != 3 || got[0].Source != "slow" || got[1].Source != "fast" { t.Fatalf("unexpected merge: %+v", got) }
+    if m.Name() != "all" { t.Fatalf("name=%q", m.Name()) }
+}
+
+func TestMulti_PriorityBreaksTimestampTies(t *testing.T) {
+    at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    quote := func(p, price string) []provider.Quote {
+        return []provider.Quote{{Symbol: "A", Source: p + ":BUFF:sell", Provider: p, Price: price, Currency: "USD", ReceivedAt: at}}
+    }
+    // whichever provider answers first, the preferred one wins the tie
+    for _, slow := range []string{"steamdt", "pricempire"} {
+        delay := func(name string) time.Duration {
+            if name == slow { return 10 * time.Millisecond }
+            return 0
+        }
+        m := &Provider{Priority: []string{"Pricempire", "SteamDT"}, Providers: []provider.Provider{
+            staticProvider{name: "pricempire", quotes: quote("Pricempire", "11"), delay: delay("pricempire")},
+            staticProvider{name: "steamdt", quotes: quote("SteamDT", "10"), delay: delay("steamdt")},
+            staticProvider{name: "skinstable", quotes: quote("Skinstable", "12")},
+        }}
+        results := m.FetchEach(t.Context(), []string{"A"})
+        if results[0].Name != "skinstable" || results[1].Name != "steamdt" || results[2].Name != "pricempire" { t.Fatalf("unexpected result order %v", []string{results[0].Name, results[1].Name, results[2].Name}) }
+        qs, _ := Merge(results)
+        got := aggregate.LatestByMarket(qs, true)
+        if len(got) != 1 || got[0].Provider != "Pricempire" { t.Fatalf("slow=%s: want the Pricempire quote to win the tie, got %+v", slow, got) }
+    }
+}
+
+func TestMulti_PartialFailureReturnsQuotesAndJoinedError(t *testing.T) {
+    upErr := provider.StatusError(503, errors.New("unavailable"))
+    m := &Provider{Providers: []provider.Provider{
+        staticProvider{name: "ok", quotes: []provider.Quote{{Symbol: "A"}}},
+        staticProvider{name: "down", err: upErr},
+    }}
+    got, err := m.Fetch(t.Context(), []string{"A"})
+    if len(got) != 1 { t.Fatalf("want the healthy provider's quote, got %+v", got) }
+    if err == nil || !strings.Contains(err.Error(), "down: ") { t.Fatalf("want error naming the provider, got %v", err) }
+    if !errors.Is(err, provider.ErrUpstream) { t.Fatalf("joined error lost its kind: %v", err) }
+}
+
+func TestMerge_KeepsPartialResults(t *testing.T) {
+    partial := &provider.PartialError{Symbols: []string{"B"}, Err: context.Canceled}
+    qs, errs := Merge([]Result{
+        {Name: "partial", Quotes: []provider.Quote{{Symbol: "A"}}, Err: partial},
+        {Name: "down", Err: errors.New("boom")},
+    })
+    if len(qs) != 1 || qs[0].Symbol != "A" { t.Fatalf("want the partial result's quotes, got %+v", qs) }
+    if len(errs) != 1 || errs[0].Error() != "boom" { t.Fatalf("want only the failed provider's error, got %v", errs) }
+}
+
+func TestMulti_TimeoutAndSkipArePerProvider(t *testing.T) {
+    m := &Provider{
+        Timeout: 10 * time.Millisecond,
+        Providers: []provider.Provider{
+            staticProvider{name: "stuck", delay: time.Second},
+            staticProvider{name: "ok", quotes: []provider.Quote{{Symbol: "A"}}},
+            staticProvider{name: "off", quotes: []provider.Quote{{Symbol: "A"}}},
+        },
+        Skip: func(p provider.Provider) bool { return p.Name() == "off" },
+    }
+    start := time.Now()
+    results := m.FetchEach(t.Context(), []string{"A"})
+    if took := time.Since(start); took > 500*time.Millisecond { t.Fatalf("per-provider timeout not applied (%s)", took) }
+    if len(results) != 2 { t.Fatalf("want skipped provider left out, got %+v", results) }
+    if !errors.Is(results[0].Err, context.DeadlineExceeded) || results[1].Err != nil || len(results[1].Quotes) != 1 {
+        t.Fatalf("unexpected results: %+v", results)
+    }
+}
+
+func TestMulti_UntilCoveredCancelsTheRest(t *testing.T) {
+    m := &Provider{UntilCovered: true, Providers: []provider.Provider{
+        staticProvider{name: "slow", quotes: []provider.Quote{{Symbol: "A", Source: "slow"}}, delay: 5 * time.Second},
+        staticProvider{name: "partial", quotes: []provider.Quote{{Symbol: "A", Source: "partial"}}},
+        staticProvider{name: "fast", quotes: []provider.Quote{{Symbol: "A", Source: "fast"}, {Symbol: "B", Source: "fast"}}, delay: 10 * time.Millisecond},
+    }}
+    start := time.Now()
+    results := m.FetchEach(t.Context(), []string{"A", "B"})
+    if took := time.Since(start); took > time.Second { t.Fatalf("slow provider not canceled (%s)", took) }
+    if len(results) != 2 || results[0].Name != "partial" || results[1].Name != "fast" { t.Fatalf("want the canceled provider left out, got %+v", results) }
+
+    // without full coverage every provider is waited for
+    m.Providers = m.Providers[:2]
+    m.Providers[0] = staticProvider{name: "slow", quotes: []provider.Quote{{Symbol: "A", Source: "slow"}}, delay: 20 * time.Millisecond}
+    if results := m.FetchEach(t.Context(), []string{"A", "B"}); len(results) != 2 || results[0].Err != nil || len(results[0].Quotes) != 1 { t.Fatalf("want both providers' results, got %+v", results) }
+}
@@ -0,0 +1,127 @@
+package provider
+
+import (
+    "bytes"
+    "encoding/json"
+    "fmt"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+// ParsePrice normalizes an upstream decimal to dot-decimal form ("1,50" ->
+// "1.50", "1,234.56" -> "1234.56", "1.234,56" -> "1234.56").
+// Rules:
+// - Spaces, NBSP, apostrophes and underscores are treated as grouping and dropped.
+// - With both ',' and '.', the last one is the decimal separator.
+// - A separator repeated more than once is grouping ("1.234.567").
+// - A lone ',' followed by exactly three digits is grouping ("1,234"),
+//   unless the integer part is 0 ("0,500"); otherwise it is the decimal point.
+// - A lone '.' is always the decimal point (current upstreams use dots).
+// - Scientific notation is expanded to plain decimals ("1.5e2" -> "150",
+//   "2.5E-3" -> "0.0025"), so clients never see an exponent.
+func ParsePrice(s string) (string, error) {
+    in := s
+    s = strings.Map(func(r rune) rune {
+        switch r {
+        case ' ', '\u00a0', '\u202f', '\'', '_':
+            return -1
+        }
+        return r
+    }, strings.TrimSpace(s))
+    if s == "" { return "", fmt.Errorf("empty price") }
+    if i := strings.IndexAny(s, "eE"); i >= 0 {
+        exp, err := strconv.Atoi(s[i+1:])
+        if err != nil || exp > maxExponent || exp < -maxExponent { return "", fmt.Errorf("invalid price %q", in) }
+        m, err := ParsePrice(s[:i])
+        if err != nil { return "", fmt.Errorf("invalid price %q", in) }
+        return shiftPoint(m, exp), nil
+    }
+
+    lastComma, lastDot := strings.LastIndexByte(s, ','), strings.LastIndexByte(s, '.')
+    nComma, nDot := strings.Count(s, ","), strings.Count(s, ".")
+    var decimal byte // 0 when there is no decimal separator
+    switch {
+    case nComma > 0 && nDot > 0:
+        decimal = '.'
+        if lastComma > lastDot { decimal = ',' }
+        if strings.Count(s, string(decimal)) > 1 { return "", fmt.Errorf("invalid price %q", in) }
+    case nComma == 1:
+        intPart := strings.TrimLeft(s[:lastComma], "+-")
+        if len(s)-lastComma-1 != 3 || strings.Trim(intPart, "0") == "" { decimal = ',' }
+    case nDot == 1:
+        decimal = '.'
+    }
+
+    var b strings.Builder
+    for i := 0; i < len(s); i++ {
+        c := s[i]
+        switch {
+        case c == decimal:
+            b.WriteByte('.')
+        case c == ',' || c == '.':
+            // grouping separator
+        default:
+            b.WriteByte(c)
+        }
+    }
+    out := b.String()
+    if _, ok := new(big.Rat).SetString(out); !ok || strings.ContainsAny(out, "eE/") {
+        return "", fmt.Errorf("invalid price %q", in)
+    }
+    return out, nil
+}
+
+// maxExponent bounds the exponents ParsePrice expands; no real price needs
+// more digits than this.
+const maxExponent = 30
+
+// shiftPoint moves the decimal point of the plain decimal m by exp places
+// (right for positive exp) and drops the leading zeros that leaves behind.
+func shiftPoint(m string, exp int) string {
+    sign := ""
+    if m[0] == '-' || m[0] == '+' {
+        if m[0] == '-' { sign = "-" }
+        m = m[1:]
+    }
+    intPart, frac, _ := strings.Cut(m, ".")
+    digits := intPart + frac
+    point := len(intPart) + exp
+    var out string
+    switch {
+    case point <= 0:
+        out = "0." + strings.Repeat("0", -point) + digits
+    case point >= len(digits):
+        out = digits + strings.Repeat("0", point-len(digits))
+    default:
+        out = digits[:point] + "." + digits[point:]
+    }
+    intEnd := strings.IndexByte(out, '.')
+    if intEnd < 0 { intEnd = len(out) }
+    lead := 0
+    for lead < intEnd-1 && out[lead] == '0' { lead++ }
+    return sign + out[lead:]
+}
+
+// Number is a price decoded from either a JSON number or a JSON string,
+// normalized with ParsePrice. Strings that do not parse decode as "" so one
+// malformed value does not fail the whole payload.
+type Number string
+
+func (n *Number) UnmarshalJSON(b []byte) error {
+    b = bytes.TrimSpace(b)
+    if bytes.Equal(b, []byte("null")) { *n = ""; return nil }
+    var raw string
+    if len(b) > 0 && b[0] == '"' {
+        if err := json.Unmarshal(b, &raw); err != nil { return err }
+    } else {
+        raw = string(b)
+    }
+    if strings.TrimSpace(raw) == "" { *n = ""; return nil }
+    v, err := ParsePrice(raw)
+    if err != nil { v = "" }
+    *n = Number(v)
+    return nil
+}
+
+func (n Number) String() string { return string(n) }
@@ -0,0 +1,56 @@
+package provider
+
+import (
+    "encoding/json"
+    "testing"
+)
+
+func TestParsePrice_Separators(t *testing.T) {
+    cases := map[string]string{
+        "1,50":         "1.50",
+        "1.50":         "1.50",
+        "1,234.56":     "1234.56",
+        "1.234,56":     "1234.56",
+        "1 234,56":     "1234.56",
+        "1,234":        "1234",
+        "0,500":        "0.500",
+        "1.234.567":    "1234567",
+        "12":           "12",
+        "-3,5":         "-3.5",
+    }
+    for in, want := range cases {
+        got, err := ParsePrice(in)
+        if err != nil || got != want { t.Fatalf("ParsePrice(%q) = %q, %v; want %q", in, got, err, want) }
+    }
+    for _, bad := range []string{"", "abc", "1,2,3.4,5", "1.2.3,4.5", "1e", "e5", "1e5e2", "1e2.5", "1e999"} {
+        if got, err := ParsePrice(bad); err == nil { t.Fatalf("ParsePrice(%q) = %q, want error", bad, got) }
+    }
+}
+
+func TestParsePrice_ExpandsScientificNotation(t *testing.T) {
+    cases := map[string]string{
+        "1.5e2":    "150",
+        "1.5E+2":   "150",
+        "1e5":      "100000",
+        "2.5e-3":   "0.0025",
+        "25e-1":    "2.5",
+        "0.05e2":   "5",
+        "1.2345e2": "123.45",
+        "-1.5e1":   "-15",
+        "1,5e2":    "150",
+        "150e0":    "150",
+    }
+    for in, want := range cases {
+        got, err := ParsePrice(in)
+        if err != nil || got != want { t.Fatalf("ParsePrice(%q) = %q, %v; want %q", in, got, err, want) }
+    }
+    var v struct{ A, B Number }
+    if err := json.Unmarshal([]byte(`{"A":1.5e2,"B":"7.25E-1"}`), &v); err != nil { t.Fatalf("decode: %v", err) }
+    if v.A != "150" || v.B != "0.725" { t.Fatalf("want plain decimals, got %+v", v) }
+}
+
+func TestNumber_DecodesNumbersAndStrings(t *testing.T) {
+    var v struct{ A, B, C, D Number }
+    if err := json.Unmarshal([]byte(`{"A":1.5,"B":"1,50","C":null,"D":"n/a"}`), &v); err != nil { t.Fatalf("decode: %v", err) }
+    if v.A != "1.5" || v.B != "1.50" || v.C != "" || v.D != "" { t.Fatalf("unexpected %+v", v) }
+}
@@ -1,8 +1,10 @@
 package pricempire
 
 import (
+	"log"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 )
 
 // HTTPClient describes an HTTP client.
@@ -22,8 +24,17 @@ type PricempireAPIClient struct {
 	header http.Header
 	// query contains additional query parameters to be sent with each request.
 	query url.Values
+	// skipMalformed drops items and sources that fail to parse instead of
+	// failing the whole response.
+	skipMalformed bool
+	// skipped counts what skipMalformed dropped; shared by per-call copies.
+	skipped *atomic.Int64
 }
 
+// malformedLogEvery samples the log of dropped items: the first drop and
+// every malformedLogEvery-th after it are logged.
+const malformedLogEvery = 100
+
 // PricempireAPIClientOption is a configuration option for the Pricempire API client.
 type PricempireAPIClientOption func(*PricempireAPIClient)
 
@@ -52,6 +63,32 @@ func WithHeader(header http.Header) PricempireAPIClientOption {
 	}
 }
 
+// WithSkipMalformed makes item responses lenient: an item or source that
+// fails to parse is dropped, counted and logged (sampled) instead of failing
+// the whole call. Strict is the default.
+func WithSkipMalformed(skip bool) PricempireAPIClientOption {
+	return func(c *PricempireAPIClient) {
+		c.skipMalformed = skip
+	}
+}
+
+// Skipped returns how many items and sources WithSkipMalformed dropped.
+func (c *PricempireAPIClient) Skipped() int64 {
+	return c.skipped.Load()
+}
+
+// malformed returns err in strict mode. In lenient mode it counts and
+// logs err and returns nil, telling the caller to skip the entry.
+func (c *PricempireAPIClient) malformed(err error) error {
+	if !c.skipMalformed {
+		return err
+	}
+	if n := c.skipped.Add(1); n%malformedLogEvery == 1 {
+		log.Printf("pricempire: skipped malformed entry (%d so far): %v", n, err)
+	}
+	return nil
+}
+
 // NewPricempireAPIClient creates a new Pricempire API client.
 func NewPricempireAPIClient(key string, options ...PricempireAPIClientOption) (*PricempireAPIClient, error) {
 	var pricempireAPIClient = &PricempireAPIClient{
@@ -59,6 +96,7 @@ func NewPricempireAPIClient(key string, options ...PricempireAPIClientOption) (*
 		httpClient: http.DefaultClient,
 		header:     http.Header{},
 		query:      url.Values{},
+		skipped:    &atomic.Int64{},
 	}
 	if key != "" {
 		// This is the header that is used to authenticate the client.
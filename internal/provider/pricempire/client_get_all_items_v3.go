@@ -9,6 +9,8 @@ import (
     "net/http/httputil"
     "strconv"
     "time"
+
+    "priceprovider/internal/provider"
 )
 
 // Item represents an item from the Pricempire API.
@@ -25,6 +27,8 @@ type Price struct {
 	Avg30     *float64
 	Inflated  *bool
 	CreatedAt *time.Time
+	// Currency is set when the API reports the source's currency explicitly.
+	Currency  *string
 }
 
 // GetAllItemsV3 retrieves all items from the Pricempire API.
@@ -34,6 +38,9 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 		httpClient: c.httpClient,
 		header:     c.header.Clone(),
 		query:      c.query,
+
+		skipMalformed: c.skipMalformed,
+		skipped:       c.skipped,
 	}
 	for _, opt := range opts {
 		opt(override)
@@ -55,7 +62,7 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 
 	res, err := override.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("performing request: %w", err)
+		return nil, fmt.Errorf("performing request: %w", provider.TransportError(err))
 	}
 	defer res.Body.Close()
 
@@ -66,18 +73,18 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 	case http.StatusBadRequest:
 		b, err := json.Marshal(sources)
 		if err != nil {
-			return nil, fmt.Errorf("bad request with sources=%v", sources)
+			return nil, provider.StatusError(res.StatusCode, fmt.Errorf("bad request with sources=%v", sources))
 		}
-		return nil, fmt.Errorf("bad request with sources=%s", string(b))
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("bad request with sources=%s", string(b)))
 
-	case http.StatusForbidden:
-		return nil, fmt.Errorf("unauthorized")
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("unauthorized"))
 
 	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("rate limited")
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("rate limited"))
 
 	default:
-		return nil, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("unexpected status code: %d", res.StatusCode))
 	}
 
     var body map[string]any
@@ -103,12 +110,18 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 		// }
 		item, ok := raw.(map[string]any)
 		if !ok {
-			return nil, fmt.Errorf("decoding item: %w", err)
+			if err := override.malformed(fmt.Errorf("decoding item %q: unexpected type %T", name, raw)); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		liquidity, err := parseNullableValue[float64](item, "liquidity")
 		if err != nil {
-			return nil, fmt.Errorf("decoding liquidity: %w", err)
+			if err := override.malformed(fmt.Errorf("decoding liquidity of %q: %w", name, err)); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
 		var prices = map[string]Price{}
@@ -128,49 +141,20 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 
 			data, ok := dataVal.(map[string]any)
 			if !ok {
-				return nil, fmt.Errorf("decoding %s: %w", source, err)
-			}
-
-			price, err := parseNullableValue[float64](data, "price")
-			if err != nil {
-				return nil, fmt.Errorf("decoding price: %w", err)
-			}
-
-			count, err := parseNullableValue[float64](data, "count")
-			if err != nil {
-				return nil, fmt.Errorf("decoding count: %w", err)
-			}
-
-			avg30, err := parseNullableValue[float64](data, "avg30")
-			if err != nil {
-				return nil, fmt.Errorf("decoding avg30: %w", err)
-			}
-
-			inflated, err := parseNullableValue[bool](data, "isInflated")
-			if err != nil {
-				return nil, fmt.Errorf("decoding inflated: %w", err)
+				if err := override.malformed(fmt.Errorf("decoding %s of %q: unexpected type %T", source, name, dataVal)); err != nil {
+					return nil, err
+				}
+				continue
 			}
 
-			createdAtStr, err := parseNullableValue[string](data, "createdAt")
+			p, err := parseV3Price(data)
 			if err != nil {
-				return nil, fmt.Errorf("decoding createdAt: %w", err)
-			}
-			var createdAt *time.Time
-			if createdAtStr != nil {
-				t, err := time.Parse(time.RFC3339, *createdAtStr)
-				if err != nil {
-					return nil, fmt.Errorf("decoding createdAt: %w", err)
+				if err := override.malformed(fmt.Errorf("decoding %s of %q: %w", source, name, err)); err != nil {
+					return nil, err
 				}
-				createdAt = &t
-			}
-
-			prices[source] = Price{
-				Price:     price,
-				Count:     count,
-				Avg30:     avg30,
-				Inflated:  inflated,
-				CreatedAt: createdAt,
+				continue
 			}
+			prices[source] = p
 		}
 
 		items = append(items, Item{
@@ -183,6 +167,56 @@ func (c *PricempireAPIClient) GetAllItemsV3(ctx context.Context, appID int, curr
 	return items, nil
 }
 
+// parseV3Price reads one per-source v3 price object.
+func parseV3Price(data map[string]any) (Price, error) {
+	price, err := parseNullableValue[float64](data, "price")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding price: %w", err)
+	}
+
+	count, err := parseNullableValue[float64](data, "count")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding count: %w", err)
+	}
+
+	avg30, err := parseNullableValue[float64](data, "avg30")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding avg30: %w", err)
+	}
+
+	inflated, err := parseNullableValue[bool](data, "isInflated")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding inflated: %w", err)
+	}
+
+	createdAtStr, err := parseNullableValue[string](data, "createdAt")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding createdAt: %w", err)
+	}
+	currency, err := parseNullableValue[string](data, "currency")
+	if err != nil {
+		return Price{}, fmt.Errorf("decoding currency: %w", err)
+	}
+
+	var createdAt *time.Time
+	if createdAtStr != nil {
+		t, err := time.Parse(time.RFC3339, *createdAtStr)
+		if err != nil {
+			return Price{}, fmt.Errorf("decoding createdAt: %w", err)
+		}
+		createdAt = &t
+	}
+
+	return Price{
+		Price:     price,
+		Count:     count,
+		Avg30:     avg30,
+		Inflated:  inflated,
+		CreatedAt: createdAt,
+		Currency:  currency,
+	}, nil
+}
+
 // parseNullableValue is a helper function to parse a nullable value.
 func parseNullableValue[T any](data map[string]any, key string) (*T, error) {
 	v, ok := data[key]
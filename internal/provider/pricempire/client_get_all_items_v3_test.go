@@ -300,3 +300,47 @@ var mockItems = []pricempire.Item{
 
 // toPtr is a small local helper to create pointers to literal values in tests.
 func toPtr[T any](v T) *T { return &v }
+
+func TestGetAllItemsV3_SkipMalformed(t *testing.T) {
+	t.Parallel()
+
+	// Arrange: one item whose price has the wrong type among good ones
+	const body = `{
+		"AK-47 | Redline (Field-Tested)": {"liquidity": 90, "buff": {"price": 1000}},
+		"Broken Item": {"buff": {"price": "not a number"}},
+		"Not An Object": 42,
+		"AWP | Asiimov (Field-Tested)": {"buff": {"price": 5000}}
+	}`
+	newClient := func(opts ...pricempire.PricempireAPIClientOption) *pricempire.PricempireAPIClient {
+		ctrl := gomock.NewController(t)
+		httpClient := NewMockHTTPClient(ctrl)
+		httpClient.EXPECT().
+			Do(gomock.Any()).
+			DoAndReturn(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			}).
+			Times(1)
+		client, err := pricempire.NewPricempireAPIClient("test-key", append([]pricempire.PricempireAPIClientOption{pricempire.WithHTTPClient(httpClient)}, opts...)...)
+		require.NoError(t, err)
+		return client
+	}
+
+	// Act + Assert: strict by default, the whole call fails
+	_, err := newClient().GetAllItemsV3(t.Context(), 730, "USD", []string{"buff"})
+	require.Error(t, err)
+
+	// Act: lenient mode
+	client := newClient(pricempire.WithSkipMalformed(true))
+	items, err := client.GetAllItemsV3(t.Context(), 730, "USD", []string{"buff"})
+	require.NoError(t, err)
+
+	// Assert: the good items survive; the broken source and the non-object are counted
+	prices := map[string]float64{}
+	for _, it := range items {
+		if p, ok := it.Prices["buff"]; ok {
+			prices[it.Name] = *p.Price
+		}
+	}
+	require.Equal(t, map[string]float64{"AK-47 | Redline (Field-Tested)": 1000, "AWP | Asiimov (Field-Tested)": 5000}, prices)
+	require.Equal(t, int64(2), client.Skipped())
+}
@@ -0,0 +1,187 @@
+package pricempire
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"priceprovider/internal/provider"
+)
+
+// GetAllItemsV4 retrieves all items from the v4 prices endpoint. It returns the
+// same Item shape as GetAllItemsV3, so callers can switch versions freely.
+//
+// v4 answers with an array of items whose prices are nested per source:
+//
+//	[{
+//	  "market_hash_name": "AK-47 | Redline (Field-Tested)",
+//	  "liquidity": 53.555,
+//	  "prices": [{
+//	    "provider_key": "buff",
+//	    "price": 32,
+//	    "count": 43,
+//	    "avg_30": 28,
+//	    "is_inflated": false,
+//	    "currency": "USD",
+//	    "updated_at": "2023-02-02T12:13:07.393Z"
+//	  }]
+//	}]
+//
+// Prices keep the units of the API, as with v3.
+func (c *PricempireAPIClient) GetAllItemsV4(ctx context.Context, appID int, currency string, sources []string, opts ...PricempireAPIClientOption) ([]Item, error) {
+	var override = &PricempireAPIClient{
+		baseURL:    c.baseURL,
+		httpClient: c.httpClient,
+		header:     c.header.Clone(),
+		query:      c.query,
+
+		skipMalformed: c.skipMalformed,
+		skipped:       c.skipped,
+	}
+	for _, opt := range opts {
+		opt(override)
+	}
+
+	query := maps.Clone(override.query)
+	query.Add("app_id", strconv.Itoa(appID))
+	query.Add("currency", currency)
+	query.Add("sources", strings.Join(sources, ","))
+
+	url := fmt.Sprintf("%s/v4/paid/items/prices?%s", override.baseURL, query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header = override.header
+
+	res, err := override.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing request: %w", provider.TransportError(err))
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("bad request with sources=%s", strings.Join(sources, ",")))
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("unauthorized"))
+	case http.StatusTooManyRequests:
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("rate limited"))
+	default:
+		return nil, provider.StatusError(res.StatusCode, fmt.Errorf("unexpected status code: %d", res.StatusCode))
+	}
+
+	var body []map[string]any
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding items response: %w", err)
+	}
+
+	wanted := make(map[string]bool, len(sources))
+	for _, s := range sources {
+		wanted[s] = true
+	}
+
+	var items = make([]Item, 0, len(body))
+	for _, raw := range body {
+		name, err := parseNullableValue[string](raw, "market_hash_name")
+		if err != nil || name == nil {
+			if err := override.malformed(fmt.Errorf("decoding market_hash_name: %v", raw["market_hash_name"])); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		liquidity, err := parseNullableValue[float64](raw, "liquidity")
+		if err != nil {
+			if err := override.malformed(fmt.Errorf("decoding liquidity of %q: %w", *name, err)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		list, ok := raw["prices"].([]any)
+		if raw["prices"] != nil && !ok {
+			if err := override.malformed(fmt.Errorf("decoding prices of %q: unexpected type %T", *name, raw["prices"])); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var prices = map[string]Price{}
+		for _, entry := range list {
+			data, ok := entry.(map[string]any)
+			if !ok {
+				if err := override.malformed(fmt.Errorf("decoding price of %q: unexpected type %T", *name, entry)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			source, err := parseNullableValue[string](data, "provider_key")
+			if err != nil || source == nil {
+				if err := override.malformed(fmt.Errorf("decoding provider_key of %q: %v", *name, data["provider_key"])); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !wanted[*source] {
+				continue
+			}
+
+			p, err := parseV4Price(data)
+			if err != nil {
+				if err := override.malformed(fmt.Errorf("decoding %s price of %q: %w", *source, *name, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			prices[*source] = p
+		}
+
+		items = append(items, Item{
+			Name:      *name,
+			Liquidity: liquidity,
+			Prices:    prices,
+		})
+	}
+
+	return items, nil
+}
+
+// parseV4Price reads one nested v4 price object.
+func parseV4Price(data map[string]any) (Price, error) {
+	var p Price
+	var err error
+	if p.Price, err = parseNullableValue[float64](data, "price"); err != nil {
+		return p, fmt.Errorf("price: %w", err)
+	}
+	if p.Count, err = parseNullableValue[float64](data, "count"); err != nil {
+		return p, fmt.Errorf("count: %w", err)
+	}
+	if p.Avg30, err = parseNullableValue[float64](data, "avg_30"); err != nil {
+		return p, fmt.Errorf("avg_30: %w", err)
+	}
+	if p.Inflated, err = parseNullableValue[bool](data, "is_inflated"); err != nil {
+		return p, fmt.Errorf("is_inflated: %w", err)
+	}
+	if p.Currency, err = parseNullableValue[string](data, "currency"); err != nil {
+		return p, fmt.Errorf("currency: %w", err)
+	}
+	updatedAt, err := parseNullableValue[string](data, "updated_at")
+	if err != nil {
+		return p, fmt.Errorf("updated_at: %w", err)
+	}
+	if updatedAt != nil {
+		t, err := time.Parse(time.RFC3339, *updatedAt)
+		if err != nil {
+			return p, fmt.Errorf("updated_at: %w", err)
+		}
+		p.CreatedAt = &t
+	}
+	return p, nil
+}
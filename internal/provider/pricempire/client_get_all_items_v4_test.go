@@ -0,0 +1,124 @@
+package pricempire_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	pricempire "priceprovider/internal/provider/pricempire"
+)
+
+func TestGetAllItemsV4_ErrUnexpectedStatusCode(t *testing.T) {
+	t.Parallel()
+
+	// Arrange: create a mock controller
+	ctrl := gomock.NewController(t)
+
+	// Arrange: create a mock HTTP client
+	httpClient := NewMockHTTPClient(ctrl)
+
+	// Assert: stub the Do method
+	httpClient.EXPECT().
+		Do(gomock.Any()).
+		DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       io.NopCloser(bytes.NewReader([]byte{})),
+			}, nil
+		}).
+		Times(1)
+
+	// Arrange: setup a new Pricempire API client
+	client, err := pricempire.NewPricempireAPIClient("", pricempire.WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	// Act: call GetAllItemsV4
+	items, err := client.GetAllItemsV4(t.Context(), 730, "USD", []string{"buff"})
+	require.Error(t, err)
+	require.Nil(t, items)
+}
+
+func TestGetAllItemsV4_WithFixture(t *testing.T) {
+	t.Parallel()
+
+	// Arrange: create a mock controller
+	ctrl := gomock.NewController(t)
+
+	// Load the fixture data
+	fixtureData, err := os.OpenFile("fixtures/get_all_items_v4.json", os.O_RDONLY, 0600)
+	require.NoError(t, err)
+
+	// Arrange: create a mock HTTP client
+	httpClient := NewMockHTTPClient(ctrl)
+
+	// Assert: stub the Do method
+	httpClient.EXPECT().
+		Do(gomock.Any()).
+		DoAndReturn(func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, http.MethodGet, req.Method)
+			require.Equal(t, "test-key", req.URL.Query().Get("api_key"))
+			require.Contains(t, req.URL.Path, "/v4/paid/items/prices")
+			require.Contains(t, req.URL.RawQuery, "app_id=730")
+			require.Contains(t, req.URL.RawQuery, "currency=USD")
+			require.Equal(t, "buff,youpin", req.URL.Query().Get("sources"))
+
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       fixtureData,
+			}, nil
+		}).
+		Times(1)
+
+	// Arrange: setup a new Pricempire API client
+	client, err := pricempire.NewPricempireAPIClient("test-key", pricempire.WithHTTPClient(httpClient))
+	require.NoError(t, err)
+	require.NotNil(t, client)
+
+	// Act: call GetAllItemsV4
+	items, err := client.GetAllItemsV4(t.Context(), 730, "USD", []string{"buff", "youpin"})
+	require.NoError(t, err)
+	require.Len(t, items, 3)
+
+	// Assert: the item should be returned.
+	ak47Hydroponic := findItem(items, "AK-47 | Hydroponic (Battle-Scarred)")
+	require.NotNilf(t, ak47Hydroponic, "expected AK-47 | Hydroponic (Battle-Scarred) item to be found")
+	require.InEpsilon(t, 55.565525383707204, *ak47Hydroponic.Liquidity, 0.0001)
+
+	// Assert: sources that were not requested are skipped
+	require.Len(t, ak47Hydroponic.Prices, 2)
+	require.NotContains(t, ak47Hydroponic.Prices, "steam")
+
+	// Assert: the nested buff price matches the v3 shape
+	buffPrice := ak47Hydroponic.Prices["buff"]
+	require.NotNilf(t, buffPrice.Price, "expected price to be set, got nil")
+	require.InEpsilon(t, 48144.0, *buffPrice.Price, 0.0001)
+	require.NotNilf(t, buffPrice.Count, "expected count to be set, got nil")
+	require.InEpsilon(t, 7.0, *buffPrice.Count, 0.0001)
+	require.NotNilf(t, buffPrice.Avg30, "expected avg30 to be set, got nil")
+	require.InEpsilon(t, 49143.0, *buffPrice.Avg30, 0.0001)
+	require.NotNilf(t, buffPrice.Inflated, "expected inflated to be set, got nil")
+	require.False(t, *buffPrice.Inflated)
+	require.NotNilf(t, buffPrice.CreatedAt, "expected updated_at to be set, got nil")
+	require.Equal(t, time.Date(2024, 7, 17, 13, 53, 47, 857000000, time.UTC), *buffPrice.CreatedAt)
+	require.Nil(t, buffPrice.Currency)
+
+	// Assert: null fields stay nil and the reported currency is kept
+	youpinPrice := ak47Hydroponic.Prices["youpin"]
+	require.InEpsilon(t, 345000.0, *youpinPrice.Price, 0.0001)
+	require.Nil(t, youpinPrice.Avg30)
+	require.Nil(t, youpinPrice.Inflated)
+	require.NotNil(t, youpinPrice.Currency)
+	require.Equal(t, "CNY", *youpinPrice.Currency)
+
+	// Assert: items without prices are still listed
+	unlisted := findItem(items, "Sticker | Unlisted")
+	require.NotNil(t, unlisted)
+	require.Nil(t, unlisted.Liquidity)
+	require.Empty(t, unlisted.Prices)
+}
@@ -2,40 +2,120 @@ package pricempireadapter
 
 import (
     "context"
+    "errors"
     "fmt"
+    "sort"
     "strconv"
     "strings"
     "time"
     "sync"
 
+    "priceprovider/internal/money"
     "priceprovider/internal/provider"
     "priceprovider/internal/provider/pricempire"
+    "priceprovider/internal/provider/timing"
 )
 
 type Config struct {
     Name     string   // display name, default: Pricempire
     AppID    int      // Steam app id (e.g., 730)
+    // AppIDs serves several Steam games from one adapter (e.g., [730, 570, 440]).
+    // Each app is fetched and cached separately and quotes carry their AppID.
+    // Defaults to [AppID].
+    AppIDs   []int
     Currency string   // e.g., USD
     Sources  []string // e.g., ["buff","steam","skinport"]
     // ItemsCacheTTLSeconds caches the full Pricempire items payload
     // to avoid re-fetching the entire dataset for successive calls.
     // If <= 0, no internal caching is used.
     ItemsCacheTTLSeconds int
+    // EmitAvg30 adds a second quote per source priced at the 30-day average,
+    // with Source "<Name>:<source>:avg30" and the same timestamp as the spot price.
+    EmitAvg30 bool
+    // CaseInsensitive falls back to a case-insensitive name match when a
+    // requested symbol has no exact match. Quotes carry the requested spelling.
+    // Off by default: names differing only in case would collide.
+    CaseInsensitive bool
+    // SourceCurrency overrides Currency per source (e.g. {"buff": "CNY"}).
+    // A currency reported by the API for a source wins over both.
+    SourceCurrency map[string]string
+    // APIVersion selects the items endpoint: "v3" (default) or "v4".
+    APIVersion string
+    // PerSourceRequests fetches each source with its own request, in
+    // parallel, and merges the items. A failing source then only loses its
+    // own prices; the failure is reported as a timing warning.
+    PerSourceRequests bool
+    // MaxCachedItems caps the items kept in the items cache across all app
+    // ids. A refresh that would exceed it evicts the app ids refreshed longest
+    // ago first; a single payload larger than the cap is served uncached.
+    // 0 means unlimited.
+    MaxCachedItems int
 }
 
 type Adapter struct {
     cfg    Config
     client *pricempire.PricempireAPIClient
 
-    // cache of last fetched items keyed by item name for fast lookup
-    mu           sync.RWMutex
-    itemsByName  map[string]pricempire.Item
-    itemsExpires time.Time
+    // cache of last fetched items per app id, keyed by item name for fast lookup
+    mu    sync.RWMutex
+    items map[int]itemsCache
+    // refreshes numbers stored payloads so eviction can find the oldest;
+    // evictions and oversized count MaxCachedItems decisions (see CacheStats).
+    refreshes uint64
+    evictions int64
+    oversized int64
+}
+
+// CacheStats describes the items cache for monitoring.
+type CacheStats struct {
+    // Apps and Items count the cached app ids and the items they hold.
+    Apps      int   `json:"apps"`
+    Items     int   `json:"items"`
+    MaxItems  int   `json:"max_items,omitempty"`
+    // Evictions counts app ids dropped to make room; Oversized counts
+    // payloads too large to cache at all.
+    Evictions int64 `json:"evictions"`
+    Oversized int64 `json:"oversized"`
+}
+
+type itemsCache struct {
+    byName  map[string]pricempire.Item
+    byLower map[string]string // lower-cased name -> name; only with CaseInsensitive
+    index   searchIndex
+    seq     uint64 // refresh order, for MaxCachedItems eviction
+    expires time.Time
+}
+
+// foldNames maps lower-cased names to their canonical spelling. When several
+// names fold to the same key the alphabetically first wins.
+func foldNames(m map[string]pricempire.Item) map[string]string {
+    out := make(map[string]string, len(m))
+    for n := range m {
+        l := strings.ToLower(n)
+        if cur, ok := out[l]; !ok || n < cur { out[l] = n }
+    }
+    return out
+}
+
+// searchIndex holds item names sorted, with lower-cased copies for matching.
+type searchIndex struct {
+    names []string
+    lower []string
+}
+
+func newSearchIndex(m map[string]pricempire.Item) searchIndex {
+    names := make([]string, 0, len(m))
+    for n := range m { names = append(names, n) }
+    sort.Strings(names)
+    lower := make([]string, len(names))
+    for i, n := range names { lower[i] = strings.ToLower(n) }
+    return searchIndex{names: names, lower: lower}
 }
 
 func New(cfg Config, client *pricempire.PricempireAPIClient) *Adapter {
     if cfg.Name == "" { cfg.Name = "Pricempire" }
     if cfg.AppID == 0 { cfg.AppID = 730 }
+    if len(cfg.AppIDs) == 0 { cfg.AppIDs = []int{cfg.AppID} }
     if cfg.Currency == "" { cfg.Currency = "USD" }
     if len(cfg.Sources) == 0 { cfg.Sources = []string{"buff"} }
     return &Adapter{cfg: cfg, client: client}
@@ -43,72 +123,267 @@ func New(cfg Config, client *pricempire.PricempireAPIClient) *Adapter {
 
 func (a *Adapter) Name() string { return a.cfg.Name }
 
-func (a *Adapter) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
-    // Try internal cache first if enabled
-    var itemsByName map[string]pricempire.Item
+// itemsFor returns the items for one app id, using the internal cache when valid.
+// The search index is only built for cached entries.
+func (a *Adapter) itemsFor(ctx context.Context, appID int) (itemsCache, error) {
     ttl := time.Duration(a.cfg.ItemsCacheTTLSeconds) * time.Second
     if ttl > 0 {
         a.mu.RLock()
-        if !a.itemsExpires.IsZero() && time.Now().Before(a.itemsExpires) && len(a.itemsByName) > 0 {
-            itemsByName = a.itemsByName
-        }
+        c, ok := a.items[appID]
         a.mu.RUnlock()
+        if ok && time.Now().Before(c.expires) && len(c.byName) > 0 {
+            return c, nil
+        }
     }
 
     // Cache miss -> fetch and populate cache map
-    if itemsByName == nil {
-        items, err := a.client.GetAllItemsV3(ctx, a.cfg.AppID, a.cfg.Currency, a.cfg.Sources)
+    getAll := a.client.GetAllItemsV3
+    if a.cfg.APIVersion == "v4" { getAll = a.client.GetAllItemsV4 }
+    var m map[string]pricempire.Item
+    if a.cfg.PerSourceRequests && len(a.cfg.Sources) > 1 {
+        var err error
+        if m, err = a.fetchPerSource(ctx, getAll, appID); err != nil { return itemsCache{}, err }
+    } else {
+        items, err := getAll(ctx, appID, a.cfg.Currency, a.cfg.Sources)
         if err != nil {
-            return nil, err
+            return itemsCache{}, err
         }
-        m := make(map[string]pricempire.Item, len(items))
+        m = make(map[string]pricempire.Item, len(items))
         for _, it := range items { m[it.Name] = it }
-        itemsByName = m
-        if ttl > 0 {
-            a.mu.Lock()
-            a.itemsByName = m
-            a.itemsExpires = time.Now().Add(ttl)
-            a.mu.Unlock()
+    }
+    c := itemsCache{byName: m}
+    if a.cfg.CaseInsensitive { c.byLower = foldNames(m) }
+    if ttl > 0 {
+        c.index, c.expires = newSearchIndex(m), time.Now().Add(ttl)
+        a.mu.Lock()
+        a.storeLocked(appID, c)
+        a.mu.Unlock()
+    }
+    return c, nil
+}
+
+// storeLocked caches c for appID within MaxCachedItems, evicting the app ids
+// refreshed longest ago first. Callers hold a.mu.
+func (a *Adapter) storeLocked(appID int, c itemsCache) {
+    if a.items == nil { a.items = make(map[int]itemsCache, len(a.cfg.AppIDs)) }
+    delete(a.items, appID)
+    a.refreshes++
+    c.seq = a.refreshes
+    if max := a.cfg.MaxCachedItems; max > 0 {
+        if len(c.byName) > max {
+            a.oversized++
+            return
+        }
+        total := len(c.byName)
+        for _, e := range a.items { total += len(e.byName) }
+        for total > max {
+            oldest, first := 0, true
+            for id, e := range a.items {
+                if first || e.seq < a.items[oldest].seq { oldest, first = id, false }
+            }
+            total -= len(a.items[oldest].byName)
+            delete(a.items, oldest)
+            a.evictions++
         }
     }
+    a.items[appID] = c
+}
+
+// CacheStats reports the current size of the items cache.
+func (a *Adapter) CacheStats() CacheStats {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    s := CacheStats{Apps: len(a.items), MaxItems: a.cfg.MaxCachedItems, Evictions: a.evictions, Oversized: a.oversized}
+    for _, e := range a.items { s.Items += len(e.byName) }
+    return s
+}
+
+// getAllFunc is the signature shared by GetAllItemsV3 and GetAllItemsV4.
+type getAllFunc func(ctx context.Context, appID int, currency string, sources []string, opts ...pricempire.PricempireAPIClientOption) ([]pricempire.Item, error)
 
-    // Build a set of requested symbols for quick filtering (case-sensitive match)
+// fetchPerSource requests every source on its own, in parallel, and merges
+// the prices per item name. It fails only when every source failed; otherwise
+// the first failure is recorded on the request's timing.Recorder.
+func (a *Adapter) fetchPerSource(ctx context.Context, getAll getAllFunc, appID int) (map[string]pricempire.Item, error) {
+    results := make([][]pricempire.Item, len(a.cfg.Sources))
+    errs := make([]error, len(a.cfg.Sources))
+    var wg sync.WaitGroup
+    for i, src := range a.cfg.Sources {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            results[i], errs[i] = getAll(ctx, appID, a.cfg.Currency, []string{src})
+        }()
+    }
+    wg.Wait()
+
+    m := make(map[string]pricempire.Item)
+    var firstErr error
+    failed := 0
+    for i, items := range results {
+        if errs[i] != nil {
+            failed++
+            if firstErr == nil { firstErr = fmt.Errorf("source %s: %w", a.cfg.Sources[i], errs[i]) }
+            continue
+        }
+        for _, it := range items {
+            cur, ok := m[it.Name]
+            if !ok {
+                cur = pricempire.Item{Name: it.Name, Liquidity: it.Liquidity, Prices: make(map[string]pricempire.Price, len(a.cfg.Sources))}
+            }
+            if cur.Liquidity == nil { cur.Liquidity = it.Liquidity }
+            for src, p := range it.Prices { cur.Prices[src] = p }
+            m[it.Name] = cur
+        }
+    }
+    if failed == len(results) { return nil, firstErr }
+    if firstErr != nil {
+        if r := timing.FromContext(ctx); r != nil { r.Warn(a.cfg.Name, firstErr) }
+    }
+    return m, nil
+}
+
+// ErrSearchCold is returned by Search while no app id has cached items.
+var ErrSearchCold = errors.New("pricempire: item index not loaded yet")
+
+// cachedIndex returns the search index of the items cached for appID,
+// expired or not: item names rarely change between refreshes.
+func (a *Adapter) cachedIndex(appID int) (searchIndex, bool) {
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    c, ok := a.items[appID]
+    return c.index, ok && len(c.index.names) > 0
+}
+
+// Search returns up to limit item names containing q (case-insensitive)
+// across all configured app ids. Prefix matches come first, then other
+// substring matches, each in alphabetical order.
+//
+// It only reads the items cache, which Fetch fills through the provider's
+// limits; it never calls upstream. App ids without cached items are left
+// out, and with none cached it returns ErrSearchCold.
+func (a *Adapter) Search(_ context.Context, q string, limit int) ([]string, error) {
+    q = strings.ToLower(strings.TrimSpace(q))
+    if q == "" || limit <= 0 { return []string{}, nil }
+    seen := make(map[string]struct{})
+    var prefix, contains []string
+    cold := true
+    for _, appID := range a.cfg.AppIDs {
+        idx, ok := a.cachedIndex(appID)
+        if !ok { continue }
+        cold = false
+        for i, l := range idx.lower {
+            pos := strings.Index(l, q)
+            if pos < 0 { continue }
+            name := idx.names[i]
+            if _, dup := seen[name]; dup { continue }
+            seen[name] = struct{}{}
+            if pos == 0 { prefix = append(prefix, name) } else { contains = append(contains, name) }
+        }
+    }
+    if cold { return nil, ErrSearchCold }
+    sort.Strings(prefix)
+    sort.Strings(contains)
+    out := append(prefix, contains...)
+    if len(out) > limit { out = out[:limit] }
+    if out == nil { out = []string{} }
+    return out, nil
+}
+
+func (a *Adapter) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    // Build a set of requested symbols for quick filtering (case-sensitive
+    // match, with a case-insensitive fallback when configured)
     want := make(map[string]struct{}, len(symbols))
     for _, s := range symbols { want[s] = struct{}{} }
 
     now := time.Now().UTC()
-    // Rough capacity hint: if filtering, assume 1-2 sources per symbol
-    capHint := len(itemsByName)
-    if len(want) > 0 { capHint = len(want) * 2 }
-    out := make([]provider.Quote, 0, capHint)
-
-    emit := func(name string, it pricempire.Item) {
-        for src, p := range it.Prices {
-            if p.Price == nil { continue }
-            price := formatFloat(*p.Price)
-            if price == "" { continue }
-            ts := now
-            if p.CreatedAt != nil { ts = p.CreatedAt.UTC() }
-            out = append(out, provider.Quote{
-                Symbol:     name,
-                Price:      price,
-                Currency:   a.cfg.Currency,
-                Source:     fmt.Sprintf("%s:%s", a.cfg.Name, src),
-                ReceivedAt: ts,
-            })
+    var out []provider.Quote
+    var firstErr error
+    for _, appID := range a.cfg.AppIDs {
+        c, err := a.itemsFor(ctx, appID)
+        itemsByName := c.byName
+        if err != nil {
+            if firstErr == nil { firstErr = err }
+            continue
         }
-    }
 
-    if len(want) > 0 {
-        for name := range want {
-            if it, ok := itemsByName[name]; ok { emit(name, it) }
+        if out == nil {
+            // Rough capacity hint: if filtering, assume 1-2 sources per symbol
+            capHint := len(itemsByName)
+            if len(want) > 0 { capHint = len(want) * 2 }
+            out = make([]provider.Quote, 0, capHint)
+        }
+
+        emit := func(name string, it pricempire.Item) {
+            for src, p := range it.Prices {
+                ts := now
+                if p.CreatedAt != nil { ts = p.CreatedAt.UTC() }
+                currency := a.currencyFor(src, p)
+                if a.cfg.EmitAvg30 && p.Avg30 != nil {
+                    if avg := formatFloat(*p.Avg30); avg != "" {
+                        out = append(out, provider.Quote{
+                            Symbol:     name,
+                            Price:      avg,
+                            Amount:     money.Of(avg),
+                            Currency:   currency,
+                            Source:     fmt.Sprintf("%s:%s:avg30", a.cfg.Name, src),
+                            Provider:   a.cfg.Name,
+                            ReceivedAt: ts,
+                            AppID:      appID,
+                        })
+                    }
+                }
+                if p.Price == nil { continue }
+                price := formatFloat(*p.Price)
+                if price == "" { continue }
+                volume := 0
+                if p.Count != nil && *p.Count > 0 { volume = int(*p.Count) }
+                // copied so quotes do not alias the cached items
+                var inflated *bool
+                if p.Inflated != nil { v := *p.Inflated; inflated = &v }
+                out = append(out, provider.Quote{
+                    Symbol:     name,
+                    Price:      price,
+                    Amount:     money.Of(price),
+                    Currency:   currency,
+                    Source:     fmt.Sprintf("%s:%s", a.cfg.Name, src),
+                    Provider:   a.cfg.Name,
+                    ReceivedAt: ts,
+                    AppID:      appID,
+                    Volume:     volume,
+                    Inflated:   inflated,
+                })
+            }
+        }
+
+        if len(want) > 0 {
+            for name := range want {
+                if it, ok := itemsByName[name]; ok {
+                    emit(name, it)
+                } else if canon, ok := c.byLower[strings.ToLower(name)]; ok {
+                    emit(name, itemsByName[canon])
+                }
+            }
+        } else {
+            for name, it := range itemsByName { emit(name, it) }
         }
-    } else {
-        for name, it := range itemsByName { emit(name, it) }
     }
+    // Only fail when no app id produced data; otherwise serve what we have.
+    if out == nil && firstErr != nil {
+        return nil, firstErr
+    }
+    if out == nil { out = []provider.Quote{} }
     return out, nil
 }
 
+// currencyFor picks a source's currency: the API's own, then the configured
+// override, then the adapter default.
+func (a *Adapter) currencyFor(src string, p pricempire.Price) string {
+    if p.Currency != nil && strings.TrimSpace(*p.Currency) != "" { return strings.ToUpper(strings.TrimSpace(*p.Currency)) }
+    if c := a.cfg.SourceCurrency[src]; c != "" { return c }
+    return a.cfg.Currency
+}
+
 func formatFloat(v float64) string {
     // Preserve precision without trailing zeros
     s := strconv.FormatFloat(v, 'f', -1, 64)
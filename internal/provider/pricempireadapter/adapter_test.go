@@ -0,0 +1,284 @@
+package pricempireadapter
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/httpx"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/pricempire"
+    "priceprovider/internal/provider/timing"
+)
+
+// newTestClient serves v3 item payloads from items, keyed by the appId query param.
+func newTestClient(t *testing.T, items map[string]map[string]any) *pricempire.PricempireAPIClient {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        _ = json.NewEncoder(w).Encode(items[r.URL.Query().Get("appId")])
+    }))
+    t.Cleanup(srv.Close)
+    c, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL(srv.URL))
+    if err != nil { t.Fatalf("client: %v", err) }
+    return c
+}
+
+func TestFetch_MultipleAppIDs_DoNotCollide(t *testing.T) {
+    sym := "Sticker | Shared Name"
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {sym: map[string]any{"buff": map[string]any{"price": 1.5}}},
+        "570": {sym: map[string]any{"buff": map[string]any{"price": 7.25}}},
+    })
+    a := New(Config{AppIDs: []int{730, 570}, Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60}, client)
+
+    qs, err := a.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 { t.Fatalf("want 2 quotes, got %d: %+v", len(qs), qs) }
+    byApp := map[int]string{}
+    for _, q := range qs { byApp[q.AppID] = q.Price }
+    if byApp[730] != "1.5" || byApp[570] != "7.25" { t.Fatalf("prices mixed across apps: %+v", qs) }
+    for _, q := range qs { if q.Provider != "Pricempire" { t.Fatalf("provider=%q, want default name", q.Provider) } }
+
+    // cached per app id: a second fetch returns the same split
+    qs, err = a.Fetch(t.Context(), []string{sym})
+    if err != nil || len(qs) != 2 { t.Fatalf("cached fetch: %v %+v", err, qs) }
+}
+
+func TestSearch_CaseInsensitivePrefixFirstAndBounded(t *testing.T) {
+    buff := map[string]any{"buff": map[string]any{"price": 1.0}}
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {
+            "AK-47 | Redline (Field-Tested)":      buff,
+            "AWP | Redline (Minimal Wear)":        buff,
+            "Redline Sticker":                     buff,
+            "M4A1-S | Hyper Beast (Field-Tested)": buff,
+        },
+    })
+    a := New(Config{Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60}, client)
+    if _, err := a.Search(t.Context(), "redline", 10); !errors.Is(err, ErrSearchCold) { t.Fatalf("want a cold index before any fetch, got %v", err) }
+    if _, err := a.Fetch(t.Context(), []string{"AK-47 | Redline (Field-Tested)"}); err != nil { t.Fatalf("fetch: %v", err) }
+
+    got, err := a.Search(t.Context(), "REDLINE", 10)
+    if err != nil { t.Fatalf("search: %v", err) }
+    want := []string{"Redline Sticker", "AK-47 | Redline (Field-Tested)", "AWP | Redline (Minimal Wear)"}
+    if len(got) != len(want) { t.Fatalf("want %v, got %v", want, got) }
+    for i := range want {
+        if got[i] != want[i] { t.Fatalf("want %v, got %v", want, got) }
+    }
+
+    if got, _ := a.Search(t.Context(), "redline", 2); len(got) != 2 { t.Fatalf("limit not applied: %v", got) }
+    if got, _ := a.Search(t.Context(), "nope", 10); got == nil || len(got) != 0 { t.Fatalf("want empty result, got %v", got) }
+}
+
+func TestFetch_CaseInsensitiveFallback(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    lower := "ak-47 | redline (field-tested)"
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {sym: map[string]any{"buff": map[string]any{"price": 10.5}}},
+    })
+
+    a := New(Config{Sources: []string{"buff"}}, client)
+    qs, err := a.Fetch(t.Context(), []string{lower})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 0 { t.Fatalf("want no match without the flag, got %+v", qs) }
+
+    for _, ttl := range []int{0, 60} {
+        a = New(Config{Sources: []string{"buff"}, CaseInsensitive: true, ItemsCacheTTLSeconds: ttl}, client)
+        qs, err = a.Fetch(t.Context(), []string{sym, lower})
+        if err != nil { t.Fatalf("fetch: %v", err) }
+        if len(qs) != 2 { t.Fatalf("ttl=%d: want exact and folded match, got %+v", ttl, qs) }
+        got := map[string]string{}
+        for _, q := range qs { got[q.Symbol] = q.Price }
+        if got[sym] != "10.5" || got[lower] != "10.5" { t.Fatalf("ttl=%d: want quotes under requested spelling, got %+v", ttl, qs) }
+    }
+}
+
+func TestFetch_EmitAvg30(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {sym: map[string]any{"buff": map[string]any{"price": 10.5, "avg30": 9.75, "createdAt": "2025-01-02T03:04:05Z"}}},
+    })
+
+    a := New(Config{Sources: []string{"buff"}}, client)
+    qs, err := a.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 1 || qs[0].Source != "Pricempire:buff" || qs[0].Price != "10.5" { t.Fatalf("want spot only, got %+v", qs) }
+
+    a = New(Config{Sources: []string{"buff"}, EmitAvg30: true}, client)
+    qs, err = a.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 { t.Fatalf("want spot and avg30, got %+v", qs) }
+    bySource := map[string]string{}
+    for _, q := range qs { bySource[q.Source] = q.Price }
+    if bySource["Pricempire:buff"] != "10.5" || bySource["Pricempire:buff:avg30"] != "9.75" { t.Fatalf("unexpected quotes: %+v", qs) }
+    if !qs[0].ReceivedAt.Equal(qs[1].ReceivedAt) || qs[0].ReceivedAt.Year() != 2025 { t.Fatalf("avg30 should share createdAt: %+v", qs) }
+}
+
+func TestFetch_FromRecordedFixtures(t *testing.T) {
+    hc := httpx.New(5 * time.Second)
+    if err := hc.UseFixtures("../pricempire/fixtures"); err != nil { t.Fatalf("fixtures: %v", err) }
+    client, err := pricempire.NewPricempireAPIClient("secret", pricempire.WithHTTPClient(hc.HTTP))
+    if err != nil { t.Fatalf("client: %v", err) }
+
+    sym := "'Blueberries' Buckshot | NSWC SEAL"
+    for _, version := range []string{"", "v4"} {
+        a := New(Config{AppID: 730, Currency: "USD", Sources: []string{"buff"}, APIVersion: version}, client)
+        qs, err := a.Fetch(t.Context(), []string{sym})
+        if err != nil { t.Fatalf("%q: fetch: %v", version, err) }
+        var buff *provider.Quote
+        for i := range qs {
+            if qs[i].Source == "Pricempire:buff" { buff = &qs[i] }
+        }
+        if buff == nil || buff.Price != "605" || buff.Volume != 212 { t.Fatalf("%q: unexpected quotes: %+v", version, qs) }
+    }
+}
+
+func TestFetch_SourceCurrency(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {sym: map[string]any{
+            "buff":   map[string]any{"price": 70.0},
+            "steam":  map[string]any{"price": 10.0},
+            "youpin": map[string]any{"price": 68.0, "currency": "cny"},
+            "csgotm": map[string]any{"price": 9.5},
+        }},
+    })
+    a := New(Config{
+        Sources:        []string{"buff", "steam", "youpin", "csgotm"},
+        Currency:       "USD",
+        SourceCurrency: map[string]string{"buff": "CNY", "youpin": "EUR"},
+    }, client)
+
+    qs, err := a.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    got := map[string]string{}
+    for _, q := range qs { got[q.Source] = q.Currency }
+    want := map[string]string{
+        "Pricempire:buff":   "CNY", // config override
+        "Pricempire:steam":  "USD", // default
+        "Pricempire:youpin": "CNY", // API-reported currency wins
+        "Pricempire:csgotm": "USD",
+    }
+    for src, cur := range want {
+        if got[src] != cur { t.Fatalf("%s: want %s, got %q (%v)", src, cur, got[src], got) }
+    }
+}
+
+func TestFetch_PerSourceRequestsKeepHealthySources(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    var calls atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        sources := r.URL.Query()["sources"]
+        if len(sources) != 1 { t.Errorf("want one source per request, got %v", sources) }
+        if sources[0] == "steam" {
+            http.Error(w, "boom", http.StatusInternalServerError)
+            return
+        }
+        _ = json.NewEncoder(w).Encode(map[string]any{sym: map[string]any{sources[0]: map[string]any{"price": 10.0}}})
+    }))
+    defer srv.Close()
+    client, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL(srv.URL))
+    if err != nil { t.Fatalf("client: %v", err) }
+    a := New(Config{Sources: []string{"buff", "steam", "youpin"}, PerSourceRequests: true}, client)
+
+    ctx, rec := timing.WithRecorder(t.Context())
+    qs, err := a.Fetch(ctx, []string{sym})
+    if err != nil { t.Fatalf("want partial results, got %v", err) }
+    if n := calls.Load(); n != 3 { t.Fatalf("want 3 requests, got %d", n) }
+    got := map[string]bool{}
+    for _, q := range qs { got[q.Source] = true }
+    if len(got) != 2 || !got["Pricempire:buff"] || !got["Pricempire:youpin"] { t.Fatalf("want buff and youpin quotes, got %+v", qs) }
+    if w := rec.Warnings()["Pricempire"]; w == "" { t.Fatalf("want the failed source reported, got %v", rec.Warnings()) }
+
+    // every source failing fails the fetch
+    a = New(Config{Sources: []string{"steam", "steam"}, PerSourceRequests: true}, client)
+    if _, err := a.Fetch(t.Context(), []string{sym}); err == nil { t.Fatalf("want error when all sources fail") }
+}
+
+func TestFetch_CarriesInflatedFlag(t *testing.T) {
+    sym := "AK-47 | Redline (Field-Tested)"
+    client := newTestClient(t, map[string]map[string]any{
+        "730": {sym: map[string]any{
+            "buff":     map[string]any{"price": 10.5, "isInflated": true},
+            "steam":    map[string]any{"price": 12, "isInflated": false},
+            "skinport": map[string]any{"price": 11},
+        }},
+    })
+
+    a := New(Config{Sources: []string{"buff", "steam", "skinport"}}, client)
+    qs, err := a.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 3 { t.Fatalf("want 3 quotes, got %+v", qs) }
+    for _, q := range qs {
+        switch q.Source {
+        case "Pricempire:buff":
+            if q.Inflated == nil || !*q.Inflated { t.Fatalf("buff: want inflated=true, got %v", q.Inflated) }
+        case "Pricempire:steam":
+            if q.Inflated == nil || *q.Inflated { t.Fatalf("steam: want inflated=false, got %v", q.Inflated) }
+        case "Pricempire:skinport":
+            if q.Inflated != nil { t.Fatalf("skinport: want no flag, got %v", *q.Inflated) }
+        }
+    }
+}
+
+func TestFetch_MaxCachedItemsBoundsCacheAcrossRefreshes(t *testing.T) {
+    buff := map[string]any{"buff": map[string]any{"price": 1.0}}
+    payload := func(prefix string, n int) map[string]any {
+        m := map[string]any{}
+        for i := range n { m[prefix+string(rune('A'+i))] = buff }
+        return m
+    }
+    client := newTestClient(t, map[string]map[string]any{
+        "730": payload("cs ", 3),
+        "570": payload("dota ", 3),
+        "440": payload("tf ", 5),
+    })
+    a := New(Config{AppIDs: []int{730, 570, 440}, Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60, MaxCachedItems: 6}, client)
+
+    // Every round refreshes 730 and 570 before 440, so they are the oldest
+    // entries whenever 440 needs room; only 440 is left cached.
+    for i := range 5 {
+        qs, err := a.Fetch(t.Context(), []string{"cs A", "dota A", "tf A"})
+        if err != nil || len(qs) != 3 { t.Fatalf("round %d: want quotes from every app, got %+v, %v", i, qs, err) }
+        s := a.CacheStats()
+        if s.Items > 6 { t.Fatalf("round %d: cache grew past its budget: %+v", i, s) }
+        if s.Apps != 1 || s.Items != 5 { t.Fatalf("round %d: want only the newest payload (440) kept, got %+v", i, s) }
+    }
+    // 2 in the first round; later ones also evict 440 to make room for 730
+    if s := a.CacheStats(); s.Evictions != 2+4*3 || s.MaxItems != 6 { t.Fatalf("unexpected evictions: %+v", s) }
+
+    // a payload larger than the budget is served but not cached
+    small := New(Config{AppIDs: []int{440}, Sources: []string{"buff"}, ItemsCacheTTLSeconds: 60, MaxCachedItems: 4}, client)
+    if qs, err := small.Fetch(t.Context(), []string{"tf A"}); err != nil || len(qs) != 1 { t.Fatalf("oversized fetch: %+v, %v", qs, err) }
+    if s := small.CacheStats(); s.Items != 0 || s.Oversized != 1 { t.Fatalf("want the oversized payload left uncached, got %+v", s) }
+}
+
+func TestSearch_NeverCallsUpstream(t *testing.T) {
+    var calls atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        _ = json.NewEncoder(w).Encode(map[string]any{"A": map[string]any{"buff": map[string]any{"price": 1.0}}})
+    }))
+    defer srv.Close()
+    client, err := pricempire.NewPricempireAPIClient("test", pricempire.WithBaseURL(srv.URL))
+    if err != nil { t.Fatalf("client: %v", err) }
+    a := New(Config{Sources: []string{"buff"}, ItemsCacheTTLSeconds: 1}, client)
+
+    if _, err := a.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch: %v", err) }
+    a.mu.Lock()
+    c := a.items[730]
+    c.expires = time.Now().Add(-time.Hour)
+    a.items[730] = c
+    a.mu.Unlock()
+
+    // an expired index is still searched, without a refresh
+    for range 3 {
+        if got, err := a.Search(t.Context(), "a", 10); err != nil || len(got) != 1 { t.Fatalf("search: %v %v", got, err) }
+    }
+    if n := calls.Load(); n != 1 { t.Fatalf("want only the fetch upstream, got %d calls", n) }
+}
@@ -3,16 +3,44 @@ package provider
 import (
     "context"
     "time"
+
+    "priceprovider/internal/money"
 )
 
 // Quote is the normalized shape returned by all providers.
-// Keep price as a string to avoid float rounding and external deps.
+// Price stays the canonical string on the wire; Amount is the same value
+// parsed once by the provider, for aggregation.
 type Quote struct {
     Symbol     string    `json:"symbol"`
     Price      string    `json:"price"`
     Currency   string    `json:"currency"`
     Source     string    `json:"source"`
+    // Provider is the configured name of the provider that produced the quote
+    // (e.g., "SteamDT"); Source keeps the finer market:side detail.
+    Provider   string    `json:"provider,omitempty"`
     ReceivedAt time.Time `json:"received_at"`
+    // AppID is the Steam app the quote belongs to when a provider serves
+    // several games (e.g., 730 CS2, 570 Dota 2, 440 TF2). 0 when unknown.
+    AppID      int       `json:"app_id,omitempty"`
+    // Volume is the number of listings (or bids) behind the price when the
+    // upstream reports it; 0 when unknown.
+    Volume     int       `json:"volume,omitempty"`
+    // ExternalID is the marketplace's own id for the listing behind the
+    // price (e.g. SteamDT's platformItemId), for deep links. Empty when unknown.
+    ExternalID string    `json:"external_id,omitempty"`
+    // Inflated is the upstream's own verdict that the price is manipulated
+    // (Pricempire's isInflated). nil for providers without the concept.
+    Inflated   *bool     `json:"inflated,omitempty"`
+    // Amount is Price parsed by the provider that built the quote. It is not
+    // serialized; use PriceAmount, which falls back to parsing Price.
+    Amount     money.Amount `json:"-"`
+}
+
+// PriceAmount returns q.Amount, or Price parsed when the quote was built
+// without one (e.g. decoded from JSON). Unparsable prices give the zero Amount.
+func (q Quote) PriceAmount() money.Amount {
+    if q.Amount.Valid() { return q.Amount }
+    return money.Of(q.Price)
 }
 
 type Provider interface {
@@ -20,3 +48,20 @@ type Provider interface {
     Fetch(ctx context.Context, symbols []string) ([]Quote, error)
 }
 
+// Wrapper is implemented by decorators (cache, rate limits, ...) that wrap
+// another Provider, so callers can walk a chain to find a specific layer.
+type Wrapper interface {
+    Unwrap() Provider
+}
+
+// Chain returns p followed by every provider it wraps, outermost first.
+func Chain(p Provider) []Provider {
+    var out []Provider
+    for p != nil {
+        out = append(out, p)
+        w, ok := p.(Wrapper)
+        if !ok { break }
+        p = w.Unwrap()
+    }
+    return out
+}
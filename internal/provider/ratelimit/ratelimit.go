@@ -5,6 +5,7 @@ import (
     "sync"
     "time"
 
+    "priceprovider/internal/clock"
     "priceprovider/internal/provider"
 )
 
@@ -14,32 +15,35 @@ import (
 type MinInterval struct {
     P           provider.Provider
     Interval    time.Duration
+    // Clock defaults to the wall clock.
+    Clock       clock.Clock
     mu          sync.Mutex
     last        time.Time
 }
 
 func (m *MinInterval) Name() string { return m.P.Name() }
+func (m *MinInterval) Unwrap() provider.Provider { return m.P }
 
 func (m *MinInterval) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
     if m.Interval > 0 {
         // simple gate: ensure at least Interval since last
         m.mu.Lock()
-        wait := time.Until(m.last.Add(m.Interval))
+        wait := m.last.Add(m.Interval).Sub(clock.Or(m.Clock).Now())
         m.mu.Unlock()
         if wait > 0 {
-            t := time.NewTimer(wait)
+            t := clock.Or(m.Clock).NewTimer(wait)
             defer t.Stop()
             select {
             case <-ctx.Done():
                 return nil, ctx.Err()
-            case <-t.C:
+            case <-t.C():
             }
         }
     }
     qs, err := m.P.Fetch(ctx, symbols)
     if m.Interval > 0 {
         m.mu.Lock()
-        m.last = time.Now()
+        m.last = clock.Or(m.Clock).Now()
         m.mu.Unlock()
     }
     return qs, err
@@ -5,6 +5,7 @@ import (
     "sync"
     "time"
 
+    "priceprovider/internal/clock"
     "priceprovider/internal/provider"
 )
 
@@ -14,28 +15,82 @@ import (
 type TokenBucket struct {
     rate     float64
     capacity float64
+    clock    clock.Clock
 
     mu     sync.Mutex
     tokens float64
     last   time.Time
+    waits  []time.Time // calls that had to block, pruned to the last minute
+}
+
+// Snapshot is a point-in-time view of a TokenBucket.
+type Snapshot struct {
+    Rate            float64 `json:"rate_per_sec"`
+    Capacity        float64 `json:"capacity"`
+    Tokens          float64 `json:"tokens"`
+    WaitsLastMinute int     `json:"waits_last_minute"`
+}
+
+// Snapshot returns the current token count (including refill since the
+// last call), capacity, rate and how many calls had to wait in the last minute.
+func (tb *TokenBucket) Snapshot() Snapshot {
+    tb.mu.Lock()
+    defer tb.mu.Unlock()
+    now := tb.clock.Now()
+    tokens := tb.tokens
+    if elapsed := now.Sub(tb.last).Seconds(); elapsed > 0 {
+        tokens += elapsed * tb.rate
+        if tokens > tb.capacity { tokens = tb.capacity }
+    }
+    tb.pruneWaits(now)
+    return Snapshot{Rate: tb.rate, Capacity: tb.capacity, Tokens: tokens, WaitsLastMinute: len(tb.waits)}
+}
+
+// pruneWaits drops wait records older than a minute. Caller holds tb.mu.
+func (tb *TokenBucket) pruneWaits(now time.Time) {
+    cutoff := now.Add(-time.Minute)
+    i := 0
+    for i < len(tb.waits) && tb.waits[i].Before(cutoff) { i++ }
+    tb.waits = tb.waits[i:]
 }
 
 func NewTokenBucket(tokensPerSecond float64, burst int) *TokenBucket {
+    return NewTokenBucketWithClock(tokensPerSecond, burst, clock.Real)
+}
+
+// NewTokenBucketWithClock is NewTokenBucket refilling by c (nil: the wall clock).
+func NewTokenBucketWithClock(tokensPerSecond float64, burst int, c clock.Clock) *TokenBucket {
     if tokensPerSecond <= 0 { tokensPerSecond = 0.0000001 }
     if burst <= 0 { burst = 1 }
+    c = clock.Or(c)
     return &TokenBucket{
         rate:     tokensPerSecond,
         capacity: float64(burst),
+        clock:    c,
         tokens:   float64(burst), // start full to allow an initial burst
-        last:     time.Now(),
+        last:     c.Now(),
     }
 }
 
+// WithTokens sets the tokens currently in tb, clamped to [0, capacity], and
+// returns tb. WithTokens(0) starts the bucket empty, so even the first call
+// waits for a refill and a strict upstream limit holds from cold start.
+func (tb *TokenBucket) WithTokens(n float64) *TokenBucket {
+    tb.mu.Lock()
+    defer tb.mu.Unlock()
+    if n < 0 { n = 0 }
+    if n > tb.capacity { n = tb.capacity }
+    tb.tokens = n
+    tb.last = tb.clock.Now()
+    return tb
+}
+
 // wait blocks until one token is available or context is canceled.
 func (tb *TokenBucket) wait(ctx context.Context) error {
+    waited := false
     for {
         tb.mu.Lock()
-        now := time.Now()
+        now := tb.clock.Now()
         // Refill
         elapsed := now.Sub(tb.last).Seconds()
         if elapsed > 0 {
@@ -52,16 +107,21 @@ func (tb *TokenBucket) wait(ctx context.Context) error {
         }
         // Need to wait for the remaining fraction
         deficit := 1 - tb.tokens
+        if !waited {
+            waited = true
+            tb.pruneWaits(now)
+            tb.waits = append(tb.waits, now)
+        }
         tb.mu.Unlock()
         // time needed to accumulate one token
         waitDur := time.Duration(deficit/tb.rate*1e9) * time.Nanosecond
         if waitDur <= 0 { waitDur = time.Millisecond }
-        timer := time.NewTimer(waitDur)
+        timer := tb.clock.NewTimer(waitDur)
         select {
         case <-ctx.Done():
             timer.Stop()
             return ctx.Err()
-        case <-timer.C:
+        case <-timer.C():
         }
     }
 }
@@ -73,6 +133,7 @@ type TokenBucketProvider struct {
 }
 
 func (t *TokenBucketProvider) Name() string { return t.P.Name() }
+func (t *TokenBucketProvider) Unwrap() provider.Provider { return t.P }
 
 func (t *TokenBucketProvider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
     if t.TB != nil {
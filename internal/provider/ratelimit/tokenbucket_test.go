@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "priceprovider/internal/clock"
+)
+
+func TestTokenBucket_SnapshotReflectsDrain(t *testing.T) {
+    tb := NewTokenBucket(0.001, 3) // effectively no refill during the test
+    s := tb.Snapshot()
+    if s.Capacity != 3 || s.Tokens < 2.99 || s.WaitsLastMinute != 0 {
+        t.Fatalf("unexpected initial snapshot: %+v", s)
+    }
+
+    for i := 0; i < 3; i++ {
+        if err := tb.wait(t.Context()); err != nil { t.Fatalf("wait %d: %v", i, err) }
+    }
+    s = tb.Snapshot()
+    if s.Tokens >= 1 { t.Fatalf("want drained bucket, got %+v", s) }
+
+    // a further call has to wait; cancel it quickly and check it was counted
+    ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+    defer cancel()
+    if err := tb.wait(ctx); err == nil { t.Fatal("want timeout on empty bucket") }
+    if s = tb.Snapshot(); s.WaitsLastMinute != 1 { t.Fatalf("want 1 wait recorded, got %+v", s) }
+}
+
+func TestTokenBucket_RefillFollowsClock(t *testing.T) {
+    clk := clock.NewFake(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+    tb := NewTokenBucketWithClock(1, 2, clk) // one token per second
+    for i := 0; i < 2; i++ {
+        if err := tb.wait(t.Context()); err != nil { t.Fatalf("wait %d: %v", i, err) }
+    }
+
+    done := make(chan error, 1)
+    go func() { done <- tb.wait(t.Context()) }()
+    clk.BlockUntil(1)
+    clk.Advance(999 * time.Millisecond)
+    select {
+    case err := <-done:
+        t.Fatalf("wait returned before a full token refilled: %v", err)
+    default:
+    }
+    clk.Advance(time.Millisecond)
+    if err := <-done; err != nil { t.Fatalf("wait: %v", err) }
+
+    clk.Advance(10 * time.Second)
+    if s := tb.Snapshot(); s.Tokens != 2 || s.WaitsLastMinute != 1 { t.Fatalf("want a full bucket and one recorded wait, got %+v", s) }
+}
+
+func TestTokenBucket_StartFullVsEmpty(t *testing.T) {
+    clk := clock.NewFake(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+
+    full := NewTokenBucketWithClock(1, 2, clk)
+    if err := full.wait(t.Context()); err != nil { t.Fatalf("full-start wait: %v", err) }
+    if s := full.Snapshot(); s.WaitsLastMinute != 0 { t.Fatalf("full-start first call should not wait, got %+v", s) }
+
+    empty := NewTokenBucketWithClock(1, 2, clk).WithTokens(0)
+    done := make(chan error, 1)
+    go func() { done <- empty.wait(t.Context()) }()
+    clk.BlockUntil(1)
+    select {
+    case err := <-done:
+        t.Fatalf("empty-start first call returned before a token refilled: %v", err)
+    default:
+    }
+    clk.Advance(time.Second)
+    if err := <-done; err != nil { t.Fatalf("empty-start wait: %v", err) }
+    if s := empty.Snapshot(); s.WaitsLastMinute != 1 || s.Tokens != 0 { t.Fatalf("want one recorded wait and no tokens left, got %+v", s) }
+
+    if s := NewTokenBucketWithClock(1, 4, clk).WithTokens(9).Snapshot(); s.Tokens != 4 { t.Fatalf("want tokens clamped to capacity, got %+v", s) }
+}
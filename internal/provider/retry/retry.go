@@ -0,0 +1,47 @@
+package retry
+
+import (
+    "context"
+    "time"
+
+    "priceprovider/internal/backoff"
+    "priceprovider/internal/provider"
+)
+
+// Provider re-attempts Fetch when the error is retryable (see
+// provider.IsRetryable), up to Attempts extra tries with backoff between them.
+// Place it above the rate limiter so every attempt is paid for with a token.
+type Provider struct {
+    P        provider.Provider
+    Attempts int
+    // Strategy, Base and Cap configure the backoff (see package backoff).
+    // Defaults: full jitter, 250ms base, 5s cap.
+    Strategy string
+    Base     time.Duration
+    Cap      time.Duration
+}
+
+func (r *Provider) Name() string { return r.P.Name() }
+func (r *Provider) Unwrap() provider.Provider { return r.P }
+
+func (r *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    qs, err := r.P.Fetch(ctx, symbols)
+    if err == nil || r.Attempts <= 0 || !provider.IsRetryable(err) { return qs, err }
+    base, cap := r.Base, r.Cap
+    if base <= 0 { base = 250 * time.Millisecond }
+    if cap <= 0 { cap = 5 * time.Second }
+    b, berr := backoff.New(r.Strategy, base, cap)
+    if berr != nil { return qs, err }
+    for attempt := 0; attempt < r.Attempts; attempt++ {
+        t := time.NewTimer(b.Next(attempt))
+        select {
+        case <-ctx.Done():
+            t.Stop()
+            return qs, err
+        case <-t.C:
+        }
+        qs, err = r.P.Fetch(ctx, symbols)
+        if err == nil || !provider.IsRetryable(err) { return qs, err }
+    }
+    return qs, err
+}
@@ -0,0 +1,39 @@
+package retry
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+type flaky struct {
+    errs  []error
+    calls int
+}
+
+func (f *flaky) Name() string { return "flaky" }
+func (f *flaky) Fetch(context.Context, []string) ([]provider.Quote, error) {
+    f.calls++
+    if f.calls <= len(f.errs) { return nil, f.errs[f.calls-1] }
+    return []provider.Quote{{Symbol: "A", Price: "1"}}, nil
+}
+
+func TestRetry_RetriesRetryableErrorsOnly(t *testing.T) {
+    htmlPage := &provider.Error{Kind: provider.ErrUpstream, Status: 200, Retryable: true, Err: errors.New("non-JSON response")}
+    f := &flaky{errs: []error{htmlPage, htmlPage}}
+    r := &Provider{P: f, Attempts: 3, Strategy: "none", Base: time.Millisecond}
+    qs, err := r.Fetch(t.Context(), []string{"A"})
+    if err != nil || len(qs) != 1 || f.calls != 3 { t.Fatalf("want success on 3rd call, got %v %+v after %d calls", err, qs, f.calls) }
+
+    f = &flaky{errs: []error{provider.StatusError(http.StatusUnauthorized, nil)}}
+    r = &Provider{P: f, Attempts: 3, Base: time.Millisecond}
+    if _, err := r.Fetch(t.Context(), []string{"A"}); !errors.Is(err, provider.ErrUnauthorized) || f.calls != 1 { t.Fatalf("auth errors must not be retried: %v after %d calls", err, f.calls) }
+
+    f = &flaky{errs: []error{htmlPage, htmlPage, htmlPage}}
+    r = &Provider{P: f, Attempts: 1, Strategy: "none", Base: time.Millisecond}
+    if _, err := r.Fetch(t.Context(), []string{"A"}); err == nil || f.calls != 2 { t.Fatalf("want failure after 2 calls, got %v after %d", err, f.calls) }
+}
@@ -1,16 +1,21 @@
 package skinstablexyz
 
 import (
+    "bytes"
     "context"
     "encoding/json"
     "fmt"
+    "io"
     "net/http"
     "net/url"
     "strconv"
+    "strings"
     "sync"
     "time"
 
+    "priceprovider/internal/backoff"
     "priceprovider/internal/httpx"
+    "priceprovider/internal/money"
     "priceprovider/internal/provider"
     "golang.org/x/sync/singleflight"
 )
@@ -24,9 +29,42 @@ type Config struct {
     Headers              map[string]string // optional extra headers
     ItemsCacheTTLSeconds int               // cache the full items payload for this long
     AppID                int               // required app/game id (e.g., 730)
+    AppIDs               []int             // optional; several games at once (defaults to [AppID])
     Sites                []string          // list of sites to query (e.g., ["CS.MONEY","BUFF.163"]) 
+    // PageSize > 0 requests each site in pages of this many items (offset/limit
+    // query params) until a short page. A non-empty "next" cursor in the response
+    // is always followed. The per-site timeout covers all pages.
+    PageSize             int
+    // IncludeBids also emits "<Name>:<site>:bid" quotes from the item's "b"
+    // field when the upstream provides it. Sell quotes keep "<Name>:<site>".
+    IncludeBids          bool
+    // SiteTimeoutSeconds bounds one attempt at refreshing a site, all pages
+    // included (default 7).
+    SiteTimeoutSeconds   int
+    // SiteRetries re-attempts a site refresh this many extra times when the
+    // error is retryable (see provider.IsRetryable), with jittered backoff.
+    SiteRetries          int
+    // StaleGraceSeconds keeps serving a site's last good payload for this long
+    // past its expiry when refreshing it fails. Zero drops it once expired.
+    StaleGraceSeconds    int
+    // Method is GET (default), which sends the request parameters in the
+    // query string, or POST, which sends them as a JSON object body.
+    Method               string
+    // ParamNames renames the request parameters for upstreams with another
+    // schema, keyed by the default names: apikey, app, site, limit, offset
+    // and cursor (e.g. {"app": "appid", "site": "market"}).
+    ParamNames           map[string]string
 }
 
+// maxPages bounds pagination in case an upstream keeps returning full pages.
+const maxPages = 1000
+
+// Site retry backoff bounds; the jitter strategy is backoff.Full.
+const (
+    retryBase = 250 * time.Millisecond
+    retryCap  = 2 * time.Second
+)
+
 // Provider fetches price data from SkinstableXYZ.
 // It pulls the aggregated items payload and filters by requested symbols.
 type Provider struct {
@@ -34,7 +72,7 @@ type Provider struct {
     client *httpx.Client
 
     // cached full items payload
-    cache   map[string]siteCache // key: site -> items + expiry
+    cache   map[string]siteCache // key: app id + site -> items + expiry
     cacheMu sync.RWMutex
 
     // coalesce concurrent refreshes per-site
@@ -44,24 +82,28 @@ type Provider struct {
 func New(cfg Config, hc *httpx.Client) *Provider {
     if cfg.Name == "" { cfg.Name = "SkinstableXYZ" }
     if cfg.Currency == "" { cfg.Currency = "USD" }
+    if cfg.SiteTimeoutSeconds <= 0 { cfg.SiteTimeoutSeconds = 7 }
+    if cfg.AppID == 0 { cfg.AppID = 730 }
+    if len(cfg.Sites) == 0 { cfg.Sites = []string{"CS.MONEY"} }
+    if len(cfg.AppIDs) == 0 { cfg.AppIDs = []int{cfg.AppID} }
+    cfg.Method = strings.ToUpper(cfg.Method)
+    if cfg.Method == "" { cfg.Method = http.MethodGet }
     return &Provider{cfg: cfg, client: hc}
 }
 
 func (p *Provider) Name() string { return p.cfg.Name }
 
 func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
-    // a) Config guards
+    // a) Config guard; defaults are applied by New, so cfg is only read here
     if p.cfg.URL == "" {
         return nil, fmt.Errorf("skinstable: missing URL")
     }
-    if p.cfg.AppID == 0 {
-        p.cfg.AppID = 730
-    }
-    if len(p.cfg.Sites) == 0 {
-        p.cfg.Sites = []string{"CS.MONEY"}
-    }
+
+    sites := p.selectSites(ctx)
+    if len(sites) == 0 { return []provider.Quote{}, nil }
 
     now := time.Now()
+    grace := time.Duration(p.cfg.StaleGraceSeconds) * time.Second
 
     // b) Lazy init cache under write lock
     p.cacheMu.Lock()
@@ -70,45 +112,48 @@ func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     }
     p.cacheMu.Unlock()
 
-    // c) Double-checked refresh per site
+    // c) Double-checked refresh per app id and site
     var anyValid bool
     var lastErr error
-    for _, site := range p.cfg.Sites {
-        // Read snapshot of current entry
-        p.cacheMu.RLock()
-        sc, ok := p.cache[site]
-        expired := !ok || now.After(sc.until)
-        if ok && !expired {
-            anyValid = true
-        }
-        p.cacheMu.RUnlock()
-
-        if expired {
-            // Coalesce refreshes per-site to avoid duplicate upstream calls
-            type result struct {
-                items map[string]item
-                until time.Time
+    for _, appID := range p.cfg.AppIDs {
+        for _, site := range sites {
+            key := cacheKey(appID, site)
+            // Read snapshot of current entry
+            p.cacheMu.RLock()
+            sc, ok := p.cache[key]
+            expired := !ok || now.After(sc.until)
+            if ok && !expired {
+                anyValid = true
             }
-            v, err, _ := p.sf.Do(site, func() (any, error) {
-                perSiteCtx, cancel := context.WithTimeout(ctx, 7*time.Second)
-                defer cancel()
-                items, until, err := p.fetchSite(perSiteCtx, site)
-                if err != nil { return nil, err }
-                return result{items: items, until: until}, nil
-            })
-            if err != nil {
-                // Record last error; continue to check other sites
-                lastErr = err
-            } else {
-                res := v.(result)
-                // Write new snapshot if still expired/missing (use fresh time)
-                p.cacheMu.Lock()
-                sc2, ok2 := p.cache[site]
-                if !ok2 || time.Now().After(sc2.until) {
-                    p.cache[site] = siteCache{items: res.items, until: res.until}
+            p.cacheMu.RUnlock()
+
+            if expired {
+                // Coalesce refreshes per-site to avoid duplicate upstream calls
+                type result struct {
+                    items map[string]item
+                    until time.Time
+                }
+                v, err, _ := p.sf.Do(key, func() (any, error) {
+                    items, until, err := p.refreshSite(ctx, appID, site)
+                    if err != nil { return nil, err }
+                    return result{items: items, until: until}, nil
+                })
+                if err != nil {
+                    // Record last error; continue to check other sites. The
+                    // previous payload still counts while within the grace window.
+                    lastErr = err
+                    if ok && !now.After(sc.until.Add(grace)) { anyValid = true }
+                } else {
+                    res := v.(result)
+                    // Write new snapshot if still expired/missing (use fresh time)
+                    p.cacheMu.Lock()
+                    sc2, ok2 := p.cache[key]
+                    if !ok2 || time.Now().After(sc2.until) {
+                        p.cache[key] = siteCache{items: res.items, until: res.until}
+                    }
+                    p.cacheMu.Unlock()
+                    anyValid = true
                 }
-                p.cacheMu.Unlock()
-                anyValid = true
             }
         }
     }
@@ -117,19 +162,22 @@ func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
         if lastErr != nil {
             return nil, lastErr
         }
-        return nil, fmt.Errorf("skinstable: no data from any site")
+        return nil, &provider.Error{Kind: provider.ErrUpstream, Err: fmt.Errorf("skinstable: no data from any site")}
     }
 
     // d) Snapshot caches for lock-free reads
     type siteSnapshot struct {
-        site string
-        sc   siteCache
+        appID int
+        site  string
+        sc    siteCache
     }
-    snaps := make([]siteSnapshot, 0, len(p.cfg.Sites))
+    snaps := make([]siteSnapshot, 0, len(p.cfg.AppIDs)*len(sites))
     p.cacheMu.RLock()
-    for _, site := range p.cfg.Sites {
-        if sc, ok := p.cache[site]; ok {
-            snaps = append(snaps, siteSnapshot{site: site, sc: sc})
+    for _, appID := range p.cfg.AppIDs {
+        for _, site := range sites {
+            if sc, ok := p.cache[cacheKey(appID, site)]; ok && !now.After(sc.until.Add(grace)) {
+                snaps = append(snaps, siteSnapshot{appID: appID, site: site, sc: sc})
+            }
         }
     }
     p.cacheMu.RUnlock()
@@ -138,52 +186,183 @@ func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     for _, snap := range snaps {
         for _, s := range symbols {
             it, ok := snap.sc.items[s]
-            if !ok || it.P == nil {
-                continue
-            }
+            if !ok { continue }
             ts := parseEpochMaybeMillis(it.T, now)
-            out = append(out, provider.Quote{
-                Symbol:     s,
-                Price:      formatFloat(*it.P),
-                Currency:   p.cfg.Currency,
-                Source:     fmt.Sprintf("%s:%s", p.cfg.Name, snap.site),
-                ReceivedAt: ts,
-            })
+            if it.P != nil {
+                if v, err := strconv.ParseFloat(string(*it.P), 64); err == nil {
+                    price := formatFloat(v)
+                    out = append(out, provider.Quote{
+                        Symbol:     s,
+                        Price:      price,
+                        Amount:     money.Of(price),
+                        Currency:   p.cfg.Currency,
+                        Source:     fmt.Sprintf("%s:%s", p.cfg.Name, snap.site),
+                        Provider:   p.cfg.Name,
+                        ReceivedAt: ts,
+                        AppID:      snap.appID,
+                    })
+                }
+            }
+            if p.cfg.IncludeBids && it.B != nil {
+                if v, err := strconv.ParseFloat(string(*it.B), 64); err == nil && v > 0 {
+                    bid := formatFloat(v)
+                    out = append(out, provider.Quote{
+                        Symbol:     s,
+                        Price:      bid,
+                        Amount:     money.Of(bid),
+                        Currency:   p.cfg.Currency,
+                        Source:     fmt.Sprintf("%s:%s:bid", p.cfg.Name, snap.site),
+                        Provider:   p.cfg.Name,
+                        ReceivedAt: ts,
+                        AppID:      snap.appID,
+                    })
+                }
+            }
         }
     }
     return out, nil
 }
 
+type sitesKey struct{}
+
+// WithSites returns a context asking the provider to refresh and serve only
+// these sites (case-insensitive) out of the configured ones; unconfigured
+// names are ignored. Empty sites leaves ctx unchanged.
+func WithSites(ctx context.Context, sites []string) context.Context {
+    if len(sites) == 0 { return ctx }
+    return context.WithValue(ctx, sitesKey{}, sites)
+}
+
+// selectSites returns the configured sites narrowed by WithSites, in
+// configured order and spelling.
+func (p *Provider) selectSites(ctx context.Context) []string {
+    want, _ := ctx.Value(sitesKey{}).([]string)
+    if len(want) == 0 { return p.cfg.Sites }
+    out := make([]string, 0, len(want))
+    for _, site := range p.cfg.Sites {
+        for _, w := range want {
+            if strings.EqualFold(strings.TrimSpace(w), site) { out = append(out, site); break }
+        }
+    }
+    return out
+}
+
+// InSites reports for a quote of this provider whether it came from one of
+// sites (case-insensitive), by its "<Name>:<site>[:bid]" source.
+func InSites(sites []string) func(provider.Quote) bool {
+    return func(q provider.Quote) bool {
+        parts := strings.SplitN(q.Source, ":", 3)
+        if len(parts) < 2 { return false }
+        for _, s := range sites {
+            if strings.EqualFold(strings.TrimSpace(s), parts[1]) { return true }
+        }
+        return false
+    }
+}
+
 type siteCache struct {
     items map[string]item
     until time.Time
 }
 
-func (p *Provider) fetchSite(ctx context.Context, site string) (map[string]item, time.Time, error) {
+// cacheKey separates cached payloads per app id so games never collide.
+func cacheKey(appID int, site string) string { return strconv.Itoa(appID) + "|" + site }
+
+// refreshSite runs fetchSite under the per-site timeout, retrying retryable
+// failures up to SiteRetries times. Backoff waits are bounded by ctx only.
+func (p *Provider) refreshSite(ctx context.Context, appID int, site string) (map[string]item, time.Time, error) {
+    timeout := time.Duration(p.cfg.SiteTimeoutSeconds) * time.Second
+    attempt := func() (map[string]item, time.Time, error) {
+        siteCtx, cancel := context.WithTimeout(ctx, timeout)
+        defer cancel()
+        return p.fetchSite(siteCtx, appID, site)
+    }
+    items, until, err := attempt()
+    if err == nil || p.cfg.SiteRetries <= 0 { return items, until, err }
+    b, _ := backoff.New(backoff.Full, retryBase, retryCap)
+    for i := 0; i < p.cfg.SiteRetries && provider.IsRetryable(err); i++ {
+        t := time.NewTimer(b.Next(i))
+        select {
+        case <-ctx.Done():
+            t.Stop()
+            return nil, time.Time{}, err
+        case <-t.C:
+        }
+        items, until, err = attempt()
+        if err == nil { return items, until, nil }
+    }
+    return nil, time.Time{}, err
+}
+
+func (p *Provider) fetchSite(ctx context.Context, appID int, site string) (map[string]item, time.Time, error) {
+    items := make(map[string]item)
+    offset, cursor := 0, ""
+    for page := 0; ; page++ {
+        if page >= maxPages { return nil, time.Time{}, fmt.Errorf("skinstable: %s exceeded %d pages", site, maxPages) }
+        body, err := p.fetchPage(ctx, appID, site, offset, cursor)
+        if err != nil { return nil, time.Time{}, err }
+        for k, v := range body.Items { items[k] = v }
+        switch {
+        case body.Next != "":
+            cursor = body.Next
+        case p.cfg.PageSize > 0 && len(body.Items) >= p.cfg.PageSize:
+            offset += len(body.Items)
+        default:
+            ttl := time.Duration(p.cfg.ItemsCacheTTLSeconds) * time.Second
+            if ttl <= 0 { ttl = 10 * time.Second }
+            return items, time.Now().Add(ttl), nil
+        }
+    }
+}
+
+// fetchPage requests one page of a site's items. Without PageSize or a cursor
+// it is the single full-payload request.
+func (p *Provider) fetchPage(ctx context.Context, appID int, site string, offset int, cursor string) (apiResponse, error) {
+    var body apiResponse
     u, err := url.Parse(p.cfg.URL)
-    if err != nil { return nil, time.Time{}, err }
-    q := u.Query()
-    if p.cfg.APIKey != "" { q.Set("apikey", p.cfg.APIKey) }
-    if p.cfg.AppID > 0 { q.Set("app", fmt.Sprintf("%d", p.cfg.AppID)) }
-    if site != "" { q.Set("site", site) }
-    u.RawQuery = q.Encode()
-
-    req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
-    if err != nil { return nil, time.Time{}, err }
+    if err != nil { return body, err }
+    params := make(map[string]any)
+    set := func(name string, v any) {
+        if n := p.cfg.ParamNames[name]; n != "" { name = n }
+        params[name] = v
+    }
+    if p.cfg.APIKey != "" { set("apikey", p.cfg.APIKey) }
+    if appID > 0 { set("app", appID) }
+    if site != "" { set("site", site) }
+    if p.cfg.PageSize > 0 {
+        set("limit", p.cfg.PageSize)
+        set("offset", offset)
+    }
+    if cursor != "" { set("cursor", cursor) }
+
+    var reqBody io.Reader = http.NoBody
+    if p.cfg.Method == http.MethodGet {
+        q := u.Query()
+        for k, v := range params { q.Set(k, fmt.Sprint(v)) }
+        u.RawQuery = q.Encode()
+    } else {
+        b, err := json.Marshal(params)
+        if err != nil { return body, err }
+        reqBody = bytes.NewReader(b)
+    }
+
+    req, err := http.NewRequestWithContext(ctx, p.cfg.Method, u.String(), reqBody)
+    if err != nil { return body, err }
     for k, v := range p.cfg.Headers { req.Header.Set(k, v) }
     req.Header.Set("Accept", "application/json")
+    if reqBody != http.NoBody { req.Header.Set("Content-Type", "application/json") }
     resp, err := p.client.Do(ctx, req)
-    if err != nil { return nil, time.Time{}, err }
+    if err != nil { return body, provider.TransportError(err) }
     defer resp.Body.Close()
     if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-        return nil, time.Time{}, fmt.Errorf("GET %s -> %d", u.String(), resp.StatusCode)
+        return body, provider.StatusError(resp.StatusCode, fmt.Errorf("%s %s -> %d", p.cfg.Method, u.String(), resp.StatusCode))
     }
-    var body apiResponse
-    dec := json.NewDecoder(resp.Body)
-    if err := dec.Decode(&body); err != nil { return nil, time.Time{}, fmt.Errorf("decode: %w", err) }
-    ttl := time.Duration(p.cfg.ItemsCacheTTLSeconds) * time.Second
-    if ttl <= 0 { ttl = 10 * time.Second }
-    return body.Items, time.Now().Add(ttl), nil
+    // an HTML error page with 200 is retryable, not a decode failure
+    jsonBody, err := provider.JSONBody(resp)
+    if err != nil { return body, err }
+    dec := json.NewDecoder(jsonBody)
+    if err := dec.Decode(&body); err != nil { return body, &provider.Error{Kind: provider.ErrUpstream, Err: fmt.Errorf("decode: %w", err)} }
+    return body, nil
 }
 
 // Response model based on the provided sample.
@@ -191,11 +370,16 @@ type apiResponse struct {
     Items     map[string]item `json:"items"`
     Time      int             `json:"time"`
     Requests  int             `json:"requests"`
+    // Next is an optional pagination cursor; empty on the last page.
+    Next      string          `json:"next"`
 }
 
 type item struct {
-    P *float64 `json:"p"`
-    T int64    `json:"t"`
+    // P accepts a number or a locale-formatted string ("1,50").
+    P *provider.Number `json:"p"`
+    // B is the highest buy order, when the upstream reports one.
+    B *provider.Number `json:"b"`
+    T int64            `json:"t"`
 }
 
 func formatFloat(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
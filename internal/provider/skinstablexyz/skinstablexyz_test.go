@@ -0,0 +1,285 @@
+package skinstablexyz
+
+import (
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "slices"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/httpx"
+    "priceprovider/internal/provider"
+)
+
+func TestFetch_MultipleAppIDs_DoNotCollide(t *testing.T) {
+    sym := "Shared Name"
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        price := map[string]string{"730": "1.5", "440": "3"}[r.URL.Query().Get("app")]
+        fmt.Fprintf(w, `{"items":{%q:{"p":%s,"t":1735787045}}}`, sym, price)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, AppIDs: []int{730, 440}, Sites: []string{"CS.MONEY"}, ItemsCacheTTLSeconds: 60}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{sym})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 { t.Fatalf("want 2 quotes, got %d: %+v", len(qs), qs) }
+    byApp := map[int]string{}
+    for _, q := range qs { byApp[q.AppID] = q.Price }
+    if byApp[730] != "1.5" || byApp[440] != "3" { t.Fatalf("prices mixed across apps: %+v", qs) }
+    for _, q := range qs { if q.Provider != "SkinstableXYZ" { t.Fatalf("provider=%q, want default name", q.Provider) } }
+}
+
+func TestNew_DefaultsAppAndSites(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if r.URL.Query().Get("app") != "730" || r.URL.Query().Get("site") != "CS.MONEY" { t.Errorf("unexpected query %s", r.URL.RawQuery) }
+        fmt.Fprint(w, `{"items":{"A":{"p":1,"t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, ItemsCacheTTLSeconds: 60}, httpx.New(5*time.Second))
+    if p.cfg.AppID != 730 || !slices.Equal(p.cfg.AppIDs, []int{730}) || !slices.Equal(p.cfg.Sites, []string{"CS.MONEY"}) { t.Fatalf("unexpected defaults %+v", p.cfg) }
+    // concurrent first calls only read cfg (run with -race)
+    var wg sync.WaitGroup
+    for range 4 {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if qs, err := p.Fetch(t.Context(), []string{"A"}); err != nil || len(qs) != 1 || qs[0].AppID != 730 { t.Errorf("fetch: %+v %v", qs, err) }
+        }()
+    }
+    wg.Wait()
+}
+
+func TestFetch_CommaDecimalPrice(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{"items":{"A":{"p":"1,50","t":1735787045},"B":{"p":"1,234.56","t":1735787045},"C":{"p":"n/a","t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{"A", "B", "C"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    got := map[string]string{}
+    for _, q := range qs { got[q.Symbol] = q.Price }
+    if len(got) != 2 || got["A"] != "1.5" || got["B"] != "1234.56" { t.Fatalf("unexpected prices: %v", got) }
+}
+
+func TestFetch_PaginatedSiteMergesAllPages(t *testing.T) {
+    names := []string{"A", "B", "C", "D", "E"}
+    var pages atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        pages.Add(1)
+        offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+        limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+        end := min(offset+limit, len(names))
+        parts := make([]string, 0, limit)
+        for i := offset; i < end; i++ { parts = append(parts, fmt.Sprintf(`%q:{"p":%d,"t":1735787045}`, names[i], i+1)) }
+        fmt.Fprintf(w, `{"items":{%s}}`, strings.Join(parts, ","))
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}, PageSize: 2}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), names)
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != len(names) { t.Fatalf("want %d quotes from merged pages, got %+v", len(names), qs) }
+    if n := pages.Load(); n != 3 { t.Fatalf("want 3 page requests, got %d", n) }
+}
+
+func TestFetch_FollowsNextCursor(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        switch r.URL.Query().Get("cursor") {
+        case "":
+            fmt.Fprint(w, `{"items":{"A":{"p":1,"t":1735787045}},"next":"c2"}`)
+        case "c2":
+            fmt.Fprint(w, `{"items":{"B":{"p":2,"t":1735787045}}}`)
+        default:
+            http.Error(w, "bad cursor", http.StatusBadRequest)
+        }
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{"A", "B"})
+    if err != nil || len(qs) != 2 { t.Fatalf("want both pages merged, got %v %+v", err, qs) }
+}
+
+func TestFetch_IncludeBids(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{"items":{"A":{"p":10.5,"b":"9,75","t":1735787045},"B":{"p":3,"t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    for _, include := range []bool{false, true} {
+        p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}, IncludeBids: include}, httpx.New(5*time.Second))
+        qs, err := p.Fetch(t.Context(), []string{"A", "B"})
+        if err != nil { t.Fatalf("fetch: %v", err) }
+        got := map[string]string{}
+        for _, q := range qs { got[q.Symbol+" "+q.Source] = q.Price }
+        if got["A SkinstableXYZ:CS.MONEY"] != "10.5" || got["B SkinstableXYZ:CS.MONEY"] != "3" { t.Fatalf("missing sell quotes: %v", got) }
+        want := 2
+        if include {
+            want = 3
+            if got["A SkinstableXYZ:CS.MONEY:bid"] != "9.75" { t.Fatalf("missing bid quote: %v", got) }
+        }
+        if len(qs) != want { t.Fatalf("include=%v: want %d quotes, got %v", include, want, got) }
+    }
+}
+
+func TestFetch_HTMLPageWith200IsRetryable(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        // no HTML content type: the leading '<' alone marks it as non-JSON
+        w.Header().Set("Content-Type", "application/json")
+        fmt.Fprint(w, "\n<!DOCTYPE html><title>Just a moment...</title>")
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}}, httpx.New(5*time.Second))
+    _, err := p.Fetch(t.Context(), []string{"A"})
+    if err == nil || !provider.IsRetryable(err) { t.Fatalf("want retryable error, got %v", err) }
+}
+
+func TestFetch_RetriesTransientSiteFailure(t *testing.T) {
+    var calls atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if calls.Add(1) == 1 {
+            http.Error(w, "busy", http.StatusServiceUnavailable)
+            return
+        }
+        fmt.Fprint(w, `{"items":{"A":{"p":1,"t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}, SiteRetries: 2}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{"A"})
+    if err != nil || len(qs) != 1 { t.Fatalf("want quote after retry, got %v %+v", err, qs) }
+    if n := calls.Load(); n != 2 { t.Fatalf("want 2 upstream calls, got %d", n) }
+
+    // non-retryable statuses are not retried
+    calls.Store(0)
+    bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        http.Error(w, "nope", http.StatusBadRequest)
+    }))
+    defer bad.Close()
+    p = New(Config{URL: bad.URL, Sites: []string{"CS.MONEY"}, SiteRetries: 2}, httpx.New(5*time.Second))
+    if _, err := p.Fetch(t.Context(), []string{"A"}); err == nil { t.Fatalf("want error") }
+    if n := calls.Load(); n != 1 { t.Fatalf("want 1 upstream call, got %d", n) }
+}
+
+func TestFetch_StaleGraceServesLastGoodPayload(t *testing.T) {
+    var failing atomic.Bool
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        if failing.Load() {
+            http.Error(w, "down", http.StatusBadGateway)
+            return
+        }
+        fmt.Fprint(w, `{"items":{"A":{"p":1,"t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    expire := func(p *Provider, by time.Duration) {
+        p.cacheMu.Lock()
+        for k, sc := range p.cache {
+            sc.until = time.Now().Add(-by)
+            p.cache[k] = sc
+        }
+        p.cacheMu.Unlock()
+    }
+
+    for _, tc := range []struct {
+        grace   int
+        expired time.Duration
+        serve   bool
+    }{
+        {grace: 60, expired: time.Second, serve: true},
+        {grace: 60, expired: 2 * time.Minute, serve: false},
+        {grace: 0, expired: time.Second, serve: false},
+    } {
+        failing.Store(false)
+        p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY"}, StaleGraceSeconds: tc.grace}, httpx.New(5*time.Second))
+        if _, err := p.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("warm: %v", err) }
+
+        failing.Store(true)
+        expire(p, tc.expired)
+        qs, err := p.Fetch(t.Context(), []string{"A"})
+        if tc.serve {
+            if err != nil || len(qs) != 1 || qs[0].Price != "1" { t.Fatalf("%+v: want stale quote, got %v %+v", tc, err, qs) }
+        } else if err == nil {
+            t.Fatalf("%+v: want error past grace, got %+v", tc, qs)
+        }
+    }
+}
+
+func TestFetch_RequestTemplate(t *testing.T) {
+    type seen struct {
+        method, contentType string
+        query               map[string]string
+        body                map[string]any
+    }
+    var got seen
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        got = seen{method: r.Method, contentType: r.Header.Get("Content-Type"), query: map[string]string{}}
+        for k := range r.URL.Query() { got.query[k] = r.URL.Query().Get(k) }
+        if r.Method == http.MethodPost {
+            if err := json.NewDecoder(r.Body).Decode(&got.body); err != nil { t.Errorf("decode body: %v", err) }
+        }
+        fmt.Fprint(w, `{"items":{"A":{"p":2.5,"t":1735787045}}}`)
+    }))
+    defer srv.Close()
+
+    // GET (default): parameters in the query string under their default names
+    p := New(Config{URL: srv.URL + "?v=2", APIKey: "k", AppID: 730, Sites: []string{"CS.MONEY"}}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{"A"})
+    if err != nil || len(qs) != 1 || qs[0].Price != "2.5" { t.Fatalf("get: %v %+v", err, qs) }
+    if got.method != http.MethodGet || got.query["apikey"] != "k" || got.query["app"] != "730" || got.query["site"] != "CS.MONEY" || got.query["v"] != "2" {
+        t.Fatalf("unexpected GET request: %+v", got)
+    }
+
+    // POST: renamed parameters in a JSON body; the URL keeps only its own query
+    p = New(Config{
+        URL: srv.URL + "?v=2", APIKey: "k", AppID: 730, Sites: []string{"CS.MONEY"}, PageSize: 50,
+        Method:     "post",
+        ParamNames: map[string]string{"apikey": "token", "app": "appid", "site": "market"},
+    }, httpx.New(5*time.Second))
+    qs, err = p.Fetch(t.Context(), []string{"A"})
+    if err != nil || len(qs) != 1 || qs[0].Price != "2.5" { t.Fatalf("post: %v %+v", err, qs) }
+    if got.method != http.MethodPost || got.contentType != "application/json" || len(got.query) != 1 { t.Fatalf("unexpected POST request: %+v", got) }
+    want := map[string]any{"token": "k", "appid": 730.0, "market": "CS.MONEY", "limit": 50.0, "offset": 0.0}
+    if len(got.body) != len(want) { t.Fatalf("body=%v, want %v", got.body, want) }
+    for k, v := range want {
+        if got.body[k] != v { t.Fatalf("body[%s]=%v, want %v (%v)", k, got.body[k], v, got.body) }
+    }
+}
+
+func TestFetch_WithSitesServesOnlyThoseSites(t *testing.T) {
+    var mu sync.Mutex
+    requested := map[string]int{}
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        site := r.URL.Query().Get("site")
+        mu.Lock()
+        requested[site]++
+        mu.Unlock()
+        fmt.Fprintf(w, `{"items":{"A":{"p":%d,"t":1735787045}}}`, len(site))
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, Sites: []string{"CS.MONEY", "BUFF.163", "SKINPORT"}, ItemsCacheTTLSeconds: 60}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(WithSites(t.Context(), []string{"buff.163", "CS.MONEY", "unknown"}), []string{"A"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 || qs[0].Source != "SkinstableXYZ:CS.MONEY" || qs[1].Source != "SkinstableXYZ:BUFF.163" { t.Fatalf("want CS.MONEY and BUFF.163 quotes, got %+v", qs) }
+    if len(requested) != 2 || requested["SKINPORT"] != 0 { t.Fatalf("want only the selected sites refreshed, got %v", requested) }
+
+    keep := InSites([]string{"buff.163"})
+    if !keep(qs[1]) || keep(qs[0]) { t.Fatalf("InSites matched the wrong quotes: %+v", qs) }
+
+    qs, err = p.Fetch(WithSites(t.Context(), []string{"unknown"}), []string{"A"})
+    if err != nil || qs == nil || len(qs) != 0 { t.Fatalf("want an empty answer for unconfigured sites, got %+v, %v", qs, err) }
+
+    if qs, err = p.Fetch(t.Context(), []string{"A"}); err != nil || len(qs) != 3 { t.Fatalf("want every site without a selection, got %+v, %v", qs, err) }
+}
@@ -4,21 +4,30 @@ import (
     "bytes"
     "context"
     "encoding/json"
+    "errors"
     "fmt"
     "io"
+    "log"
     "net/http"
     "sort"
     "strings"
     "time"
 
     "priceprovider/internal/httpx"
+    "priceprovider/internal/money"
     "priceprovider/internal/provider"
+    "priceprovider/internal/provider/timing"
     "sync"
+    "sync/atomic"
 )
 
 type Config struct {
     Name        string
     URL         string
+    // URLs lists equivalent endpoints (e.g. regional mirrors) used instead
+    // of URL. A batch that fails with a connection error or 5xx is retried on
+    // the next one, and the endpoint that answered is preferred afterwards.
+    URLs        []string
     Method      string
     Headers     map[string]string
     Currency    string
@@ -27,14 +36,42 @@ type Config struct {
     // MaxItemsPerRequest splits large symbol lists into smaller batch API requests.
     // 0 or negative means no limit (single request).
     MaxItemsPerRequest int
-    // MaxConcurrency limits concurrent batch requests when splitting.
-    // Defaults to 1 when <= 0.
+    // MaxConcurrency limits concurrent SteamDT requests across all callers of
+    // one Provider (not per Fetch). Defaults to 1 when <= 0.
     MaxConcurrency int
+    // BatchMemoTTL memoizes whole batch responses keyed by the sorted key set,
+    // so identical batches within the TTL skip the network. 0 disables.
+    BatchMemoTTL time.Duration
+    // MinBatchTime is the least time that must remain before the context
+    // deadline for a new batch to be started. Batches that would start later
+    // are skipped and reported as deadline errors instead of firing doomed
+    // requests. 0 only skips once the deadline has passed.
+    MinBatchTime time.Duration
+    // StrictErrors fails a batch whose response has success:false with an
+    // errorCode or errorMsg even when it carries data. By default that data
+    // is used and the error is logged and reported as a warning.
+    StrictErrors bool
+    // IncludePlatformItemID sets Quote.ExternalID to the listing's
+    // platformItemId, so clients can link to the marketplace item.
+    IncludePlatformItemID bool
 }
 
 type Provider struct {
     cfg    Config
     client *httpx.Client
+    // sem bounds in-flight upstream requests for the whole provider instance.
+    sem    chan struct{}
+    // preferred indexes cfg.URLs: the endpoint that last answered.
+    preferred atomic.Int32
+
+    // memo of recent batch responses keyed by sorted marketHashNames
+    memoMu sync.Mutex
+    memo   map[string]batchMemo
+}
+
+type batchMemo struct {
+    data  []entry
+    until time.Time
 }
 
 func New(cfg Config, hc *httpx.Client) *Provider {
@@ -42,7 +79,9 @@ func New(cfg Config, hc *httpx.Client) *Provider {
     if cfg.URL == "" { cfg.URL = "https://open.steamdt.com/open/cs2/v1/price/batch" }
     if cfg.Method == "" { cfg.Method = http.MethodPost }
     if cfg.Currency == "" { cfg.Currency = "CNY" }
-    return &Provider{cfg: cfg, client: hc}
+    if cfg.MaxConcurrency <= 0 { cfg.MaxConcurrency = 1 }
+    if len(cfg.URLs) == 0 { cfg.URLs = []string{cfg.URL} }
+    return &Provider{cfg: cfg, client: hc, sem: make(chan struct{}, cfg.MaxConcurrency)}
 }
 
 func (p *Provider) Name() string { return p.cfg.Name }
@@ -65,60 +104,65 @@ func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     // perform one or more batch requests as needed
     byMarketAll := make(map[string]entry, len(uniqKeys))
     var firstErr error
+    failedKeys := make(map[string]struct{})
+    var mu sync.Mutex // guards byMarketAll, firstErr and failedKeys across batches
+    store := func(data []entry) {
+        mu.Lock()
+        for _, e := range data { byMarketAll[e.MarketHashName] = e }
+        mu.Unlock()
+    }
 
     doBatch := func(ctx context.Context, keys []string) error {
+        memoKey := ""
+        if p.cfg.BatchMemoTTL > 0 {
+            memoKey = batchKey(keys)
+            if data, ok := p.memoGet(memoKey); ok {
+                store(data)
+                return nil
+            }
+        }
+        // check before and after waiting for a slot: the wait itself may eat the budget
+        if p.tooLate(ctx) {
+            return fmt.Errorf("steamdt: skipped batch of %d near deadline: %w", len(keys), context.DeadlineExceeded)
+        }
+        select {
+        case p.sem <- struct{}{}:
+            defer func() { <-p.sem }()
+        case <-ctx.Done():
+            return fmt.Errorf("steamdt: skipped batch of %d: %w", len(keys), ctx.Err())
+        }
+        if p.tooLate(ctx) {
+            return fmt.Errorf("steamdt: skipped batch of %d near deadline: %w", len(keys), context.DeadlineExceeded)
+        }
         payload := map[string]any{"marketHashNames": keys}
         body, _ := json.Marshal(payload)
-        req, err := http.NewRequestWithContext(ctx, p.cfg.Method, p.cfg.URL, bytes.NewReader(body))
+        data, err := p.postFailover(ctx, body)
         if err != nil { return err }
-        req.Header.Set("Content-Type", "application/json")
-        for k, v := range p.cfg.Headers { req.Header.Set(k, v) }
-        resp, err := p.client.Do(ctx, req)
-        if err != nil { return err }
-        defer resp.Body.Close()
-        if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-            b, _ := io.ReadAll(io.LimitReader(resp.Body, 2<<10))
-            return fmt.Errorf("%s %s -> %d: %s", p.cfg.Method, p.cfg.URL, resp.StatusCode, string(b))
-        }
-        dec := json.NewDecoder(resp.Body)
-        dec.UseNumber()
-        var api apiResponse
-        if err := dec.Decode(&api); err != nil { return fmt.Errorf("decode: %w", err) }
-        if !api.Success && (api.ErrorCode != 0 || strings.TrimSpace(api.ErrorMsg) != "") && len(api.Data) == 0 {
-            return fmt.Errorf("provider error: code=%d msg=%q", api.ErrorCode, api.ErrorMsg)
-        }
-        for _, e := range api.Data { byMarketAll[e.MarketHashName] = e }
+        store(data)
+        if memoKey != "" { p.memoPut(memoKey, data) }
         return nil
     }
 
     batchSize := p.cfg.MaxItemsPerRequest
     if batchSize <= 0 || len(uniqKeys) <= batchSize {
         // single request path
-        if err := doBatch(ctx, uniqKeys); err != nil { firstErr = err }
+        if err := doBatch(ctx, uniqKeys); err != nil { return nil, err }
     } else {
-        // concurrent batched requests with a limit
+        // batched requests; p.sem caps how many run at once across all callers
         batches := chunkStrings(uniqKeys, batchSize)
-        maxConc := p.cfg.MaxConcurrency
-        if maxConc <= 0 { maxConc = 1 }
-        sem := make(chan struct{}, maxConc)
         var wg sync.WaitGroup
-        var mu sync.Mutex
+        recordErr := func(keys []string, err error) {
+            mu.Lock()
+            if firstErr == nil { firstErr = err }
+            for _, k := range keys { failedKeys[k] = struct{}{} }
+            mu.Unlock()
+        }
         for _, b := range batches {
             b := b
             wg.Add(1)
             go func() {
                 defer wg.Done()
-                select {
-                case sem <- struct{}{}:
-                    defer func() { <-sem }()
-                case <-ctx.Done():
-                    return
-                }
-                if err := doBatch(ctx, b); err != nil {
-                    mu.Lock()
-                    if firstErr == nil { firstErr = err }
-                    mu.Unlock()
-                }
+                if err := doBatch(ctx, b); err != nil { recordErr(b, err) }
             }()
         }
         wg.Wait()
@@ -129,33 +173,162 @@ func (p *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quot
     for _, aggSym := range symbols {
         provKey := keyByAgg[aggSym]
         if e, ok := byMarketAll[provKey]; ok {
-            cs := collectCandidates(e.DataList, now)
-            for _, c := range cs {
-                out = append(out, provider.Quote{
-                    Symbol:     aggSym,
-                    Price:      c.sell,
-                    Currency:   p.cfg.Currency,
-                    Source:     fmt.Sprintf("%s:%s:sell", p.cfg.Name, c.platform),
-                    ReceivedAt: c.ts,
-                })
-                if p.cfg.IncludeBids && c.bid != "" && c.bid != "0" && c.bid != "0.0" {
-                    out = append(out, provider.Quote{
-                        Symbol:     aggSym,
-                        Price:      c.bid,
-                        Currency:   p.cfg.Currency,
-                        Source:     fmt.Sprintf("%s:%s:bid", p.cfg.Name, c.platform),
-                        ReceivedAt: c.ts,
-                    })
-                }
-            }
+            out = p.appendQuotes(out, aggSym, e, now)
+        }
+    }
+    if firstErr != nil {
+        if len(out) == 0 { return nil, firstErr }
+        // completed batches still count; name the symbols of the failed ones
+        var failed []string
+        for _, aggSym := range symbols {
+            if _, bad := failedKeys[keyByAgg[aggSym]]; bad { failed = append(failed, aggSym) }
+        }
+        return out, &provider.PartialError{Symbols: failed, Err: firstErr}
+    }
+    return out, nil
+}
+
+// postFailover sends one batch to the preferred endpoint and, when it fails
+// with a connection error or 5xx, to the remaining ones in order. The first
+// endpoint that answers becomes the preferred one.
+func (p *Provider) postFailover(ctx context.Context, body []byte) ([]entry, error) {
+    urls := p.cfg.URLs
+    start := int(p.preferred.Load())
+    var err error
+    for i := range urls {
+        idx := (start + i) % len(urls)
+        var data []entry
+        if data, err = p.post(ctx, urls[idx], body); err == nil {
+            if idx != start { p.preferred.Store(int32(idx)) }
+            return data, nil
+        }
+        if ctx.Err() != nil || !endpointDown(err) { return nil, err }
+    }
+    return nil, err
+}
+
+// endpointDown reports whether err means the endpoint itself failed (no
+// response, or a 5xx), so another endpoint may do better.
+func endpointDown(err error) bool {
+    var pe *provider.Error
+    if !errors.As(err, &pe) { return false }
+    if pe.Status == 0 { return errors.Is(err, provider.ErrUpstream) || errors.Is(err, provider.ErrTimeout) }
+    return pe.Status >= 500
+}
+
+func (p *Provider) post(ctx context.Context, url string, body []byte) ([]entry, error) {
+    req, err := http.NewRequestWithContext(ctx, p.cfg.Method, url, bytes.NewReader(body))
+    if err != nil { return nil, err }
+    req.Header.Set("Content-Type", "application/json")
+    for k, v := range p.cfg.Headers { req.Header.Set(k, v) }
+    resp, err := p.client.Do(ctx, req)
+    if err != nil { return nil, provider.TransportError(err) }
+    defer resp.Body.Close()
+    if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+        b, _ := io.ReadAll(io.LimitReader(resp.Body, 2<<10))
+        return nil, provider.StatusError(resp.StatusCode, fmt.Errorf("%s %s -> %d: %s", p.cfg.Method, url, resp.StatusCode, string(b)))
+    }
+    // an HTML error page with 200 is retryable, not a decode failure
+    jsonBody, err := provider.JSONBody(resp)
+    if err != nil { return nil, err }
+    dec := json.NewDecoder(jsonBody)
+    dec.UseNumber()
+    var api apiResponse
+    if err := dec.Decode(&api); err != nil { return nil, &provider.Error{Kind: provider.ErrUpstream, Err: fmt.Errorf("decode: %w", err)} }
+    if err := api.failure(); err != nil {
+        if p.cfg.StrictErrors || len(api.Data) == 0 { return nil, &provider.Error{Kind: provider.ErrUpstream, Err: err} }
+        // partial success: keep the data, report what was rejected
+        log.Printf("steamdt: %s: %v (using %d items)", p.cfg.Name, err, len(api.Data))
+        if r := timing.FromContext(ctx); r != nil { r.Warn(p.cfg.Name, err) }
+    }
+    return api.Data, nil
+}
+
+// appendQuotes adds the sell (and, with IncludeBids, bid) quotes of one entry
+// under symbol sym.
+func (p *Provider) appendQuotes(out []provider.Quote, sym string, e entry, now time.Time) []provider.Quote {
+    for _, c := range collectCandidates(e.DataList, now) {
+        externalID := ""
+        if p.cfg.IncludePlatformItemID { externalID = c.itemID }
+        out = append(out, provider.Quote{
+            Symbol:     sym,
+            Price:      c.sell,
+            Amount:     money.Of(c.sell),
+            Currency:   p.cfg.Currency,
+            Source:     fmt.Sprintf("%s:%s:sell", p.cfg.Name, c.platform),
+            Provider:   p.cfg.Name,
+            ReceivedAt: c.ts,
+            Volume:     c.sellCount,
+            ExternalID: externalID,
+        })
+        if p.cfg.IncludeBids && c.bid != "" && c.bid != "0" && c.bid != "0.0" {
+            out = append(out, provider.Quote{
+                Symbol:     sym,
+                Price:      c.bid,
+                Amount:     money.Of(c.bid),
+                Currency:   p.cfg.Currency,
+                Source:     fmt.Sprintf("%s:%s:bid", p.cfg.Name, c.platform),
+                Provider:   p.cfg.Name,
+                ReceivedAt: c.ts,
+                Volume:     c.bidCount,
+                ExternalID: externalID,
+            })
         }
     }
-    if len(out) == 0 && firstErr != nil {
-        return nil, firstErr
+    return out
+}
+
+// Decode reads a batch response envelope, which is also the file format
+// cmd/steamdt_dump writes, and returns the quotes Fetch would build keyed by
+// market hash name. Items without a usable price map to an empty slice.
+func (p *Provider) Decode(r io.Reader) (map[string][]provider.Quote, error) {
+    dec := json.NewDecoder(r)
+    dec.UseNumber()
+    var api apiResponse
+    if err := dec.Decode(&api); err != nil { return nil, fmt.Errorf("decode: %w", err) }
+    if err := api.failure(); err != nil && len(api.Data) == 0 { return nil, err }
+    now := time.Now().UTC()
+    out := make(map[string][]provider.Quote, len(api.Data))
+    for _, e := range api.Data {
+        out[e.MarketHashName] = p.appendQuotes(out[e.MarketHashName], e.MarketHashName, e, now)
     }
     return out, nil
 }
 
+// tooLate reports whether ctx is done or too close to its deadline to start a batch.
+func (p *Provider) tooLate(ctx context.Context) bool {
+    if ctx.Err() != nil { return true }
+    dl, ok := ctx.Deadline()
+    return ok && time.Until(dl) <= p.cfg.MinBatchTime
+}
+
+// batchKey identifies a batch by its key set regardless of order.
+func batchKey(keys []string) string {
+    sorted := append([]string(nil), keys...)
+    sort.Strings(sorted)
+    return strings.Join(sorted, "\x00")
+}
+
+func (p *Provider) memoGet(key string) ([]entry, bool) {
+    p.memoMu.Lock()
+    defer p.memoMu.Unlock()
+    m, ok := p.memo[key]
+    if !ok || time.Now().After(m.until) { return nil, false }
+    return m.data, true
+}
+
+func (p *Provider) memoPut(key string, data []entry) {
+    now := time.Now()
+    p.memoMu.Lock()
+    defer p.memoMu.Unlock()
+    if p.memo == nil { p.memo = make(map[string]batchMemo) }
+    // drop expired batches so the memo stays small
+    for k, m := range p.memo {
+        if now.After(m.until) { delete(p.memo, k) }
+    }
+    p.memo[key] = batchMemo{data: data, until: now.Add(p.cfg.BatchMemoTTL)}
+}
+
 type entry struct {
     MarketHashName string    `json:"marketHashName"`
     DataList       []listing `json:"dataList"`
@@ -164,9 +337,10 @@ type entry struct {
 type listing struct {
     Platform       string      `json:"platform"`
     PlatformItemID string      `json:"platformItemId"`
-    SellPrice      json.Number `json:"sellPrice"`
-    SellCount      int         `json:"sellCount"`
-    BiddingPrice   json.Number `json:"biddingPrice"`
+    // Prices accept numbers or locale-formatted strings ("1,50").
+    SellPrice      provider.Number `json:"sellPrice"`
+    SellCount      int             `json:"sellCount"`
+    BiddingPrice   provider.Number `json:"biddingPrice"`
     BiddingCount   int         `json:"biddingCount"`
     UpdateTime     int64       `json:"updateTime"`
 }
@@ -180,11 +354,21 @@ type apiResponse struct {
     ErrorCodeStr string  `json:"errorCodeStr"`
 }
 
+// failure returns the error a response reports: success:false with an
+// errorCode or errorMsg. It is nil otherwise, whether or not data came along.
+func (api apiResponse) failure() error {
+    if api.Success || (api.ErrorCode == 0 && strings.TrimSpace(api.ErrorMsg) == "") { return nil }
+    return fmt.Errorf("provider error: code=%d msg=%q", api.ErrorCode, api.ErrorMsg)
+}
+
 type candidate struct {
-    platform string
-    sell     string
-    bid      string
-    ts       time.Time
+    platform  string
+    itemID    string
+    sell      string
+    bid       string
+    sellCount int
+    bidCount  int
+    ts        time.Time
 }
 
 func collectCandidates(list []listing, now time.Time) []candidate {
@@ -196,7 +380,7 @@ func collectCandidates(list []listing, now time.Time) []candidate {
             continue
         }
         ts := parseEpochMaybeMillis(d.UpdateTime, now)
-        cs = append(cs, candidate{platform: d.Platform, sell: sel, bid: bid, ts: ts})
+        cs = append(cs, candidate{platform: d.Platform, itemID: strings.TrimSpace(d.PlatformItemID), sell: sel, bid: bid, sellCount: d.SellCount, bidCount: d.BiddingCount, ts: ts})
     }
     sort.Slice(cs, func(i, j int) bool {
         if cs[i].platform == cs[j].platform {
@@ -207,7 +391,7 @@ func collectCandidates(list []listing, now time.Time) []candidate {
     return cs
 }
 
-func numToString(n json.Number) string {
+func numToString(n provider.Number) string {
     s := strings.TrimSpace(n.String())
     return s
 }
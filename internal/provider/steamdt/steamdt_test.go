@@ -0,0 +1,287 @@
+package steamdt
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "reflect"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "priceprovider/internal/httpx"
+    "priceprovider/internal/provider"
+    "priceprovider/internal/provider/timing"
+)
+
+// newTestServer answers every batch with one BUFF listing per requested name.
+func newTestServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+    t.Helper()
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        calls.Add(1)
+        var body struct{ MarketHashNames []string `json:"marketHashNames"` }
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil { t.Errorf("decode body: %v", err) }
+        data := make([]map[string]any, 0, len(body.MarketHashNames))
+        for _, n := range body.MarketHashNames {
+            data = append(data, map[string]any{
+                "marketHashName": n,
+                "dataList": []map[string]any{{"platform": "BUFF", "sellPrice": 10, "biddingPrice": 9, "updateTime": 1735787045}},
+            })
+        }
+        fmt.Fprint(w, mustJSON(t, map[string]any{"success": true, "data": data}))
+    }))
+    t.Cleanup(srv.Close)
+    return srv
+}
+
+func mustJSON(t *testing.T, v any) string {
+    t.Helper()
+    b, err := json.Marshal(v)
+    if err != nil { t.Fatalf("marshal: %v", err) }
+    return string(b)
+}
+
+func TestFetch_SetsProviderName(t *testing.T) {
+    var calls atomic.Int32
+    srv := newTestServer(t, &calls)
+    p := New(Config{Name: "SteamDT-Primary", URL: srv.URL, IncludeBids: true}, httpx.New(5*time.Second))
+
+    qs, err := p.Fetch(t.Context(), []string{"A"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 { t.Fatalf("want sell+bid quotes, got %+v", qs) }
+    for _, q := range qs {
+        if q.Provider != "SteamDT-Primary" { t.Fatalf("provider=%q, want configured name: %+v", q.Provider, q) }
+    }
+}
+
+func TestFetch_BatchMemo_IdenticalBatchSkipsNetwork(t *testing.T) {
+    var calls atomic.Int32
+    srv := newTestServer(t, &calls)
+    p := New(Config{URL: srv.URL, BatchMemoTTL: time.Minute}, httpx.New(5*time.Second))
+
+    if _, err := p.Fetch(t.Context(), []string{"A", "B"}); err != nil { t.Fatalf("fetch 1: %v", err) }
+    // same key set in a different order hits the memo
+    qs, err := p.Fetch(t.Context(), []string{"B", "A"})
+    if err != nil { t.Fatalf("fetch 2: %v", err) }
+    if n := calls.Load(); n != 1 { t.Fatalf("want 1 upstream call, got %d", n) }
+    if len(qs) != 2 { t.Fatalf("want 2 sell quotes from memo, got %d: %+v", len(qs), qs) }
+
+    // a different batch misses
+    if _, err := p.Fetch(t.Context(), []string{"A", "C"}); err != nil { t.Fatalf("fetch 3: %v", err) }
+    if n := calls.Load(); n != 2 { t.Fatalf("want 2 upstream calls after changed batch, got %d", n) }
+}
+
+func TestFetch_BatchMemo_ExpiresAfterTTL(t *testing.T) {
+    var calls atomic.Int32
+    srv := newTestServer(t, &calls)
+    p := New(Config{URL: srv.URL, BatchMemoTTL: 10 * time.Millisecond}, httpx.New(5*time.Second))
+
+    if _, err := p.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch 1: %v", err) }
+    time.Sleep(20 * time.Millisecond)
+    if _, err := p.Fetch(t.Context(), []string{"A"}); err != nil { t.Fatalf("fetch 2: %v", err) }
+    if n := calls.Load(); n != 2 { t.Fatalf("want 2 upstream calls after TTL, got %d", n) }
+}
+
+func TestFetch_NoBatchStartsAfterDeadline(t *testing.T) {
+    var mu sync.Mutex
+    var arrivals []time.Time
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        mu.Lock()
+        arrivals = append(arrivals, time.Now())
+        mu.Unlock()
+        select {
+        case <-time.After(30 * time.Millisecond):
+        case <-r.Context().Done():
+        }
+        fmt.Fprint(w, `{"success":true,"data":[]}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, MaxItemsPerRequest: 1, MaxConcurrency: 1, MinBatchTime: 20 * time.Millisecond}, httpx.New(5*time.Second))
+    symbols := make([]string, 20)
+    for i := range symbols { symbols[i] = fmt.Sprintf("S%d", i) }
+
+    ctx, cancel := context.WithTimeout(t.Context(), 80*time.Millisecond)
+    defer cancel()
+    deadline, _ := ctx.Deadline()
+    _, err := p.Fetch(ctx, symbols)
+    if !errors.Is(err, context.DeadlineExceeded) { t.Fatalf("want deadline error, got %v", err) }
+
+    // give any stray goroutine a chance to fire before checking
+    time.Sleep(50 * time.Millisecond)
+    mu.Lock()
+    defer mu.Unlock()
+    if len(arrivals) == 0 || len(arrivals) >= len(symbols) { t.Fatalf("want some but not all batches, got %d", len(arrivals)) }
+    for i, at := range arrivals {
+        if !at.Before(deadline) { t.Fatalf("batch %d started %s after the deadline", i, at.Sub(deadline)) }
+    }
+}
+
+func TestFetch_MaxConcurrencySharedAcrossCallers(t *testing.T) {
+    var inFlight, peak atomic.Int32
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        n := inFlight.Add(1)
+        for {
+            p := peak.Load()
+            if n <= p || peak.CompareAndSwap(p, n) { break }
+        }
+        time.Sleep(10 * time.Millisecond)
+        inFlight.Add(-1)
+        fmt.Fprint(w, `{"success":true,"data":[]}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, MaxItemsPerRequest: 1, MaxConcurrency: 2}, httpx.New(5*time.Second))
+    var wg sync.WaitGroup
+    for i := 0; i < 8; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            if _, err := p.Fetch(t.Context(), []string{fmt.Sprintf("A%d", i), fmt.Sprintf("B%d", i), fmt.Sprintf("C%d", i)}); err != nil { t.Errorf("fetch: %v", err) }
+        }(i)
+    }
+    wg.Wait()
+    if got := peak.Load(); got > 2 { t.Fatalf("want at most 2 concurrent upstream requests, saw %d", got) }
+}
+
+func TestFetch_HTMLPageWith200IsRetryable(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Set("Content-Type", "text/html; charset=utf-8")
+        fmt.Fprint(w, "<html><body>502 Bad Gateway</body></html>")
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL}, httpx.New(5*time.Second))
+    _, err := p.Fetch(t.Context(), []string{"A"})
+    if err == nil || !provider.IsRetryable(err) || !errors.Is(err, provider.ErrUpstream) { t.Fatalf("want retryable upstream error, got %v", err) }
+}
+
+func TestFetch_FailsOverToHealthyEndpointAndPrefersIt(t *testing.T) {
+    var badCalls, goodCalls atomic.Int32
+    bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        badCalls.Add(1)
+        http.Error(w, "unavailable", http.StatusServiceUnavailable)
+    }))
+    t.Cleanup(bad.Close)
+    good := newTestServer(t, &goodCalls)
+    p := New(Config{URLs: []string{bad.URL, good.URL}}, httpx.New(5*time.Second))
+
+    qs, err := p.Fetch(t.Context(), []string{"A"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 1 { t.Fatalf("want the quote from the healthy endpoint, got %+v", qs) }
+    if badCalls.Load() != 1 || goodCalls.Load() != 1 { t.Fatalf("want one call each, got bad=%d good=%d", badCalls.Load(), goodCalls.Load()) }
+
+    if _, err := p.Fetch(t.Context(), []string{"B"}); err != nil { t.Fatalf("second fetch: %v", err) }
+    if badCalls.Load() != 1 || goodCalls.Load() != 2 { t.Fatalf("healthy endpoint not preferred: bad=%d good=%d", badCalls.Load(), goodCalls.Load()) }
+}
+
+func TestFetch_NoFailoverOnClientError(t *testing.T) {
+    var calls atomic.Int32
+    unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        http.Error(w, "bad key", http.StatusUnauthorized)
+    }))
+    t.Cleanup(unauthorized.Close)
+    other := newTestServer(t, &calls)
+    p := New(Config{URLs: []string{unauthorized.URL, other.URL}}, httpx.New(5*time.Second))
+
+    if _, err := p.Fetch(t.Context(), []string{"A"}); !errors.Is(err, provider.ErrUnauthorized) { t.Fatalf("want unauthorized, got %v", err) }
+    if calls.Load() != 0 { t.Fatalf("a 401 should not fail over, got %d calls", calls.Load()) }
+}
+
+func TestFetch_FailedBatchKeepsCompletedBatches(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        var body struct{ MarketHashNames []string `json:"marketHashNames"` }
+        if err := json.NewDecoder(r.Body).Decode(&body); err != nil { t.Errorf("decode body: %v", err) }
+        if body.MarketHashNames[0] == "B" {
+            // outlive the request deadline
+            <-r.Context().Done()
+            return
+        }
+        fmt.Fprint(w, mustJSON(t, map[string]any{"success": true, "data": []map[string]any{{
+            "marketHashName": body.MarketHashNames[0],
+            "dataList":       []map[string]any{{"platform": "BUFF", "sellPrice": 10, "updateTime": 1735787045}},
+        }}}))
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, MaxItemsPerRequest: 1, MaxConcurrency: 2}, httpx.New(5*time.Second))
+    ctx, cancel := context.WithTimeout(t.Context(), 200*time.Millisecond)
+    defer cancel()
+    qs, err := p.Fetch(ctx, []string{"A", "B"})
+    var pe *provider.PartialError
+    if !errors.As(err, &pe) || !reflect.DeepEqual(pe.Symbols, []string{"B"}) || !errors.Is(err, context.DeadlineExceeded) { t.Fatalf("want a partial error naming B, got %v", err) }
+    if len(qs) != 1 || qs[0].Symbol != "A" || qs[0].Price != "10" { t.Fatalf("want A from the completed batch, got %+v", qs) }
+
+    // with nothing collected the error is returned as before
+    ctx, cancel = context.WithTimeout(t.Context(), 100*time.Millisecond)
+    defer cancel()
+    if _, err := p.Fetch(ctx, []string{"B"}); !errors.Is(err, context.DeadlineExceeded) { t.Fatalf("want deadline error, got %v", err) }
+}
+
+// partialSuccess is a batch answer that rejects one of the requested names
+// but still carries data for the other.
+const partialSuccess = `{
+  "success": false,
+  "errorCode": 4001,
+  "errorMsg": "unknown marketHashName: B",
+  "data": [
+    {"marketHashName": "A", "dataList": [{"platform": "BUFF", "sellPrice": 10, "updateTime": 1735787045}]}
+  ]
+}`
+
+func TestFetch_PartialSuccessStrictness(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, partialSuccess) }))
+    defer srv.Close()
+
+    t.Run("lenient", func(t *testing.T) {
+        p := New(Config{URL: srv.URL}, httpx.New(5*time.Second))
+        ctx, rec := timing.WithRecorder(t.Context())
+        qs, err := p.Fetch(ctx, []string{"A", "B"})
+        if err != nil { t.Fatalf("want partial data used, got %v", err) }
+        if len(qs) != 1 || qs[0].Symbol != "A" { t.Fatalf("want A only, got %+v", qs) }
+        if w := rec.Warnings()["SteamDT"]; !strings.Contains(w, "code=4001") { t.Fatalf("want errorCode reported as metadata, got %v", rec.Warnings()) }
+    })
+    t.Run("strict", func(t *testing.T) {
+        p := New(Config{URL: srv.URL, StrictErrors: true}, httpx.New(5*time.Second))
+        _, err := p.Fetch(t.Context(), []string{"A", "B"})
+        if !errors.Is(err, provider.ErrUpstream) || !strings.Contains(err.Error(), "code=4001") { t.Fatalf("want upstream error with the errorCode, got %v", err) }
+    })
+}
+
+func TestFetch_IncludePlatformItemID(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{"success": true, "data": [{"marketHashName": "A", "dataList": [
+            {"platform": "BUFF", "platformItemId": "33345", "sellPrice": 10, "biddingPrice": 9, "updateTime": 1735787045}]}]}`)
+    }))
+    defer srv.Close()
+
+    for _, include := range []bool{false, true} {
+        p := New(Config{URL: srv.URL, IncludeBids: true, IncludePlatformItemID: include}, httpx.New(5*time.Second))
+        qs, err := p.Fetch(t.Context(), []string{"A"})
+        if err != nil { t.Fatalf("fetch: %v", err) }
+        if len(qs) != 2 { t.Fatalf("want sell+bid quotes, got %+v", qs) }
+        want := ""
+        if include { want = "33345" }
+        for _, q := range qs {
+            if q.ExternalID != want { t.Fatalf("include=%v: external_id=%q, want %q: %+v", include, q.ExternalID, want, q) }
+        }
+    }
+}
+
+func TestFetch_ScientificNotationPricesArePlain(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{"success": true, "data": [{"marketHashName": "A", "dataList": [
+            {"platform": "BUFF", "sellPrice": 1.5e2, "biddingPrice": "1.25E+2", "updateTime": 1735787045}]}]}`)
+    }))
+    defer srv.Close()
+
+    p := New(Config{URL: srv.URL, IncludeBids: true}, httpx.New(5*time.Second))
+    qs, err := p.Fetch(t.Context(), []string{"A"})
+    if err != nil { t.Fatalf("fetch: %v", err) }
+    if len(qs) != 2 || qs[0].Price != "150" || qs[1].Price != "125" { t.Fatalf("want plain sell 150 and bid 125, got %+v", qs) }
+}
@@ -0,0 +1,109 @@
+package timing
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// Recorder collects per-provider Fetch durations for one request, and the
+// failures providers absorbed while still returning quotes.
+type Recorder struct {
+    mu       sync.Mutex
+    d        map[string]time.Duration
+    warnings map[string]string
+    stale    map[string]bool
+}
+
+type recorderKey struct{}
+
+// WithRecorder attaches a fresh Recorder to ctx.
+func WithRecorder(ctx context.Context) (context.Context, *Recorder) {
+    r := &Recorder{d: make(map[string]time.Duration)}
+    return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// FromContext returns the Recorder attached to ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+    r, _ := ctx.Value(recorderKey{}).(*Recorder)
+    return r
+}
+
+// Record stores d for name; repeated calls for the same name keep the longest.
+func (r *Recorder) Record(name string, d time.Duration) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if d > r.d[name] { r.d[name] = d }
+}
+
+// Milliseconds returns the recorded durations in whole milliseconds, or nil when empty.
+func (r *Recorder) Milliseconds() map[string]int64 {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if len(r.d) == 0 { return nil }
+    out := make(map[string]int64, len(r.d))
+    for k, v := range r.d { out[k] = v.Milliseconds() }
+    return out
+}
+
+// Warn notes a failure that name recovered from, such as one failed batch out
+// of several. The first message per name is kept.
+func (r *Recorder) Warn(name string, err error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.warnings == nil { r.warnings = make(map[string]string) }
+    if _, ok := r.warnings[name]; !ok { r.warnings[name] = err.Error() }
+}
+
+// Warnings returns the noted failures by provider name, or nil when empty.
+func (r *Recorder) Warnings() map[string]string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if len(r.warnings) == 0 { return nil }
+    out := make(map[string]string, len(r.warnings))
+    for k, v := range r.warnings { out[k] = v }
+    return out
+}
+
+// MarkStale notes that name served cached data past its TTL because its
+// upstream failed.
+func (r *Recorder) MarkStale(name string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if r.stale == nil { r.stale = make(map[string]bool) }
+    r.stale[name] = true
+}
+
+// Stale returns the names marked by MarkStale, sorted, or nil when none.
+func (r *Recorder) Stale() []string {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if len(r.stale) == 0 { return nil }
+    out := make([]string, 0, len(r.stale))
+    for k := range r.stale { out = append(out, k) }
+    sort.Strings(out)
+    return out
+}
+
+// Provider measures how long Fetch takes and records it on the request's
+// Recorder (if any), keyed by provider name. Errors are timed as well, and
+// partial errors noted as warnings.
+type Provider struct {
+    P provider.Provider
+}
+
+func (t *Provider) Name() string { return t.P.Name() }
+func (t *Provider) Unwrap() provider.Provider { return t.P }
+
+func (t *Provider) Fetch(ctx context.Context, symbols []string) ([]provider.Quote, error) {
+    r := FromContext(ctx)
+    if r == nil { return t.P.Fetch(ctx, symbols) }
+    start := time.Now()
+    qs, err := t.P.Fetch(ctx, symbols)
+    r.Record(t.P.Name(), time.Since(start))
+    if provider.IsPartial(err) { r.Warn(t.P.Name(), err) }
+    return qs, err
+}
@@ -0,0 +1,29 @@
+package timing
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+type sleepyProvider time.Duration
+
+func (s sleepyProvider) Name() string { return "sleepy" }
+func (s sleepyProvider) Fetch(ctx context.Context, _ []string) ([]provider.Quote, error) {
+    time.Sleep(time.Duration(s))
+    return []provider.Quote{{Symbol: "A", Price: "1"}}, nil
+}
+
+func TestTiming_RecordsFetchDuration(t *testing.T) {
+    p := &Provider{P: sleepyProvider(30 * time.Millisecond)}
+
+    // no recorder: plain pass-through
+    if qs, err := p.Fetch(t.Context(), []string{"A"}); err != nil || len(qs) != 1 { t.Fatalf("fetch: %v %v", qs, err) }
+
+    ctx, rec := WithRecorder(t.Context())
+    if _, err := p.Fetch(ctx, []string{"A"}); err != nil { t.Fatalf("fetch: %v", err) }
+    ms := rec.Milliseconds()
+    if got := ms["sleepy"]; got < 30 || got > 1000 { t.Fatalf("implausible duration %dms (%v)", got, ms) }
+}
@@ -0,0 +1,78 @@
+package publish
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+
+    "priceprovider/internal/provider"
+)
+
+// KafkaConfig selects the cluster and topic quotes are written to.
+type KafkaConfig struct {
+    // Brokers are bootstrap "host:port" addresses; partition leaders are
+    // discovered from the cluster metadata.
+    Brokers  []string
+    Topic    string
+    ClientID string        // default "priceprovider"
+    // Timeout bounds dialing and each write and read (default 5s).
+    Timeout  time.Duration
+}
+
+// messageWriter is the part of *kafka.Writer that Kafka uses.
+type messageWriter interface {
+    WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+    Close() error
+}
+
+// Kafka publishes each quote as a JSON message keyed by symbol, with acks=1.
+// Partitions are picked with the murmur2 hash of the Java client's default
+// partitioner, so a symbol lands where other producers would put it.
+type Kafka struct {
+    w messageWriter
+}
+
+// batchTimeout is how long the writer waits to fill a batch. Publish already
+// hands over a whole batch, so there is little to gain from waiting.
+const batchTimeout = 10 * time.Millisecond
+
+func NewKafka(cfg KafkaConfig) (*Kafka, error) {
+    if len(cfg.Brokers) == 0 { return nil, errors.New("kafka: no brokers") }
+    if cfg.Topic == "" { return nil, errors.New("kafka: no topic") }
+    if cfg.ClientID == "" { cfg.ClientID = "priceprovider" }
+    if cfg.Timeout <= 0 { cfg.Timeout = 5 * time.Second }
+    w := &kafka.Writer{
+        Addr:                   kafka.TCP(cfg.Brokers...),
+        Topic:                  cfg.Topic,
+        Balancer:               &kafka.Murmur2Balancer{},
+        RequiredAcks:           kafka.RequireOne,
+        BatchTimeout:           batchTimeout,
+        WriteTimeout:           cfg.Timeout,
+        ReadTimeout:            cfg.Timeout,
+        AllowAutoTopicCreation: true,
+        Transport:              &kafka.Transport{ClientID: cfg.ClientID, DialTimeout: cfg.Timeout},
+    }
+    return &Kafka{w: w}, nil
+}
+
+// Publish writes quotes and waits for the partition leaders to ack them.
+func (k *Kafka) Publish(ctx context.Context, quotes []provider.Quote) error {
+    if len(quotes) == 0 { return nil }
+    msgs := make([]kafka.Message, len(quotes))
+    for i, q := range quotes {
+        v, err := json.Marshal(q)
+        if err != nil { return fmt.Errorf("kafka: encode %q: %w", q.Symbol, err) }
+        msgs[i] = kafka.Message{Key: []byte(q.Symbol), Value: v}
+    }
+    if err := k.w.WriteMessages(ctx, msgs...); err != nil { return fmt.Errorf("kafka: %w", err) }
+    return nil
+}
+
+// Close flushes pending writes and closes the broker connections.
+func (k *Kafka) Close() error {
+    return k.w.Close()
+}
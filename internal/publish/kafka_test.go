@@ -0,0 +1,68 @@
+package publish
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "testing"
+    "time"
+
+    "github.com/segmentio/kafka-go"
+
+    "priceprovider/internal/provider"
+)
+
+// fakeWriter records the messages of each WriteMessages call.
+type fakeWriter struct {
+    batches [][]kafka.Message
+    err     error
+    closed  bool
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+    w.batches = append(w.batches, msgs)
+    return w.err
+}
+
+func (w *fakeWriter) Close() error {
+    w.closed = true
+    return nil
+}
+
+func TestNewKafka_ConfiguresWriter(t *testing.T) {
+    if _, err := NewKafka(KafkaConfig{Topic: "quotes"}); err == nil { t.Fatalf("want error without brokers") }
+    if _, err := NewKafka(KafkaConfig{Brokers: []string{"127.0.0.1:9092"}}); err == nil { t.Fatalf("want error without topic") }
+
+    k, err := NewKafka(KafkaConfig{Brokers: []string{"127.0.0.1:9092"}, Topic: "quotes"})
+    if err != nil { t.Fatalf("new: %v", err) }
+    w := k.w.(*kafka.Writer)
+    if w.Topic != "quotes" || w.RequiredAcks != kafka.RequireOne || w.WriteTimeout != 5*time.Second { t.Fatalf("unexpected writer %+v", w) }
+    if _, ok := w.Balancer.(*kafka.Murmur2Balancer); !ok { t.Fatalf("want murmur2 balancer, got %T", w.Balancer) }
+    if tr := w.Transport.(*kafka.Transport); tr.ClientID != "priceprovider" { t.Fatalf("client id %q", tr.ClientID) }
+    if err := k.Close(); err != nil { t.Fatalf("close: %v", err) }
+}
+
+func TestKafka_PublishesQuotesKeyedBySymbol(t *testing.T) {
+    fw := &fakeWriter{}
+    k := &Kafka{w: fw}
+
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+    quotes := []provider.Quote{
+        {Symbol: "AK-47 | Redline (Field-Tested)", Price: "10", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+        {Symbol: "AK-47 | Redline (Field-Tested)", Price: "11", Currency: "USD", Source: "Pricempire:steam", ReceivedAt: ts},
+        {Symbol: "AWP | Asiimov (Field-Tested)", Price: "90", Currency: "USD", Source: "SteamDT:BUFF:sell", ReceivedAt: ts},
+    }
+    if err := k.Publish(t.Context(), quotes); err != nil { t.Fatalf("publish: %v", err) }
+    if err := k.Publish(t.Context(), nil); err != nil { t.Fatalf("empty publish: %v", err) }
+    if len(fw.batches) != 1 || len(fw.batches[0]) != len(quotes) { t.Fatalf("want one batch of %d, got %v", len(quotes), fw.batches) }
+    for i, m := range fw.batches[0] {
+        if string(m.Key) != quotes[i].Symbol { t.Errorf("key %q, want %q", m.Key, quotes[i].Symbol) }
+        var q provider.Quote
+        if err := json.Unmarshal(m.Value, &q); err != nil || q.Symbol != quotes[i].Symbol || q.Price != quotes[i].Price || !q.ReceivedAt.Equal(ts) { t.Errorf("bad message %s: %v", m.Value, err) }
+    }
+
+    fw.err = errors.New("leader not available")
+    if err := k.Publish(t.Context(), quotes[:1]); err == nil || !errors.Is(err, fw.err) { t.Fatalf("want wrapped write error, got %v", err) }
+    _ = k.Close()
+    if !fw.closed { t.Fatalf("writer not closed") }
+}
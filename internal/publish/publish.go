@@ -0,0 +1,89 @@
+// Package publish forwards served quotes to external systems (see Kafka).
+package publish
+
+import (
+    "context"
+    "errors"
+    "log"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "priceprovider/internal/provider"
+)
+
+// Sink receives batches of quotes. Publish may block until the batch is
+// delivered or ctx ends.
+type Sink interface {
+    Publish(ctx context.Context, quotes []provider.Quote) error
+    Close() error
+}
+
+// ErrQueueFull is returned by Async.Publish when a batch was dropped.
+var ErrQueueFull = errors.New("publish: queue full, batch dropped")
+
+// Async keeps a slow Sink off the request path: Publish only enqueues, and a
+// single goroutine delivers batches in order, each bounded by Timeout. Batches
+// are dropped while the queue is full; delivery errors are logged.
+type Async struct {
+    sink    Sink
+    timeout time.Duration
+    queue   chan []provider.Quote
+    done    chan struct{}
+    dropped atomic.Int64
+
+    mu     sync.RWMutex // guards closed against Publish racing Close
+    closed bool
+}
+
+// NewAsync starts the delivery goroutine. size <= 0 defaults to 64 batches and
+// timeout <= 0 to 5s.
+func NewAsync(sink Sink, size int, timeout time.Duration) *Async {
+    if size <= 0 { size = 64 }
+    if timeout <= 0 { timeout = 5 * time.Second }
+    a := &Async{sink: sink, timeout: timeout, queue: make(chan []provider.Quote, size), done: make(chan struct{})}
+    go a.run()
+    return a
+}
+
+func (a *Async) run() {
+    defer close(a.done)
+    for qs := range a.queue {
+        ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+        if err := a.sink.Publish(ctx, qs); err != nil { log.Printf("publish: %d quotes: %v", len(qs), err) }
+        cancel()
+    }
+}
+
+// Publish enqueues quotes without blocking. ctx is ignored: delivery outlives
+// the request that produced the quotes.
+func (a *Async) Publish(_ context.Context, quotes []provider.Quote) error {
+    if len(quotes) == 0 { return nil }
+    a.mu.RLock()
+    defer a.mu.RUnlock()
+    if a.closed { return errors.New("publish: closed") }
+    select {
+    case a.queue <- quotes:
+        return nil
+    default:
+        a.dropped.Add(1)
+        return ErrQueueFull
+    }
+}
+
+// Dropped reports how many batches were discarded because the queue was full.
+func (a *Async) Dropped() int64 { return a.dropped.Load() }
+
+// Close stops accepting batches, delivers the queued ones and closes the sink.
+func (a *Async) Close() error {
+    a.mu.Lock()
+    if a.closed {
+        a.mu.Unlock()
+        return nil
+    }
+    a.closed = true
+    close(a.queue)
+    a.mu.Unlock()
+    <-a.done
+    return a.sink.Close()
+}
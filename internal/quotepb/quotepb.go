@@ -0,0 +1,76 @@
+// Package quotepb holds the protobuf messages of quotes.proto, generated by
+// protoc-gen-go, and converts quotes to and from them.
+package quotepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative quotes.proto
+
+import (
+    "time"
+
+    "google.golang.org/protobuf/proto"
+    "google.golang.org/protobuf/types/known/timestamppb"
+
+    "priceprovider/internal/provider"
+)
+
+// ContentType is the media type negotiated via the Accept header.
+const ContentType = "application/x-protobuf"
+
+// Marshal encodes m deterministically (map entries in key order), so equal
+// responses encode to equal bytes.
+func Marshal(m proto.Message) ([]byte, error) {
+    return proto.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// FromQuotes converts quotes to their messages.
+func FromQuotes(qs []provider.Quote) []*Quote {
+    out := make([]*Quote, len(qs))
+    for i, q := range qs {
+        out[i] = &Quote{
+            Symbol:     q.Symbol,
+            Price:      q.Price,
+            Currency:   q.Currency,
+            Source:     q.Source,
+            Provider:   q.Provider,
+            ReceivedAt: Timestamp(q.ReceivedAt),
+            AppId:      int32(q.AppID),
+            Volume:     int32(q.Volume),
+            ExternalId: q.ExternalID,
+            Inflated:   q.Inflated,
+        }
+    }
+    return out
+}
+
+// ToQuotes converts messages back to quotes. Amount is left unset, as for
+// quotes decoded from JSON.
+func ToQuotes(pbs []*Quote) []provider.Quote {
+    out := make([]provider.Quote, len(pbs))
+    for i, pb := range pbs {
+        out[i] = provider.Quote{
+            Symbol:     pb.GetSymbol(),
+            Price:      pb.GetPrice(),
+            Currency:   pb.GetCurrency(),
+            Source:     pb.GetSource(),
+            Provider:   pb.GetProvider(),
+            ReceivedAt: Time(pb.GetReceivedAt()),
+            AppID:      int(pb.GetAppId()),
+            Volume:     int(pb.GetVolume()),
+            ExternalID: pb.GetExternalId(),
+            Inflated:   pb.Inflated,
+        }
+    }
+    return out
+}
+
+// Timestamp converts t, leaving the field unset for the zero time.
+func Timestamp(t time.Time) *timestamppb.Timestamp {
+    if t.IsZero() { return nil }
+    return timestamppb.New(t)
+}
+
+// Time converts ts back, in UTC; nil gives the zero time.
+func Time(ts *timestamppb.Timestamp) time.Time {
+    if ts == nil { return time.Time{} }
+    return ts.AsTime()
+}
@@ -0,0 +1,49 @@
+package quotepb
+
+import (
+    "encoding/hex"
+    "reflect"
+    "testing"
+    "time"
+
+    "google.golang.org/protobuf/proto"
+
+    "priceprovider/internal/provider"
+)
+
+func TestQuotesResponse_RoundTrip(t *testing.T) {
+    ts := time.Date(2025, 1, 2, 3, 4, 5, 6000, time.UTC)
+    quotes := []provider.Quote{
+        {Symbol: "AK-47 | Redline (Field-Tested)", Price: "12.34", Currency: "USD", Source: "SteamDT:BUFF:sell", Provider: "SteamDT", ReceivedAt: ts, AppID: 730, Volume: 42, ExternalID: "33345"},
+        {Symbol: "Glove Case", Price: "0.5", Currency: "USD", Source: "Pricempire:buff", Inflated: new(bool)},
+    }
+    in := &QuotesResponse{
+        Quotes:            FromQuotes(quotes),
+        Missing:           []string{"Nope"},
+        ProviderTimingsMs: map[string]int64{"SteamDT": 12, "Pricempire": 0},
+        PartialErrors:     map[string]string{"SteamDT": "no quotes for B: context deadline exceeded"},
+        Stale:             []string{"Pricempire"},
+        OldestReceivedAt:  Timestamp(ts),
+        NewestReceivedAt:  Timestamp(ts),
+    }
+    b, err := Marshal(in)
+    if err != nil { t.Fatalf("marshal: %v", err) }
+    var out QuotesResponse
+    if err := proto.Unmarshal(b, &out); err != nil { t.Fatalf("unmarshal: %v", err) }
+    if !proto.Equal(in, &out) { t.Fatalf("round trip mismatch:\n in=%v\nout=%v", in, &out) }
+    if got := out.GetPartialErrors()["SteamDT"]; got != "no quotes for B: context deadline exceeded" { t.Fatalf("partial error lost: %q", got) }
+    if got := out.GetStale(); len(got) != 1 || got[0] != "Pricempire" { t.Fatalf("stale lost: %v", got) }
+    if got := ToQuotes(out.GetQuotes()); !reflect.DeepEqual(got, quotes) { t.Fatalf("quotes differ:\n in=%+v\nout=%+v", quotes, got) }
+    if !Time(out.GetOldestReceivedAt()).Equal(ts) || !Time(nil).IsZero() { t.Fatalf("unexpected watermark %v", out.GetOldestReceivedAt()) }
+}
+
+func TestMarshal_MatchesWireFormat(t *testing.T) {
+    // Quote{symbol:"A", app_id:730} inside QuotesResponse.quotes.
+    b, err := Marshal(&QuotesResponse{Quotes: FromQuotes([]provider.Quote{{Symbol: "A", AppID: 730}})})
+    if err != nil { t.Fatalf("marshal: %v", err) }
+    if got, want := hex.EncodeToString(b), "0a060a014138da05"; got != want { t.Fatalf("want %s, got %s", want, got) }
+}
+
+func TestUnmarshal_Truncated(t *testing.T) {
+    if err := proto.Unmarshal([]byte{0x0a, 0x05, 0x0a}, &QuotesResponse{}); err == nil { t.Fatalf("want error for truncated input") }
+}
@@ -0,0 +1,335 @@
+// Protobuf shape of the /api/quotes response, served for
+// Accept: application/x-protobuf. quotes.pb.go is generated from this file
+// (see the go:generate line in quotepb.go); regenerate it when fields change.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: quotes.proto
+
+package quotepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Quote struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Symbol        string                 `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Price         string                 `protobuf:"bytes,2,opt,name=price,proto3" json:"price,omitempty"` // decimal string, as in JSON
+	Currency      string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	Source        string                 `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	Provider      string                 `protobuf:"bytes,5,opt,name=provider,proto3" json:"provider,omitempty"`
+	ReceivedAt    *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=received_at,json=receivedAt,proto3" json:"received_at,omitempty"`
+	AppId         int32                  `protobuf:"varint,7,opt,name=app_id,json=appId,proto3" json:"app_id,omitempty"`
+	Volume        int32                  `protobuf:"varint,8,opt,name=volume,proto3" json:"volume,omitempty"`
+	ExternalId    string                 `protobuf:"bytes,9,opt,name=external_id,json=externalId,proto3" json:"external_id,omitempty"` // marketplace listing id, when known
+	Inflated      *bool                  `protobuf:"varint,10,opt,name=inflated,proto3,oneof" json:"inflated,omitempty"`               // unset when the provider has no such flag
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Quote) Reset() {
+	*x = Quote{}
+	mi := &file_quotes_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Quote) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Quote) ProtoMessage() {}
+
+func (x *Quote) ProtoReflect() protoreflect.Message {
+	mi := &file_quotes_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Quote.ProtoReflect.Descriptor instead.
+func (*Quote) Descriptor() ([]byte, []int) {
+	return file_quotes_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Quote) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Quote) GetPrice() string {
+	if x != nil {
+		return x.Price
+	}
+	return ""
+}
+
+func (x *Quote) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Quote) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Quote) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Quote) GetReceivedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReceivedAt
+	}
+	return nil
+}
+
+func (x *Quote) GetAppId() int32 {
+	if x != nil {
+		return x.AppId
+	}
+	return 0
+}
+
+func (x *Quote) GetVolume() int32 {
+	if x != nil {
+		return x.Volume
+	}
+	return 0
+}
+
+func (x *Quote) GetExternalId() string {
+	if x != nil {
+		return x.ExternalId
+	}
+	return ""
+}
+
+func (x *Quote) GetInflated() bool {
+	if x != nil && x.Inflated != nil {
+		return *x.Inflated
+	}
+	return false
+}
+
+type QuotesResponse struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Quotes []*Quote               `protobuf:"bytes,1,rep,name=quotes,proto3" json:"quotes,omitempty"`
+	// Requested symbols without quotes; only with ?report_missing=true.
+	Missing           []string         `protobuf:"bytes,2,rep,name=missing,proto3" json:"missing,omitempty"`
+	ProviderTimingsMs map[string]int64 `protobuf:"bytes,3,rep,name=provider_timings_ms,json=providerTimingsMs,proto3" json:"provider_timings_ms,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// Bounds of received_at across quotes; unset when no quote has one.
+	OldestReceivedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=oldest_received_at,json=oldestReceivedAt,proto3" json:"oldest_received_at,omitempty"`
+	NewestReceivedAt *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=newest_received_at,json=newestReceivedAt,proto3" json:"newest_received_at,omitempty"`
+	// Failures a provider recovered from while still returning quotes, by
+	// provider name (e.g. the symbols of a failed SteamDT batch).
+	PartialErrors map[string]string `protobuf:"bytes,6,rep,name=partial_errors,json=partialErrors,proto3" json:"partial_errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Providers that served cache entries past their TTL because the
+	// upstream failed.
+	Stale         []string `protobuf:"bytes,7,rep,name=stale,proto3" json:"stale,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QuotesResponse) Reset() {
+	*x = QuotesResponse{}
+	mi := &file_quotes_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QuotesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QuotesResponse) ProtoMessage() {}
+
+func (x *QuotesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quotes_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QuotesResponse.ProtoReflect.Descriptor instead.
+func (*QuotesResponse) Descriptor() ([]byte, []int) {
+	return file_quotes_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *QuotesResponse) GetQuotes() []*Quote {
+	if x != nil {
+		return x.Quotes
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetMissing() []string {
+	if x != nil {
+		return x.Missing
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetProviderTimingsMs() map[string]int64 {
+	if x != nil {
+		return x.ProviderTimingsMs
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetOldestReceivedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OldestReceivedAt
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetNewestReceivedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NewestReceivedAt
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetPartialErrors() map[string]string {
+	if x != nil {
+		return x.PartialErrors
+	}
+	return nil
+}
+
+func (x *QuotesResponse) GetStale() []string {
+	if x != nil {
+		return x.Stale
+	}
+	return nil
+}
+
+var File_quotes_proto protoreflect.FileDescriptor
+
+const file_quotes_proto_rawDesc = "" +
+	"\n" +
+	"\fquotes.proto\x12\x10priceprovider.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc0\x02\n" +
+	"\x05Quote\x12\x16\n" +
+	"\x06symbol\x18\x01 \x01(\tR\x06symbol\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\tR\x05price\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\x12\x1a\n" +
+	"\bprovider\x18\x05 \x01(\tR\bprovider\x12;\n" +
+	"\vreceived_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"receivedAt\x12\x15\n" +
+	"\x06app_id\x18\a \x01(\x05R\x05appId\x12\x16\n" +
+	"\x06volume\x18\b \x01(\x05R\x06volume\x12\x1f\n" +
+	"\vexternal_id\x18\t \x01(\tR\n" +
+	"externalId\x12\x1f\n" +
+	"\binflated\x18\n" +
+	" \x01(\bH\x00R\binflated\x88\x01\x01B\v\n" +
+	"\t_inflated\"\xd2\x04\n" +
+	"\x0eQuotesResponse\x12/\n" +
+	"\x06quotes\x18\x01 \x03(\v2\x17.priceprovider.v1.QuoteR\x06quotes\x12\x18\n" +
+	"\amissing\x18\x02 \x03(\tR\amissing\x12g\n" +
+	"\x13provider_timings_ms\x18\x03 \x03(\v27.priceprovider.v1.QuotesResponse.ProviderTimingsMsEntryR\x11providerTimingsMs\x12H\n" +
+	"\x12oldest_received_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x10oldestReceivedAt\x12H\n" +
+	"\x12newest_received_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\x10newestReceivedAt\x12Z\n" +
+	"\x0epartial_errors\x18\x06 \x03(\v23.priceprovider.v1.QuotesResponse.PartialErrorsEntryR\rpartialErrors\x12\x14\n" +
+	"\x05stale\x18\a \x03(\tR\x05stale\x1aD\n" +
+	"\x16ProviderTimingsMsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value:\x028\x01\x1a@\n" +
+	"\x12PartialErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B Z\x1epriceprovider/internal/quotepbb\x06proto3"
+
+var (
+	file_quotes_proto_rawDescOnce sync.Once
+	file_quotes_proto_rawDescData []byte
+)
+
+func file_quotes_proto_rawDescGZIP() []byte {
+	file_quotes_proto_rawDescOnce.Do(func() {
+		file_quotes_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_quotes_proto_rawDesc), len(file_quotes_proto_rawDesc)))
+	})
+	return file_quotes_proto_rawDescData
+}
+
+var file_quotes_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_quotes_proto_goTypes = []any{
+	(*Quote)(nil),                 // 0: priceprovider.v1.Quote
+	(*QuotesResponse)(nil),        // 1: priceprovider.v1.QuotesResponse
+	nil,                           // 2: priceprovider.v1.QuotesResponse.ProviderTimingsMsEntry
+	nil,                           // 3: priceprovider.v1.QuotesResponse.PartialErrorsEntry
+	(*timestamppb.Timestamp)(nil), // 4: google.protobuf.Timestamp
+}
+var file_quotes_proto_depIdxs = []int32{
+	4, // 0: priceprovider.v1.Quote.received_at:type_name -> google.protobuf.Timestamp
+	0, // 1: priceprovider.v1.QuotesResponse.quotes:type_name -> priceprovider.v1.Quote
+	2, // 2: priceprovider.v1.QuotesResponse.provider_timings_ms:type_name -> priceprovider.v1.QuotesResponse.ProviderTimingsMsEntry
+	4, // 3: priceprovider.v1.QuotesResponse.oldest_received_at:type_name -> google.protobuf.Timestamp
+	4, // 4: priceprovider.v1.QuotesResponse.newest_received_at:type_name -> google.protobuf.Timestamp
+	3, // 5: priceprovider.v1.QuotesResponse.partial_errors:type_name -> priceprovider.v1.QuotesResponse.PartialErrorsEntry
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_quotes_proto_init() }
+func file_quotes_proto_init() {
+	if File_quotes_proto != nil {
+		return
+	}
+	file_quotes_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_quotes_proto_rawDesc), len(file_quotes_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_quotes_proto_goTypes,
+		DependencyIndexes: file_quotes_proto_depIdxs,
+		MessageInfos:      file_quotes_proto_msgTypes,
+	}.Build()
+	File_quotes_proto = out.File
+	file_quotes_proto_goTypes = nil
+	file_quotes_proto_depIdxs = nil
+}